@@ -0,0 +1,156 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+
+	"github.com/urfave/cli/v2"
+)
+
+// AttachList lists the attachments of a secret
+func (s *Action) AttachList(c *cli.Context) error {
+	ctx := ctxutil.WithGlobalFlags(c)
+	name := c.Args().First()
+	if name == "" {
+		return ExitError(ExitNoName, nil, "Usage: %s attach list <NAME>", s.Name)
+	}
+
+	atts, err := s.Store.ListAttachments(ctx, name)
+	if err != nil {
+		return attachError(err, name)
+	}
+	if len(atts) < 1 {
+		out.Printf(ctx, "%s has no attachments", name)
+		return nil
+	}
+
+	for _, att := range atts {
+		m, err := s.Store.AttachmentManifest(ctx, name, att)
+		if err != nil {
+			out.Errorf(ctx, "%s: %s", att, err)
+			continue
+		}
+		out.Printf(ctx, "%s (%d bytes, sha256 %s)", att, m.Size, m.Sum)
+	}
+	return nil
+}
+
+// AttachAdd adds a file as an attachment to an existing secret. The file
+// name is used as the name of the attachment unless --name is given.
+// Use - to read from stdin.
+func (s *Action) AttachAdd(c *cli.Context) error {
+	ctx := ctxutil.WithGlobalFlags(c)
+	name := c.Args().Get(0)
+	file := c.Args().Get(1)
+	if name == "" || file == "" {
+		return ExitError(ExitUsage, nil, "Usage: %s attach add [--name NAME] <SECRET> <FILE>", s.Name)
+	}
+
+	att := c.String("name")
+	if att == "" {
+		if file == "-" {
+			return ExitError(ExitUsage, nil, "--name is required when reading from stdin")
+		}
+		att = filepath.Base(file)
+	}
+
+	var in io.Reader = binstdin
+	if file != "-" {
+		fh, err := os.Open(file)
+		if err != nil {
+			return ExitError(ExitIO, err, "failed to open %s: %s", file, err)
+		}
+		defer func() {
+			_ = fh.Close()
+		}()
+		in = fh
+	}
+
+	ctx = ctxutil.WithCommitMessage(ctx, fmt.Sprintf("Attached %s", att))
+	m, err := s.Store.SetAttachment(ctx, name, att, in)
+	if err != nil {
+		return attachError(err, name)
+	}
+
+	out.OKf(ctx, "Attached %s to %s (%d bytes)", att, name, m.Size)
+	return nil
+}
+
+// AttachGet writes an attachment to a file or to stdout
+func (s *Action) AttachGet(c *cli.Context) error {
+	ctx := ctxutil.WithGlobalFlags(c)
+	name := c.Args().Get(0)
+	att := c.Args().Get(1)
+	file := c.Args().Get(2)
+	if name == "" || att == "" {
+		return ExitError(ExitUsage, nil, "Usage: %s attach get <SECRET> <ATTACHMENT> [FILE]", s.Name)
+	}
+
+	if file == "" || file == "-" {
+		if _, err := s.Store.GetAttachment(ctx, name, att, stdout); err != nil {
+			return attachError(err, name)
+		}
+		return nil
+	}
+
+	if err := s.attachGetFile(ctx, name, att, file); err != nil {
+		return attachError(err, name)
+	}
+	out.OKf(ctx, "Wrote %s of %s to %s", att, name, file)
+	return nil
+}
+
+func (s *Action) attachGetFile(ctx context.Context, name, att, file string) error {
+	fh, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Store.GetAttachment(ctx, name, att, fh); err != nil {
+		_ = fh.Close()
+		_ = os.Remove(file)
+		return err
+	}
+	return fh.Close()
+}
+
+// AttachRemove removes an attachment from a secret
+func (s *Action) AttachRemove(c *cli.Context) error {
+	ctx := ctxutil.WithGlobalFlags(c)
+	name := c.Args().Get(0)
+	att := c.Args().Get(1)
+	if name == "" || att == "" {
+		return ExitError(ExitUsage, nil, "Usage: %s attach rm <SECRET> <ATTACHMENT>", s.Name)
+	}
+
+	ctx = ctxutil.WithCommitMessage(ctx, fmt.Sprintf("Removed attachment %s", att))
+	if err := s.Store.RemoveAttachment(ctx, name, att); err != nil {
+		return attachError(err, name)
+	}
+
+	out.OKf(ctx, "Removed %s from %s", att, name)
+	return nil
+}
+
+// attachError maps errors of the attachment commands to exit codes
+func attachError(err error, name string) error {
+	switch {
+	case err == store.ErrNotFound:
+		return ExitError(ExitNotFound, err, "Secret %s not found", name)
+	case errors.Is(err, store.ErrNotFound):
+		return ExitError(ExitNotFound, err, "%s", err)
+	case errors.Is(err, store.ErrDecrypt):
+		return ExitError(ExitDecrypt, err, "%s", err)
+	case errors.Is(err, store.ErrEncrypt):
+		return ExitError(ExitEncrypt, err, "%s", err)
+	default:
+		return ExitError(ExitIO, err, "%s", err)
+	}
+}
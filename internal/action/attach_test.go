@@ -0,0 +1,90 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/tests/gptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttach(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithInteractive(ctx, false)
+
+	buf := &bytes.Buffer{}
+	out.Stdout = buf
+	stdout = buf
+	defer func() {
+		out.Stdout = os.Stdout
+		stdout = os.Stdout
+	}()
+
+	act, err := newMock(ctx, u)
+	require.NoError(t, err)
+	require.NotNil(t, act)
+
+	infile := filepath.Join(u.Dir, "cert.pem")
+	require.NoError(t, os.WriteFile(infile, []byte("certificate"), 0644))
+
+	t.Run("add to a missing secret", func(t *testing.T) {
+		defer buf.Reset()
+		assert.Error(t, act.AttachAdd(gptest.CliCtx(ctx, t, "nope", infile)))
+	})
+
+	t.Run("add", func(t *testing.T) {
+		defer buf.Reset()
+		require.NoError(t, act.AttachAdd(gptest.CliCtx(ctx, t, "foo", infile)))
+		assert.Contains(t, buf.String(), "Attached cert.pem to foo")
+		require.NoError(t, act.AttachAdd(gptest.CliCtxWithFlags(ctx, t, map[string]string{"name": "copy.pem"}, "foo", infile)))
+	})
+
+	t.Run("list", func(t *testing.T) {
+		defer buf.Reset()
+		require.NoError(t, act.AttachList(gptest.CliCtx(ctx, t, "foo")))
+		assert.Contains(t, buf.String(), "cert.pem (11 bytes")
+		assert.Contains(t, buf.String(), "copy.pem (11 bytes")
+	})
+
+	t.Run("the password is kept", func(t *testing.T) {
+		sec, err := act.Store.Get(ctx, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "secret", sec.Password())
+	})
+
+	t.Run("get", func(t *testing.T) {
+		defer buf.Reset()
+		require.NoError(t, act.AttachGet(gptest.CliCtx(ctx, t, "foo", "cert.pem")))
+		assert.Equal(t, "certificate", buf.String())
+		buf.Reset()
+
+		outfile := filepath.Join(u.Dir, "out.pem")
+		require.NoError(t, act.AttachGet(gptest.CliCtx(ctx, t, "foo", "copy.pem", outfile)))
+		got, err := os.ReadFile(outfile)
+		require.NoError(t, err)
+		assert.Equal(t, "certificate", string(got))
+
+		assert.Error(t, act.AttachGet(gptest.CliCtx(ctx, t, "foo", "nope.pem", outfile)))
+		assert.NoFileExists(t, outfile)
+	})
+
+	t.Run("rm", func(t *testing.T) {
+		defer buf.Reset()
+		require.NoError(t, act.AttachRemove(gptest.CliCtx(ctx, t, "foo", "copy.pem")))
+		assert.Error(t, act.AttachRemove(gptest.CliCtx(ctx, t, "foo", "copy.pem")))
+		buf.Reset()
+		require.NoError(t, act.AttachList(gptest.CliCtx(ctx, t, "foo")))
+		assert.NotContains(t, buf.String(), "copy.pem")
+	})
+}
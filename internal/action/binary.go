@@ -5,12 +5,14 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 
 	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/internal/store/leaf"
 	"github.com/itsonlycode/gosecret/pkg/ctxutil"
 	"github.com/itsonlycode/gosecret/pkg/debug"
 	"github.com/itsonlycode/gosecret/pkg/fsutil"
@@ -41,25 +43,27 @@ func (s *Action) Cat(c *cli.Context) error {
 	// if content is piped to stdin, read and save it
 	if info.Mode()&os.ModeCharDevice == 0 {
 		debug.Log("Reading from STDIN ...")
-		content := &bytes.Buffer{}
+		ctx = ctxutil.WithCommitMessage(ctx, "Read secret from STDIN")
 
-		if written, err := io.Copy(content, binstdin); err != nil {
+		// anything larger than one chunk is streamed into a chunked secret
+		content := &bytes.Buffer{}
+		limit := int64(leaf.GetChunkSize(ctx))
+		if written, err := io.CopyN(content, binstdin, limit+1); err != nil && !errors.Is(err, io.EOF) {
 			return ExitError(ExitIO, err, "Failed to copy after %d bytes: %s", written, err)
 		}
+		if int64(content.Len()) > limit {
+			if _, err := s.Store.SetBinary(ctx, name, "STDIN", io.MultiReader(content, binstdin)); err != nil {
+				return ExitError(ExitEncrypt, err, "failed to save secret: %s", err)
+			}
+			return nil
+		}
 
-		return s.Store.Set(
-			ctxutil.WithCommitMessage(ctx, "Read secret from STDIN"),
-			name,
-			secFromBytes(name, "STDIN", content.Bytes()),
-		)
+		return s.Store.Set(ctx, name, secFromBytes(name, "STDIN", content.Bytes()))
 	}
 
-	buf, err := s.binaryGet(ctx, name)
-	if err != nil {
+	if err := s.binaryWrite(ctx, name, stdout); err != nil {
 		return ExitError(ExitDecrypt, err, "failed to read secret: %s", err)
 	}
-
-	fmt.Fprint(stdout, string(buf))
 	return nil
 }
 
@@ -143,16 +147,35 @@ func (s *Action) binaryCopyFromFileToStore(ctx context.Context, from, to string,
 	// if the source is a file the destination must no to avoid ambiguities
 	// if necessary this can be resolved by using a absolute path for the file
 	// and a relative one for the secret
+	ctx = ctxutil.WithCommitMessage(ctx, fmt.Sprintf("Copied data from %s to %s", from, to))
 
 	// copy from FS to store
-	buf, err := os.ReadFile(from)
+	fh, err := os.Open(from)
+	if err != nil {
+		return fmt.Errorf("failed to read file from %q: %w", from, err)
+	}
+	defer func() {
+		_ = fh.Close()
+	}()
+	fi, err := fh.Stat()
 	if err != nil {
 		return fmt.Errorf("failed to read file from %q: %w", from, err)
 	}
 
-	if err := s.Store.Set(
-		ctxutil.WithCommitMessage(ctx, fmt.Sprintf("Copied data from %s to %s", from, to)), to, secFromBytes(to, from, buf)); err != nil {
-		return fmt.Errorf("failed to save buffer to store: %w", err)
+	// large files are streamed into a chunked secret, smaller ones are kept
+	// in a single secret
+	if fi.Size() > int64(leaf.GetChunkSize(ctx)) {
+		if _, err := s.Store.SetBinary(ctx, to, from, fh); err != nil {
+			return fmt.Errorf("failed to save file to store: %w", err)
+		}
+	} else {
+		buf, err := io.ReadAll(fh)
+		if err != nil {
+			return fmt.Errorf("failed to read file from %q: %w", from, err)
+		}
+		if err := s.Store.Set(ctx, to, secFromBytes(to, from, buf)); err != nil {
+			return fmt.Errorf("failed to save buffer to store: %w", err)
+		}
 	}
 
 	if !deleteSource {
@@ -161,7 +184,7 @@ func (s *Action) binaryCopyFromFileToStore(ctx context.Context, from, to string,
 
 	// it's important that we return if the validation fails, because
 	// in that case we don't want to shred our (only) copy of this data!
-	if err := s.binaryValidate(ctx, buf, to); err != nil {
+	if err := s.binaryValidate(ctx, from, to); err != nil {
 		return fmt.Errorf("failed to validate written data: %w", err)
 	}
 	if err := fsutil.Shred(from, 8); err != nil {
@@ -175,11 +198,16 @@ func (s *Action) binaryCopyFromStoreToFile(ctx context.Context, from, to string,
 	// (which may already exist or not)
 
 	// copy from store to FS
-	buf, err := s.binaryGet(ctx, from)
+	fh, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
+		return fmt.Errorf("failed to write data to %q: %w", to, err)
+	}
+	if err := s.binaryWrite(ctx, from, fh); err != nil {
+		_ = fh.Close()
+		_ = os.Remove(to)
 		return fmt.Errorf("failed to read data from %q: %w", from, err)
 	}
-	if err := os.WriteFile(to, buf, 0600); err != nil {
+	if err := fh.Close(); err != nil {
 		return fmt.Errorf("failed to write data to %q: %w", to, err)
 	}
 
@@ -189,7 +217,7 @@ func (s *Action) binaryCopyFromStoreToFile(ctx context.Context, from, to string,
 
 	// as before: if validation of the written data fails, we MUST NOT
 	// delete the (only) source
-	if err := s.binaryValidate(ctx, buf, from); err != nil {
+	if err := s.binaryValidate(ctx, to, from); err != nil {
 		return fmt.Errorf("failed to validate the written data: %w", err)
 	}
 	if err := s.Store.Delete(ctx, from); err != nil {
@@ -198,23 +226,28 @@ func (s *Action) binaryCopyFromStoreToFile(ctx context.Context, from, to string,
 	return nil
 }
 
-func (s *Action) binaryValidate(ctx context.Context, buf []byte, name string) error {
+// binaryValidate compares the checksums of the file and the secret
+func (s *Action) binaryValidate(ctx context.Context, file, name string) error {
 	h := sha256.New()
-	_, _ = h.Write(buf)
+	fh, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %q: %w", file, err)
+	}
+	defer func() {
+		_ = fh.Close()
+	}()
+	if _, err := io.Copy(h, fh); err != nil {
+		return fmt.Errorf("failed to read %q: %w", file, err)
+	}
 	fileSum := fmt.Sprintf("%x", h.Sum(nil))
-	h.Reset()
+	debug.Log("in: %s", fileSum)
 
-	debug.Log("in: %s - %q", fileSum, string(buf))
-
-	var err error
-	buf, err = s.binaryGet(ctx, name)
+	// the file may be deleted or shredded next, so check the actual content
+	storeSum, err := s.binarySum(ctx, name, true)
 	if err != nil {
 		return fmt.Errorf("failed to read %q from the store: %w", name, err)
 	}
-	_, _ = h.Write(buf)
-	storeSum := fmt.Sprintf("%x", h.Sum(nil))
-
-	debug.Log("store: %s - %q", storeSum, string(buf))
+	debug.Log("store: %s", storeSum)
 
 	if fileSum != storeSum {
 		return fmt.Errorf("hashsum mismatch (file: %s, store: %s)", fileSum, storeSum)
@@ -223,21 +256,61 @@ func (s *Action) binaryValidate(ctx context.Context, buf []byte, name string) er
 }
 
 func (s *Action) binaryGet(ctx context.Context, name string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := s.binaryWrite(ctx, name, buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// binaryWrite writes the decoded content of a secret to w. Chunked secrets
+// are streamed, one chunk at a time.
+func (s *Action) binaryWrite(ctx context.Context, name string, w io.Writer) error {
 	sec, err := s.Store.Get(ctx, name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read %q from the store: %w", name, err)
+		return fmt.Errorf("failed to read %q from the store: %w", name, err)
+	}
+
+	if _, err := leaf.ParseManifest(sec); err == nil {
+		if _, err := s.Store.GetBinary(ctx, name, w); err != nil {
+			return fmt.Errorf("failed to read %q from the store: %w", name, err)
+		}
+		return nil
 	}
 
 	if cte, _ := sec.Get("content-transfer-encoding"); cte != "Base64" {
 		// need to use sec.Bytes() otherwise the first line is missing.
-		return sec.Bytes(), nil
+		_, err := w.Write(sec.Bytes())
+		return err
 	}
 
 	buf, err := base64.StdEncoding.DecodeString(sec.Body())
 	if err != nil {
-		return nil, fmt.Errorf("failed to encode to base64: %w", err)
+		return fmt.Errorf("failed to encode to base64: %w", err)
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// binarySum returns the SHA256 checksum of the decoded content of a secret.
+// The checksum of a chunked secret is taken from its manifest, unless verify
+// is set. Then every chunk is decrypted and checked.
+func (s *Action) binarySum(ctx context.Context, name string, verify bool) (string, error) {
+	if !verify {
+		sec, err := s.Store.Get(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %q from the store: %w", name, err)
+		}
+		if m, err := leaf.ParseManifest(sec); err == nil && m.Sum != "" {
+			return m.Sum, nil
+		}
 	}
-	return buf, nil
+
+	h := sha256.New()
+	if err := s.binaryWrite(ctx, name, h); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
 // Sum decodes binary content and computes the SHA256 checksum
@@ -245,17 +318,14 @@ func (s *Action) Sum(c *cli.Context) error {
 	ctx := ctxutil.WithGlobalFlags(c)
 	name := c.Args().First()
 	if name == "" {
-		return ExitError(ExitUsage, nil, "Usage: %s sha256 name", c.App.Name)
+		return ExitError(ExitUsage, nil, "Usage: %s sha256 [--verify] name", c.App.Name)
 	}
 
-	buf, err := s.binaryGet(ctx, name)
+	sum, err := s.binarySum(ctx, name, c.Bool("verify"))
 	if err != nil {
 		return ExitError(ExitDecrypt, err, "failed to read secret: %s", err)
 	}
 
-	h := sha256.New()
-	_, _ = h.Write(buf)
-	out.Printf(ctx, "%x", h.Sum(nil))
-
+	out.Printf(ctx, "%s", sum)
 	return nil
 }
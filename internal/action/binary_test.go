@@ -3,12 +3,15 @@ package action
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"fmt"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/internal/store/leaf"
 	"github.com/itsonlycode/gosecret/pkg/ctxutil"
 	"github.com/itsonlycode/gosecret/tests/gptest"
 
@@ -208,6 +211,93 @@ func TestBinaryGet(t *testing.T) {
 	assert.Equal(t, data, out)
 }
 
+func TestBinaryChunked(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = leaf.WithChunkSize(ctx, 100)
+
+	buf := &bytes.Buffer{}
+	out.Stdout = buf
+	stdout = buf
+	defer func() {
+		out.Stdout = os.Stdout
+		stdout = os.Stdout
+	}()
+
+	act, err := newMock(ctx, u)
+	require.NoError(t, err)
+	require.NotNil(t, act)
+
+	infile := filepath.Join(u.Dir, "input.raw")
+	writeBinfile(t, infile)
+	want, err := os.ReadFile(infile)
+	require.NoError(t, err)
+
+	t.Run("move large file into the store", func(t *testing.T) {
+		defer buf.Reset()
+
+		require.NoError(t, act.binaryCopy(ctx, gptest.CliCtx(ctx, t), infile, "big", true))
+		assert.NoFileExists(t, infile)
+
+		sec, err := act.Store.Get(ctx, "big")
+		require.NoError(t, err)
+		m, err := leaf.ParseManifest(sec)
+		require.NoError(t, err)
+		assert.Len(t, m.Chunks, 11)
+	})
+
+	t.Run("sum and cat stream the chunks", func(t *testing.T) {
+		require.NoError(t, act.Sum(gptest.CliCtx(ctx, t, "big")))
+		assert.Equal(t, fmt.Sprintf("%x\n", sha256.Sum256(want)), buf.String())
+		buf.Reset()
+
+		require.NoError(t, act.Cat(gptest.CliCtx(ctx, t, "big")))
+		assert.Equal(t, want, buf.Bytes())
+		buf.Reset()
+	})
+
+	t.Run("move back to a file", func(t *testing.T) {
+		defer buf.Reset()
+
+		require.NoError(t, act.BinaryMove(gptest.CliCtx(ctx, t, "big", infile)))
+		got, err := os.ReadFile(infile)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+		assert.False(t, act.Store.Exists(ctx, "big"))
+	})
+
+	t.Run("cat large input from stdin", func(t *testing.T) {
+		defer buf.Reset()
+
+		fd, err := os.Open(infile)
+		require.NoError(t, err)
+		binstdin = fd
+		defer func() {
+			binstdin = os.Stdin
+			_ = fd.Close()
+		}()
+
+		require.NoError(t, act.Cat(gptest.CliCtx(ctx, t, "stdin")))
+		got, err := act.binaryGet(ctx, "stdin")
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("sum reads the manifest unless asked to verify", func(t *testing.T) {
+		defer buf.Reset()
+
+		require.NoError(t, os.Remove(filepath.Join(u.StoreDir(""), "stdin.chunks", "000003")))
+
+		require.NoError(t, act.Sum(gptest.CliCtx(ctx, t, "stdin")))
+		assert.Equal(t, fmt.Sprintf("%x\n", sha256.Sum256(want)), buf.String())
+
+		assert.Error(t, act.Sum(gptest.CliCtxWithFlags(ctx, t, map[string]string{"verify": "true"}, "stdin")))
+	})
+}
+
 func writeBinfile(t *testing.T, fn string) {
 	// tests should be predicable
 	rand.Seed(42)
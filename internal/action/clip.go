@@ -0,0 +1,182 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/clipboard"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret"
+	"github.com/itsonlycode/gosecret/pkg/otp"
+	"github.com/itsonlycode/gosecret/pkg/termio"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// clipFieldsKey is the key of a secret that sets the order of the fields
+	// copied by clip, e.g. "autotype: username,password"
+	clipFieldsKey = "autotype"
+	// clipDefaultDelay is the number of seconds between two fields if the
+	// clipboard can't detect pastes
+	clipDefaultDelay = 10
+)
+
+var (
+	clipDefaultFields = []string{"username", "password"}
+	// clipAliases are alternative keys that are tried if a field is missing
+	clipAliases = map[string][]string{
+		"username": {"user", "login", "email"},
+	}
+)
+
+// Clip copies several fields of a secret to the clipboard, one after another.
+// The next field is copied after the previous one was pasted or after a delay.
+func (s *Action) Clip(c *cli.Context) error {
+	ctx := ctxutil.WithGlobalFlags(c)
+	name := c.Args().First()
+	if name == "" {
+		return ExitError(ExitUsage, nil, "Usage: %s clip [--fields username,password] [--delay N] [--primary] <NAME>", s.Name)
+	}
+
+	sec, err := s.Store.Get(ctx, name)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ExitError(ExitNotFound, err, "Secret %s not found", name)
+		}
+		return ExitError(ExitDecrypt, err, "failed to read secret %s: %s", name, err)
+	}
+
+	fields, explicit := clipFields(sec, c.String("fields"))
+	values := make([]func(time.Time) string, 0, len(fields))
+	copied := make([]string, 0, len(fields))
+	for _, field := range fields {
+		v, err := clipValue(name, sec, field)
+		if err != nil {
+			if !explicit {
+				continue
+			}
+			return ExitError(ExitNotFound, err, "%s", err)
+		}
+		values = append(values, v)
+		copied = append(copied, field)
+	}
+	if len(values) < 1 {
+		return ExitError(ExitNotFound, nil, "None of the fields %s found in %s", strings.Join(fields, ", "), name)
+	}
+
+	ctx = clipboard.WithPrimary(ctx, c.Bool("primary"))
+	if _, err := clipboard.Select(ctx); err != nil {
+		if errors.Is(err, clipboard.ErrNotSupported) {
+			out.Printf(ctx, "%s", err)
+			return nil
+		}
+		if errors.Is(err, clipboard.ErrNoPrimary) {
+			return ExitError(ExitUnsupported, err, "%s", err)
+		}
+		return ExitError(ExitIO, err, "%s", err)
+	}
+
+	if err := s.clipSequence(ctx, name, copied, values, c.Int("delay")); err != nil {
+		return ExitError(ExitIO, err, "failed to copy to clipboard: %s", err)
+	}
+	return nil
+}
+
+// clipSequence copies the values in order. Each value is computed right
+// before it is copied, so a TOTP code is still fresh after waiting for the
+// previous fields. The last value is cleared by unclip after the usual
+// timeout.
+func (s *Action) clipSequence(ctx context.Context, name string, fields []string, values []func(time.Time) string, delay int) error {
+	for i, field := range fields {
+		label := fmt.Sprintf("%s of %s", field, name)
+		if i == len(fields)-1 {
+			return clipboard.CopyTo(ctx, label, []byte(values[i](time.Now())), s.cfg.ClipTimeout)
+		}
+
+		if delay < 1 {
+			err := clipboard.CopyOnce(ctx, label, []byte(values[i](time.Now())), s.cfg.ClipTimeout)
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, clipboard.ErrNoPasteOnce) {
+				return err
+			}
+			out.Noticef(ctx, "The clipboard can't detect pastes. Copying the next field after %d seconds.", clipDefaultDelay)
+			delay = clipDefaultDelay
+		}
+
+		if err := clipboard.CopyTo(ctx, label, []byte(values[i](time.Now())), s.cfg.ClipTimeout); err != nil {
+			return err
+		}
+		out.Printf(ctx, "Copying %s in %d seconds", fields[i+1], delay)
+		select {
+		case <-time.After(time.Duration(delay) * time.Second):
+		case <-ctx.Done():
+			return termio.ErrAborted
+		}
+	}
+	return nil
+}
+
+// clipFields returns the fields to copy. The flag takes precedence over the
+// autotype key of the secret. explicit is false for the default fields,
+// missing fields are skipped in that case.
+func clipFields(sec gosecret.Secret, flag string) ([]string, bool) {
+	spec := flag
+	if spec == "" {
+		spec, _ = sec.Get(clipFieldsKey)
+	}
+	if spec == "" {
+		return clipDefaultFields, false
+	}
+
+	var fields []string
+	for _, f := range strings.Split(spec, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields, true
+}
+
+// clipValue returns a function that yields the value of a field at the given
+// time. Besides the keys of the secret it supports password and otp, the TOTP
+// code. Missing fields are reported right away.
+func clipValue(name string, sec gosecret.Secret, field string) (func(time.Time) string, error) {
+	switch field {
+	case "password":
+		if pw := sec.Password(); pw != "" {
+			return clipConst(pw), nil
+		}
+		return nil, fmt.Errorf("%s has no password", name)
+	case "otp", "totp":
+		key, err := otp.Get(name, sec)
+		if err != nil {
+			return nil, fmt.Errorf("no OTP secret found in %s: %w", name, err)
+		}
+		if key.Type == otp.TypeHOTP {
+			return nil, fmt.Errorf("HOTP codes are not supported, use otp -c")
+		}
+		return key.Code, nil
+	}
+
+	for _, k := range append([]string{field}, clipAliases[field]...) {
+		if v, found := sec.Get(k); found && v != "" {
+			return clipConst(v), nil
+		}
+	}
+	return nil, fmt.Errorf("field %q not found in %s", field, name)
+}
+
+// clipConst returns a value that does not change over time
+func clipConst(v string) func(time.Time) string {
+	return func(time.Time) string {
+		return v
+	}
+}
@@ -0,0 +1,132 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets/secparse"
+	"github.com/itsonlycode/gosecret/tests/gptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestClip(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithInteractive(ctx, false)
+
+	act, err := newMock(ctx, u)
+	require.NoError(t, err)
+	require.NotNil(t, act)
+
+	buf := &bytes.Buffer{}
+	out.Stdout = buf
+	out.Stderr = buf
+	defer func() {
+		out.Stdout = os.Stdout
+		out.Stderr = os.Stderr
+	}()
+
+	sec := secrets.NewKV()
+	sec.SetPassword("hunter2")
+	require.NoError(t, sec.Set("user", "alice"))
+	require.NoError(t, act.Store.Set(ctx, "clip/login", sec))
+
+	t.Run("invalid args", func(t *testing.T) {
+		defer buf.Reset()
+		assert.Error(t, act.Clip(gptest.CliCtx(ctx, t)))
+		assert.Error(t, act.Clip(gptest.CliCtx(ctx, t, "clip/nope")))
+		assert.Error(t, act.Clip(gptest.CliCtxWithFlags(ctx, t, map[string]string{"fields": "pin"}, "clip/login")))
+	})
+
+	t.Run("no clipboard", func(t *testing.T) {
+		defer buf.Reset()
+		assert.NoError(t, act.Clip(gptest.CliCtx(ctx, t, "clip/login")))
+		assert.Contains(t, buf.String(), "No clipboard available")
+	})
+
+	t.Run("primary selection not supported", func(t *testing.T) {
+		defer buf.Reset()
+
+		require.NoError(t, os.Setenv("GOPASS_CLIPBOARD", "osc52"))
+		defer func() {
+			_ = os.Setenv("GOPASS_CLIPBOARD", "none")
+		}()
+
+		err := act.Clip(gptest.CliCtxWithFlags(ctx, t, map[string]string{"primary": "true"}, "clip/login"))
+		require.Error(t, err)
+		var ec cli.ExitCoder
+		require.True(t, errors.As(err, &ec))
+		assert.Equal(t, ExitUnsupported, ec.ExitCode())
+	})
+
+	t.Run("sequence", func(t *testing.T) {
+		defer buf.Reset()
+
+		// fake wl-copy that pastes immediately
+		bin := filepath.Join(u.Dir, "bin")
+		clip := filepath.Join(u.Dir, "clipboard")
+		require.NoError(t, os.MkdirAll(bin, 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(bin, "wl-copy"), []byte("#!/bin/sh\ncat >> \""+clip+"\"\necho >> \""+clip+"\"\n"), 0700))
+		path := os.Getenv("PATH")
+		require.NoError(t, os.Setenv("PATH", bin+string(os.PathListSeparator)+path))
+		require.NoError(t, os.Setenv("GOPASS_CLIPBOARD", "wayland"))
+		defer func() {
+			_ = os.Setenv("PATH", path)
+			_ = os.Setenv("GOPASS_CLIPBOARD", "none")
+		}()
+
+		assert.NoError(t, act.Clip(gptest.CliCtx(ctx, t, "clip/login")))
+		content, err := os.ReadFile(clip)
+		require.NoError(t, err)
+		assert.Equal(t, "alice\nhunter2\n", string(content))
+		assert.Contains(t, buf.String(), "Waiting for it to be pasted")
+	})
+}
+
+func TestClipFields(t *testing.T) {
+	sec, err := secparse.Parse([]byte("hunter2\nlogin: alice\nautotype: password, login\notpauth: //totp/test?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\n"))
+	require.NoError(t, err)
+
+	fields, explicit := clipFields(sec, "")
+	assert.Equal(t, []string{"password", "login"}, fields)
+	assert.True(t, explicit)
+
+	fields, _ = clipFields(sec, "username,otp")
+	assert.Equal(t, []string{"username", "otp"}, fields)
+
+	fields, explicit = clipFields(secrets.NewKV(), "")
+	assert.Equal(t, clipDefaultFields, fields)
+	assert.False(t, explicit)
+
+	now := time.Now()
+	v, err := clipValue("test", sec, "username")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", v(now))
+
+	v, err = clipValue("test", sec, "password")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", v(now))
+
+	// the code is computed when the field is copied, not up front
+	v, err = clipValue("test", sec, "otp")
+	require.NoError(t, err)
+	assert.Len(t, v(now), 6)
+	assert.NotEqual(t, v(time.Unix(59, 0)), v(time.Unix(89, 0)))
+
+	_, err = clipValue("test", sec, "pin")
+	assert.Error(t, err)
+}
@@ -23,6 +23,9 @@ func (s *Action) Clone(c *cli.Context) error {
 	if c.IsSet("crypto") {
 		ctx = backend.WithCryptoBackendString(ctx, c.String("crypto"))
 	}
+	if c.IsSet("storage") {
+		ctx = backend.WithStorageBackendString(ctx, c.String("storage"))
+	}
 	path := c.String("path")
 
 	if c.Args().Len() < 1 {
@@ -53,6 +56,9 @@ func storageBackendOrDefault(ctx context.Context) backend.StorageBackend {
 }
 
 func (s *Action) clone(ctx context.Context, repo, mount, path string) error {
+	if err := checkStorageBackend(storageBackendOrDefault(ctx)); err != nil {
+		return err
+	}
 	if path == "" {
 		path = config.PwStoreDir(mount)
 	}
@@ -107,6 +113,11 @@ func (s *Action) clone(ctx context.Context, repo, mount, path string) error {
 		mount = " " + mount
 	}
 	out.Printf(ctx, "Your password store is ready to use! Have a look around: `%s list%s`\n", s.Name, mount)
+	if mount == "" {
+		if _, err := s.Store.MountsManifest(ctx); err == nil {
+			out.Printf(ctx, "This store declares shared mounts. Set them up with: `%s mounts sync`", s.Name)
+		}
+	}
 
 	return nil
 }
@@ -49,6 +49,14 @@ func ShowFlags() []cli.Flag {
 			Aliases: []string{"n"},
 			Usage:   "Do not parse the output.",
 		},
+		&cli.BoolFlag{
+			Name:  "follow",
+			Usage: "Show the secret a link points to (default)",
+		},
+		&cli.BoolFlag{
+			Name:  "no-follow",
+			Usage: "Show the target of a link instead of the secret it points to",
+		},
 	}
 }
 
@@ -85,6 +93,67 @@ func (s *Action) GetCommands() []*cli.Command {
 				},
 			},
 		},
+		{
+			Name:      "attach",
+			Usage:     "Manage files attached to a secret",
+			ArgsUsage: "[secret]",
+			Description: "" +
+				"Attachments are files that belong to a secret, e.g. a certificate " +
+				"and the password of its key store. They are encrypted in chunks and " +
+				"referenced from the attachment key of the secret. Copying, moving or " +
+				"deleting the secret takes its attachments along. Without a subcommand " +
+				"the attachments of the secret are listed.",
+			Before:       s.IsInitialized,
+			Action:       s.AttachList,
+			BashComplete: s.Complete,
+			Subcommands: []*cli.Command{
+				{
+					Name:         "add",
+					Usage:        "Attach a file to a secret",
+					ArgsUsage:    "[secret] [file]",
+					Description:  "Attaches a file to an existing secret, replacing an attachment of the same name. Use - to read from stdin.",
+					Before:       s.IsInitialized,
+					Action:       s.AttachAdd,
+					BashComplete: s.Complete,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "name",
+							Aliases: []string{"n"},
+							Usage:   "Name of the attachment. Defaults to the name of the file.",
+						},
+					},
+				},
+				{
+					Name:         "get",
+					Usage:        "Write an attachment to a file or stdout",
+					ArgsUsage:    "[secret] [attachment] [file]",
+					Description:  "Writes an attachment to a file or, if no file is given, to stdout. The checksums are verified while reading.",
+					Before:       s.IsInitialized,
+					Action:       s.AttachGet,
+					BashComplete: s.Complete,
+				},
+				{
+					Name:         "list",
+					Aliases:      []string{"ls"},
+					Usage:        "List the attachments of a secret",
+					ArgsUsage:    "[secret]",
+					Description:  "Lists the attachments of a secret with their size and SHA256 checksum.",
+					Before:       s.IsInitialized,
+					Action:       s.AttachList,
+					BashComplete: s.Complete,
+				},
+				{
+					Name:         "rm",
+					Aliases:      []string{"remove"},
+					Usage:        "Remove an attachment from a secret",
+					ArgsUsage:    "[secret] [attachment]",
+					Description:  "Removes an attachment and its reference from a secret.",
+					Before:       s.IsInitialized,
+					Action:       s.AttachRemove,
+					BashComplete: s.Complete,
+				},
+			},
+		},
 		{
 			Name:      "audit",
 			Usage:     "Decrypt all secrets and scan for weak or leaked passwords",
@@ -108,6 +177,35 @@ func (s *Action) GetCommands() []*cli.Command {
 			Action:       s.Cat,
 			BashComplete: s.Complete,
 		},
+		{
+			Name:      "clip",
+			Usage:     "Copy several fields of a secret to the clipboard, one after another",
+			ArgsUsage: "[secret]",
+			Description: "" +
+				"This command copies the fields of a secret to the clipboard in sequence, " +
+				"e.g. the username and then the password. The next field is copied as soon " +
+				"as the previous one was pasted, if the clipboard supports it, or after a delay. " +
+				"The order of the fields can be set per secret with a key like " +
+				"'autotype: username,password'. The special fields 'password' and 'otp' " +
+				"copy the password and the current TOTP code.",
+			Before:       s.IsInitialized,
+			Action:       s.Clip,
+			BashComplete: s.Complete,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "fields",
+					Usage: "Comma separated list of fields to copy. Overrides the autotype key",
+				},
+				&cli.IntFlag{
+					Name:  "delay",
+					Usage: "Seconds between two fields. 0 waits until the field was pasted",
+				},
+				&cli.BoolFlag{
+					Name:  "primary",
+					Usage: "Use the primary selection (X11 and Wayland) instead of the clipboard",
+				},
+			},
+		},
 		{
 			Name:      "clone",
 			Usage:     "Clone a password store from a git repository",
@@ -130,6 +228,10 @@ func (s *Action) GetCommands() []*cli.Command {
 					Name:  "crypto",
 					Usage: fmt.Sprintf("Select crypto backend %v", backend.CryptoBackends()),
 				},
+				&cli.StringFlag{
+					Name:  "storage",
+					Usage: fmt.Sprintf("Select storage backend %v", backend.PersistentStorageBackends()),
+				},
 			},
 		},
 		{
@@ -168,7 +270,11 @@ func (s *Action) GetCommands() []*cli.Command {
 				},
 				&cli.StringFlag{
 					Name:  "storage",
-					Usage: fmt.Sprintf("Which storage backend? %v", backend.StorageBackends()),
+					Usage: fmt.Sprintf("Which storage backend? %v", backend.PersistentStorageBackends()),
+				},
+				&cli.BoolFlag{
+					Name:  "encrypted-names",
+					Usage: "Encrypt the names of the secrets in the converted store",
 				},
 			},
 		},
@@ -240,6 +346,36 @@ func (s *Action) GetCommands() []*cli.Command {
 				},
 			},
 		},
+		{
+			Name:      "diff",
+			Usage:     "Compare secrets, revisions or mounts",
+			ArgsUsage: "[secret] [secret]",
+			Description: "" +
+				"This command compares two secrets key by key. With --revision it " +
+				"compares two revisions of one secret, or one revision with the " +
+				"current version. With --mounts it compares the listings of two mounts " +
+				"and shows which entries exist only in one of them. " +
+				"Values are masked unless --unsafe is given.",
+			Before:       s.IsInitialized,
+			Action:       s.Diff,
+			BashComplete: s.Complete,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "revision",
+					Aliases: []string{"r"},
+					Usage:   "Compare revisions of one secret. Use <rev> to compare with the current version or <rev>..<rev>",
+				},
+				&cli.BoolFlag{
+					Name:  "mounts",
+					Usage: "Compare the listings of two mount points. Use \"\" for the root store",
+				},
+				&cli.BoolFlag{
+					Name:    "unsafe",
+					Aliases: []string{"u", "force", "f"},
+					Usage:   "Display unmasked values",
+				},
+			},
+		},
 		{
 			Name:      "edit",
 			Usage:     "Edit new or existing secrets",
@@ -320,6 +456,10 @@ func (s *Action) GetCommands() []*cli.Command {
 					Name:  "decrypt",
 					Usage: "Decrypt and reencryt during fsck.\nWARNING: This will update the secret content to the latest format. This might be incompatible with other implementations. Use with caution!",
 				},
+				&cli.BoolFlag{
+					Name:  "prune-links",
+					Usage: "Remove dangling links and symlinks",
+				},
 			},
 		},
 		{
@@ -436,7 +576,7 @@ func (s *Action) GetCommands() []*cli.Command {
 						},
 						&cli.StringFlag{
 							Name:  "sign-key",
-							Usage: "GPG Key to sign commits",
+							Usage: "GPG key id or SSH public key to sign commits with",
 						},
 						&cli.StringFlag{
 							Name:    "name",
@@ -450,7 +590,7 @@ func (s *Action) GetCommands() []*cli.Command {
 						},
 						&cli.StringFlag{
 							Name:  "storage",
-							Usage: fmt.Sprintf("Select storage backend %v", backend.StorageBackends()),
+							Usage: fmt.Sprintf("Select storage backend %v", backend.PersistentStorageBackends()),
 							Value: "gitfs",
 						},
 					},
@@ -564,6 +704,29 @@ func (s *Action) GetCommands() []*cli.Command {
 					Usage:   "Include passwords in output",
 				},
 			},
+			Subcommands: []*cli.Command{
+				{
+					Name:      "restore",
+					Usage:     "Restore a secret or folder to an earlier revision",
+					ArgsUsage: "[secret] [revision|timestamp]",
+					Description: "" +
+						"Restores a secret to an earlier revision. The revision can be a " +
+						"revision identifier, a negative offset (e.g. -2) or a timestamp " +
+						"(e.g. 2021-01-31 or 2021-01-31T12:00:00Z). With --recursive every " +
+						"secret below the given folder is restored to its state at that " +
+						"revision. Secrets are re-encrypted for the current recipients.",
+					Before:       s.IsInitialized,
+					Action:       s.HistoryRestore,
+					BashComplete: s.Complete,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:    "recursive",
+							Aliases: []string{"r"},
+							Usage:   "Restore all secrets below the given folder",
+						},
+					},
+				},
+			},
 		},
 		{
 			Name:      "init",
@@ -590,9 +753,13 @@ func (s *Action) GetCommands() []*cli.Command {
 				},
 				&cli.StringFlag{
 					Name:  "storage",
-					Usage: fmt.Sprintf("Select storage backend %v", backend.StorageBackends()),
+					Usage: fmt.Sprintf("Select storage backend %v", backend.PersistentStorageBackends()),
 					Value: "gitfs",
 				},
+				&cli.BoolFlag{
+					Name:  "encrypted-names",
+					Usage: "Encrypt the names of the secrets",
+				},
 			},
 		},
 		{
@@ -631,11 +798,11 @@ func (s *Action) GetCommands() []*cli.Command {
 		},
 		{
 			Name:      "link",
-			Usage:     "Create a symlink",
+			Usage:     "Create a link",
 			ArgsUsage: "[from] [to]",
 			Description: "" +
-				"This command creates a symlink from one entry in a mounted store to another entry." +
-				"Important: Does not cross mounts!",
+				"This command creates a link from one entry to another entry. " +
+				"Links can point to entries in other mounts and are updated when their target is moved.",
 			Aliases:      []string{"ln", "symlink"},
 			Hidden:       true,
 			Before:       s.IsInitialized,
@@ -684,8 +851,12 @@ func (s *Action) GetCommands() []*cli.Command {
 				"This command implements a merge workflow to help deduplicate " +
 				"secrets. It requires exactly one destination (may already exist) " +
 				"and at least one source (must exist, can be multiple). gosecret will " +
-				"then merge all entries into one, drop into an editor, save the result " +
-				"and remove all merged entries.",
+				"then parse all entries and merge their keys and bodies into one. " +
+				"Identical values are only kept once, different values for the same " +
+				"key are kept as multiple values. The password is chosen according " +
+				"to the --password strategy. Only if the entries have conflicting " +
+				"passwords gosecret will drop into an editor to resolve them. " +
+				"Finally it saves the result and removes all merged entries.",
 			Before:       s.IsInitialized,
 			Action:       s.Merge,
 			BashComplete: s.Complete,
@@ -701,6 +872,15 @@ func (s *Action) GetCommands() []*cli.Command {
 					Aliases: []string{"f"},
 					Usage:   "Skip editor, merge entries unattended",
 				},
+				&cli.StringFlag{
+					Name:  "password",
+					Usage: "Strategy to pick the password if the entries have different ones: first, last, longest or edit",
+					Value: "edit",
+				},
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Only show the changes to the destination, don't write anything",
+				},
 			},
 		},
 		{
@@ -742,6 +922,24 @@ func (s *Action) GetCommands() []*cli.Command {
 						"at any path in an existing root store.",
 					Before: s.IsInitialized,
 					Action: s.MountAdd,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:  "read-only",
+							Usage: "Reject any change to the mounted store",
+						},
+						&cli.BoolFlag{
+							Name:  "no-autosync",
+							Usage: "Do not push changes to the remote automatically",
+						},
+						&cli.BoolFlag{
+							Name:  "require-signing",
+							Usage: "Reject changes unless git signs the commits",
+						},
+						&cli.StringSliceFlag{
+							Name:  "writer",
+							Usage: "Only allow changes by this recipient. Can be given multiple times",
+						},
+					},
 				},
 				{
 					Name:    "remove",
@@ -754,6 +952,22 @@ func (s *Action) GetCommands() []*cli.Command {
 					Action:       s.MountRemove,
 					BashComplete: s.MountsComplete,
 				},
+				{
+					Name:  "sync",
+					Usage: "Set up the mounts declared by the root store",
+					Description: "" +
+						"This command clones, updates and removes mounts to match the " +
+						".gosecret-mounts.yml manifest of the root store. Mounts that " +
+						"were not added from the manifest are never changed.",
+					Before: s.IsInitialized,
+					Action: s.MountsSync,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:  "dry-run",
+							Usage: "Only print the changes",
+						},
+					},
+				},
 			},
 		},
 		{
@@ -762,8 +976,10 @@ func (s *Action) GetCommands() []*cli.Command {
 			ArgsUsage: "[secret]",
 			Aliases:   []string{"totp", "hotp"},
 			Description: "" +
-				"Tries to parse an OTP URL (otpauth://). URL can be TOTP or HOTP. " +
-				"The URL can be provided on its own line or on a key value line with a key named 'totp'.",
+				"Tries to parse an OTP URL (otpauth://). URL can be TOTP, HOTP or Steam. " +
+				"The URL can be provided on its own line or on a key value line with a key named 'totp'. " +
+				"The period, digits and algorithm from the URL are honoured. " +
+				"HOTP counters are incremented and saved after each use.",
 			Before:       s.IsInitialized,
 			Action:       s.OTP,
 			BashComplete: s.Complete,
@@ -783,6 +999,43 @@ func (s *Action) GetCommands() []*cli.Command {
 					Aliases: []string{"o"},
 					Usage:   "Only display the token",
 				},
+				&cli.BoolFlag{
+					Name:  "next",
+					Usage: "Show the next token if the current one is about to expire",
+				},
+			},
+			Subcommands: []*cli.Command{
+				{
+					Name:      "add",
+					Usage:     "Add an OTP secret from a QR code or URL",
+					ArgsUsage: "[secret]",
+					Description: "" +
+						"Reads an otpauth:// URL from a QR code image (PNG, JPEG or GIF) or " +
+						"from the command line and stores it in a new or existing secret. " +
+						"Exports from Google Authenticator (otpauth-migration://) can contain " +
+						"many accounts, those are stored as <secret>/<issuer>/<account>. " +
+						"Each key is validated by computing a code before it is saved.",
+					Before:       s.IsInitialized,
+					Action:       s.OTPAdd,
+					BashComplete: s.Complete,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "image",
+							Aliases: []string{"i"},
+							Usage:   "Read the OTP URL from the QR code in FILE",
+						},
+						&cli.StringFlag{
+							Name:    "url",
+							Aliases: []string{"u"},
+							Usage:   "The otpauth:// or otpauth-migration:// URL",
+						},
+						&cli.BoolFlag{
+							Name:    "force",
+							Aliases: []string{"f"},
+							Usage:   "Replace an existing OTP secret",
+						},
+					},
+				},
 			},
 		},
 		{
@@ -846,6 +1099,44 @@ func (s *Action) GetCommands() []*cli.Command {
 				},
 			},
 		},
+		{
+			Name:      "run",
+			Usage:     "Run a subprocess with secrets mounted as temporary files",
+			ArgsUsage: "[command and args...]",
+			Description: "" +
+				"This command writes the given secrets (or keys of secrets) to temporary files " +
+				"and runs a sub process with their paths exported as environment variables. " +
+				"On Linux these files are placed on a ramdisk. They are securely removed " +
+				"when the sub process exits or gosecret is interrupted.",
+			Before:       s.IsInitialized,
+			Action:       s.Run,
+			BashComplete: s.Complete,
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:    "file",
+					Aliases: []string{"f"},
+					Usage:   "Secret to write to a file, given as [ENV=]secret[:key]. Can be given multiple times.",
+				},
+			},
+		},
+		{
+			Name:  "serve",
+			Usage: "Answer JSON-RPC requests for scripts and editor plugins",
+			Description: "" +
+				"This command reads line-delimited JSON-RPC 2.0 requests from stdin and writes " +
+				"one response per line to stdout until stdin is closed. It exposes list, get, " +
+				"set, delete, move, generate and otp, so scripts can use a single process " +
+				"instead of starting gosecret for every operation. Errors use the exit codes " +
+				"of the CLI as error codes.",
+			Before: s.IsInitialized,
+			Action: s.Serve,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "stdio",
+					Usage: "Read requests from stdin and write responses to stdout",
+				},
+			},
+		},
 		{
 			Name:  "setup",
 			Usage: "Initialize a new password store",
@@ -881,7 +1172,7 @@ func (s *Action) GetCommands() []*cli.Command {
 				},
 				&cli.StringFlag{
 					Name:  "storage",
-					Usage: fmt.Sprintf("Select storage backend %v", backend.StorageBackends()),
+					Usage: fmt.Sprintf("Select storage backend %v", backend.PersistentStorageBackends()),
 				},
 			},
 		},
@@ -904,11 +1195,18 @@ func (s *Action) GetCommands() []*cli.Command {
 			Description: "" +
 				"This command decodes an Base64 encoded secret and computes the SHA256 checksum " +
 				"over the decoded data. This is useful to verify the integrity of an " +
-				"inserted secret.",
+				"inserted secret. The checksum of a large file is read from its manifest, " +
+				"use --verify to decrypt and check every chunk instead.",
 			Aliases:      []string{"sha", "sha256"},
 			Before:       s.IsInitialized,
 			Action:       s.Sum,
 			BashComplete: s.Complete,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "verify",
+					Usage: "Decrypt all chunks of a large file and verify their checksums",
+				},
+			},
 		},
 		{
 			Name:  "sync",
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/itsonlycode/gosecret/internal/out"
 	"github.com/itsonlycode/gosecret/pkg/ctxutil"
@@ -39,10 +40,15 @@ func (s *Action) printConfigValues(ctx context.Context, needles ...string) {
 	for _, k := range filterMap(m, needles) {
 		out.Printf(ctx, "%s: %s", k, m[k])
 	}
-	for alias, path := range s.cfg.Mounts {
-		if len(needles) < 1 {
-			out.Printf(ctx, "mount %q => %q", alias, path)
+	for alias, mc := range s.cfg.Mounts {
+		if len(needles) > 0 {
+			continue
+		}
+		if opts := mc.Options(); len(opts) > 0 {
+			out.Printf(ctx, "mount %q => %q (%s)", alias, mc.Path, strings.Join(opts, ", "))
+			continue
 		}
+		out.Printf(ctx, "mount %q => %q", alias, mc.Path)
 	}
 }
 
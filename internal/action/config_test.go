@@ -40,7 +40,10 @@ func TestConfig(t *testing.T) {
 		assert.NoError(t, act.Config(c))
 		want := `autoclip: true
 autoimport: true
+autolock: 15
+clipboard: 
 cliptimeout: 45
+durablequeue: false
 exportkeys: true
 nopager: false
 notifications: true
@@ -48,6 +51,7 @@ parsing: true
 `
 		want += "path: " + u.StoreDir("") + "\n"
 		want += `safecontent: false
+syncinterval: 0
 `
 		assert.Equal(t, want, buf.String())
 	})
@@ -80,14 +84,18 @@ parsing: true
 		act.printConfigValues(ctx)
 		want := `autoclip: true
 autoimport: true
+autolock: 15
+clipboard: 
 cliptimeout: 45
+durablequeue: false
 exportkeys: true
 nopager: true
 notifications: true
 parsing: true
 `
 		want += "path: " + u.StoreDir("") + "\n"
-		want += `safecontent: false`
+		want += `safecontent: false
+syncinterval: 0`
 		assert.Equal(t, want, strings.TrimSpace(buf.String()), "action.printConfigValues")
 
 		delete(act.cfg.Mounts, "foo")
@@ -115,7 +123,10 @@ parsing: true
 		act.ConfigComplete(gptest.CliCtx(ctx, t))
 		want := `autoclip
 autoimport
+autolock
+clipboard
 cliptimeout
+durablequeue
 exportkeys
 nopager
 notifications
@@ -123,6 +134,7 @@ parsing
 path
 remote
 safecontent
+syncinterval
 `
 		assert.Equal(t, want, buf.String())
 	})
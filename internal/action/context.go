@@ -12,6 +12,7 @@ const (
 	ctxKeyKey
 	ctxKeyOnlyClip
 	ctxKeyAlsoClip
+	ctxKeyFollowLinks
 )
 
 // WithClip returns a context with the value for clip (for copy to clipboard)
@@ -126,3 +127,17 @@ func GetKey(ctx context.Context) string {
 	}
 	return sv
 }
+
+// WithFollowLinks returns a context with the value for following links set
+func WithFollowLinks(ctx context.Context, follow bool) context.Context {
+	return context.WithValue(ctx, ctxKeyFollowLinks, follow)
+}
+
+// IsFollowLinks returns the value of follow links or the default (true)
+func IsFollowLinks(ctx context.Context) bool {
+	bv, ok := ctx.Value(ctxKeyFollowLinks).(bool)
+	if !ok {
+		return true
+	}
+	return bv
+}
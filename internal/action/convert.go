@@ -2,6 +2,7 @@ package action
 
 import (
 	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/store/leaf"
 	"github.com/itsonlycode/gosecret/pkg/ctxutil"
 	"github.com/urfave/cli/v2"
 )
@@ -13,7 +14,11 @@ func (s *Action) Convert(c *cli.Context) error {
 	store := c.String("store")
 	move := c.Bool("move")
 	storage := backend.StorageBackendFromName(c.String("storage"))
+	if err := checkStorageBackend(storage); err != nil {
+		return err
+	}
 	crypto := backend.CryptoBackendFromName(c.String("crypto"))
+	ctx = leaf.WithEncryptedNames(ctx, c.Bool("encrypted-names"))
 
 	return s.Store.Convert(ctx, store, crypto, storage, move)
 }
@@ -0,0 +1,233 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/itsonlycode/gosecret/internal/diff"
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// diffMask replaces secret values unless --unsafe is given
+	diffMask = "*****"
+	// diffPasswordKey is the name used for the password in the key level diff
+	diffPasswordKey = "password"
+	// diffRevisionSep separates two revisions in the --revision flag
+	diffRevisionSep = ".."
+)
+
+// Diff compares two secrets, two revisions of one secret or the listings of
+// two mounts
+func (s *Action) Diff(c *cli.Context) error {
+	ctx := ctxutil.WithGlobalFlags(c)
+	unsafe := c.Bool("unsafe")
+
+	if c.Bool("mounts") {
+		if c.Args().Len() != 2 {
+			return ExitError(ExitUsage, nil, "Usage: %s diff --mounts <mount> <mount>", s.Name)
+		}
+		return s.diffMounts(ctx, c.Args().Get(0), c.Args().Get(1))
+	}
+
+	if rev := c.String("revision"); rev != "" {
+		if c.Args().Len() != 1 {
+			return ExitError(ExitUsage, nil, "Usage: %s diff --revision <rev>[..<rev>] <name>", s.Name)
+		}
+		return s.diffRevisions(ctx, c.Args().First(), rev, unsafe)
+	}
+
+	if c.Args().Len() != 2 {
+		return ExitError(ExitUsage, nil, "Usage: %s diff <name> <name>", s.Name)
+	}
+	l, r := c.Args().Get(0), c.Args().Get(1)
+	ls, err := s.diffGet(ctx, l)
+	if err != nil {
+		return err
+	}
+	rs, err := s.diffGet(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	out.Printf(ctx, "--- %s\n+++ %s", l, r)
+	diffSecrets(ctx, ls, rs, unsafe)
+	return nil
+}
+
+func (s *Action) diffGet(ctx context.Context, name string) (gosecret.Secret, error) {
+	if !s.Store.Exists(ctx, name) {
+		return nil, ExitError(ExitNotFound, nil, "Secret %s not found", name)
+	}
+	sec, err := s.Store.Get(ctx, name)
+	if err != nil {
+		return nil, ExitError(ExitDecrypt, err, "failed to decrypt %s: %s", name, err)
+	}
+	return sec, nil
+}
+
+// diffRevisions compares two revisions of one secret. If only one revision
+// is given it will be compared to the current version.
+func (s *Action) diffRevisions(ctx context.Context, name, revs string, unsafe bool) error {
+	from, to := revs, ""
+	if p := strings.SplitN(revs, diffRevisionSep, 2); len(p) == 2 {
+		from, to = p[0], p[1]
+	}
+
+	fromRev, err := s.parseRevision(ctx, name, from)
+	if err != nil {
+		return ExitError(ExitUsage, err, "Invalid revision %q of %s: %s", from, name, err)
+	}
+	_, ls, err := s.Store.GetRevision(ctx, name, fromRev)
+	if err != nil {
+		return ExitError(ExitUnknown, err, "Failed to get revision %q of %s: %s", from, name, err)
+	}
+
+	var rs gosecret.Secret
+	if to == "" {
+		rs, err = s.diffGet(ctx, name)
+		if err != nil {
+			return err
+		}
+		to = "current"
+	} else {
+		toRev, err := s.parseRevision(ctx, name, to)
+		if err != nil {
+			return ExitError(ExitUsage, err, "Invalid revision %q of %s: %s", to, name, err)
+		}
+		_, rs, err = s.Store.GetRevision(ctx, name, toRev)
+		if err != nil {
+			return ExitError(ExitUnknown, err, "Failed to get revision %q of %s: %s", to, name, err)
+		}
+	}
+
+	out.Printf(ctx, "--- %s@%s\n+++ %s@%s", name, from, name, to)
+	diffSecrets(ctx, ls, rs, unsafe)
+	return nil
+}
+
+// diffSecrets prints the key level differences between both secrets. Values
+// are masked unless unsafe is true. Unchanged keys are omitted.
+func diffSecrets(ctx context.Context, l, r gosecret.Secret, unsafe bool) {
+	lkv, rkv := diffKeyValues(l), diffKeyValues(r)
+
+	keys := make([]string, 0, len(lkv)+len(rkv))
+	for k := range lkv {
+		keys = append(keys, k)
+	}
+	for k := range rkv {
+		if _, found := lkv[k]; !found {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	mask := func(v string) string {
+		if unsafe {
+			return v
+		}
+		return diffMask
+	}
+
+	var changes int
+	for _, k := range keys {
+		lv, inL := lkv[k]
+		rv, inR := rkv[k]
+		switch {
+		case !inR:
+			out.Printf(ctx, "- %s: %s", k, mask(lv))
+		case !inL:
+			out.Printf(ctx, "+ %s: %s", k, mask(rv))
+		case lv != rv:
+			out.Printf(ctx, "~ %s: %s -> %s", k, mask(lv), mask(rv))
+		default:
+			continue
+		}
+		changes++
+	}
+
+	if lb, rb := l.Body(), r.Body(); lb != rb {
+		changes++
+		if !unsafe {
+			out.Printf(ctx, "~ body changed")
+		} else {
+			out.Printf(ctx, "~ body:")
+			for _, line := range diff.Lines(splitLines([]byte(lb)), splitLines([]byte(rb))) {
+				out.Printf(ctx, "  %s", line)
+			}
+		}
+	}
+
+	if changes == 0 {
+		out.Printf(ctx, "No differences")
+	}
+}
+
+// diffKeyValues returns all keys of the secret, including the password, with
+// their values. Multiple values are joined.
+func diffKeyValues(sec gosecret.Secret) map[string]string {
+	kv := make(map[string]string, len(sec.Keys())+1)
+	if pw := sec.Password(); pw != "" {
+		kv[diffPasswordKey] = pw
+	}
+	for _, k := range sec.Keys() {
+		values, _ := sec.Values(k)
+		kv[k] = strings.Join(values, ", ")
+	}
+	return kv
+}
+
+// diffMounts compares the listings of two mounts. Use an empty name for the
+// root store.
+func (s *Action) diffMounts(ctx context.Context, l, r string) error {
+	ll, err := s.diffListMount(ctx, l)
+	if err != nil {
+		return err
+	}
+	rl, err := s.diffListMount(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	onlyL := diff.Missing(ll, rl)
+	onlyR := diff.Missing(rl, ll)
+	for _, e := range onlyL {
+		out.Printf(ctx, "- %s", e)
+	}
+	for _, e := range onlyR {
+		out.Printf(ctx, "+ %s", e)
+	}
+	out.Printf(ctx, "%d entries only in %s, %d entries only in %s, %d in both", len(onlyL), diffMountName(l), len(onlyR), diffMountName(r), len(ll)-len(onlyL))
+	return nil
+}
+
+// diffListMount returns the entries of the mount relative to the mount point
+func (s *Action) diffListMount(ctx context.Context, mp string) ([]string, error) {
+	sub, err := s.Store.GetSubStore(strings.TrimSuffix(mp, "/"))
+	if err != nil {
+		return nil, ExitError(ExitMount, err, "Failed to get mount %s: %s", mp, err)
+	}
+	entries, err := sub.List(ctx, "")
+	if err != nil {
+		return nil, ExitError(ExitList, err, "Failed to list mount %s: %s", mp, err)
+	}
+	for i, e := range entries {
+		if sub.Alias() != "" {
+			entries[i] = strings.TrimPrefix(e, sub.Alias()+"/")
+		}
+	}
+	return entries, nil
+}
+
+func diffMountName(mp string) string {
+	if mp == "" {
+		return "<root>"
+	}
+	return fmt.Sprintf("%q", mp)
+}
@@ -0,0 +1,117 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+	"github.com/itsonlycode/gosecret/tests/gptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithInteractive(ctx, false)
+	ctx = backend.WithStorageBackend(ctx, backend.GitFS)
+
+	act, err := newMock(ctx, u)
+	require.NoError(t, err)
+	require.NotNil(t, act)
+	require.NoError(t, act.rcsInit(ctx, "", "foo bar", "foo.bar@example.org"))
+
+	buf := &bytes.Buffer{}
+	out.Stdout = buf
+	out.Stderr = buf
+	defer func() {
+		out.Stdout = os.Stdout
+		out.Stderr = os.Stderr
+	}()
+
+	sec := secrets.NewKV()
+	sec.SetPassword("one")
+	require.NoError(t, sec.Set("user", "alice"))
+	require.NoError(t, sec.Set("url", "example.org"))
+	require.NoError(t, act.Store.Set(ctx, "diff/a", sec))
+
+	sec = secrets.NewKV()
+	sec.SetPassword("two")
+	require.NoError(t, sec.Set("user", "alice"))
+	require.NoError(t, sec.Set("pin", "1234"))
+	require.NoError(t, act.Store.Set(ctx, "diff/b", sec))
+
+	t.Run("invalid args", func(t *testing.T) {
+		defer buf.Reset()
+		assert.Error(t, act.Diff(gptest.CliCtx(ctx, t)))
+		assert.Error(t, act.Diff(gptest.CliCtx(ctx, t, "diff/a")))
+		assert.Error(t, act.Diff(gptest.CliCtx(ctx, t, "diff/a", "diff/c")))
+	})
+
+	t.Run("masked", func(t *testing.T) {
+		defer buf.Reset()
+		assert.NoError(t, act.Diff(gptest.CliCtx(ctx, t, "diff/a", "diff/b")))
+		assert.Contains(t, buf.String(), "~ password: ***** -> *****")
+		assert.Contains(t, buf.String(), "+ pin: *****")
+		assert.Contains(t, buf.String(), "- url: *****")
+		assert.NotContains(t, buf.String(), "user")
+		assert.NotContains(t, buf.String(), "1234")
+	})
+
+	t.Run("unsafe", func(t *testing.T) {
+		defer buf.Reset()
+		c := gptest.CliCtxWithFlags(ctx, t, map[string]string{"unsafe": "true"}, "diff/a", "diff/b")
+		assert.NoError(t, act.Diff(c))
+		assert.Contains(t, buf.String(), "~ password: one -> two")
+		assert.Contains(t, buf.String(), "+ pin: 1234")
+	})
+
+	t.Run("revisions", func(t *testing.T) {
+		defer buf.Reset()
+		sec.SetPassword("three")
+		require.NoError(t, act.Store.Set(ctx, "diff/b", sec))
+
+		c := gptest.CliCtxWithFlags(ctx, t, map[string]string{"revision": "HEAD~1", "unsafe": "true"}, "diff/b")
+		assert.NoError(t, act.Diff(c))
+		assert.Contains(t, buf.String(), "~ password: two -> three")
+		buf.Reset()
+
+		c = gptest.CliCtxWithFlags(ctx, t, map[string]string{"revision": "HEAD..HEAD"}, "diff/b")
+		assert.NoError(t, act.Diff(c))
+		assert.Contains(t, buf.String(), "No differences")
+		buf.Reset()
+
+		// offsets are resolved like show --revision does
+		c = gptest.CliCtxWithFlags(ctx, t, map[string]string{"revision": "-1..-2", "unsafe": "true"}, "diff/b")
+		assert.NoError(t, act.Diff(c))
+		assert.Contains(t, buf.String(), "~ password: two -> three")
+		buf.Reset()
+
+		c = gptest.CliCtxWithFlags(ctx, t, map[string]string{"revision": "-x"}, "diff/b")
+		assert.Error(t, act.Diff(c))
+	})
+
+	t.Run("mounts", func(t *testing.T) {
+		defer buf.Reset()
+		require.NoError(t, u.InitStore("mnt"))
+		require.NoError(t, act.Store.AddMount(ctx, "mnt", u.StoreDir("mnt")))
+		require.NoError(t, act.Store.Set(ctx, "mnt/diff/a", sec))
+
+		c := gptest.CliCtxWithFlags(ctx, t, map[string]string{"mounts": "true"}, "", "mnt")
+		assert.NoError(t, act.Diff(c))
+		assert.Contains(t, buf.String(), "- diff/b")
+		assert.NotContains(t, buf.String(), "- diff/a")
+		assert.Contains(t, buf.String(), "1 entries only in <root>, 0 entries only in \"mnt\", 2 in both")
+
+		assert.Error(t, act.Diff(gptest.CliCtxWithFlags(ctx, t, map[string]string{"mounts": "true"}, "", "nope")))
+	})
+}
@@ -23,6 +23,9 @@ func (s *Action) Fsck(c *cli.Context) error {
 	if c.IsSet("decrypt") {
 		ctx = leaf.WithFsckDecrypt(ctx, c.Bool("decrypt"))
 	}
+	if c.IsSet("prune-links") {
+		ctx = leaf.WithFsckPruneLinks(ctx, c.Bool("prune-links"))
+	}
 
 	out.Printf(ctx, "Checking store integrity ...")
 	// make sure config is in the right place
@@ -1,9 +1,17 @@
 package action
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/itsonlycode/gosecret/internal/backend"
 	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/internal/tree"
 	"github.com/itsonlycode/gosecret/pkg/ctxutil"
 	"github.com/itsonlycode/gosecret/pkg/debug"
 
@@ -44,3 +52,233 @@ func (s *Action) History(c *cli.Context) error {
 	}
 	return nil
 }
+
+// restoreTimeFormats are the accepted formats for restoring the state at a
+// given point in time
+var restoreTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// HistoryRestore restores a secret, or all secrets below a folder, to an
+// earlier revision. The revision can be a revision identifier, a negative
+// offset (e.g. -2) or a timestamp.
+func (s *Action) HistoryRestore(c *cli.Context) error {
+	ctx := ctxutil.WithGlobalFlags(c)
+	name := strings.TrimSuffix(c.Args().Get(0), "/")
+	revision := c.Args().Get(1)
+
+	if name == "" || revision == "" {
+		return ExitError(ExitUsage, nil, "Usage: %s history restore [--recursive] <NAME> <REVISION|TIMESTAMP>", s.Name)
+	}
+
+	ts, isTime := parseRestoreTime(revision)
+	var revs map[string]string
+	if c.Bool("recursive") {
+		var err error
+		if revs, err = s.restoreFolder(ctx, name, revision, ts, isTime); err != nil {
+			return err
+		}
+	} else {
+		rev, err := s.restoreRevision(ctx, name, revision, ts, isTime)
+		if err != nil {
+			return ExitError(ExitNotFound, err, "Failed to find revision %s of %s: %s", revision, name, err)
+		}
+		revs = make(map[string]string, 1)
+		if s.restoreUnchanged(ctx, name, rev) {
+			debug.Log("%s is unchanged at %s", name, rev)
+		} else {
+			revs[name] = rev
+		}
+	}
+
+	if len(revs) < 1 {
+		out.Printf(ctx, "Nothing to restore")
+		return nil
+	}
+
+	ctx = ctxutil.WithCommitMessage(ctx, restoreCommitMessage(name, revision, revs))
+	if err := s.Store.Restore(ctx, revs); err != nil {
+		return ExitError(ExitEncrypt, err, "Failed to restore %s: %s", name, err)
+	}
+
+	for _, n := range restoreNames(revs) {
+		if revs[n] == "" {
+			out.OKf(ctx, "Removed %s, it did not exist at revision %s", n, revision)
+			continue
+		}
+		out.OKf(ctx, "Restored %s from revision %s", n, revs[n])
+	}
+	return nil
+}
+
+// restoreFolder returns the revisions of the secrets below the folder that
+// differ from the given revision of its store. The secrets are listed from
+// that revision, so deleted secrets are restored as well. Secrets that were
+// added after it map to an empty revision, i.e. they are removed.
+func (s *Action) restoreFolder(ctx context.Context, name, revision string, ts time.Time, isTime bool) (map[string]string, error) {
+	rev, err := s.folderRevision(ctx, name, revision, ts, isTime)
+	if err != nil {
+		return nil, ExitError(ExitNotFound, err, "Failed to find revision %s of %s: %s", revision, name, err)
+	}
+	old, err := s.Store.ListRevision(ctx, name, rev)
+	if err != nil {
+		return nil, ExitError(ExitList, err, "Failed to list %s at revision %s: %s", name, rev, err)
+	}
+
+	t, err := s.Store.Tree(ctx)
+	if err != nil {
+		return nil, ExitError(ExitList, err, "failed to list store: %s", err)
+	}
+	var cur []string
+	if subtree, err := t.FindFolder(name); err == nil {
+		cur = subtree.List(tree.INF)
+	}
+	if len(old) < 1 && len(cur) < 1 {
+		return nil, ExitError(ExitNotFound, nil, "Folder %s not found", name)
+	}
+
+	revs := make(map[string]string, len(old))
+	existed := make(map[string]bool, len(old))
+	for _, n := range old {
+		existed[n] = true
+		if s.restoreUnchanged(ctx, n, rev) {
+			debug.Log("%s is unchanged at %s", n, rev)
+			continue
+		}
+		revs[n] = rev
+	}
+	// secrets of other mounts below the folder have a different history
+	mp := s.Store.MountPoint(name)
+	for _, n := range cur {
+		if !existed[n] && s.Store.MountPoint(n) == mp {
+			revs[n] = ""
+		}
+	}
+	return revs, nil
+}
+
+// folderRevision returns the revision the folder should be restored to.
+// Offsets (e.g. -2) and timestamps refer to the revisions of the folder,
+// offsets count like they do for single secrets.
+func (s *Action) folderRevision(ctx context.Context, name, revision string, ts time.Time, isTime bool) (string, error) {
+	if !isTime && !strings.HasPrefix(revision, "-") {
+		return revision, nil
+	}
+	revs, err := s.Store.ListFolderRevisions(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if isTime {
+		return revisionAt(revs, ts)
+	}
+
+	offset, err := strconv.Atoi(strings.TrimPrefix(revision, "-"))
+	if err != nil {
+		return "", err
+	}
+	rev, ok := offsetRevision(revs, offset)
+	if !ok {
+		return "", fmt.Errorf("%s only has %d revisions", name, len(revs))
+	}
+	return rev, nil
+}
+
+// restoreRevision returns the revision of the secret that should be restored
+func (s *Action) restoreRevision(ctx context.Context, name, revision string, ts time.Time, isTime bool) (string, error) {
+	if !isTime {
+		rev, err := s.parseRevision(ctx, name, revision)
+		if err != nil {
+			return "", err
+		}
+		if _, _, err := s.Store.GetRevision(ctx, name, rev); err != nil {
+			return "", fmt.Errorf("revision %s not found: %w", revision, err)
+		}
+		return rev, nil
+	}
+
+	revs, err := s.Store.ListRevisions(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return revisionAt(revs, ts)
+}
+
+// revisionAt returns the latest revision at or before the given time
+func revisionAt(revs []backend.Revision, ts time.Time) (string, error) {
+	var found *backend.Revision
+	for i, rev := range revs {
+		if rev.Date.After(ts) {
+			continue
+		}
+		if found == nil || rev.Date.After(found.Date) {
+			found = &revs[i]
+		}
+	}
+	if found == nil {
+		return "", fmt.Errorf("no revision at or before %s", ts.Format(time.RFC3339))
+	}
+	return found.Hash, nil
+}
+
+// restoreUnchanged returns true if the current content of the secret equals
+// the content at the given revision
+func (s *Action) restoreUnchanged(ctx context.Context, name, revision string) bool {
+	if !s.Store.Exists(ctx, name) {
+		return false
+	}
+	cur, err := s.Store.Get(ctxutil.WithShowParsing(ctx, false), name)
+	if err != nil {
+		return false
+	}
+	_, old, err := s.Store.GetRevision(ctx, name, revision)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(cur.Bytes(), old.Bytes())
+}
+
+func parseRestoreTime(in string) (time.Time, bool) {
+	for _, f := range restoreTimeFormats {
+		if ts, err := time.ParseInLocation(f, in, time.Local); err == nil {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func restoreCommitMessage(name, revision string, revs map[string]string) string {
+	var sb strings.Builder
+	if len(revs) == 1 {
+		for n, rev := range revs {
+			if rev == "" {
+				fmt.Fprintf(&sb, "Removed %s, it did not exist at revision %s", n, revision)
+				continue
+			}
+			fmt.Fprintf(&sb, "Restored %s from revision %s", n, rev)
+		}
+		return sb.String()
+	}
+
+	fmt.Fprintf(&sb, "Restored %s/ to revision %s\n", name, revision)
+	for _, n := range restoreNames(revs) {
+		if revs[n] == "" {
+			fmt.Fprintf(&sb, "\n%s: removed", n)
+			continue
+		}
+		fmt.Fprintf(&sb, "\n%s: %s", n, revs[n])
+	}
+	return sb.String()
+}
+
+func restoreNames(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -5,11 +5,13 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/itsonlycode/gosecret/internal/backend"
 	"github.com/itsonlycode/gosecret/internal/config"
 	"github.com/itsonlycode/gosecret/internal/out"
 	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
 	"github.com/itsonlycode/gosecret/pkg/termio"
 	"github.com/itsonlycode/gosecret/tests/gptest"
 
@@ -71,3 +73,141 @@ func TestHistory(t *testing.T) {
 		assert.NoError(t, act.History(gptest.CliCtxWithFlags(ctx, t, map[string]string{"password": "true"}, "bar")))
 	})
 }
+
+func TestHistoryRestore(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithInteractive(ctx, false)
+	ctx = backend.WithStorageBackend(ctx, backend.GitFS)
+
+	act, err := newMock(ctx, u)
+	require.NoError(t, err)
+	require.NotNil(t, act)
+	require.NoError(t, act.rcsInit(ctx, "", "foo bar", "foo.bar@example.org"))
+
+	buf := &bytes.Buffer{}
+	out.Stdout = buf
+	out.Stderr = buf
+	defer func() {
+		out.Stdout = os.Stdout
+		out.Stderr = os.Stderr
+	}()
+
+	set := func(name, pw string) {
+		sec := secrets.NewKV()
+		sec.SetPassword(pw)
+		require.NoError(t, act.Store.Set(ctx, name, sec))
+	}
+	get := func(name string) string {
+		sec, err := act.Store.Get(ctx, name)
+		require.NoError(t, err)
+		return sec.Password()
+	}
+
+	set("web/a", "a1")
+	set("web/b", "b1")
+	revs, err := act.Store.ListRevisions(ctx, "web/b")
+	require.NoError(t, err)
+	require.Len(t, revs, 1)
+	first := revs[0].Hash
+
+	set("web/a", "a2")
+	set("web/b", "b2")
+
+	t.Run("invalid args", func(t *testing.T) {
+		defer buf.Reset()
+		assert.Error(t, act.HistoryRestore(gptest.CliCtx(ctx, t)))
+		assert.Error(t, act.HistoryRestore(gptest.CliCtx(ctx, t, "web/a")))
+		assert.Error(t, act.HistoryRestore(gptest.CliCtx(ctx, t, "web/a", "1999-01-01")))
+	})
+
+	t.Run("restore single secret", func(t *testing.T) {
+		defer buf.Reset()
+		assert.NoError(t, act.HistoryRestore(gptest.CliCtx(ctx, t, "web/a", "-1")))
+		assert.Equal(t, "a1", get("web/a"))
+		assert.Equal(t, "b2", get("web/b"))
+
+		revs, err := act.Store.ListRevisions(ctx, "web/a")
+		require.NoError(t, err)
+		require.Len(t, revs, 3)
+		assert.Contains(t, revs[0].Subject, "Restored web/a from revision")
+	})
+
+	t.Run("restore subtree", func(t *testing.T) {
+		defer buf.Reset()
+		set("web/a", "a3")
+		c := gptest.CliCtxWithFlags(ctx, t, map[string]string{"recursive": "true"}, "web", first)
+		assert.NoError(t, act.HistoryRestore(c))
+		assert.Equal(t, "a1", get("web/a"))
+		assert.Equal(t, "b1", get("web/b"))
+		assert.Contains(t, buf.String(), "Restored web/b from revision "+first)
+	})
+
+	t.Run("restore subtree with deleted and added secrets", func(t *testing.T) {
+		defer buf.Reset()
+		revs, err := act.Store.ListRevisions(ctx, "web/a")
+		require.NoError(t, err)
+		before := revs[0].Hash
+
+		require.NoError(t, act.Store.Delete(ctx, "web/b"))
+		set("web/c", "c1")
+		assert.False(t, act.Store.Exists(ctx, "web/b"))
+
+		c := gptest.CliCtxWithFlags(ctx, t, map[string]string{"recursive": "true"}, "web", before)
+		assert.NoError(t, act.HistoryRestore(c))
+		assert.Equal(t, "a1", get("web/a"))
+		assert.Equal(t, "b1", get("web/b"))
+		assert.False(t, act.Store.Exists(ctx, "web/c"))
+		assert.Contains(t, buf.String(), "Restored web/b from revision "+before)
+		assert.Contains(t, buf.String(), "Removed web/c, it did not exist at revision "+before)
+
+		revs, err = act.Store.ListRevisions(ctx, "web/b")
+		require.NoError(t, err)
+		assert.Contains(t, revs[0].Subject, "Restored web/ to revision "+before)
+	})
+
+	t.Run("restore subtree by offset", func(t *testing.T) {
+		defer buf.Reset()
+		set("solo/x", "x1")
+		set("solo/x", "x2")
+		set("solo/x", "x3")
+
+		// offsets of a folder count like those of a single secret
+		for _, off := range []string{"-1", "-2", "-3"} {
+			single, err := act.parseRevision(ctx, "solo/x", off)
+			require.NoError(t, err)
+			folder, err := act.folderRevision(ctx, "solo", off, time.Time{}, false)
+			require.NoError(t, err)
+			assert.Equal(t, single, folder, off)
+		}
+		_, err := act.folderRevision(ctx, "solo", "-4", time.Time{}, false)
+		assert.Error(t, err)
+
+		c := gptest.CliCtxWithFlags(ctx, t, map[string]string{"recursive": "true"}, "solo", "-1")
+		assert.NoError(t, act.HistoryRestore(c))
+		assert.Equal(t, "x1", get("solo/x"))
+		assert.NoError(t, act.HistoryRestore(gptest.CliCtx(ctx, t, "solo/x", "-2")))
+		assert.Equal(t, "x2", get("solo/x"))
+	})
+
+	t.Run("restore subtree at timestamp", func(t *testing.T) {
+		defer buf.Reset()
+		c := gptest.CliCtxWithFlags(ctx, t, map[string]string{"recursive": "true"}, "web", time.Now().Add(time.Hour).Format(time.RFC3339))
+		assert.NoError(t, act.HistoryRestore(c))
+		assert.Contains(t, buf.String(), "Nothing to restore")
+	})
+}
+
+func TestParseRestoreTime(t *testing.T) {
+	for _, in := range []string{"2021-01-31", "2021-01-31 12:00", "2021-01-31T12:00:00Z"} {
+		_, ok := parseRestoreTime(in)
+		assert.True(t, ok, in)
+	}
+	for _, in := range []string{"-2", "HEAD~1", "2a3b4c"} {
+		_, ok := parseRestoreTime(in)
+		assert.False(t, ok, in)
+	}
+}
@@ -3,11 +3,13 @@ package action
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/itsonlycode/gosecret/internal/backend"
 	"github.com/itsonlycode/gosecret/internal/config"
 	"github.com/itsonlycode/gosecret/internal/cui"
 	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/internal/store/leaf"
 	"github.com/itsonlycode/gosecret/pkg/ctxutil"
 	"github.com/itsonlycode/gosecret/pkg/debug"
 	"github.com/itsonlycode/gosecret/pkg/fsutil"
@@ -58,6 +60,9 @@ func (s *Action) Init(c *cli.Context) error {
 	alias := c.String("store")
 
 	ctx = initParseContext(ctx, c)
+	if err := checkStorageBackend(backend.GetStorageBackend(ctx)); err != nil {
+		return err
+	}
 	out.Printf(ctx, "🍭 Initializing a new password store ...")
 
 	if name := termio.DetectName(c.Context, c); name != "" {
@@ -87,6 +92,9 @@ func initParseContext(ctx context.Context, c *cli.Context) context.Context {
 	if c.IsSet("storage") {
 		ctx = backend.WithStorageBackendString(ctx, c.String("storage"))
 	}
+	if c.Bool("encrypted-names") {
+		ctx = leaf.WithEncryptedNames(ctx, true)
+	}
 
 	if !backend.HasCryptoBackend(ctx) {
 		debug.Log("Using default Crypto Backend (GPGCLI)")
@@ -100,6 +108,16 @@ func initParseContext(ctx context.Context, c *cli.Context) context.Context {
 	return ctx
 }
 
+// checkStorageBackend refuses the storage backends that only keep the
+// secrets in memory. A store created with them would be lost as soon as
+// gosecret exits.
+func checkStorageBackend(be backend.StorageBackend) error {
+	if be.IsPersistent() {
+		return nil
+	}
+	return ExitError(ExitUsage, nil, "The storage backend %s only keeps secrets in memory and can not be used from the command line. Use one of: %s", be, strings.Join(backend.PersistentStorageBackends(), ", "))
+}
+
 func (s *Action) init(ctx context.Context, alias, path string, keys ...string) error {
 	if path == "" {
 		if alias != "" {
@@ -104,3 +104,36 @@ func TestInitParseContext(t *testing.T) {
 		})
 	}
 }
+
+func TestInitInMemoryStorage(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithInteractive(ctx, false)
+
+	act, err := newMock(ctx, u)
+	require.NoError(t, err)
+	require.NotNil(t, act)
+
+	buf := &bytes.Buffer{}
+	out.Stdout = buf
+	out.Stderr = buf
+	defer func() {
+		out.Stdout = os.Stdout
+		out.Stderr = os.Stderr
+	}()
+
+	for _, storage := range []string{"inmem", "overlay"} {
+		flags := map[string]string{"storage": storage}
+		for name, err := range map[string]error{
+			"init":  act.Init(gptest.CliCtxWithFlags(ctx, t, flags, "foo.bar@example.org")),
+			"clone": act.Clone(gptest.CliCtxWithFlags(ctx, t, flags, u.StoreDir(""), "sub")),
+		} {
+			require.Error(t, err, name)
+			assert.Contains(t, err.Error(), "only keeps secrets in memory", name)
+		}
+	}
+	assert.NotContains(t, fmt.Sprintf("%v", backend.PersistentStorageBackends()), "inmem")
+}
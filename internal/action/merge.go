@@ -3,18 +3,49 @@ package action
 import (
 	"bytes"
 	"fmt"
-	"time"
+	"strings"
 
 	"github.com/itsonlycode/gosecret/internal/audit"
+	"github.com/itsonlycode/gosecret/internal/diff"
 	"github.com/itsonlycode/gosecret/internal/editor"
 	"github.com/itsonlycode/gosecret/internal/out"
-	"github.com/itsonlycode/gosecret/internal/queue"
 	"github.com/itsonlycode/gosecret/pkg/ctxutil"
 	"github.com/itsonlycode/gosecret/pkg/debug"
+	"github.com/itsonlycode/gosecret/pkg/gosecret"
 	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets/secparse"
 	"github.com/urfave/cli/v2"
 )
 
+const (
+	// mergePasswordFirst picks the password of the first entry that has one.
+	// The destination is always considered first.
+	mergePasswordFirst = "first"
+	// mergePasswordLast picks the password of the last entry that has one
+	mergePasswordLast = "last"
+	// mergePasswordLongest picks the longest password
+	mergePasswordLongest = "longest"
+	// mergePasswordEdit lets the user pick the password in an editor if the
+	// entries have different passwords
+	mergePasswordEdit = "edit"
+
+	// mergeConflictPrefix marks lines that are added to the editor content and
+	// will be removed before saving
+	mergeConflictPrefix = "# gosecret: "
+)
+
+// mergeSource is a parsed secret that should be merged
+type mergeSource struct {
+	name string
+	sec  gosecret.Secret
+}
+
+// mergeCandidate is a password found in one of the merged entries
+type mergeCandidate struct {
+	name     string
+	password string
+}
+
 // Merge implements the merge subcommand that allows merging multiple entries.
 func (s *Action) Merge(c *cli.Context) error {
 	ctx := ctxutil.WithGlobalFlags(c)
@@ -28,12 +59,18 @@ func (s *Action) Merge(c *cli.Context) error {
 		return ExitError(ExitUsage, nil, "usage: %s merge <to> <from> [<from>]", s.Name)
 	}
 
-	ed := editor.Path(c)
-	if err := editor.Check(ctx, ed); err != nil {
-		out.Warningf(ctx, "Failed to check editor config: %s", err)
+	strategy := c.String("password")
+	if strategy == "" {
+		strategy = mergePasswordEdit
+	}
+	switch strategy {
+	case mergePasswordFirst, mergePasswordLast, mergePasswordLongest, mergePasswordEdit:
+	default:
+		return ExitError(ExitUsage, nil, "unknown password strategy %q. Use one of %s, %s, %s or %s", strategy, mergePasswordFirst, mergePasswordLast, mergePasswordLongest, mergePasswordEdit)
 	}
 
-	content := &bytes.Buffer{}
+	var oldContent []byte
+	srcs := make([]mergeSource, 0, c.Args().Len())
 	for _, k := range c.Args().Slice() {
 		if !s.Store.Exists(ctx, k) {
 			continue
@@ -42,33 +79,59 @@ func (s *Action) Merge(c *cli.Context) error {
 		if err != nil {
 			return ExitError(ExitDecrypt, err, "failed to decrypt: %s: %s", k, err)
 		}
-		_, err = content.WriteString("\n# Secret: " + k + "\n")
-		if err != nil {
-			return ExitError(ExitUnknown, err, "failed to write: %s", err)
+		if k == to {
+			oldContent = sec.Bytes()
 		}
-		_, err = content.Write(sec.Bytes())
+		parsed, err := secparse.Parse(sec.Bytes())
 		if err != nil {
-			return ExitError(ExitUnknown, err, "failed to write: %s", err)
+			debug.Log("failed to parse %s: %s", k, err)
 		}
+		srcs = append(srcs, mergeSource{name: k, sec: parsed})
+	}
+
+	nSec, candidates := mergeSecrets(srcs)
+	pw, conflict := mergePassword(candidates, strategy)
+	nSec.SetPassword(pw)
+	newContent := nSec.Bytes()
+
+	if conflict && (c.Bool("force") || c.Bool("dry-run")) {
+		out.Warningf(ctx, "Entries have different passwords. Using the first one.")
 	}
 
-	newContent := content.Bytes()
-	if !c.Bool("force") {
-		var err error
-		// invoke the editor to let the user edit the content
-		newContent, err = editor.Invoke(ctx, ed, content.Bytes())
+	if c.Bool("dry-run") {
+		out.Printf(ctx, "Merging %s into %s would result in:", strings.Join(from, ", "), to)
+		for _, line := range diff.Lines(splitLines(oldContent), splitLines(newContent)) {
+			out.Print(ctx, out.Secret(line))
+		}
+		return nil
+	}
+
+	if conflict && !c.Bool("force") {
+		ed := editor.Path(c)
+		if err := editor.Check(ctx, ed); err != nil {
+			out.Warningf(ctx, "Failed to check editor config: %s", err)
+		}
+
+		content := mergeConflictContent(newContent, candidates)
+		// invoke the editor to let the user resolve the conflicts
+		edited, err := editor.Invoke(ctx, ed, content)
 		if err != nil {
 			return ExitError(ExitUnknown, err, "failed to invoke editor: %s", err)
 		}
 
-		// If content is equal, nothing changed, exiting
-		if bytes.Equal(content.Bytes(), newContent) {
-			return nil
+		// If content is equal, the conflicts were not resolved. Use the
+		// first password, like --force does.
+		if bytes.Equal(content, edited) {
+			out.Warningf(ctx, "Entries have different passwords. Using the first one.")
+		} else {
+			newContent = mergeStripConflicts(edited)
+			nSec, err = secparse.Parse(newContent)
+			if err != nil {
+				debug.Log("failed to parse merged secret: %s", err)
+			}
 		}
 	}
 
-	nSec := secrets.ParsePlain(newContent)
-
 	// if the secret has a password, we check it's strength
 	if pw := nSec.Password(); pw != "" && !c.Bool("force") {
 		audit.Single(ctx, pw)
@@ -83,15 +146,6 @@ func (s *Action) Merge(c *cli.Context) error {
 		return nil
 	}
 
-	// wait until the previous commit is done
-	// TODO: This wouldn't be necessary if we could handle merging and deleting
-	// in a single commit, but then we'd need to expose additional implementation
-	// details of the underlying VCS. Or create some kind of transaction on top
-	// of the Git wrapper.
-	if err := queue.GetQueue(ctx).Idle(time.Minute); err != nil {
-		return err
-	}
-
 	for _, old := range from {
 		if !s.Store.Exists(ctx, old) {
 			continue
@@ -103,3 +157,119 @@ func (s *Action) Merge(c *cli.Context) error {
 	}
 	return nil
 }
+
+// mergeSecrets merges the keys and bodies of all sources into a new secret.
+// Identical values are only added once, different values for the same key
+// are kept as multiple values. It does not set the password, but returns all
+// distinct passwords found.
+func mergeSecrets(srcs []mergeSource) (gosecret.Secret, []mergeCandidate) {
+	nSec := secrets.NewKV()
+	candidates := make([]mergeCandidate, 0, len(srcs))
+	seenPw := make(map[string]bool, len(srcs))
+	bodies := make([]string, 0, len(srcs))
+	seenBody := make(map[string]bool, len(srcs))
+
+	for _, src := range srcs {
+		if pw := src.sec.Password(); pw != "" && !seenPw[pw] {
+			seenPw[pw] = true
+			candidates = append(candidates, mergeCandidate{name: src.name, password: pw})
+		}
+
+		for _, key := range src.sec.Keys() {
+			values, _ := src.sec.Values(key)
+			for _, v := range values {
+				if have, found := nSec.Values(key); found && mergeHasValue(have, v) {
+					continue
+				}
+				if err := nSec.Add(key, v); err != nil {
+					debug.Log("failed to add %s from %s: %s", key, src.name, err)
+				}
+			}
+		}
+
+		body := strings.TrimSpace(src.sec.Body())
+		if body == "" || seenBody[body] {
+			continue
+		}
+		seenBody[body] = true
+		bodies = append(bodies, body)
+	}
+
+	if len(bodies) > 0 {
+		_, _ = nSec.Write([]byte(strings.Join(bodies, "\n") + "\n"))
+	}
+
+	return nSec, candidates
+}
+
+// mergePassword selects one of the candidates according to the given
+// strategy. It returns true if there are conflicting passwords that need to
+// be resolved by the user.
+func mergePassword(candidates []mergeCandidate, strategy string) (string, bool) {
+	if len(candidates) < 1 {
+		return "", false
+	}
+
+	switch strategy {
+	case mergePasswordLast:
+		return candidates[len(candidates)-1].password, false
+	case mergePasswordLongest:
+		pw := candidates[0].password
+		for _, c := range candidates[1:] {
+			if len(c.password) > len(pw) {
+				pw = c.password
+			}
+		}
+		return pw, false
+	case mergePasswordEdit:
+		return candidates[0].password, len(candidates) > 1
+	default:
+		return candidates[0].password, false
+	}
+}
+
+// mergeConflictContent appends a list of all password candidates to the
+// content so the user can pick one in the editor
+func mergeConflictContent(content []byte, candidates []mergeCandidate) []byte {
+	buf := &bytes.Buffer{}
+	_, _ = buf.Write(content)
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		_, _ = buf.WriteString("\n")
+	}
+	_, _ = buf.WriteString(mergeConflictPrefix + "The merged entries have different passwords.\n")
+	_, _ = buf.WriteString(mergeConflictPrefix + "Put the one you want to keep on the first line. Lines starting with\n")
+	_, _ = buf.WriteString(mergeConflictPrefix + "'" + strings.TrimSpace(mergeConflictPrefix) + "' will be removed.\n")
+	for _, c := range candidates {
+		_, _ = buf.WriteString(mergeConflictPrefix + c.name + " = " + c.password + "\n")
+	}
+	return buf.Bytes()
+}
+
+// mergeStripConflicts removes any lines added by mergeConflictContent
+func mergeStripConflicts(content []byte) []byte {
+	lines := strings.SplitAfter(string(content), "\n")
+	buf := &bytes.Buffer{}
+	for _, line := range lines {
+		if strings.HasPrefix(line, mergeConflictPrefix) {
+			continue
+		}
+		_, _ = buf.WriteString(line)
+	}
+	return buf.Bytes()
+}
+
+func splitLines(buf []byte) []string {
+	if len(buf) < 1 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")
+}
+
+func mergeHasValue(haystack []string, needle string) bool {
+	for _, v := range haystack {
+		if v == needle {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+	"github.com/itsonlycode/gosecret/tests/gptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithInteractive(ctx, false)
+
+	act, err := newMock(ctx, u)
+	require.NoError(t, err)
+	require.NotNil(t, act)
+
+	buf := &bytes.Buffer{}
+	out.Stdout = buf
+	out.Stderr = buf
+	defer func() {
+		out.Stdout = os.Stdout
+		out.Stderr = os.Stderr
+	}()
+
+	setup := func(t *testing.T) {
+		t.Helper()
+
+		sec := secrets.NewKV()
+		sec.SetPassword("short")
+		require.NoError(t, sec.Set("user", "alice"))
+		require.NoError(t, sec.Set("url", "https://example.org"))
+		_, _ = sec.Write([]byte("first note\n"))
+		require.NoError(t, act.Store.Set(ctx, "merge/a", sec))
+
+		sec = secrets.NewKV()
+		sec.SetPassword("much-longer")
+		require.NoError(t, sec.Set("user", "bob"))
+		require.NoError(t, sec.Set("url", "https://example.org"))
+		_, _ = sec.Write([]byte("first note\n"))
+		require.NoError(t, act.Store.Set(ctx, "merge/b", sec))
+	}
+
+	t.Run("invalid args", func(t *testing.T) {
+		defer buf.Reset()
+		assert.Error(t, act.Merge(gptest.CliCtx(ctx, t)))
+		assert.Error(t, act.Merge(gptest.CliCtx(ctx, t, "merge/a")))
+		assert.Error(t, act.Merge(gptest.CliCtxWithFlags(ctx, t, map[string]string{"password": "random"}, "merge/a", "merge/b")))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		defer buf.Reset()
+		setup(t)
+
+		c := gptest.CliCtxWithFlags(ctx, t, map[string]string{"dry-run": "true", "password": "longest"}, "merge/a", "merge/b")
+		assert.NoError(t, act.Merge(c))
+		assert.Contains(t, buf.String(), "- short")
+		assert.Contains(t, buf.String(), "+ much-longer")
+		assert.Contains(t, buf.String(), "+ user: bob")
+
+		sec, err := act.Store.Get(ctx, "merge/a")
+		require.NoError(t, err)
+		assert.Equal(t, "short", sec.Password())
+		assert.True(t, act.Store.Exists(ctx, "merge/b"))
+	})
+
+	t.Run("merge with longest password", func(t *testing.T) {
+		defer buf.Reset()
+		setup(t)
+
+		c := gptest.CliCtxWithFlags(ctx, t, map[string]string{"delete": "true", "password": "longest"}, "merge/a", "merge/b")
+		assert.NoError(t, act.Merge(c))
+		assert.False(t, act.Store.Exists(ctx, "merge/b"))
+
+		sec, err := act.Store.Get(ctx, "merge/a")
+		require.NoError(t, err)
+		assert.Equal(t, "much-longer", sec.Password())
+		users, _ := sec.Values("user")
+		assert.Equal(t, []string{"alice", "bob"}, users)
+		urls, _ := sec.Values("url")
+		assert.Equal(t, []string{"https://example.org"}, urls)
+		assert.Equal(t, "first note\n", sec.Body())
+	})
+
+	t.Run("conflicts are resolved with force", func(t *testing.T) {
+		defer buf.Reset()
+		setup(t)
+
+		c := gptest.CliCtxWithFlags(ctx, t, map[string]string{"force": "true"}, "merge/c", "merge/b", "merge/a")
+		assert.NoError(t, act.Merge(c))
+		assert.Contains(t, buf.String(), "different passwords")
+
+		sec, err := act.Store.Get(ctx, "merge/c")
+		require.NoError(t, err)
+		assert.Equal(t, "much-longer", sec.Password())
+		assert.True(t, act.Store.Exists(ctx, "merge/a"))
+	})
+
+	t.Run("dry run does not invoke the editor", func(t *testing.T) {
+		defer buf.Reset()
+		setup(t)
+
+		// the editor needs a terminal, so it would fail
+		c := gptest.CliCtxWithFlags(ctx, t, map[string]string{"dry-run": "true"}, "merge/a", "merge/b")
+		assert.NoError(t, act.Merge(c))
+		assert.Contains(t, buf.String(), "different passwords")
+		assert.Contains(t, buf.String(), "+ user: bob")
+
+		sec, err := act.Store.Get(ctx, "merge/a")
+		require.NoError(t, err)
+		assert.Equal(t, "short", sec.Password())
+	})
+
+	t.Run("unchanged editor content uses the first password", func(t *testing.T) {
+		defer buf.Reset()
+		setup(t)
+
+		c := gptest.CliCtxWithFlags(ctxutil.WithTerminal(ctx, true), t, map[string]string{"editor": "true"}, "merge/d", "merge/b", "merge/a")
+		assert.NoError(t, act.Merge(c))
+		assert.Contains(t, buf.String(), "different passwords")
+
+		sec, err := act.Store.Get(ctx, "merge/d")
+		require.NoError(t, err)
+		assert.Equal(t, "much-longer", sec.Password())
+		users, _ := sec.Values("user")
+		assert.Equal(t, []string{"bob", "alice"}, users)
+	})
+}
+
+func TestMergePassword(t *testing.T) {
+	candidates := []mergeCandidate{
+		{name: "a", password: "foo"},
+		{name: "b", password: "foobar"},
+		{name: "c", password: "baz"},
+	}
+
+	for _, tc := range []struct {
+		strategy string
+		pw       string
+		conflict bool
+	}{
+		{strategy: mergePasswordFirst, pw: "foo"},
+		{strategy: mergePasswordLast, pw: "baz"},
+		{strategy: mergePasswordLongest, pw: "foobar"},
+		{strategy: mergePasswordEdit, pw: "foo", conflict: true},
+	} {
+		pw, conflict := mergePassword(candidates, tc.strategy)
+		assert.Equal(t, tc.pw, pw, tc.strategy)
+		assert.Equal(t, tc.conflict, conflict, tc.strategy)
+	}
+
+	pw, conflict := mergePassword(candidates[:1], mergePasswordEdit)
+	assert.Equal(t, "foo", pw)
+	assert.False(t, conflict)
+}
+
+func TestMergeStripConflicts(t *testing.T) {
+	content := mergeConflictContent([]byte("foo\nuser: bar\n"), []mergeCandidate{{name: "a", password: "foo"}, {name: "b", password: "bar"}})
+	assert.Contains(t, string(content), mergeConflictPrefix+"b = bar")
+	assert.Equal(t, "foo\nuser: bar\n", string(mergeStripConflicts(content)))
+}
@@ -1,11 +1,14 @@
 package action
 
 import (
+	"context"
 	"fmt"
 	"sort"
+	"strings"
 
 	"errors"
 
+	"github.com/itsonlycode/gosecret/internal/backend"
 	"github.com/itsonlycode/gosecret/internal/config"
 	"github.com/itsonlycode/gosecret/internal/out"
 	"github.com/itsonlycode/gosecret/internal/store"
@@ -13,6 +16,7 @@ import (
 	"github.com/itsonlycode/gosecret/internal/tree"
 	"github.com/itsonlycode/gosecret/pkg/ctxutil"
 	"github.com/itsonlycode/gosecret/pkg/debug"
+	"github.com/itsonlycode/gosecret/pkg/fsutil"
 
 	"github.com/fatih/color"
 	"github.com/urfave/cli/v2"
@@ -45,12 +49,22 @@ func (s *Action) MountsPrint(c *cli.Context) error {
 		return nil
 	}
 
-	root := tree.New(color.GreenString(fmt.Sprintf("gosecret (%s)", s.Store.Path())))
+	rootPath := s.Store.Path()
+	if st := s.syncState(ctx, ""); st != "" {
+		rootPath += ", " + st
+	}
+	root := tree.New(color.GreenString(fmt.Sprintf("gosecret (%s)", rootPath)))
 	mounts := s.Store.Mounts()
 	mps := s.Store.MountPoints()
 	sort.Sort(store.ByPathLen(mps))
 	for _, alias := range mps {
 		path := mounts[alias]
+		if opts := s.Store.MountConfig(alias).Options(); len(opts) > 0 {
+			path += ", " + strings.Join(opts, ", ")
+		}
+		if st := s.syncState(ctx, alias); st != "" {
+			path += ", " + st
+		}
 		if err := root.AddMount(alias, path); err != nil {
 			out.Errorf(ctx, "Failed to add mount to tree: %s", err)
 		}
@@ -61,6 +75,23 @@ func (s *Action) MountsPrint(c *cli.Context) error {
 	return nil
 }
 
+// syncState describes the state of the sync of a mount with its remote. It's
+// empty unless auto-sync is enabled.
+func (s *Action) syncState(ctx context.Context, alias string) string {
+	if ctxutil.GetSyncInterval(ctx) <= 0 {
+		return ""
+	}
+	if alias != "" && s.Store.MountConfig(alias).NoAutoSync {
+		return "auto-sync disabled"
+	}
+	sub, err := s.Store.GetSubStore(alias)
+	if err != nil || sub == nil {
+		debug.Log("failed to get sub store %q: %s", alias, err)
+		return ""
+	}
+	return sub.SyncState().String()
+}
+
 // MountsComplete will print a list of existings mount points for bash
 // completion
 func (s *Action) MountsComplete(*cli.Context) {
@@ -99,6 +130,10 @@ func (s *Action) MountAdd(c *cli.Context) error {
 		}
 	}
 
+	if err := s.Store.SetMountOptions(alias, mountOptions(c)); err != nil {
+		return ExitError(ExitMount, err, "failed to set options of mount %q: %s", alias, err)
+	}
+
 	if err := s.cfg.Save(); err != nil {
 		return ExitError(ExitConfig, err, "failed to save config: %s", err)
 	}
@@ -106,3 +141,108 @@ func (s *Action) MountAdd(c *cli.Context) error {
 	out.Printf(ctx, "Mounted %s as %s", alias, localPath)
 	return nil
 }
+
+// mountOptions returns the mount options set by the flags of mounts add
+func mountOptions(c *cli.Context) config.MountConfig {
+	return config.MountConfig{
+		ReadOnly:       c.Bool("read-only"),
+		NoAutoSync:     c.Bool("no-autosync"),
+		RequireSigning: c.Bool("require-signing"),
+		Writers:        c.StringSlice("writer"),
+	}
+}
+
+// MountsSync clones, updates and removes mounts to match the mounts manifest
+// of the root store
+func (s *Action) MountsSync(c *cli.Context) error {
+	ctx := ctxutil.WithGlobalFlags(c)
+	m, err := s.Store.MountsManifest(ctx)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ExitError(ExitNotFound, err, "The root store has no %s", root.MountsManifestFile)
+		}
+		return ExitError(ExitConfig, err, "%s", err)
+	}
+
+	changes := s.Store.PlanMounts(m)
+	if c.Bool("dry-run") {
+		for _, mc := range changes {
+			out.Printf(ctx, "%s %s", mc.Kind, mc.Alias)
+		}
+		return nil
+	}
+
+	for _, mc := range changes {
+		if err := s.mountsSyncOne(ctx, mc); err != nil {
+			return err
+		}
+	}
+
+	if err := s.cfg.Save(); err != nil {
+		return ExitError(ExitConfig, err, "failed to save config: %s", err)
+	}
+	out.OKf(ctx, "Mounts match %s", root.MountsManifestFile)
+	return nil
+}
+
+func (s *Action) mountsSyncOne(ctx context.Context, mc root.MountChange) error {
+	switch mc.Kind {
+	case root.MountChangeRemove:
+		path := s.Store.Mounts()[mc.Alias]
+		if err := s.Store.RemoveMount(ctx, mc.Alias); err != nil {
+			return ExitError(ExitMount, err, "failed to remove mount %s: %s", mc.Alias, err)
+		}
+		out.Printf(ctx, "Removed mount %s. Its files are kept at %s", mc.Alias, path)
+		return nil
+	case root.MountChangeUpdate:
+		path := s.Store.MountConfig(mc.Alias).Path
+		if mc.Mount.Path != "" && fsutil.CleanPath(mc.Mount.Path) != fsutil.CleanPath(path) {
+			out.Warningf(ctx, "Mount %s is at %s but %s declares %s. Remount it to move it.", mc.Alias, path, root.MountsManifestFile, mc.Mount.Path)
+		}
+		if err := s.Store.SetMountOptions(mc.Alias, mc.Mount.MountConfig(path)); err != nil {
+			return ExitError(ExitMount, err, "failed to update mount %s: %s", mc.Alias, err)
+		}
+		if err := s.Store.RCSPull(ctx, mc.Alias, "", ""); err != nil && !errors.Is(err, store.ErrGitNotInit) && !errors.Is(err, store.ErrGitNoRemote) {
+			out.Errorf(ctx, "Failed to pull %s: %s", mc.Alias, err)
+		}
+		out.Printf(ctx, "Updated mount %s", mc.Alias)
+		return nil
+	}
+
+	path := config.PwStoreDir(mc.Alias)
+	if mc.Mount.Path != "" {
+		path = fsutil.CleanPath(mc.Mount.Path)
+	}
+
+	ctx = mc.Mount.WithBackends(ctx)
+	cloned := false
+	if !fsutil.IsDir(path) {
+		if mc.Mount.Remote == "" {
+			return ExitError(ExitMount, nil, "mount %s has no remote and %s does not exist", mc.Alias, path)
+		}
+		out.Noticef(ctx, "Cloning git repository %q to %q ...", mc.Mount.Remote, path)
+		if _, err := backend.Clone(ctx, storageBackendOrDefault(ctx), mc.Mount.Remote, path); err != nil {
+			return ExitError(ExitGit, err, "failed to clone repo %q to %q: %s", mc.Mount.Remote, path, err)
+		}
+		cloned = true
+	}
+
+	if err := s.Store.AddMount(ctx, mc.Alias, path); err != nil {
+		return ExitError(ExitMount, err, "failed to add mount %q to %q: %s", mc.Alias, path, err)
+	}
+	if err := s.Store.SetMountOptions(mc.Alias, mc.Mount.MountConfig(path)); err != nil {
+		return ExitError(ExitMount, err, "failed to set options of mount %q: %s", mc.Alias, err)
+	}
+
+	if cloned {
+		username, email, err := s.cloneGetGitConfig(ctx, mc.Alias)
+		if err != nil {
+			return err
+		}
+		if err := s.Store.RCSInitConfig(ctx, mc.Alias, username, email); err != nil {
+			out.Errorf(ctx, "Failed to configure git: %s", err)
+		}
+	}
+	out.Printf(ctx, "Mounted %s as %s", mc.Alias, path)
+	return nil
+}
@@ -8,7 +8,9 @@ import (
 	"testing"
 
 	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/internal/store/root"
 	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
 	"github.com/itsonlycode/gosecret/tests/gptest"
 
 	"github.com/stretchr/testify/assert"
@@ -70,8 +72,82 @@ func TestMounts(t *testing.T) {
 		assert.NoError(t, act.Store.AddMount(ctx, "mount2", u.StoreDir("mount2")))
 	})
 
+	t.Run("add read-only mount", func(t *testing.T) {
+		defer buf.Reset()
+		assert.NoError(t, u.InitStore("team"))
+		c := gptest.CliCtxWithFlags(ctx, t, map[string]string{"read-only": "true"}, "team", u.StoreDir("team"))
+		assert.NoError(t, act.MountAdd(c))
+		assert.True(t, act.cfg.Mounts["team"].ReadOnly)
+		assert.Error(t, act.Store.Set(ctx, "team/foo", secrets.ParsePlain([]byte("bar"))))
+	})
+
 	t.Run("print mounts", func(t *testing.T) {
 		defer buf.Reset()
 		assert.NoError(t, act.MountsPrint(gptest.CliCtx(ctx, t)))
+		assert.Contains(t, buf.String(), "team ("+u.StoreDir("team")+", read-only)")
+	})
+}
+
+func TestMountsSync(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithInteractive(ctx, false)
+	act, err := newMock(ctx, u)
+	require.NoError(t, err)
+	require.NotNil(t, act)
+
+	buf := &bytes.Buffer{}
+	out.Stdout = buf
+	out.Stderr = buf
+	stdout = buf
+	defer func() {
+		out.Stdout = os.Stdout
+		out.Stderr = os.Stderr
+		stdout = os.Stdout
+	}()
+
+	t.Run("no manifest", func(t *testing.T) {
+		defer buf.Reset()
+		assert.Error(t, act.MountsSync(gptest.CliCtx(ctx, t)))
+	})
+
+	repo := aGitRepo(ctx, u, t, "team-repo")
+	manifest := filepath.Join(u.StoreDir(""), root.MountsManifestFile)
+	require.NoError(t, os.WriteFile(manifest, []byte("version: 1\nmounts:\n  team:\n    remote: "+repo+"\n    path: "+filepath.Join(u.Dir, "team")+"\n    readonly: true\n"), 0600))
+	buf.Reset()
+
+	t.Run("dry run", func(t *testing.T) {
+		defer buf.Reset()
+		c := gptest.CliCtxWithFlags(ctx, t, map[string]string{"dry-run": "true"})
+		assert.NoError(t, act.MountsSync(c))
+		assert.Equal(t, "add team\n", buf.String())
+		assert.Len(t, act.Store.Mounts(), 0)
+	})
+
+	t.Run("clone and mount", func(t *testing.T) {
+		defer buf.Reset()
+		assert.NoError(t, act.MountsSync(gptest.CliCtx(ctx, t)))
+		assert.Equal(t, filepath.Join(u.Dir, "team"), act.Store.Mounts()["team"])
+		mc := act.cfg.Mounts["team"]
+		assert.True(t, mc.ReadOnly)
+		assert.True(t, mc.Managed)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		defer buf.Reset()
+		require.NoError(t, os.WriteFile(manifest, []byte("version: 1\nmounts:\n  team:\n    remote: "+repo+"\n    path: "+filepath.Join(u.Dir, "team")+"\n"), 0600))
+		assert.NoError(t, act.MountsSync(gptest.CliCtx(ctx, t)))
+		assert.False(t, act.cfg.Mounts["team"].ReadOnly)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		defer buf.Reset()
+		require.NoError(t, os.WriteFile(manifest, []byte("version: 1\nmounts: {}\n"), 0600))
+		assert.NoError(t, act.MountsSync(gptest.CliCtx(ctx, t)))
+		assert.Len(t, act.Store.Mounts(), 0)
+		assert.DirExists(t, filepath.Join(u.Dir, "team"))
 	})
 }
@@ -3,12 +3,18 @@ package action
 import (
 	"context"
 	"fmt"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/internal/qrdecode"
 	"github.com/itsonlycode/gosecret/internal/store"
 	"github.com/itsonlycode/gosecret/pkg/clipboard"
 	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/fsutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
 	"github.com/itsonlycode/gosecret/pkg/otp"
 	"github.com/itsonlycode/gosecret/pkg/termio"
 
@@ -16,9 +22,9 @@ import (
 )
 
 const (
-	// we might want to replace this with the currently un-exported step value
-	// from twofactor.FromURL if it gets ever exported
-	otpPeriod = 30
+	// otpNextThreshold is the number of seconds before the end of the period
+	// when --next switches to the upcoming token
+	otpNextThreshold = 5
 )
 
 // OTP implements OTP token handling for TOTP and HOTP
@@ -32,25 +38,44 @@ func (s *Action) OTP(c *cli.Context) error {
 	qrf := c.String("qr")
 	clip := c.Bool("clip")
 	pw := c.Bool("password")
+	next := c.Bool("next")
 
-	return s.otp(ctx, name, qrf, clip, pw, true)
+	return s.otp(ctx, name, qrf, clip, pw, next, true)
 }
 
-func (s *Action) otp(ctx context.Context, name, qrf string, clip, pw, recurse bool) error {
+func (s *Action) otp(ctx context.Context, name, qrf string, clip, pw, next, recurse bool) error {
 	sec, err := s.Store.Get(ctx, name)
 	if err != nil {
-		return s.otpHandleError(ctx, name, qrf, clip, pw, recurse, err)
+		return s.otpHandleError(ctx, name, qrf, clip, pw, next, recurse, err)
 	}
-	two, label, err := otp.Calculate(name, sec)
+
+	now := time.Now()
+	key, token, err := s.otpToken(ctx, name, sec, now)
 	if err != nil {
-		return ExitError(ExitUnknown, err, "No OTP entry found for %s: %s", name, err)
+		return err
 	}
-	token := two.OTP()
 
-	now := time.Now()
-	expiresAt := now.Add(otpPeriod * time.Second).Truncate(otpPeriod * time.Second)
+	if key.Type == otp.TypeHOTP {
+		return s.otpOutput(ctx, name, key, token, qrf, clip)
+	}
+
+	expiresAt := key.Expires(now)
 	secondsLeft := int(time.Until(expiresAt).Seconds())
 
+	// close to the end of the period the current token might expire before
+	// it's used, so we offer the next one
+	if next && secondsLeft < otpNextThreshold {
+		nextToken := key.Next(now)
+		if pw || clip || qrf != "" || out.OutputIsRedirected() {
+			// the next token is valid until the end of the following period
+			token = nextToken
+			expiresAt = key.Expires(expiresAt)
+			secondsLeft = int(time.Until(expiresAt).Seconds())
+		} else {
+			out.Printf(ctx, "Next token (valid in %ds): %s", secondsLeft, nextToken)
+		}
+	}
+
 	if clip {
 		if err := clipboard.CopyTo(ctx, fmt.Sprintf("token for %s", name), []byte(token), s.cfg.ClipTimeout); err != nil {
 			return ExitError(ExitIO, err, "failed to copy to clipboard: %s", err)
@@ -88,7 +113,7 @@ func (s *Action) otp(ctx context.Context, name, qrf string, clip, pw, recurse bo
 	}
 
 	if qrf != "" {
-		return otp.WriteQRFile(two, label, qrf)
+		return key.WriteQRFile(qrf)
 	}
 
 	// we need to return if we are skipping, to avoid a deadlock in select
@@ -105,16 +130,165 @@ func (s *Action) otp(ctx context.Context, name, qrf string, clip, pw, recurse bo
 	}
 }
 
-func (s *Action) otpHandleError(ctx context.Context, name, qrf string, clip, pw, recurse bool, err error) error {
+// otpToken returns the OTP key of the secret and its current token
+func (s *Action) otpToken(ctx context.Context, name string, sec gosecret.Secret, now time.Time) (*otp.Key, string, error) {
+	key, err := otp.Get(name, sec)
+	if err != nil {
+		return nil, "", ExitError(ExitUnknown, err, "No OTP entry found for %s: %s", name, err)
+	}
+	token := key.Code(now)
+	if key.Type != otp.TypeHOTP {
+		return key, token, nil
+	}
+
+	// HOTP tokens are only valid once, so we need to persist the new counter
+	key.Counter++
+	nSec, err := key.Update(sec)
+	if err != nil {
+		return nil, "", ExitError(ExitUnknown, err, "failed to update HOTP counter for %s: %s", name, err)
+	}
+	if err := s.Store.Set(ctxutil.WithCommitMessage(ctx, "Increment HOTP counter"), name, nSec); err != nil {
+		return nil, "", ExitError(ExitEncrypt, err, "failed to save HOTP counter for %s: %s", name, err)
+	}
+	return key, token, nil
+}
+
+// otpOutput displays a token that does not expire
+func (s *Action) otpOutput(ctx context.Context, name string, key *otp.Key, token, qrf string, clip bool) error {
+	if clip {
+		if err := clipboard.CopyTo(ctx, fmt.Sprintf("token for %s", name), []byte(token), s.cfg.ClipTimeout); err != nil {
+			return ExitError(ExitIO, err, "failed to copy to clipboard: %s", err)
+		}
+	}
+
+	out.Printf(ctx, "%s", token)
+
+	if qrf != "" {
+		return key.WriteQRFile(qrf)
+	}
+	return nil
+}
+
+func (s *Action) otpHandleError(ctx context.Context, name, qrf string, clip, pw, next, recurse bool, err error) error {
 	if err != store.ErrNotFound || !recurse || !ctxutil.IsTerminal(ctx) {
 		return ExitError(ExitUnknown, err, "failed to retrieve secret %q: %s", name, err)
 	}
 	out.Printf(ctx, "Entry %q not found. Starting search...", name)
 	cb := func(ctx context.Context, c *cli.Context, name string, recurse bool) error {
-		return s.otp(ctx, name, qrf, clip, pw, false)
+		return s.otp(ctx, name, qrf, clip, pw, next, false)
 	}
 	if err := s.find(ctx, nil, name, cb, false); err != nil {
 		return ExitError(ExitNotFound, err, "%s", err)
 	}
 	return nil
 }
+
+// OTPAdd enrolls OTP secrets from a QR code image or from an otpauth:// or
+// otpauth-migration:// URL. Migration URLs can contain many accounts, those
+// are stored below the given name.
+func (s *Action) OTPAdd(c *cli.Context) error {
+	ctx := ctxutil.WithGlobalFlags(c)
+	name := c.Args().First()
+	if name == "" {
+		return ExitError(ExitUsage, nil, "Usage: %s otp add <NAME> --image FILE | --url URL", s.Name)
+	}
+
+	in := c.String("url")
+	if fn := c.String("image"); fn != "" {
+		txt, err := qrdecode.DecodeFile(fn)
+		if err != nil {
+			return ExitError(ExitIO, err, "failed to read QR code from %s: %s", fn, err)
+		}
+		in = txt
+	}
+	if in == "" {
+		return ExitError(ExitUsage, nil, "Usage: %s otp add <NAME> --image FILE | --url URL", s.Name)
+	}
+
+	keys, err := otpParseKeys(in)
+	if err != nil {
+		return ExitError(ExitUsage, err, "invalid OTP URL: %s", err)
+	}
+
+	if len(keys) == 1 {
+		return s.otpAdd(ctx, name, keys[0], c.Bool("force"))
+	}
+
+	out.Printf(ctx, "Found %d accounts", len(keys))
+	for _, key := range keys {
+		if err := s.otpAdd(ctx, path.Join(name, otpEntryName(key)), key, c.Bool("force")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// otpAdd stores the key in a new or existing secret. Existing OTP entries are
+// only replaced with force.
+func (s *Action) otpAdd(ctx context.Context, name string, key *otp.Key, force bool) error {
+	now := time.Now()
+	token := key.Code(now)
+
+	var sec gosecret.Secret = secrets.NewKV()
+	if s.Store.Exists(ctx, name) {
+		var err error
+		sec, err = s.Store.Get(ctx, name)
+		if err != nil {
+			return ExitError(ExitDecrypt, err, "failed to read secret %s: %s", name, err)
+		}
+		if otp.HasKey(sec) && !force {
+			return ExitError(ExitAborted, nil, "%s already contains an OTP secret. Use --force to replace it", name)
+		}
+	}
+
+	nSec, err := key.Set(sec)
+	if err != nil {
+		return ExitError(ExitUnknown, err, "failed to add OTP to %s: %s", name, err)
+	}
+
+	// make sure the stored key yields the same codes as the enrolled one
+	stored, err := otp.Get(name, nSec)
+	if err != nil || stored.Code(now) != token {
+		return ExitError(ExitUnknown, err, "failed to validate OTP for %s", name)
+	}
+
+	if err := s.Store.Set(ctxutil.WithCommitMessage(ctx, "Added OTP"), name, nSec); err != nil {
+		return ExitError(ExitEncrypt, err, "failed to save secret %s: %s", name, err)
+	}
+
+	out.OKf(ctx, "Added OTP to %s (current code %s)", name, token)
+	return nil
+}
+
+func otpParseKeys(in string) ([]*otp.Key, error) {
+	in = strings.TrimSpace(in)
+	if strings.HasPrefix(in, otp.MigrationScheme+":") {
+		return otp.ParseMigrationURL(in)
+	}
+
+	key, err := otp.ParseURL(in)
+	if err != nil {
+		return nil, err
+	}
+	return []*otp.Key{key}, nil
+}
+
+// otpEntryName returns issuer/account for keys from a migration URL
+func otpEntryName(key *otp.Key) string {
+	issuer, account := key.Issuer, key.Label
+	if i := strings.Index(account, ":"); i >= 0 {
+		if issuer == "" {
+			issuer = account[:i]
+		}
+		account = account[i+1:]
+	}
+
+	account = fsutil.CleanFilename(account)
+	if account == "" {
+		account = "otp"
+	}
+	if issuer = fsutil.CleanFilename(issuer); issuer == "" {
+		return account
+	}
+	return path.Join(issuer, account)
+}
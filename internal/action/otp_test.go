@@ -5,6 +5,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/itsonlycode/gosecret/internal/out"
@@ -13,6 +14,7 @@ import (
 	"github.com/itsonlycode/gosecret/tests/gptest"
 
 	"github.com/gokyle/twofactor"
+	"github.com/skip2/go-qrcode"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -52,7 +54,7 @@ func TestOTP(t *testing.T) {
 
 	t.Run("copy to clipboard", func(t *testing.T) {
 		defer buf.Reset()
-		assert.NoError(t, act.otp(ctx, "bar", "", true, false, false))
+		assert.NoError(t, act.otp(ctx, "bar", "", true, false, false, false))
 	})
 
 	t.Run("write QR file", func(t *testing.T) {
@@ -61,4 +63,105 @@ func TestOTP(t *testing.T) {
 		assert.NoError(t, act.OTP(gptest.CliCtxWithFlags(ctx, t, map[string]string{"qr": fn}, "bar")))
 		assert.FileExists(t, fn)
 	})
+
+	t.Run("next token", func(t *testing.T) {
+		defer buf.Reset()
+		assert.NoError(t, act.OTP(gptest.CliCtxWithFlags(ctx, t, map[string]string{"next": "true", "password": "true"}, "bar")))
+		assert.Len(t, strings.TrimSpace(buf.String()), 6)
+	})
+
+	t.Run("HOTP counter is persisted", func(t *testing.T) {
+		defer buf.Reset()
+		sec := &secrets.Plain{}
+		sec.SetPassword("foo")
+		sec.WriteString("otpauth://hotp/baz?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=1")
+		assert.NoError(t, act.Store.Set(ctx, "baz", sec))
+
+		assert.NoError(t, act.OTP(gptest.CliCtxWithFlags(ctx, t, map[string]string{"password": "true"}, "baz")))
+		assert.NoError(t, act.OTP(gptest.CliCtxWithFlags(ctx, t, map[string]string{"password": "true"}, "baz")))
+		assert.Equal(t, "287082\n359152\n", buf.String())
+
+		nSec, err := act.Store.Get(ctx, "baz")
+		require.NoError(t, err)
+		assert.Contains(t, string(nSec.Bytes()), "counter=3")
+	})
+}
+
+func TestOTPAdd(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithInteractive(ctx, false)
+
+	act, err := newMock(ctx, u)
+	require.NoError(t, err)
+	require.NotNil(t, act)
+
+	buf := &bytes.Buffer{}
+	out.Stdout = buf
+	defer func() {
+		out.Stdout = os.Stdout
+	}()
+
+	otpURL := "otpauth://totp/example?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=Example"
+
+	t.Run("invalid args", func(t *testing.T) {
+		defer buf.Reset()
+		assert.Error(t, act.OTPAdd(gptest.CliCtx(ctx, t)))
+		assert.Error(t, act.OTPAdd(gptest.CliCtx(ctx, t, "otp/new")))
+		assert.Error(t, act.OTPAdd(gptest.CliCtxWithFlags(ctx, t, map[string]string{"url": "https://example.org"}, "otp/new")))
+		assert.Error(t, act.OTPAdd(gptest.CliCtxWithFlags(ctx, t, map[string]string{"image": filepath.Join(u.Dir, "nope.png")}, "otp/new")))
+	})
+
+	t.Run("add to existing secret", func(t *testing.T) {
+		defer buf.Reset()
+		assert.NoError(t, act.OTPAdd(gptest.CliCtxWithFlags(ctx, t, map[string]string{"url": otpURL}, "foo")))
+		assert.Contains(t, buf.String(), "Added OTP to foo")
+
+		sec, err := act.Store.Get(ctx, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "secret", sec.Password())
+		v, found := sec.Get("otpauth")
+		assert.True(t, found)
+		assert.Contains(t, v, "//totp/example")
+
+		// existing OTP secrets are only replaced with force
+		assert.Error(t, act.OTPAdd(gptest.CliCtxWithFlags(ctx, t, map[string]string{"url": otpURL}, "foo")))
+		assert.NoError(t, act.OTPAdd(gptest.CliCtxWithFlags(ctx, t, map[string]string{"url": otpURL, "force": "true"}, "foo")))
+	})
+
+	t.Run("password that looks like a key", func(t *testing.T) {
+		defer buf.Reset()
+		sec := secrets.NewKV()
+		sec.SetPassword("JBSWY3DPEHPK3PXP")
+		require.NoError(t, act.Store.Set(ctx, "otp/base32", sec))
+
+		assert.NoError(t, act.OTPAdd(gptest.CliCtxWithFlags(ctx, t, map[string]string{"url": otpURL}, "otp/base32")))
+		sec2, err := act.Store.Get(ctx, "otp/base32")
+		require.NoError(t, err)
+		assert.Equal(t, "JBSWY3DPEHPK3PXP", sec2.Password())
+	})
+
+	t.Run("add from QR image", func(t *testing.T) {
+		defer buf.Reset()
+		fn := filepath.Join(u.Dir, "enroll.png")
+		require.NoError(t, qrcode.WriteFile(otpURL, qrcode.Medium, 256, fn))
+
+		assert.NoError(t, act.OTPAdd(gptest.CliCtxWithFlags(ctx, t, map[string]string{"image": fn}, "otp/qr")))
+		buf.Reset()
+		assert.NoError(t, act.OTP(gptest.CliCtxWithFlags(ctx, t, map[string]string{"password": "true"}, "otp/qr")))
+		assert.Len(t, strings.TrimSpace(buf.String()), 6)
+	})
+
+	t.Run("add from migration URL", func(t *testing.T) {
+		defer buf.Reset()
+		migration := "otpauth-migration://offline?data=CjQKFDEyMzQ1Njc4OTAxMjM0NTY3ODkwEhFhbGljZUBleGFtcGxlLm9yZxoHRXhhbXBsZTACCiMKFDEyMzQ1Njc4OTAxMjM0NTY3ODkwEglPdGhlcjpib2IwAg%3D%3D"
+		assert.NoError(t, act.OTPAdd(gptest.CliCtxWithFlags(ctx, t, map[string]string{"url": migration}, "otp/import")))
+		assert.Contains(t, buf.String(), "Found 2 accounts")
+
+		assert.True(t, act.Store.Exists(ctx, "otp/import/Example/alice@example.org"))
+		assert.True(t, act.Store.Exists(ctx, "otp/import/Other/bob"))
+	})
 }
@@ -25,11 +25,17 @@ func (s *Action) RCSInit(c *cli.Context) error {
 	un := termio.DetectName(c.Context, c)
 	ue := termio.DetectEmail(c.Context, c)
 	ctx = backend.WithStorageBackendString(ctx, c.String("storage"))
+	if key := c.String("sign-key"); key != "" {
+		ctx = ctxutil.WithSigningKey(ctx, key)
+	}
 
 	// default to git
 	if !backend.HasStorageBackend(ctx) {
 		ctx = backend.WithStorageBackend(ctx, backend.GitFS)
 	}
+	if err := checkStorageBackend(backend.GetStorageBackend(ctx)); err != nil {
+		return err
+	}
 
 	if err := s.rcsInit(ctx, store, un, ue); err != nil {
 		return ExitError(ExitGit, err, "failed to initialize git: %s", err)
@@ -3,12 +3,17 @@ package action
 import (
 	"context"
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/itsonlycode/gosecret/internal/tree"
 
 	"github.com/chzyer/readline"
 	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/pkg/appdir"
 	"github.com/itsonlycode/gosecret/pkg/debug"
 	shellquote "github.com/kballard/go-shellquote"
 	"github.com/urfave/cli/v2"
@@ -105,8 +110,239 @@ func (s *Action) prefixCompleter(c *cli.Context) *readline.PrefixCompleter {
 	return readline.NewPrefixCompleter(cmds...)
 }
 
-// REPL implements a read-execute-print-line shell
-// with readline support and autocompletion.
+// replNameArgs is the number of leading arguments of a command that are
+// names of secrets or folders. They are relative to the current folder of
+// the shell.
+var replNameArgs = map[string]int{
+	"cat":      1,
+	"clip":     1,
+	"copy":     2,
+	"delete":   1,
+	"diff":     2,
+	"edit":     1,
+	"env":      1,
+	"generate": 1,
+	"history":  1,
+	"insert":   1,
+	"link":     2,
+	"list":     1,
+	"move":     2,
+	"otp":      1,
+	"show":     1,
+	"sum":      1,
+	// subcommands
+	"history restore": 1,
+	"otp add":         1,
+}
+
+// replSession is the state of an interactive shell
+type replSession struct {
+	// cwd is the current folder, without leading or trailing slashes
+	cwd string
+	// prev is the folder before the last cd
+	prev string
+}
+
+// resolve returns the full name of a secret or folder given relative to
+// the current folder. Names starting with a slash are absolute.
+func (r *replSession) resolve(name string) string {
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + path.Join(r.cwd, name)
+	}
+	return strings.Trim(path.Clean(name), "/")
+}
+
+// findFlag returns the flag of the command with the given name or alias
+func findFlag(cmd *cli.Command, name string) cli.Flag {
+	for _, f := range cmd.Flags {
+		for _, n := range f.Names() {
+			if n == name {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// takesValue returns true if the flag of the command is followed by a value
+func takesValue(cmd *cli.Command, name string) bool {
+	f := findFlag(cmd, name)
+	if f == nil {
+		return false
+	}
+	_, isBool := f.(*cli.BoolFlag)
+	return !isBool
+}
+
+// rewriteArgs makes the names of secrets and folders in the arguments of a
+// command absolute. Listing without a folder lists the current folder.
+func (r *replSession) rewriteArgs(cmd *cli.Command, args []string) []string {
+	key := cmd.Name
+	out := make([]string, 0, len(args)+1)
+	// subcommands, e.g. history restore, have arguments of their own
+	if len(args) > 0 {
+		for _, sub := range cmd.Subcommands {
+			if sub.HasName(args[0]) {
+				key = cmd.Name + " " + sub.Name
+				cmd = sub
+				out = append(out, args[0])
+				args = args[1:]
+				break
+			}
+		}
+	}
+
+	n, found := replNameArgs[key]
+	if !found {
+		return append(out, args...)
+	}
+
+	var names int
+	var skip, flags bool
+	flags = true
+	for _, arg := range args {
+		switch {
+		case skip:
+			skip = false
+		case flags && arg == "--":
+			flags = false
+		case flags && strings.HasPrefix(arg, "-") && len(arg) > 1:
+			skip = !strings.Contains(arg, "=") && takesValue(cmd, strings.TrimLeft(arg, "-"))
+		case names < n:
+			arg = r.resolve(arg)
+			names++
+		}
+		out = append(out, arg)
+	}
+	if cmd.Name == "list" && names < 1 && r.cwd != "" {
+		out = append(out, r.cwd)
+	}
+	return out
+}
+
+// replSecretFlags are the names of flags whose values might be secrets
+var replSecretFlags = map[string]bool{
+	"content":  true,
+	"pass":     true,
+	"password": true,
+	"secret":   true,
+	"token":    true,
+	"url":      true,
+	"value":    true,
+}
+
+// secretFlag returns true if the value of the named flag might be a secret.
+// Flags of the command are found by any of their names, unknown flags by
+// their name only.
+func secretFlag(cmd *cli.Command, name string) bool {
+	if f := findFlag(cmd, name); f != nil {
+		return replSecretFlags[f.Names()[0]]
+	}
+	return replSecretFlags[name] || name == "p"
+}
+
+// replRecord returns false for lines that must not be kept in the history
+// because they might contain secret values, e.g. insert foo user=bob
+// password:hunter2 or otp add --url otpauth://...
+func replRecord(cmd *cli.Command, args []string) bool {
+	if cmd == nil {
+		return true
+	}
+	// flags of subcommands, e.g. otp add
+	if len(args) > 0 {
+		for _, sub := range cmd.Subcommands {
+			if sub.HasName(args[0]) {
+				cmd, args = sub, args[1:]
+				break
+			}
+		}
+	}
+
+	var value bool
+	for _, arg := range args {
+		if value {
+			return false
+		}
+		switch {
+		case strings.Contains(strings.ToLower(arg), "otpauth"):
+			return false
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			name := strings.TrimLeft(arg, "-")
+			if i := strings.Index(name, "="); i >= 0 {
+				if secretFlag(cmd, name[:i]) {
+					return false
+				}
+				continue
+			}
+			// unknown flags might take a value, too
+			value = secretFlag(cmd, name) && (takesValue(cmd, name) || findFlag(cmd, name) == nil)
+		case strings.ContainsAny(arg, "=:"):
+			return false
+		}
+	}
+	return !value
+}
+
+// replPrompt shows the current folder and the mount it belongs to
+func (s *Action) replPrompt(r *replSession) string {
+	p := "gosecret"
+	if mp := s.Store.MountPoint(r.cwd); mp != "" {
+		p += " [" + mp + "]"
+	}
+	return p + " /" + r.cwd + "> "
+}
+
+// replIsDir returns true if the folder exists in a store or holds a mount
+func (s *Action) replIsDir(ctx context.Context, dir string) bool {
+	if dir == "" || s.Store.IsDir(ctx, dir) {
+		return true
+	}
+	for _, mp := range s.Store.MountPoints() {
+		if mp == dir || strings.HasPrefix(mp, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// replCd changes the current folder. Without an argument it changes to the
+// root, - changes to the previous folder.
+func (s *Action) replCd(ctx context.Context, r *replSession, args []string) {
+	dir := ""
+	switch {
+	case len(args) < 1:
+	case args[0] == "-":
+		dir = r.prev
+	default:
+		dir = r.resolve(args[0])
+	}
+	if !s.replIsDir(ctx, dir) {
+		out.Errorf(ctx, "No such folder: /%s", dir)
+		return
+	}
+	r.prev, r.cwd = r.cwd, dir
+}
+
+// replHistory returns the file that keeps the history of the shell. It's
+// created with restrictive permissions, because it contains secret names.
+func replHistory() string {
+	fn := filepath.Join(appdir.UserData(), "repl_history")
+	if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+		debug.Log("failed to create %s: %s", filepath.Dir(fn), err)
+		return ""
+	}
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		debug.Log("failed to open %s: %s", fn, err)
+		return ""
+	}
+	_ = fh.Close()
+	return fn
+}
+
+// REPL implements a read-execute-print-line shell with readline support
+// and autocompletion. Names are relative to the current folder, see cd,
+// and the credential caches are locked after some idle time.
 func (s *Action) REPL(c *cli.Context) error {
 	c.App.ExitErrHandler = func(c *cli.Context, err error) {
 		if err == nil {
@@ -119,12 +355,18 @@ func (s *Action) REPL(c *cli.Context) error {
 	out.Printf(c.Context, "🌟 Welcome to gosecret!")
 	out.Printf(c.Context, "⚠ This is the built-in shell. Type 'help' for a list of commands.")
 
-	rl, err := readline.New("gosecret> ")
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:                 "gosecret> ",
+		HistoryFile:            replHistory(),
+		DisableAutoSaveHistory: true,
+	})
 	if err != nil {
 		return err
 	}
 	defer rl.Close()
 
+	sess := &replSession{}
+
 READ:
 	for {
 		// check for context cancelation
@@ -134,7 +376,11 @@ READ:
 		default:
 		}
 		rl.Config.AutoComplete = s.prefixCompleter(c)
+		rl.SetPrompt(s.replPrompt(sess))
+
+		stop := s.replAutoLock(c.Context, rl)
 		line, err := rl.Readline()
+		stop()
 		if err != nil {
 			debug.Log("Readline error: %s", err)
 			break
@@ -147,6 +393,12 @@ READ:
 		if len(args) < 1 {
 			continue
 		}
+		cmd := c.App.Command(args[0])
+		if replRecord(cmd, args[1:]) {
+			if err := rl.SaveHistory(line); err != nil {
+				debug.Log("failed to save history: %s", err)
+			}
+		}
 		switch strings.ToLower(args[0]) {
 		case "quit":
 			break READ
@@ -156,8 +408,17 @@ READ:
 		case "clear":
 			readline.ClearScreen(stdout)
 			continue
+		case "cd":
+			s.replCd(c.Context, sess, args[1:])
+			continue
+		case "pwd":
+			out.Printf(c.Context, "/%s", sess.cwd)
+			continue
 		default:
 		}
+		if cmd != nil {
+			args = append(args[:1], sess.rewriteArgs(cmd, args[1:])...)
+		}
 		// need to reinitialize the config to pick up any changes from the
 		// previous iteration
 		// TODO: this means the context will grow with every loop. Eventually
@@ -170,6 +431,22 @@ READ:
 	return nil
 }
 
+// replAutoLock locks the stores if the shell waits for input longer than
+// configured. The returned func must be called once a line was read.
+func (s *Action) replAutoLock(ctx context.Context, rl *readline.Instance) func() {
+	timeout := time.Duration(s.cfg.AutoLock) * time.Minute
+	if timeout <= 0 {
+		return func() {}
+	}
+	t := time.AfterFunc(timeout, func() {
+		out.Printf(ctx, "")
+		out.Noticef(ctx, "Idle for %s", timeout)
+		s.replLock(ctx)
+		rl.Refresh()
+	})
+	return func() { t.Stop() }
+}
+
 func (s *Action) replLock(ctx context.Context) {
 	if err := s.Store.Lock(); err != nil {
 		out.Errorf(ctx, "Failed to lock stores: %s", err)
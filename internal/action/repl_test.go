@@ -0,0 +1,166 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/tests/gptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestREPLResolve(t *testing.T) {
+	r := &replSession{cwd: "foo/bar"}
+
+	for in, want := range map[string]string{
+		"baz":        "foo/bar/baz",
+		"baz/":       "foo/bar/baz",
+		"./baz":      "foo/bar/baz",
+		"../baz":     "foo/baz",
+		"../../../x": "x",
+		"/baz":       "baz",
+		"/":          "",
+		"..":         "foo",
+	} {
+		assert.Equal(t, want, r.resolve(in), in)
+	}
+}
+
+func TestREPLRewriteArgs(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithInteractive(ctx, false)
+	act, err := newMock(ctx, u)
+	require.NoError(t, err)
+
+	app := cli.NewApp()
+	app.Commands = act.GetCommands()
+	r := &replSession{cwd: "web"}
+
+	for _, tc := range []struct {
+		in   []string
+		want []string
+	}{
+		{in: []string{"show", "foo"}, want: []string{"web/foo"}},
+		{in: []string{"show", "-c", "foo", "user"}, want: []string{"-c", "web/foo", "user"}},
+		{in: []string{"show", "--revision", "-1", "foo"}, want: []string{"--revision", "-1", "web/foo"}},
+		{in: []string{"move", "foo", "/mail/foo"}, want: []string{"web/foo", "mail/foo"}},
+		{in: []string{"insert", "--", "-foo"}, want: []string{"--", "web/-foo"}},
+		{in: []string{"ls"}, want: []string{"web"}},
+		{in: []string{"ls", "-l", "2"}, want: []string{"-l", "2", "web"}},
+		{in: []string{"ls", "-f", ".."}, want: []string{"-f", ""}},
+		{in: []string{"find", "foo"}, want: []string{"foo"}},
+	} {
+		cmd := app.Command(tc.in[0])
+		require.NotNil(t, cmd, tc.in[0])
+		assert.Equal(t, tc.want, r.rewriteArgs(cmd, tc.in[1:]), tc.in)
+	}
+
+	// the root is listed without a prefix
+	assert.Equal(t, []string{}, (&replSession{}).rewriteArgs(app.Command("ls"), []string{}))
+
+	// subcommands are not taken for names
+	r = &replSession{cwd: "bank"}
+	for _, tc := range []struct {
+		in   []string
+		want []string
+	}{
+		{in: []string{"history", "foo"}, want: []string{"bank/foo"}},
+		{in: []string{"history", "restore", "foo", "-1"}, want: []string{"restore", "bank/foo", "-1"}},
+		{in: []string{"history", "restore", "--recursive", "foo", "2021-01-01"}, want: []string{"restore", "--recursive", "bank/foo", "2021-01-01"}},
+		{in: []string{"otp", "add", "foo"}, want: []string{"add", "bank/foo"}},
+		{in: []string{"otp", "-c", "foo"}, want: []string{"-c", "bank/foo"}},
+	} {
+		cmd := app.Command(tc.in[0])
+		require.NotNil(t, cmd, tc.in[0])
+		assert.Equal(t, tc.want, r.rewriteArgs(cmd, tc.in[1:]), tc.in)
+	}
+}
+
+func TestREPLRecord(t *testing.T) {
+	insert := &cli.Command{Name: "insert"}
+	generate := &cli.Command{Name: "generate"}
+	show := &cli.Command{Name: "show"}
+
+	assert.True(t, replRecord(nil, []string{"user=bob"}))
+	assert.True(t, replRecord(show, []string{"foo", "user"}))
+	assert.True(t, replRecord(insert, []string{"-m", "foo"}))
+	assert.True(t, replRecord(generate, []string{"foo", "24"}))
+	assert.False(t, replRecord(insert, []string{"foo", "password=hunter2"}))
+	assert.False(t, replRecord(generate, []string{"foo", "24", "pin:1234"}))
+
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithInteractive(ctx, false)
+	act, err := newMock(ctx, u)
+	require.NoError(t, err)
+
+	app := cli.NewApp()
+	app.Commands = act.GetCommands()
+	otp := app.Command("otp")
+	require.NotNil(t, otp)
+
+	assert.True(t, replRecord(otp, []string{"foo"}))
+	assert.True(t, replRecord(otp, []string{"--password", "foo"}))
+	assert.True(t, replRecord(otp, []string{"add", "--force", "foo"}))
+	assert.False(t, replRecord(otp, []string{"add", "--url", "otpauth://totp/foo?secret=JBSWY3DPEHPK3PXP", "foo"}))
+	assert.False(t, replRecord(otp, []string{"add", "-u", "otpauth-migration://offline?data=abc", "foo"}))
+	assert.False(t, replRecord(otp, []string{"add", "--url=otpauth://totp/foo?secret=JBSWY3DPEHPK3PXP", "foo"}))
+	assert.False(t, replRecord(otp, []string{"add", "foo", "--url"}))
+	assert.False(t, replRecord(show, []string{"--password", "hunter2", "foo"}))
+	assert.False(t, replRecord(show, []string{"-p", "hunter2"}))
+	assert.False(t, replRecord(app.Command("run"), []string{"foo", "--", "curl", "-u", "bob:hunter2"}))
+}
+
+func TestREPLCd(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	u.Entries = append(u.Entries, "web/foo", "web/bar/baz")
+	require.NoError(t, u.InitStore(""))
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithInteractive(ctx, false)
+	act, err := newMock(ctx, u)
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	out.Stdout = buf
+	out.Stderr = buf
+	defer func() {
+		out.Stdout = os.Stdout
+		out.Stderr = os.Stderr
+	}()
+
+	r := &replSession{}
+	assert.Equal(t, "gosecret /> ", act.replPrompt(r))
+
+	act.replCd(ctx, r, []string{"web"})
+	assert.Equal(t, "web", r.cwd)
+	act.replCd(ctx, r, []string{"bar"})
+	assert.Equal(t, "web/bar", r.cwd)
+	act.replCd(ctx, r, []string{"-"})
+	assert.Equal(t, "web", r.cwd)
+
+	act.replCd(ctx, r, []string{"nope"})
+	assert.Equal(t, "web", r.cwd)
+	assert.Contains(t, buf.String(), "No such folder: /web/nope")
+
+	act.replCd(ctx, r, nil)
+	assert.Equal(t, "", r.cwd)
+
+	require.NoError(t, u.InitStore("mnt"))
+	require.NoError(t, act.Store.AddMount(ctx, "team/mnt", u.StoreDir("mnt")))
+	act.replCd(ctx, r, []string{"team"})
+	assert.Equal(t, "team", r.cwd)
+	act.replCd(ctx, r, []string{"mnt"})
+	assert.Equal(t, "gosecret [team/mnt] /team/mnt> ", act.replPrompt(r))
+}
@@ -0,0 +1,176 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+	"github.com/itsonlycode/gosecret/pkg/fsutil"
+	"github.com/itsonlycode/gosecret/pkg/tempfile"
+	"github.com/urfave/cli/v2"
+)
+
+var reEnvInvalid = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// runFile describes a single secret (or key of a secret) that will be written
+// to a temporary file and exported to the subprocess.
+type runFile struct {
+	env    string
+	secret string
+	key    string
+}
+
+// parseRunFile parses a file spec of the form [ENV=]secret[:key]. If no
+// variable name is given it is derived from the last path component of
+// the secret.
+func parseRunFile(spec string) (runFile, error) {
+	rf := runFile{}
+	if p := strings.SplitN(spec, "=", 2); len(p) == 2 {
+		rf.env = p[0]
+		spec = p[1]
+	}
+	if p := strings.SplitN(spec, ":", 2); len(p) == 2 {
+		spec = p[0]
+		rf.key = p[1]
+	}
+	rf.secret = strings.TrimSpace(spec)
+	if rf.secret == "" {
+		return rf, fmt.Errorf("no secret given")
+	}
+	if rf.env == "" {
+		rf.env = reEnvInvalid.ReplaceAllString(strings.ToUpper(path.Base(rf.secret)), "_")
+	}
+	return rf, nil
+}
+
+// Run implements the run subcommand. It writes the requested secrets to
+// temporary files (on a ramdisk, if possible), exports their paths to a
+// subprocess and securely removes them once the subprocess has exited.
+func (s *Action) Run(c *cli.Context) error {
+	ctx := ctxutil.WithGlobalFlags(c)
+	args := c.Args().Slice()
+
+	if len(args) == 0 {
+		return ExitError(ExitUsage, nil, "Missing subcommand to execute")
+	}
+
+	specs := c.StringSlice("file")
+	if len(specs) == 0 {
+		return ExitError(ExitUsage, nil, "Missing secrets. Use --file [ENV=]secret[:key]")
+	}
+
+	// make sure the files are removed even if we're interrupted or terminated.
+	// Canceling the context will kill the subprocess and unwind the stack.
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	files := make([]*tempfile.File, 0, len(specs))
+	defer func() {
+		// the context might already be canceled but we still need to
+		// clean up after ourselves
+		s.runCleanup(context.Background(), files)
+	}()
+
+	env := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		rf, err := parseRunFile(spec)
+		if err != nil {
+			return ExitError(ExitUsage, err, "invalid file spec %q: %s", spec, err)
+		}
+
+		buf, err := s.runContent(ctx, rf)
+		if err != nil {
+			return err
+		}
+
+		tf, err := tempfile.New(ctx, "gosecret-run-")
+		if err != nil {
+			return ExitError(ExitIO, err, "failed to create tempfile: %s", err)
+		}
+		files = append(files, tf)
+
+		if _, err := tf.Write(buf); err != nil {
+			return ExitError(ExitIO, err, "failed to write tempfile: %s", err)
+		}
+		if err := tf.Close(); err != nil {
+			return ExitError(ExitIO, err, "failed to close tempfile: %s", err)
+		}
+
+		debug.Log("exporting %s to %s", rf.secret, rf.env)
+		env = append(env, fmt.Sprintf("%s=%s", rf.env, tf.Name()))
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		// pass on the exit status of the subprocess, so scripts can tell
+		// a failed command from a failure of gosecret
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && ee.ExitCode() > 0 {
+			return ExitError(ee.ExitCode(), err, "%s exited with status %d", args[0], ee.ExitCode())
+		}
+		return ExitError(ExitUnknown, err, "failed to run %s: %s", args[0], err)
+	}
+	return nil
+}
+
+// runContent returns the content that should be written to the file. Binary
+// secrets are decoded, keys are looked up and everything else is written
+// as is.
+func (s *Action) runContent(ctx context.Context, rf runFile) ([]byte, error) {
+	if !s.Store.Exists(ctx, rf.secret) {
+		return nil, ExitError(ExitNotFound, nil, "Secret %s not found", rf.secret)
+	}
+
+	if rf.key == "" {
+		buf, err := s.binaryGet(ctx, rf.secret)
+		if err != nil {
+			return nil, ExitError(ExitDecrypt, err, "failed to decrypt %s: %s", rf.secret, err)
+		}
+		return buf, nil
+	}
+
+	sec, err := s.Store.Get(ctx, rf.secret)
+	if err != nil {
+		return nil, ExitError(ExitDecrypt, err, "failed to decrypt %s: %s", rf.secret, err)
+	}
+	if rf.key == "password" {
+		if v, found := sec.Get(rf.key); found {
+			return []byte(v), nil
+		}
+		return []byte(sec.Password()), nil
+	}
+	v, found := sec.Get(rf.key)
+	if !found {
+		return nil, ExitError(ExitNotFound, nil, "Key %s not found in %s", rf.key, rf.secret)
+	}
+	return []byte(v), nil
+}
+
+// runCleanup shreds and removes all tempfiles. It will try to remove all of
+// them even if some fail.
+func (s *Action) runCleanup(ctx context.Context, files []*tempfile.File) {
+	for _, tf := range files {
+		if fn := tf.Name(); fn != "" {
+			if err := fsutil.Shred(fn, 8); err != nil {
+				debug.Log("failed to shred %s: %s", fn, err)
+			}
+		}
+		if err := tf.Remove(ctx); err != nil {
+			out.Errorf(ctx, "Failed to remove tempfile %s: %s", tf.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"regexp"
+	"runtime"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/tests/gptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestParseRunFile(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		out runFile
+	}{
+		{
+			in:  "foo/bar",
+			out: runFile{env: "BAR", secret: "foo/bar"},
+		},
+		{
+			in:  "KUBECONFIG=k8s/prod-cluster",
+			out: runFile{env: "KUBECONFIG", secret: "k8s/prod-cluster"},
+		},
+		{
+			in:  "k8s/prod-cluster:token",
+			out: runFile{env: "PROD_CLUSTER", secret: "k8s/prod-cluster", key: "token"},
+		},
+		{
+			in:  "TOKEN=k8s/prod:token",
+			out: runFile{env: "TOKEN", secret: "k8s/prod", key: "token"},
+		},
+	} {
+		rf, err := parseRunFile(tc.in)
+		require.NoError(t, err, tc.in)
+		assert.Equal(t, tc.out, rf, tc.in)
+	}
+
+	_, err := parseRunFile("FOO=")
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires env")
+	}
+
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithTerminal(ctx, false)
+	act, err := newMock(ctx, u)
+	require.NoError(t, err)
+	require.NotNil(t, act)
+
+	buf := &bytes.Buffer{}
+	out.Stdout = buf
+	out.Stderr = buf
+	stdout = buf
+	defer func() {
+		out.Stdout = os.Stdout
+		out.Stderr = os.Stderr
+		stdout = os.Stdout
+	}()
+
+	runCtx := func(files []string, args ...string) *cli.Context {
+		fs := flag.NewFlagSet("default", flag.ContinueOnError)
+		sf := cli.StringSliceFlag{
+			Name: "file",
+		}
+		require.NoError(t, sf.Apply(fs))
+		fa := make([]string, 0, 2*len(files)+len(args))
+		for _, f := range files {
+			fa = append(fa, "--file", f)
+		}
+		require.NoError(t, fs.Parse(append(fa, args...)))
+		c := cli.NewContext(cli.NewApp(), fs, nil)
+		c.Context = ctx
+		return c
+	}
+
+	t.Run("no command", func(t *testing.T) {
+		assert.Error(t, act.Run(runCtx([]string{"foo"})))
+	})
+
+	t.Run("no files", func(t *testing.T) {
+		assert.Error(t, act.Run(runCtx(nil, "env")))
+	})
+
+	t.Run("secret not found", func(t *testing.T) {
+		assert.Error(t, act.Run(runCtx([]string{"non-existing"}, "env")))
+	})
+
+	t.Run("files are removed", func(t *testing.T) {
+		buf.Reset()
+		require.NoError(t, act.Run(runCtx([]string{"SECRET_FILE=foo"}, "env")))
+
+		m := regexp.MustCompile(`SECRET_FILE=(\S+)`).FindStringSubmatch(buf.String())
+		require.Len(t, m, 2, buf.String())
+		_, err := os.Stat(m[1])
+		assert.True(t, os.IsNotExist(err), m[1])
+	})
+
+	t.Run("exit status is passed on", func(t *testing.T) {
+		err := act.Run(runCtx([]string{"foo"}, "sh", "-c", "exit 42"))
+		require.Error(t, err)
+		var ec cli.ExitCoder
+		require.True(t, errors.As(err, &ec))
+		assert.Equal(t, 42, ec.ExitCode())
+	})
+}
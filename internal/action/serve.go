@@ -0,0 +1,422 @@
+package action
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/internal/tree"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+	"github.com/itsonlycode/gosecret/pkg/gosecret"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+	"github.com/itsonlycode/gosecret/pkg/otp"
+
+	"github.com/urfave/cli/v2"
+)
+
+const rpcVersion = "2.0"
+
+// rpcRequest is a JSON-RPC 2.0 request. Requests without an ID are
+// notifications and don't get a response.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError uses the exit codes of the CLI as error codes
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcHandler func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// Serve answers line-delimited JSON-RPC requests on stdin until stdin is
+// closed. This lets scripts and editor plugins keep a single process with
+// initialized stores instead of running one process per operation.
+func (s *Action) Serve(c *cli.Context) error {
+	if !c.Bool("stdio") {
+		return ExitError(ExitUsage, nil, "Usage: %s serve --stdio", s.Name)
+	}
+
+	// nothing but responses may be written to stdout and there is no one
+	// to answer any questions
+	ctx := ctxutil.WithGlobalFlags(c)
+	ctx = ctxutil.WithInteractive(ctx, false)
+	ctx = ctxutil.WithTerminal(ctx, false)
+	ctx = ctxutil.WithHidden(ctx, true)
+
+	return s.serve(ctx, stdin, stdout)
+}
+
+func (s *Action) serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	handlers := s.rpcHandlers()
+	in := bufio.NewReader(r)
+	enc := json.NewEncoder(w)
+
+	for {
+		line, err := in.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if resp := s.rpcCall(ctx, handlers, line); resp != nil {
+				if err := enc.Encode(resp); err != nil {
+					return ExitError(ExitIO, err, "failed to write response: %s", err)
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return ExitError(ExitIO, err, "failed to read request: %s", err)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// rpcCall handles a single request. It returns nil for notifications.
+func (s *Action) rpcCall(ctx context.Context, handlers map[string]rpcHandler, line []byte) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return rpcErrorResponse(nil, ExitError(ExitUsage, err, "invalid request: %s", err))
+	}
+
+	debug.Log("handling %s request", req.Method)
+	var result interface{}
+	var err error
+	h, found := handlers[req.Method]
+	switch {
+	case req.JSONRPC != rpcVersion:
+		err = ExitError(ExitUsage, nil, "unsupported jsonrpc version %q, expected %q", req.JSONRPC, rpcVersion)
+	case !found:
+		err = ExitError(ExitUsage, nil, "unknown method %q", req.Method)
+	default:
+		result, err = h(ctx, req.Params)
+	}
+
+	if len(req.ID) < 1 {
+		if err != nil {
+			debug.Log("notification %s failed: %s", req.Method, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return rpcErrorResponse(req.ID, err)
+	}
+	return &rpcResponse{JSONRPC: rpcVersion, ID: req.ID, Result: result}
+}
+
+func rpcErrorResponse(id json.RawMessage, err error) *rpcResponse {
+	if len(id) < 1 {
+		id = json.RawMessage("null")
+	}
+	rerr := &rpcError{Code: ExitUnknown, Message: err.Error()}
+	var ec cli.ExitCoder
+	if errors.As(err, &ec) {
+		rerr.Code = ec.ExitCode()
+	}
+	return &rpcResponse{JSONRPC: rpcVersion, ID: id, Error: rerr}
+}
+
+// rpcParams decodes the params of a request. Unknown params are rejected to
+// catch typos.
+func rpcParams(raw json.RawMessage, v interface{}) error {
+	if len(raw) < 1 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return ExitError(ExitUsage, err, "invalid params: %s", err)
+	}
+	return nil
+}
+
+func (s *Action) rpcHandlers() map[string]rpcHandler {
+	return map[string]rpcHandler{
+		"list":     s.rpcList,
+		"get":      s.rpcGet,
+		"set":      s.rpcSet,
+		"delete":   s.rpcDelete,
+		"move":     s.rpcMove,
+		"generate": s.rpcGenerate,
+		"otp":      s.rpcOTP,
+	}
+}
+
+type rpcSecret struct {
+	Name     string            `json:"name"`
+	Password string            `json:"password"`
+	Values   map[string]string `json:"values,omitempty"`
+	Body     string            `json:"body,omitempty"`
+}
+
+type rpcValue struct {
+	Name  string `json:"name"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (s *Action) rpcGetSecret(ctx context.Context, name string) (gosecret.Secret, error) {
+	if name == "" {
+		return nil, ExitError(ExitNoName, nil, "missing name")
+	}
+	sec, err := s.Store.Get(ctx, name)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ExitError(ExitNotFound, err, "entry %q not found", name)
+		}
+		return nil, ExitError(ExitDecrypt, err, "failed to decrypt %s: %s", name, err)
+	}
+	return sec, nil
+}
+
+func (s *Action) rpcList(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var p struct {
+		Prefix string `json:"prefix"`
+	}
+	if err := rpcParams(raw, &p); err != nil {
+		return nil, err
+	}
+
+	l, err := s.Store.List(ctx, tree.INF)
+	if err != nil {
+		return nil, ExitError(ExitList, err, "failed to list store: %s", err)
+	}
+	entries := make([]string, 0, len(l))
+	for _, e := range l {
+		if strings.HasPrefix(e, p.Prefix) {
+			entries = append(entries, e)
+		}
+	}
+	sort.Strings(entries)
+	return entries, nil
+}
+
+func (s *Action) rpcGet(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name string `json:"name"`
+		Key  string `json:"key"`
+	}
+	if err := rpcParams(raw, &p); err != nil {
+		return nil, err
+	}
+
+	sec, err := s.rpcGetSecret(ctx, p.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Key != "" {
+		v, found := sec.Get(p.Key)
+		if !found {
+			return nil, ExitError(ExitNotFound, nil, "key %q not found in %s", p.Key, p.Name)
+		}
+		return rpcValue{Name: p.Name, Key: p.Key, Value: v}, nil
+	}
+
+	res := rpcSecret{
+		Name:     p.Name,
+		Password: sec.Password(),
+		Body:     sec.Body(),
+	}
+	for _, k := range sec.Keys() {
+		if res.Values == nil {
+			res.Values = make(map[string]string, len(sec.Keys()))
+		}
+		res.Values[k], _ = sec.Get(k)
+	}
+	return res, nil
+}
+
+func (s *Action) rpcSet(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name    string `json:"name"`
+		Content string `json:"content"`
+		Key     string `json:"key"`
+		Value   string `json:"value"`
+	}
+	if err := rpcParams(raw, &p); err != nil {
+		return nil, err
+	}
+	if p.Name == "" {
+		return nil, ExitError(ExitNoName, nil, "missing name")
+	}
+
+	// update a single key, the secret is created if it does not exist
+	if p.Key != "" {
+		return struct{}{}, s.insertYAML(ctx, p.Name, p.Key, []byte(p.Value), nil)
+	}
+
+	if err := s.Store.Set(ctxutil.WithCommitMessage(ctx, "Saved secret"), p.Name, secrets.ParsePlain([]byte(p.Content))); err != nil {
+		return nil, ExitError(ExitEncrypt, err, "failed to save secret %s: %s", p.Name, err)
+	}
+	return struct{}{}, nil
+}
+
+func (s *Action) rpcDelete(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name      string `json:"name"`
+		Recursive bool   `json:"recursive"`
+	}
+	if err := rpcParams(raw, &p); err != nil {
+		return nil, err
+	}
+	if p.Name == "" {
+		return nil, ExitError(ExitNoName, nil, "missing name")
+	}
+
+	if p.Recursive {
+		if err := s.Store.Prune(ctx, p.Name); err != nil {
+			return nil, ExitError(ExitUnknown, err, "failed to prune %q: %s", p.Name, err)
+		}
+		return struct{}{}, nil
+	}
+
+	if !s.Store.Exists(ctx, p.Name) {
+		return nil, ExitError(ExitNotFound, nil, "entry %q not found", p.Name)
+	}
+	if err := s.Store.Delete(ctx, p.Name); err != nil {
+		return nil, ExitError(ExitIO, err, "Can not delete %q: %s", p.Name, err)
+	}
+	return struct{}{}, nil
+}
+
+func (s *Action) rpcMove(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var p struct {
+		From  string `json:"from"`
+		To    string `json:"to"`
+		Force bool   `json:"force"`
+	}
+	if err := rpcParams(raw, &p); err != nil {
+		return nil, err
+	}
+	if p.From == "" || p.To == "" {
+		return nil, ExitError(ExitUsage, nil, "from and to are required")
+	}
+
+	if !p.Force && s.Store.Exists(ctx, p.To) {
+		return nil, ExitError(ExitAborted, nil, "not overwriting %s. Use force to replace it", p.To)
+	}
+	if err := s.Store.Move(ctx, p.From, p.To); err != nil {
+		return nil, ExitError(ExitUnknown, err, "%s", err)
+	}
+	return struct{}{}, nil
+}
+
+func (s *Action) rpcGenerate(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name    string `json:"name"`
+		Key     string `json:"key"`
+		Length  int    `json:"length"`
+		Symbols *bool  `json:"symbols"`
+		Force   bool   `json:"force"`
+	}
+	if err := rpcParams(raw, &p); err != nil {
+		return nil, err
+	}
+	if p.Name == "" {
+		return nil, ExitError(ExitNoName, nil, "missing name")
+	}
+
+	if !p.Force && p.Key == "" && s.Store.Exists(ctx, p.Name) {
+		return nil, ExitError(ExitAborted, nil, "not overwriting the password of %s. Use force to replace it", p.Name)
+	}
+
+	// the password is chosen like in generate, so the password rules of the
+	// domain and the configured generator apply
+	c, err := s.rpcGenerateContext(ctx, p.Symbols)
+	if err != nil {
+		return nil, ExitError(ExitUnknown, err, "%s", err)
+	}
+	length := ""
+	if p.Length > 0 {
+		length = strconv.Itoa(p.Length)
+	}
+	password, err := s.generatePassword(ctx, c, length, p.Name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.generateSetPassword(ctx, p.Name, p.Key, password, nil); err != nil {
+		return nil, err
+	}
+	return rpcValue{Name: p.Name, Key: p.Key, Value: password}, nil
+}
+
+// rpcGenerateContext returns a cli context with the defaults of the flags of
+// generate. Only symbols can be changed.
+func (s *Action) rpcGenerateContext(ctx context.Context, symbols *bool) (*cli.Context, error) {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	for _, cmd := range s.GetCommands() {
+		if cmd.Name != "generate" {
+			continue
+		}
+		for _, f := range cmd.Flags {
+			if err := f.Apply(fs); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if symbols != nil {
+		if err := fs.Set("symbols", strconv.FormatBool(*symbols)); err != nil {
+			return nil, err
+		}
+	}
+	c := cli.NewContext(cli.NewApp(), fs, nil)
+	c.Context = ctx
+	return c, nil
+}
+
+type rpcToken struct {
+	Name    string     `json:"name"`
+	Token   string     `json:"token"`
+	Type    string     `json:"type"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+func (s *Action) rpcOTP(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name string `json:"name"`
+	}
+	if err := rpcParams(raw, &p); err != nil {
+		return nil, err
+	}
+
+	sec, err := s.rpcGetSecret(ctx, p.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	key, token, err := s.otpToken(ctx, p.Name, sec, now)
+	if err != nil {
+		return nil, err
+	}
+
+	res := rpcToken{Name: p.Name, Token: token, Type: key.Type}
+	if key.Type != otp.TypeHOTP {
+		exp := key.Expires(now)
+		res.Expires = &exp
+	}
+	return res, nil
+}
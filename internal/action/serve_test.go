@@ -0,0 +1,161 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+	"github.com/itsonlycode/gosecret/tests/gptest"
+
+	"github.com/gokyle/twofactor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServe(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithInteractive(ctx, false)
+	ctx = ctxutil.WithHidden(ctx, true)
+
+	act, err := newMock(ctx, u)
+	require.NoError(t, err)
+	require.NotNil(t, act)
+
+	buf := &bytes.Buffer{}
+	out.Stdout = buf
+	stdout = buf
+	defer func() {
+		out.Stdout = os.Stdout
+		stdout = os.Stdout
+	}()
+
+	sec := secrets.New()
+	sec.SetPassword("hunter2")
+	sec.Set("user", "alice")
+	require.NoError(t, act.Store.Set(ctx, "web/example", sec))
+	sec = secrets.ParsePlain([]byte("seed\n" + twofactor.GenerateGoogleTOTP().URL("foo")))
+	require.NoError(t, act.Store.Set(ctx, "web/totp", sec))
+
+	call := func(t *testing.T, reqs ...string) []map[string]interface{} {
+		t.Helper()
+		buf.Reset()
+		require.NoError(t, act.serve(ctx, strings.NewReader(strings.Join(reqs, "\n")), buf))
+
+		var resps []map[string]interface{}
+		dec := json.NewDecoder(buf)
+		for dec.More() {
+			var resp map[string]interface{}
+			require.NoError(t, dec.Decode(&resp))
+			resps = append(resps, resp)
+		}
+		return resps
+	}
+	errCode := func(t *testing.T, resp map[string]interface{}) int {
+		t.Helper()
+		e, ok := resp["error"].(map[string]interface{})
+		require.True(t, ok, "no error in %+v", resp)
+		return int(e["code"].(float64))
+	}
+
+	t.Run("requires --stdio", func(t *testing.T) {
+		assert.Error(t, act.Serve(gptest.CliCtx(ctx, t)))
+	})
+
+	t.Run("list and get", func(t *testing.T) {
+		resps := call(t,
+			`{"jsonrpc":"2.0","id":1,"method":"list","params":{"prefix":"web/"}}`,
+			`{"jsonrpc":"2.0","id":"two","method":"get","params":{"name":"web/example"}}`,
+			`{"jsonrpc":"2.0","id":3,"method":"get","params":{"name":"web/example","key":"user"}}`,
+		)
+		require.Len(t, resps, 3)
+		assert.Equal(t, []interface{}{"web/example", "web/totp"}, resps[0]["result"])
+		assert.Equal(t, "two", resps[1]["id"])
+		res := resps[1]["result"].(map[string]interface{})
+		assert.Equal(t, "hunter2", res["password"])
+		assert.Equal(t, map[string]interface{}{"user": "alice"}, res["values"])
+		assert.Equal(t, "alice", resps[2]["result"].(map[string]interface{})["value"])
+	})
+
+	t.Run("set, move and delete", func(t *testing.T) {
+		resps := call(t,
+			`{"jsonrpc":"2.0","id":1,"method":"set","params":{"name":"rpc/a","content":"secret\nfoo: bar"}}`,
+			`{"jsonrpc":"2.0","id":2,"method":"set","params":{"name":"rpc/a","key":"baz","value":"zab"}}`,
+			`{"jsonrpc":"2.0","id":3,"method":"move","params":{"from":"rpc/a","to":"rpc/b"}}`,
+			`{"jsonrpc":"2.0","id":4,"method":"move","params":{"from":"rpc/b","to":"web/example"}}`,
+			`{"jsonrpc":"2.0","id":5,"method":"set","params":{"name":"rpc/c","content":"gone"}}`,
+			`{"jsonrpc":"2.0","method":"delete","params":{"name":"rpc/c"}}`,
+		)
+		require.Len(t, resps, 5)
+		for _, resp := range resps[:3] {
+			assert.Nil(t, resp["error"])
+		}
+		assert.Equal(t, ExitAborted, errCode(t, resps[3]))
+		assert.Nil(t, resps[4]["error"])
+
+		sec, err := act.Store.Get(ctx, "rpc/b")
+		require.NoError(t, err)
+		assert.Equal(t, "secret", sec.Password())
+		v, _ := sec.Get("baz")
+		assert.Equal(t, "zab", v)
+		assert.False(t, act.Store.Exists(ctx, "rpc/a"))
+		assert.False(t, act.Store.Exists(ctx, "rpc/c"))
+	})
+
+	t.Run("generate and otp", func(t *testing.T) {
+		resps := call(t,
+			`{"jsonrpc":"2.0","id":1,"method":"generate","params":{"name":"rpc/gen","length":12}}`,
+			`{"jsonrpc":"2.0","id":2,"method":"generate","params":{"name":"rpc/gen"}}`,
+			`{"jsonrpc":"2.0","id":3,"method":"otp","params":{"name":"web/totp"}}`,
+			`{"jsonrpc":"2.0","id":4,"method":"generate","params":{"name":"rpc/gen2","symbols":false}}`,
+		)
+		require.Len(t, resps, 4)
+		pw := resps[0]["result"].(map[string]interface{})["value"].(string)
+		assert.Len(t, pw, 12)
+		sec, err := act.Store.Get(ctx, "rpc/gen")
+		require.NoError(t, err)
+		assert.Equal(t, pw, sec.Password())
+		assert.Equal(t, ExitAborted, errCode(t, resps[1]))
+
+		res := resps[2]["result"].(map[string]interface{})
+		assert.Len(t, res["token"], 6)
+		assert.Equal(t, "totp", res["type"])
+		assert.NotEmpty(t, res["expires"])
+
+		pw = resps[3]["result"].(map[string]interface{})["value"].(string)
+		assert.NotEmpty(t, pw)
+		sec, err = act.Store.Get(ctx, "rpc/gen2")
+		require.NoError(t, err)
+		assert.Equal(t, pw, sec.Password())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		resps := call(t,
+			`not json`,
+			`{"jsonrpc":"2.0","id":1,"method":"nope"}`,
+			`{"jsonrpc":"2.0","id":2,"method":"get","params":{"name":"does/not/exist"}}`,
+			`{"jsonrpc":"2.0","id":3,"method":"get","params":{"nmae":"foo"}}`,
+			`{"jsonrpc":"2.0","id":4,"method":"otp","params":{"name":"web/example"}}`,
+			`{"jsonrpc":"1.0","id":5,"method":"get","params":{"name":"web/example"}}`,
+		)
+		require.Len(t, resps, 6)
+		assert.Nil(t, resps[0]["id"])
+		assert.Equal(t, ExitUsage, errCode(t, resps[0]))
+		assert.Equal(t, ExitUsage, errCode(t, resps[1]))
+		assert.Equal(t, ExitNotFound, errCode(t, resps[2]))
+		assert.Equal(t, ExitUsage, errCode(t, resps[3]))
+		assert.Equal(t, ExitUnknown, errCode(t, resps[4]))
+		assert.Contains(t, resps[1]["error"].(map[string]interface{})["message"], "unknown method")
+		assert.Equal(t, ExitUsage, errCode(t, resps[5]))
+		assert.Contains(t, resps[5]["error"].(map[string]interface{})["message"], "unsupported jsonrpc version")
+	})
+}
@@ -25,6 +25,9 @@ func (s *Action) Setup(c *cli.Context) error {
 	create := c.Bool("create")
 
 	ctx = initParseContext(ctx, c)
+	if err := checkStorageBackend(backend.GetStorageBackend(ctx)); err != nil {
+		return err
+	}
 
 	out.Printf(ctx, logo)
 	out.Printf(ctx, "🌟 Welcome to gosecret!")
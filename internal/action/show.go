@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/itsonlycode/gosecret/internal/backend"
 	"github.com/itsonlycode/gosecret/internal/notify"
 	"github.com/itsonlycode/gosecret/internal/out"
 	"github.com/itsonlycode/gosecret/internal/store"
@@ -44,6 +45,12 @@ func showParseArgs(c *cli.Context) context.Context {
 	if c.IsSet("noparsing") {
 		ctx = ctxutil.WithShowParsing(ctx, !c.Bool("noparsing"))
 	}
+	if c.IsSet("no-follow") {
+		ctx = WithFollowLinks(ctx, !c.Bool("no-follow"))
+	}
+	if c.IsSet("follow") {
+		ctx = WithFollowLinks(ctx, c.Bool("follow"))
+	}
 	ctx = WithClip(ctx, IsOnlyClip(ctx) || IsAlsoClip(ctx))
 	return ctx
 }
@@ -78,6 +85,10 @@ func (s *Action) show(ctx context.Context, c *cli.Context, name string, recurse
 		out.Warningf(ctx, "%s is a secret and a folder. Use 'gosecret show %s' to display the secret and 'gosecret list %s' to show the content of the folder", name, name, name)
 	}
 
+	if !IsFollowLinks(ctx) && s.Store.IsLink(ctx, name) {
+		return s.showLink(ctx, name)
+	}
+
 	if HasRevision(ctx) {
 		return s.showHandleRevision(ctx, c, name, GetRevision(ctx))
 	}
@@ -90,6 +101,19 @@ func (s *Action) show(ctx context.Context, c *cli.Context, name string, recurse
 	return s.showHandleOutput(ctx, name, sec)
 }
 
+// showLink displays the target of a link instead of the secret it points to
+func (s *Action) showLink(ctx context.Context, name string) error {
+	target, err := s.Store.LinkTarget(ctx, name)
+	if err != nil {
+		return ExitError(ExitNotFound, err, "failed to read link %s: %s", name, err)
+	}
+	if _, err := s.Store.ResolveLink(ctx, name); err != nil {
+		out.Warningf(ctx, "%s", err)
+	}
+	out.Printf(ctx, "%s -> %s", name, target)
+	return nil
+}
+
 // showHandleRevision displays a single revision
 func (s *Action) showHandleRevision(ctx context.Context, c *cli.Context, name, revision string) error {
 	revision, err := s.parseRevision(ctx, name, revision)
@@ -123,16 +147,24 @@ func (s *Action) parseRevision(ctx context.Context, name, revision string) (stri
 		return "", err
 	}
 
-	if len(revs) < offset {
+	revision, ok := offsetRevision(revs, offset)
+	if !ok {
 		debug.Log("Not enough revisions (%d)", len(revs))
 		return revStr, nil
 	}
-
-	revision = revs[len(revs)-offset].Hash
 	debug.Log("Found %s for offset %d", revision, offset)
 	return revision, nil
 }
 
+// offsetRevision returns the revision at the offset. The revisions are
+// listed newest first, the offset counts from the end of that list.
+func offsetRevision(revs []backend.Revision, offset int) (string, bool) {
+	if offset < 1 || len(revs) < offset {
+		return "", false
+	}
+	return revs[len(revs)-offset].Hash, true
+}
+
 // showHandleOutput displays a secret
 func (s *Action) showHandleOutput(ctx context.Context, name string, sec gosecret.Secret) error {
 	pw, body, err := s.showGetContent(ctx, sec)
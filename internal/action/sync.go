@@ -98,12 +98,16 @@ func (s *Action) syncMount(ctx context.Context, mp string) error {
 		out.Printf(ctxno, "\n   WARNING: Mount uses Storage backend 'fs'. Not syncing!\n")
 	} else {
 		out.Printf(ctxno, "\n   "+color.GreenString("git pull and push ... "))
-		if err := sub.Storage().Push(ctx, "", ""); err != nil {
+		if err := sub.Sync(ctx); err != nil {
 			if errors.Is(err, store.ErrGitNoRemote) {
 				out.Printf(ctx, "Skipped (no remote)")
 				debug.Log("Failed to push %q to its remote: %s", name, err)
 				return err
 			}
+			if errors.Is(err, store.ErrGitUnverified) {
+				out.Errorf(ctx, "Rejected changes of %q: %s", name, err)
+				return err
+			}
 
 			out.Errorf(ctx, "Failed to push %q to its remote: %s", name, err)
 			return err
@@ -45,6 +45,17 @@ func (s *Action) Version(c *cli.Context) error {
 		}
 	}
 
+	if ctxutil.GetSyncInterval(ctx) > 0 {
+		fmt.Fprintf(stdout, "Sync:\n")
+		for _, mp := range append([]string{""}, s.Store.MountPoints()...) {
+			name := mp
+			if name == "" {
+				name = "<root>"
+			}
+			fmt.Fprintf(stdout, "%-10s - %s\n", name, s.syncState(ctx, mp))
+		}
+	}
+
 	fmt.Fprintf(stdout, "Available Crypto Backends: %s\n", strings.Join(backend.CryptoBackends(), ", "))
 	fmt.Fprintf(stdout, "Available Storage Backends: %s\n", strings.Join(backend.StorageBackends(), ", "))
 
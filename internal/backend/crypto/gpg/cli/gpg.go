@@ -4,6 +4,7 @@ package cli
 import (
 	"context"
 	"os"
+	"os/exec"
 
 	"github.com/itsonlycode/gosecret/internal/backend/crypto/gpg"
 	"github.com/itsonlycode/gosecret/pkg/debug"
@@ -106,3 +107,13 @@ func (g *GPG) IDFile() string {
 func (g *GPG) Concurrency() int {
 	return 1
 }
+
+// Lock makes the gpg-agent forget all cached passphrases
+func (g *GPG) Lock() {
+	cmd := exec.Command("gpgconf", "--reload", "gpg-agent")
+	if buf, err := cmd.CombinedOutput(); err != nil {
+		debug.Log("failed to reload gpg-agent: %s: %s", err, string(buf))
+		return
+	}
+	debug.Log("reloaded gpg-agent")
+}
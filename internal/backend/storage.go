@@ -22,12 +22,26 @@ const (
 	FS StorageBackend = iota
 	// GitFS is a filesystem-backed storage with Git
 	GitFS
+	// InMem is an in-memory storage with a simple revision history
+	InMem
+	// Overlay is an in-memory layer that stages changes over another storage
+	Overlay
+	// GoGit is a filesystem-backed storage with Git, implemented in pure Go
+	GoGit
+	// S3 is a storage in an S3-compatible bucket with object versioning
+	S3
 )
 
 func (s StorageBackend) String() string {
 	return StorageNameFromBackend(s)
 }
 
+// IsPersistent returns false for the storage backends that only keep the
+// secrets in memory. They are meant for library users and sessions.
+func (s StorageBackend) IsPersistent() bool {
+	return s != InMem && s != Overlay
+}
+
 // Storage is an storage backend
 type Storage interface {
 	fmt.Stringer
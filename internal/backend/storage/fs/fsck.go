@@ -59,6 +59,11 @@ func (s *Store) Fsck(ctx context.Context) error {
 func (s *Store) fsckCheckFile(ctx context.Context, filename string) error {
 	fi, err := os.Stat(filename)
 	if err != nil {
+		// dangling symlinks are reported (and pruned) by the store
+		if lfi, lerr := os.Lstat(filename); lerr == nil && lfi.Mode()&os.ModeSymlink != 0 {
+			debug.Log("skipping dangling symlink %q", filename)
+			return nil
+		}
 		return err
 	}
 
@@ -10,6 +10,7 @@ import (
 
 	"github.com/itsonlycode/gosecret/internal/out"
 	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
 	"github.com/itsonlycode/gosecret/pkg/debug"
 )
 
@@ -68,6 +69,14 @@ func (g *Git) InitConfig(ctx context.Context, userName, userEmail string) error
 		out.Printf(ctx, "Git Email not set")
 	}
 
+	// sign every commit
+	if key := ctxutil.GetSigningKey(ctx); key != "" {
+		if err := g.configureSigning(ctx, key); err != nil {
+			return err
+		}
+		out.Printf(ctx, "Git commits are signed with %s", key)
+	}
+
 	// ensure sane git config
 	if err := g.fixConfig(ctx); err != nil {
 		return fmt.Errorf("failed to fix git config: %w", err)
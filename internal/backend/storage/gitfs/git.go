@@ -278,8 +278,9 @@ func (g *Git) PushPull(ctx context.Context, op, remote, branch string) error {
 		return store.ErrGitNoRemote
 	}
 
-	if err := g.Cmd(ctx, "gitPush", "pull", remote, branch); err != nil {
-		if op == "pull" {
+	if err := g.pull(ctx, remote, branch); err != nil {
+		// never push on top of commits that could not be verified
+		if op == "pull" || isUnverified(err) {
 			return err
 		}
 		out.Warningf(ctx, "Failed to pull before git push: %s", err)
@@ -322,10 +323,14 @@ func (g *Git) RemoveRemote(ctx context.Context, remote string) error {
 	return g.Cmd(ctx, "gitRemoveRemote", "remote", "remove", remote)
 }
 
-// Revisions will list all available revisions of the named entity
+// Revisions will list all available revisions of the named entity. An empty
+// name lists the revisions of the whole store.
 // see http://blog.lost-theory.org/post/how-to-parse-git-log-output/
 // and https://git-scm.com/docs/git-log#_pretty_formats
 func (g *Git) Revisions(ctx context.Context, name string) ([]backend.Revision, error) {
+	if name == "" {
+		name = "."
+	}
 	args := []string{
 		"log",
 		`--format=%H%x1f%an%x1f%ae%x1f%at%x1f%s%x1f%b%x1e`,
@@ -391,6 +396,22 @@ func (g *Git) GetRevision(ctx context.Context, name, revision string) ([]byte, e
 	return stdout, nil
 }
 
+// ListRevision lists all files of the store at the revision
+func (g *Git) ListRevision(ctx context.Context, revision string) ([]string, error) {
+	stdout, stderr, err := g.captureCmd(ctx, "ListRevision", "ls-tree", "-r", "-z", "--name-only", strings.TrimSpace(revision))
+	if err != nil {
+		debug.Log("Command failed: %s", string(stderr))
+		return nil, err
+	}
+	var names []string
+	for _, name := range strings.Split(string(stdout), "\x00") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
 // Status return the git status output
 func (g *Git) Status(ctx context.Context) ([]byte, error) {
 	stdout, stderr, err := g.captureCmd(ctx, "GitStatus", "status")
@@ -83,5 +83,14 @@ func TestGit(t *testing.T) {
 		content, err := git.GetRevision(ctx, "some-other-file", revs[0].Hash)
 		require.NoError(t, err)
 		assert.Equal(t, "foobar", string(content))
+
+		files, err := git.ListRevision(ctx, revs[0].Hash)
+		require.NoError(t, err)
+		assert.Contains(t, files, "some-other-file")
+
+		all, err := git.Revisions(ctx, "")
+		require.NoError(t, err)
+		require.True(t, len(all) >= len(revs))
+		assert.Equal(t, revs[0].Hash, all[0].Hash)
 	})
 }
@@ -3,6 +3,7 @@ package gitfs
 import (
 	"context"
 	"fmt"
+	"os/exec"
 	"path/filepath"
 
 	"github.com/itsonlycode/gosecret/internal/backend"
@@ -43,6 +44,9 @@ func (l loader) Handles(path string) error {
 	if !fsutil.IsDir(filepath.Join(path, ".git")) {
 		return fmt.Errorf("no .git")
 	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("git not found: %w", err)
+	}
 	return nil
 }
 
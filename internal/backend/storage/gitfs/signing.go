@@ -0,0 +1,350 @@
+package gitfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+)
+
+const (
+	// SignersFile lists the keys that may sign commits of the store. Once
+	// it's in the store, pulled commits must be signed by one of them.
+	SignersFile = ".gosecret-signers"
+	// QuarantinePrefix is the prefix of the refs that keep fetched commits
+	// that failed verification
+	QuarantinePrefix = "refs/gosecret/quarantine/"
+)
+
+// sshKeyTypes are the prefixes of SSH public keys
+var sshKeyTypes = []string{"ssh-", "ecdsa-", "sk-"}
+
+func isSSHKey(key string) bool {
+	for _, p := range sshKeyTypes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// configureSigning makes git sign every commit with the given key. SSH
+// public keys, files with them and key:: literals use SSH signing,
+// everything else is a GPG key.
+func (g *Git) configureSigning(ctx context.Context, key string) error {
+	format := "openpgp"
+	if isSSHKey(key) || strings.HasPrefix(key, "key::") || strings.HasSuffix(key, ".pub") {
+		format = "ssh"
+	}
+	for _, kv := range [][2]string{
+		{"gpg.format", format},
+		{"user.signingkey", key},
+		{"commit.gpgsign", "true"},
+	} {
+		if err := g.ConfigSet(ctx, kv[0], kv[1]); err != nil {
+			return fmt.Errorf("failed to set git config %s: %w", kv[0], err)
+		}
+	}
+	return nil
+}
+
+// SignsCommits returns true if git is configured to sign every commit
+func (g *Git) SignsCommits(ctx context.Context) (bool, error) {
+	v, err := g.ConfigGet(ctx, "commit.gpgsign")
+	if err != nil {
+		debug.Log("commit.gpgsign is not set: %s", err)
+		return false, nil
+	}
+	return v == "true", nil
+}
+
+// signers is the allowlist of the keys that may sign commits
+type signers struct {
+	// fingerprints of GPG keys or long key ids in upper case and of SSH
+	// keys as SHA256:...
+	fingerprints []string
+	// sshKeys are the SSH public keys as type and base64 blob
+	sshKeys []string
+}
+
+// parseSigners parses a SignersFile. Every line holds a GPG fingerprint, an
+// SSH public key or the SHA256 fingerprint of one. Everything after a #
+// is a comment.
+func parseSigners(buf []byte) (signers, error) {
+	var s signers
+
+	sc := bufio.NewScanner(bytes.NewReader(buf))
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case isSSHKey(line):
+			p := strings.Fields(line)
+			if len(p) < 2 {
+				return s, fmt.Errorf("line %d: invalid SSH key", n)
+			}
+			blob, err := base64.StdEncoding.DecodeString(p[1])
+			if err != nil {
+				return s, fmt.Errorf("line %d: invalid SSH key: %w", n, err)
+			}
+			sum := sha256.Sum256(blob)
+			s.sshKeys = append(s.sshKeys, p[0]+" "+p[1])
+			s.fingerprints = append(s.fingerprints, "SHA256:"+base64.RawStdEncoding.EncodeToString(sum[:]))
+		case strings.HasPrefix(line, "SHA256:"):
+			s.fingerprints = append(s.fingerprints, line)
+		default:
+			fp := strings.ToUpper(strings.TrimPrefix(strings.ReplaceAll(line, " ", ""), "0x"))
+			if len(fp) < 16 || strings.Trim(fp, "0123456789ABCDEF") != "" {
+				return s, fmt.Errorf("line %d: %q is neither a GPG fingerprint nor an SSH key", n, line)
+			}
+			s.fingerprints = append(s.fingerprints, fp)
+		}
+	}
+	return s, sc.Err()
+}
+
+// allows returns true if one of the fingerprints is allowed. GPG keys may
+// be listed by their long key id.
+func (s signers) allows(fprs ...string) bool {
+	for _, fpr := range fprs {
+		if fpr == "" {
+			continue
+		}
+		for _, allowed := range s.fingerprints {
+			if strings.HasPrefix(allowed, "SHA256:") {
+				if fpr == allowed {
+					return true
+				}
+				continue
+			}
+			if strings.HasSuffix(strings.ToUpper(fpr), allowed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// signers returns the allowlist of the store. It's not an error if there is
+// none, commits are not verified then.
+func (g *Git) signers(ctx context.Context) (signers, bool, error) {
+	if !g.fs.Exists(ctx, SignersFile) {
+		return signers{}, false, nil
+	}
+	buf, err := g.fs.Get(ctx, SignersFile)
+	if err != nil {
+		return signers{}, false, fmt.Errorf("failed to read %s: %w", SignersFile, err)
+	}
+	s, err := parseSigners(buf)
+	if err != nil {
+		return s, false, fmt.Errorf("invalid %s: %w", SignersFile, err)
+	}
+	return s, true, nil
+}
+
+// UnverifiedCommit is a commit that is not signed by an allowed key
+type UnverifiedCommit struct {
+	Hash    string
+	Subject string
+	Reason  string
+}
+
+func (c UnverifiedCommit) String() string {
+	h := c.Hash
+	if len(h) > 10 {
+		h = h[:10]
+	}
+	return fmt.Sprintf("%s %s: %s", h, c.Subject, c.Reason)
+}
+
+// unverifiedReason explains why a commit with the given signature status
+// (see %G? in git log) and fingerprints is not accepted. It's empty for
+// commits signed by an allowed key.
+func unverifiedReason(s signers, status, fpr, primary string) string {
+	switch status {
+	case "G", "U":
+		if s.allows(fpr, primary) {
+			return ""
+		}
+		return fmt.Sprintf("signed by %s, which is not in %s", fpr, SignersFile)
+	case "N":
+		return "not signed"
+	case "B":
+		return "bad signature"
+	case "E":
+		return "the signature can't be checked, the key is missing"
+	case "X", "Y":
+		return "the signature or its key expired"
+	case "R":
+		return "the key was revoked"
+	default:
+		return "unknown signature status " + status
+	}
+}
+
+// verifyCommits returns the commits selected by the git log arguments, e.g. a
+// range, that are not signed by an allowed key
+func (g *Git) verifyCommits(ctx context.Context, s signers, rng ...string) ([]UnverifiedCommit, error) {
+	// git verifies SSH signatures against an allowed signers file. It only
+	// holds the allowed keys, so others show up as valid but unknown.
+	fh, err := os.CreateTemp("", "gosecret-signers-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create allowed signers: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(fh.Name())
+	}()
+	for _, k := range s.sshKeys {
+		fmt.Fprintf(fh, "gosecret namespaces=\"git\" %s\n", k)
+	}
+	if err := fh.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write allowed signers: %w", err)
+	}
+
+	args := append([]string{
+		"-c", "gpg.ssh.allowedSignersFile=" + filepath.ToSlash(fh.Name()),
+		"log", "--format=%H%x00%G?%x00%GF%x00%GP%x00%s",
+	}, rng...)
+	stdout, stderr, err := g.captureCmd(ctx, "gitVerify", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits %s: %s: %w", strings.Join(rng, " "), strings.TrimSpace(string(stderr)), err)
+	}
+
+	var bad []UnverifiedCommit
+	for _, line := range strings.Split(string(stdout), "\n") {
+		p := strings.SplitN(line, "\x00", 5)
+		if len(p) < 5 {
+			continue
+		}
+		if reason := unverifiedReason(s, p[1], p[2], p[3]); reason != "" {
+			debug.Log("commit %s is not verified: %s", p[0], reason)
+			bad = append(bad, UnverifiedCommit{Hash: p[0], Subject: p[4], Reason: reason})
+		}
+	}
+	return bad, nil
+}
+
+// UnverifiedError is returned if pulled commits are not signed by an
+// allowed key. They are not merged, but kept in a quarantine ref.
+type UnverifiedError struct {
+	Path    string
+	Remote  string
+	Branch  string
+	Ref     string
+	Commits []UnverifiedCommit
+}
+
+func (e *UnverifiedError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d commits from %s/%s are not signed by an allowed key and were not merged:", len(e.Commits), e.Remote, e.Branch)
+	for _, c := range e.Commits {
+		sb.WriteString("\n  " + c.String())
+	}
+	fmt.Fprintf(&sb, "\nThey are kept in %s. Inspect them with: git -C %s log -p HEAD..%s", e.Ref, e.Path, e.Ref)
+	return sb.String()
+}
+
+// Unwrap returns store.ErrGitUnverified
+func (e *UnverifiedError) Unwrap() error {
+	return store.ErrGitUnverified
+}
+
+// pull pulls from the remote. If the store has a SignersFile the changes
+// are fetched and only merged if all new commits are signed by an allowed
+// key. Otherwise they are quarantined.
+func (g *Git) pull(ctx context.Context, remote, branch string) error {
+	s, found, err := g.signers(ctx)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return g.Cmd(ctx, "gitPull", "pull", remote, branch)
+	}
+
+	if err := g.Cmd(ctx, "gitFetch", "fetch", remote, branch); err != nil {
+		return err
+	}
+	ref := QuarantinePrefix + remote + "/" + branch
+	bad, err := g.verifyCommits(ctx, s, "HEAD..FETCH_HEAD")
+	if err != nil {
+		return err
+	}
+	if len(bad) > 0 {
+		if err := g.Cmd(ctx, "gitQuarantine", "update-ref", ref, "FETCH_HEAD"); err != nil {
+			return fmt.Errorf("failed to quarantine unverified commits: %w", err)
+		}
+		return &UnverifiedError{Path: g.fs.Path(), Remote: remote, Branch: branch, Ref: ref, Commits: bad}
+	}
+
+	if err := g.Cmd(ctx, "gitMerge", "merge", "--no-edit", "FETCH_HEAD"); err != nil {
+		return err
+	}
+	if g.Cmd(ctx, "gitQuarantine", "show-ref", "--verify", "--quiet", ref) == nil {
+		debug.Log("removing quarantine %s, the remote only has verified commits now", ref)
+		_ = g.Cmd(ctx, "gitQuarantine", "update-ref", "-d", ref)
+	}
+	return nil
+}
+
+// fsckSignatures checks that all commits since the SignersFile was added are
+// signed by an allowed key and reports quarantined commits
+func (g *Git) fsckSignatures(ctx context.Context) error {
+	s, found, err := g.signers(ctx)
+	if err != nil || !found {
+		return err
+	}
+
+	stdout, _, err := g.captureCmd(ctx, "gitQuarantine", "for-each-ref", "--format=%(refname)", QuarantinePrefix)
+	if err == nil {
+		for _, ref := range strings.Fields(string(stdout)) {
+			out.Warningf(ctx, "Unverified commits fetched from %s are quarantined in %s. Inspect them with: git -C %s log -p HEAD..%s", strings.TrimPrefix(ref, QuarantinePrefix), ref, g.fs.Path(), ref)
+		}
+	}
+
+	// commits before the allowlist was added can't be verified
+	stdout, _, err = g.captureCmd(ctx, "gitSigners", "log", "--diff-filter=A", "--format=%H", "--", SignersFile)
+	added := strings.Fields(string(stdout))
+	if err != nil || len(added) < 1 {
+		debug.Log("%s is not committed, yet", SignersFile)
+		return nil
+	}
+	first := added[len(added)-1]
+	bad, err := g.verifyCommits(ctx, s, first+"..HEAD")
+	if err != nil {
+		return err
+	}
+	// the commit that added the allowlist must be signed, too. It may be the
+	// root commit, so it's checked on its own instead of starting the range
+	// at its parent.
+	badFirst, err := g.verifyCommits(ctx, s, "-1", first)
+	if err != nil {
+		return err
+	}
+	bad = append(bad, badFirst...)
+	for _, c := range bad {
+		out.Errorf(ctx, "Commit %s", c)
+	}
+	if len(bad) > 0 {
+		return fmt.Errorf("%d commits since %s was added: %w", len(bad), SignersFile, store.ErrGitUnverified)
+	}
+	return nil
+}
+
+// isUnverified returns true if the error is about unverified commits
+func isUnverified(err error) bool {
+	return errors.Is(err, store.ErrGitUnverified)
+}
@@ -0,0 +1,222 @@
+package gitfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSSHKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMGSNLmvYSIny3Gf4fEpMTME85e4foFly9WPJ2whRY2A alice@example.org"
+	testSSHFpr = "SHA256:+e7eBfQOlrpeqnRypE6eZxvdDHoytemff7XZkI397Po"
+)
+
+func TestParseSigners(t *testing.T) {
+	s, err := parseSigners([]byte(`# the team
+0123 4567 89AB CDEF 0123  4567 89AB CDEF 0123 4567 # bob
+0xdeadbeefdeadbeef
+
+` + testSSHKey + `
+SHA256:abcdef
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"0123456789ABCDEF0123456789ABCDEF01234567",
+		"DEADBEEFDEADBEEF",
+		testSSHFpr,
+		"SHA256:abcdef",
+	}, s.fingerprints)
+	assert.Equal(t, []string{strings.Join(strings.Fields(testSSHKey)[:2], " ")}, s.sshKeys)
+
+	assert.True(t, s.allows("0123456789ABCDEF0123456789ABCDEF01234567"))
+	assert.True(t, s.allows("", "00000000000000000000000000000000deadbeefdeadbeef"))
+	assert.True(t, s.allows(testSSHFpr))
+	assert.False(t, s.allows("SHA256:abcdefg"))
+	assert.False(t, s.allows("0123456789ABCDEF0123456789ABCDEF01234568"))
+	assert.False(t, s.allows(""))
+
+	for _, in := range []string{"deadbeef", "foo bar", "ssh-ed25519", "ssh-ed25519 !!!"} {
+		_, err := parseSigners([]byte(in))
+		assert.Error(t, err, in)
+	}
+}
+
+func TestUnverifiedReason(t *testing.T) {
+	s, err := parseSigners([]byte("DEADBEEFDEADBEEF\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "", unverifiedReason(s, "G", "00DEADBEEFDEADBEEF", ""))
+	assert.Equal(t, "", unverifiedReason(s, "U", "1111111111111111", "DEADBEEFDEADBEEF"))
+	assert.Contains(t, unverifiedReason(s, "G", "1111111111111111", ""), "not in "+SignersFile)
+	for _, status := range []string{"N", "B", "E", "X", "Y", "R", "?"} {
+		assert.NotEqual(t, "", unverifiedReason(s, status, "DEADBEEFDEADBEEF", ""), status)
+	}
+}
+
+// sshKey creates an SSH key and returns the file of its public key
+func sshKey(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	fn := filepath.Join(dir, name)
+	require.NoError(t, exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", name, "-f", fn).Run())
+	return fn + ".pub"
+}
+
+func commitFile(ctx context.Context, t *testing.T, g *Git, name string) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(filepath.Join(g.Path(), name), []byte(name), 0644))
+	require.NoError(t, g.Add(ctx, name))
+	require.NoError(t, g.Commit(ctx, "add "+name))
+}
+
+func TestSignedPull(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is required to sign commits")
+	}
+
+	td := t.TempDir()
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+
+	buf := &bytes.Buffer{}
+	out.Stdout = buf
+	out.Stderr = buf
+	defer func() {
+		out.Stdout = os.Stdout
+		out.Stderr = os.Stderr
+	}()
+
+	alice := sshKey(t, td, "alice")
+	mallory := sshKey(t, td, "mallory")
+	alicePub, err := os.ReadFile(alice)
+	require.NoError(t, err)
+
+	remoteDir := filepath.Join(td, "remote")
+	require.NoError(t, os.Mkdir(remoteDir, 0755))
+	remote, err := Init(ctxutil.WithSigningKey(ctx, alice), remoteDir, "Alice", "alice@example.org")
+	require.NoError(t, err)
+	v, err := remote.ConfigGet(ctx, "gpg.format")
+	require.NoError(t, err)
+	assert.Equal(t, "ssh", v)
+	v, err = remote.ConfigGet(ctx, "commit.gpgsign")
+	require.NoError(t, err)
+	assert.Equal(t, "true", v)
+	signs, err := remote.SignsCommits(ctx)
+	require.NoError(t, err)
+	assert.True(t, signs)
+
+	require.NoError(t, os.WriteFile(filepath.Join(remoteDir, SignersFile), alicePub, 0644))
+	require.NoError(t, remote.Add(ctx, SignersFile))
+	require.NoError(t, remote.Commit(ctx, "add signers"))
+	require.NoError(t, remote.Cmd(ctx, "config", "config", "receive.denyCurrentBranch", "ignore"))
+
+	localDir := filepath.Join(td, "local")
+	local, err := Clone(ctx, remoteDir, localDir)
+	require.NoError(t, err)
+	require.NoError(t, local.InitConfig(ctxutil.WithSigningKey(ctx, alice), "Alice", "alice@example.org"))
+	branch := local.defaultBranch(ctx)
+	quarantine := QuarantinePrefix + "origin/" + branch
+
+	t.Run("signed by an allowed key", func(t *testing.T) {
+		commitFile(ctx, t, remote, "foo")
+		require.NoError(t, local.Pull(ctx, "origin", branch))
+		assert.True(t, local.Exists(ctx, "foo"))
+		assert.NoError(t, local.fsckSignatures(ctx))
+	})
+
+	t.Run("signed by another key", func(t *testing.T) {
+		require.NoError(t, remote.ConfigSet(ctx, "user.signingkey", mallory))
+		commitFile(ctx, t, remote, "bar")
+
+		err := local.Pull(ctx, "origin", branch)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, store.ErrGitUnverified))
+		assert.Contains(t, err.Error(), "add bar: signed by SHA256:")
+		assert.Contains(t, err.Error(), quarantine)
+		assert.False(t, local.Exists(ctx, "bar"))
+		assert.NoError(t, local.Cmd(ctx, "show-ref", "show-ref", "--verify", quarantine))
+
+		// nothing is pushed on top of them
+		assert.True(t, errors.Is(local.Push(ctx, "origin", branch), store.ErrGitUnverified))
+
+		// fsck reports the quarantine
+		buf.Reset()
+		assert.NoError(t, local.fsckSignatures(ctx))
+		assert.Contains(t, buf.String(), quarantine)
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		require.NoError(t, remote.Cmd(ctx, "reset", "reset", "--hard", "HEAD^"))
+		require.NoError(t, remote.ConfigSet(ctx, "commit.gpgsign", "false"))
+		commitFile(ctx, t, remote, "baz")
+
+		err := local.Pull(ctx, "origin", branch)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "add baz: not signed")
+		assert.False(t, local.Exists(ctx, "baz"))
+	})
+
+	t.Run("quarantine is lifted", func(t *testing.T) {
+		require.NoError(t, remote.Cmd(ctx, "reset", "reset", "--hard", "HEAD^"))
+		require.NoError(t, remote.ConfigSet(ctx, "commit.gpgsign", "true"))
+		require.NoError(t, remote.ConfigSet(ctx, "user.signingkey", alice))
+		commitFile(ctx, t, remote, "zab")
+
+		require.NoError(t, local.Pull(ctx, "origin", branch))
+		assert.True(t, local.Exists(ctx, "zab"))
+		assert.Error(t, local.Cmd(ctx, "show-ref", "show-ref", "--verify", "--quiet", quarantine))
+	})
+
+	t.Run("fsck finds unsigned local commits", func(t *testing.T) {
+		require.NoError(t, local.ConfigSet(ctx, "commit.gpgsign", "false"))
+		commitFile(ctx, t, local, "oof")
+
+		err := local.fsckSignatures(ctx)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, store.ErrGitUnverified))
+	})
+
+	t.Run("fsck checks the commit that added the signers", func(t *testing.T) {
+		dir := filepath.Join(td, "root")
+		require.NoError(t, os.Mkdir(dir, 0755))
+		require.NoError(t, exec.Command("git", "init", "-q", dir).Run())
+		g, err := New(dir)
+		require.NoError(t, err)
+		for k, v := range map[string]string{"user.name": "Alice", "user.email": "alice@example.org", "gpg.format": "ssh", "commit.gpgsign": "false"} {
+			require.NoError(t, g.ConfigSet(ctx, k, v))
+		}
+
+		// the signers file is added by the unsigned root commit
+		require.NoError(t, os.WriteFile(filepath.Join(dir, SignersFile), alicePub, 0644))
+		require.NoError(t, g.Add(ctx, SignersFile))
+		require.NoError(t, g.Commit(ctx, "add signers"))
+
+		buf.Reset()
+		err = g.fsckSignatures(ctx)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, store.ErrGitUnverified))
+		assert.Contains(t, buf.String(), "add signers: not signed")
+
+		// later commits are checked as well
+		require.NoError(t, g.ConfigSet(ctx, "user.signingkey", alice))
+		require.NoError(t, g.ConfigSet(ctx, "commit.gpgsign", "true"))
+		commitFile(ctx, t, g, "foo")
+		buf.Reset()
+		err = g.fsckSignatures(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "1 commits")
+	})
+}
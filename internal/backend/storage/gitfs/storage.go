@@ -58,6 +58,9 @@ func (g *Git) Fsck(ctx context.Context) error {
 	if err := g.fixConfig(ctx); err != nil {
 		return fmt.Errorf("failed to fix git config: %w", err)
 	}
+	if err := g.fsckSignatures(ctx); err != nil {
+		return err
+	}
 	return g.fs.Fsck(ctx)
 }
 
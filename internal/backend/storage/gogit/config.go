@@ -0,0 +1,176 @@
+package gogit
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/config"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+)
+
+// splitKey splits section.subsection.key. Sections and keys are case
+// insensitive, subsections are not.
+func splitKey(key string) (string, string, string) {
+	first := strings.Index(key, ".")
+	last := strings.LastIndex(key, ".")
+	if first < 0 {
+		return "", "", strings.ToLower(key)
+	}
+	section := strings.ToLower(key[:first])
+	name := strings.ToLower(key[last+1:])
+	if first == last {
+		return section, "", name
+	}
+	return section, key[first+1 : last], name
+}
+
+// getOption returns the last value of the key, e.g. remote.origin.url
+func getOption(raw *format.Config, key string) (string, bool) {
+	section, sub, name := splitKey(key)
+	if !raw.HasSection(section) {
+		return "", false
+	}
+	s := raw.Section(section)
+	opts := s.Options
+	if sub != "" {
+		if !s.HasSubsection(sub) {
+			return "", false
+		}
+		opts = s.Subsection(sub).Options
+	}
+	if !opts.Has(name) {
+		return "", false
+	}
+	return opts.Get(name), true
+}
+
+// setOption replaces all values of the key
+func setOption(raw *format.Config, key, value string) {
+	section, sub, name := splitKey(key)
+	raw.SetOption(section, sub, name, value)
+}
+
+// listOptions returns all values by their full key, e.g. remote.origin.url
+func listOptions(raw *format.Config) map[string]string {
+	kv := map[string]string{}
+	for _, s := range raw.Sections {
+		section := strings.ToLower(s.Name)
+		for _, o := range s.Options {
+			kv[section+"."+strings.ToLower(o.Key)] = o.Value
+		}
+		for _, ss := range s.Subsections {
+			for _, o := range ss.Options {
+				kv[section+"."+ss.Name+"."+strings.ToLower(o.Key)] = o.Value
+			}
+		}
+	}
+	return kv
+}
+
+// config returns the local git config
+func (g *Git) config() (*format.Config, error) {
+	cfg, err := g.repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Raw, nil
+}
+
+// updateConfig changes the local git config with fn and writes it. go-git
+// keeps remotes, branches and the user in fields that take precedence over
+// the raw config when it's written, so the changed raw config is parsed
+// again before it's stored.
+func (g *Git) updateConfig(fn func(raw *format.Config) error) error {
+	cfg, err := g.repo.Config()
+	if err != nil {
+		return err
+	}
+	if err := fn(cfg.Raw); err != nil {
+		return err
+	}
+	if err := quoteValues(cfg.Raw); err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := format.NewEncoder(buf).Encode(cfg.Raw); err != nil {
+		return err
+	}
+	updated := config.NewConfig()
+	if err := updated.Unmarshal(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := quoteValues(updated.Raw); err != nil {
+		return err
+	}
+	return g.repo.SetConfig(updated)
+}
+
+// quoteValues quotes the values that the encoder of go-git would write as
+// they are, although git would read them differently, e.g. with a comment
+// character. The encoder only quotes values with backslashes. go-git writes
+// the values it keeps in fields, like user.name or remote URLs, again
+// without quotes, so those still can't have comment characters.
+func quoteValues(raw *format.Config) error {
+	quote := func(opts format.Options) error {
+		for _, o := range opts {
+			v := o.Value
+			if strings.Contains(v, `\`) || (!strings.ContainsAny(v, "#;\"\n") && strings.TrimSpace(v) == v) {
+				continue
+			}
+			if strings.ContainsAny(v, "\"\n") {
+				return fmt.Errorf("can not write %q to the git config", v)
+			}
+			o.Value = `"` + v + `"`
+		}
+		return nil
+	}
+	for _, s := range raw.Sections {
+		if err := quote(s.Options); err != nil {
+			return err
+		}
+		for _, ss := range s.Subsections {
+			if err := quote(ss.Options); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// globalConfigFiles returns the global config files, like git in the order
+// of precedence
+func globalConfigFiles() []string {
+	var files []string
+	home, err := os.UserHomeDir()
+	if err == nil {
+		files = append(files, filepath.Join(home, ".gitconfig"))
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		files = append(files, filepath.Join(xdg, "git", "config"))
+	} else if err == nil {
+		files = append(files, filepath.Join(home, ".config", "git", "config"))
+	}
+	return files
+}
+
+// globalConfigs returns the global git configs that exist and can be parsed
+func globalConfigs() []*format.Config {
+	var cfgs []*format.Config
+	for _, fn := range globalConfigFiles() {
+		fh, err := os.Open(fn)
+		if err != nil {
+			continue
+		}
+		cfg, err := config.ReadConfig(fh)
+		_ = fh.Close()
+		if err != nil {
+			continue
+		}
+		cfgs = append(cfgs, cfg.Raw)
+	}
+	return cfgs
+}
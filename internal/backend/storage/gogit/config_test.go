@@ -0,0 +1,85 @@
+package gogit
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	ctx := testContext(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	dir := filepath.Join(t.TempDir(), "store")
+	g, err := Init(ctx, dir, "Alice", "alice@example.org")
+	require.NoError(t, err)
+
+	fn := filepath.Join(dir, ".git", "config")
+	require.NoError(t, os.WriteFile(fn, []byte(`# a comment
+[core]
+	bare = false
+[remote "origin"]
+	url = "/srv/git/store.git" ; a comment
+	fetch = +refs/heads/*:refs/remotes/origin/*
+[User]
+	Name = Dead Beef
+`), 0644))
+
+	for key, want := range map[string]string{
+		"core.bare":         "false",
+		"remote.origin.url": "/srv/git/store.git",
+		"user.name":         "Dead Beef",
+	} {
+		v, err := g.ConfigGet(ctx, key)
+		assert.NoError(t, err, key)
+		assert.Equal(t, want, v, key)
+	}
+	_, err = g.ConfigGet(ctx, "remote.Origin.url")
+	assert.Error(t, err)
+
+	// the user and remotes are fields of the go-git config, changing them
+	// must not be undone when it's written
+	require.NoError(t, g.ConfigSet(ctx, "user.email", "dead.beef@example.org"))
+	require.NoError(t, g.ConfigSet(ctx, "user.name", "Foo Bar"))
+	require.NoError(t, g.ConfigSet(ctx, "branch.master.remote", "origin"))
+	require.NoError(t, g.ConfigSet(ctx, "diff.gpg.textconv", "gpg --no-tty --decrypt # comment"))
+	require.NoError(t, g.AddRemote(ctx, "backup", "/srv/git/backup.git"))
+	assert.Error(t, g.AddRemote(ctx, "backup", "/srv/git/backup.git"))
+	require.NoError(t, g.RemoveRemote(ctx, "origin"))
+	assert.Error(t, g.RemoveRemote(ctx, "origin"))
+
+	g, err = New(dir)
+	require.NoError(t, err)
+	kv, err := g.ConfigList(ctx)
+	require.NoError(t, err)
+	for key, want := range map[string]string{
+		"core.bare":            "false",
+		"user.name":            "Foo Bar",
+		"user.email":           "dead.beef@example.org",
+		"branch.master.remote": "origin",
+		"diff.gpg.textconv":    "gpg --no-tty --decrypt # comment",
+		"remote.backup.url":    "/srv/git/backup.git",
+		"remote.backup.fetch":  "+refs/heads/*:refs/remotes/backup/*",
+	} {
+		assert.Equal(t, want, kv[key], key)
+	}
+	assert.NotContains(t, kv, "remote.origin.url")
+
+	// git reads it the same way
+	buf, err := exec.Command("git", "config", "--file", fn, "diff.gpg.textconv").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "gpg --no-tty --decrypt # comment\n", string(buf))
+	assert.Error(t, g.ConfigSet(ctx, "foo.bar", "line\nbreak"))
+
+	// the local config takes precedence over the global one
+	require.NoError(t, os.WriteFile(filepath.Join(os.Getenv("HOME"), ".gitconfig"), []byte("[user]\n\tname = Global\n[commit]\n\tgpgsign = true\n"), 0644))
+	v, _ := g.gitConfig("user.name")
+	assert.Equal(t, "Foo Bar", v)
+	v, _ = g.gitConfig("commit.gpgsign")
+	assert.Equal(t, "true", v)
+}
@@ -0,0 +1,1015 @@
+// Package gogit implements a git RCS backend in pure Go on top of go-git. It
+// works on the same repositories as gitfs, but does not need a git binary.
+// Remotes can be local paths or SSH URLs, the latter require an ssh client.
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/backend/storage/fs"
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+	"github.com/itsonlycode/gosecret/pkg/fsutil"
+
+	"github.com/blang/semver/v4"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/revlist"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+const (
+	fileMode = 0600
+)
+
+// Git is a pure Go git backend
+type Git struct {
+	sync.Mutex
+
+	fs   *fs.Store
+	repo *git.Repository
+}
+
+// New opens an existing git repository
+func New(path string) (*Git, error) {
+	if !fsutil.IsDir(filepath.Join(path, ".git")) {
+		return nil, fmt.Errorf("git repo does not exist")
+	}
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Git{
+		fs:   fs.New(path),
+		repo: repo,
+	}, nil
+}
+
+// Clone clones an existing git repo and returns a new git backend for the
+// clone
+func Clone(ctx context.Context, remote, path string) (*Git, error) {
+	if entries, err := os.ReadDir(path); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("destination path %s already exists and is not an empty directory", path)
+	}
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, err
+	}
+	repo, err := git.PlainInit(path, false)
+	if err != nil {
+		return nil, err
+	}
+	g := &Git{
+		fs:   fs.New(path),
+		repo: repo,
+	}
+	if err := g.AddRemote(ctx, "origin", remote); err != nil {
+		return nil, err
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	rr, err := g.fetch(ctx, "origin", remote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone %s: %w", remote, err)
+	}
+	branch := rr.headBranch()
+	if branch == "" {
+		debug.Log("cloned an empty repository from %s", remote)
+		return g, nil
+	}
+	if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branch)); err != nil {
+		return nil, err
+	}
+	short := branch.Short()
+	if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(
+		plumbing.NewRemoteHEADReferenceName("origin"),
+		plumbing.NewRemoteReferenceName("origin", short),
+	)); err != nil {
+		return nil, err
+	}
+	if err := g.updateConfig(func(raw *format.Config) error {
+		setOption(raw, "branch."+short+".remote", "origin")
+		setOption(raw, "branch."+short+".merge", branch.String())
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := g.merge(ctx, rr.refs[branch], ""); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Init initializes this store's git repo
+func Init(ctx context.Context, path, userName, userEmail string) (*Git, error) {
+	g := &Git{
+		fs: fs.New(path),
+	}
+	// the git repo may be empty (i.e. no branches, cloned from a fresh remote)
+	// or already initialized. Only initialize it if the folder is completely
+	// empty.
+	if g.IsInitialized() {
+		repo, err := git.PlainOpen(path)
+		if err != nil {
+			return nil, err
+		}
+		g.repo = repo
+	} else {
+		if err := os.MkdirAll(path, 0700); err != nil {
+			return nil, err
+		}
+		repo, err := git.PlainInit(path, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize git: %s", err)
+		}
+		g.repo = repo
+		out.Printf(ctx, "git initialized at %s", g.fs.Path())
+	}
+
+	if !ctxutil.IsGitInit(ctx) {
+		return g, nil
+	}
+
+	// initialize the local git config
+	if err := g.InitConfig(ctx, userName, userEmail); err != nil {
+		return g, fmt.Errorf("failed to configure git: %s", err)
+	}
+	out.Printf(ctx, "git configured at %s", g.fs.Path())
+
+	// add current content of the store
+	if err := g.Add(ctx, g.fs.Path()); err != nil {
+		return g, fmt.Errorf("failed to add %q to git: %w", g.fs.Path(), err)
+	}
+
+	// commit if there is something to commit
+	if !g.HasStagedChanges(ctx) {
+		debug.Log("No staged changes")
+		return g, nil
+	}
+
+	if err := g.Commit(ctx, "Add current content of password store"); err != nil {
+		return g, fmt.Errorf("failed to commit changes to git: %w", err)
+	}
+
+	return g, nil
+}
+
+// Name returns gogit
+func (g *Git) Name() string {
+	return name
+}
+
+// Version returns the version of this backend
+func (g *Git) Version(ctx context.Context) semver.Version {
+	return semver.Version{Major: 1}
+}
+
+// IsInitialized returns true if this stores has an (probably) initialized .git folder
+func (g *Git) IsInitialized() bool {
+	return fsutil.IsFile(filepath.Join(g.fs.Path(), ".git", "config"))
+}
+
+// Add adds the listed files to the git index. Like git add --all, files
+// that were removed are removed from the index.
+func (g *Git) Add(ctx context.Context, files ...string) error {
+	if !g.IsInitialized() {
+		return store.ErrGitNotInit
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		if f == g.fs.Path() {
+			f = ""
+		}
+		f = strings.TrimPrefix(f, g.fs.Path()+"/")
+		paths = append(paths, strings.TrimPrefix(filepath.ToSlash(filepath.Clean(f)), "./"))
+	}
+
+	wt, err := g.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return g.updateIndex(func(idx *index.Index) error {
+		status, err := wt.Status()
+		if err != nil {
+			return err
+		}
+		for name, fs := range status {
+			if fs.Worktree == git.Unmodified || !inPathspec(name, paths) {
+				continue
+			}
+			if fs.Worktree == git.Deleted {
+				if _, err := idx.Remove(name); err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+					return err
+				}
+				continue
+			}
+			if err := g.addFile(idx, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// head returns the branch HEAD points to and its commit. The hash is zero if
+// the branch has no commits, yet.
+func (g *Git) head() (plumbing.ReferenceName, plumbing.Hash, error) {
+	ref, err := g.repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", plumbing.ZeroHash, err
+	}
+	if ref.Type() == plumbing.HashReference {
+		return plumbing.HEAD, ref.Hash(), nil
+	}
+	branch := ref.Target()
+	ref, err = g.repo.Storer.Reference(branch)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return branch, plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return branch, plumbing.ZeroHash, err
+	}
+	return branch, ref.Hash(), nil
+}
+
+// headFiles returns the files of the current commit. Must be called with
+// the lock held.
+func (g *Git) headFiles() (plumbing.ReferenceName, plumbing.Hash, map[string]fileEntry, error) {
+	branch, h, err := g.head()
+	if err != nil {
+		return branch, h, nil, err
+	}
+	if h.IsZero() {
+		return branch, h, map[string]fileEntry{}, nil
+	}
+	c, err := g.repo.CommitObject(h)
+	if err != nil {
+		return branch, h, nil, err
+	}
+	files, err := g.flattenTree(c.TreeHash)
+	return branch, h, files, err
+}
+
+// HasStagedChanges returns true if there are any staged changes which can be committed
+func (g *Git) HasStagedChanges(ctx context.Context) bool {
+	g.Lock()
+	defer g.Unlock()
+
+	return g.hasStagedChanges()
+}
+
+func (g *Git) hasStagedChanges() bool {
+	status, err := g.status()
+	if err != nil {
+		debug.Log("failed to get status: %s", err)
+		return false
+	}
+	for _, fs := range status {
+		if fs.Staging != git.Unmodified && fs.Staging != git.Untracked {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Git) status() (git.Status, error) {
+	wt, err := g.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	return wt.Status()
+}
+
+// signature returns the identity for new commits. Like git it's taken from
+// the environment or the git config.
+func (g *Git) signature(ctx context.Context, role string) (*object.Signature, error) {
+	sig := &object.Signature{
+		Name:  os.Getenv("GIT_" + role + "_NAME"),
+		Email: os.Getenv("GIT_" + role + "_EMAIL"),
+		When:  time.Now(),
+	}
+	if role == "AUTHOR" {
+		sig.When = ctxutil.GetCommitTimestamp(ctx).UTC()
+	}
+	if sig.Name == "" {
+		sig.Name, _ = g.gitConfig("user.name")
+	}
+	if sig.Email == "" {
+		sig.Email, _ = g.gitConfig("user.email")
+	}
+	if sig.Name == "" || sig.Email == "" {
+		return sig, fmt.Errorf("author identity unknown, please configure user.name and user.email")
+	}
+	return sig, nil
+}
+
+// cleanupMessage removes trailing whitespace and surrounding and repeated
+// blank lines, like git commit does
+func cleanupMessage(msg string) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(msg, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			blank = len(lines) > 0
+			continue
+		}
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// subject returns the first paragraph of the message, like %s in git log
+func subject(msg string) string {
+	p := strings.SplitN(strings.TrimSpace(msg), "\n\n", 2)
+	return strings.Join(strings.Fields(p[0]), " ")
+}
+
+// body returns everything after the first paragraph, like %b in git log
+func body(msg string) string {
+	p := strings.SplitN(strings.TrimSpace(msg), "\n\n", 2)
+	if len(p) < 2 {
+		return ""
+	}
+	return strings.TrimSpace(p[1])
+}
+
+// Commit creates a new git commit with the given commit message
+func (g *Git) Commit(ctx context.Context, msg string) error {
+	if !g.IsInitialized() {
+		return store.ErrGitNotInit
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	if !g.hasStagedChanges() {
+		return store.ErrGitNothingToCommit
+	}
+	if err := g.checkSigning(ctx); err != nil {
+		return err
+	}
+	author, err := g.signature(ctx, "AUTHOR")
+	if err != nil {
+		return err
+	}
+	committer, err := g.signature(ctx, "COMMITTER")
+	if err != nil {
+		return err
+	}
+	wt, err := g.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	h, err := wt.Commit(cleanupMessage(msg), &git.CommitOptions{
+		Author:    author,
+		Committer: committer,
+	})
+	if err != nil {
+		return err
+	}
+	debug.Log("committed %s: %s", h, subject(msg))
+	return nil
+}
+
+// commit writes a commit object for the tree. The current branch is not
+// updated. Must be called with the lock held.
+func (g *Git) commit(ctx context.Context, tree plumbing.Hash, parents []plumbing.Hash, msg string) (plumbing.Hash, error) {
+	c := &object.Commit{
+		TreeHash:     tree,
+		ParentHashes: parents,
+		Message:      cleanupMessage(msg),
+	}
+	author, err := g.signature(ctx, "AUTHOR")
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	committer, err := g.signature(ctx, "COMMITTER")
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	c.Author, c.Committer = *author, *committer
+
+	obj := g.repo.Storer.NewEncodedObject()
+	if err := c.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	h, err := g.repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return h, err
+	}
+	debug.Log("committed %s: %s", h, subject(msg))
+	return h, nil
+}
+
+func (g *Git) defaultRemote(cfg *format.Config, branch string) string {
+	remote, _ := getOption(cfg, "branch."+branch+".remote")
+	if remote == "" {
+		return "origin"
+	}
+	if _, found := getOption(cfg, "remote."+remote+".url"); found {
+		return remote
+	}
+	return "origin"
+}
+
+func (g *Git) defaultBranch() string {
+	branch, _, err := g.head()
+	if err != nil || !branch.IsBranch() {
+		// see https://github.com/github/renaming
+		return "main"
+	}
+	return branch.Short()
+}
+
+// sshCommand returns the ssh command, either from GIT_SSH_COMMAND or the
+// git config
+func (g *Git) sshCommand(cfg *format.Config) string {
+	if sc, found := os.LookupEnv("GIT_SSH_COMMAND"); found {
+		return sc
+	}
+	sc, _ := getOption(cfg, "core.sshCommand")
+	return sc
+}
+
+// fetch downloads all branches of the remote and updates the remote
+// tracking branches. Must be called with the lock held.
+func (g *Git) fetch(ctx context.Context, remote, location string) (*remoteRefs, error) {
+	cfg, err := g.config()
+	if err != nil {
+		return nil, err
+	}
+	e, err := newEndpoint(location, g.sshCommand(cfg))
+	if err != nil {
+		return nil, err
+	}
+	rr, err := e.fetch(ctx, g.repo.Storer)
+	if err != nil {
+		return nil, err
+	}
+	for name, h := range rr.refs {
+		if !name.IsBranch() {
+			continue
+		}
+		ref := plumbing.NewHashReference(plumbing.NewRemoteReferenceName(remote, name.Short()), h)
+		if err := g.repo.Storer.SetReference(ref); err != nil {
+			return nil, err
+		}
+	}
+	return rr, nil
+}
+
+// PushPull pushes the repo to it's origin.
+// optional arguments: remote and branch
+func (g *Git) PushPull(ctx context.Context, op, remote, branch string) error {
+	if ctxutil.IsNoNetwork(ctx) {
+		debug.Log("Skipping network ops. NoNetwork=true")
+		return nil
+	}
+	if !g.IsInitialized() {
+		return store.ErrGitNotInit
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	cfg, err := g.config()
+	if err != nil {
+		return err
+	}
+	if branch == "" {
+		branch = g.defaultBranch()
+	}
+	if remote == "" {
+		remote = g.defaultRemote(cfg, branch)
+	}
+	location, _ := getOption(cfg, "remote."+remote+".url")
+	if location == "" {
+		return store.ErrGitNoRemote
+	}
+
+	if err := g.pull(ctx, remote, location, branch); err != nil {
+		if op == "pull" {
+			return err
+		}
+		out.Warningf(ctx, "Failed to pull before git push: %s", err)
+	}
+	if op == "pull" {
+		return nil
+	}
+
+	if uf := g.untrackedFiles(); len(uf) > 0 {
+		out.Warningf(ctx, "Found untracked files: %+v", uf)
+	}
+	return g.push(ctx, remote, location, branch)
+}
+
+func (g *Git) pull(ctx context.Context, remote, location, branch string) error {
+	if err := g.checkSigning(ctx); err != nil {
+		return err
+	}
+	rr, err := g.fetch(ctx, remote, location)
+	if err != nil {
+		return err
+	}
+	theirs, found := rr.refs[plumbing.NewBranchReferenceName(branch)]
+	if !found {
+		return fmt.Errorf("couldn't find remote ref %s", branch)
+	}
+	return g.merge(ctx, theirs, fmt.Sprintf("Merge branch '%s' of %s", branch, location))
+}
+
+func (g *Git) push(ctx context.Context, remote, location, branch string) error {
+	name := plumbing.NewBranchReferenceName(branch)
+	ref, err := g.repo.Storer.Reference(name)
+	if err != nil {
+		return fmt.Errorf("src refspec %s does not match any: %w", branch, err)
+	}
+	cfg, err := g.config()
+	if err != nil {
+		return err
+	}
+	e, err := newEndpoint(location, g.sshCommand(cfg))
+	if err != nil {
+		return err
+	}
+	if err := e.push(ctx, g.repo.Storer, name, ref.Hash()); err != nil {
+		return err
+	}
+	return g.repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewRemoteReferenceName(remote, branch), ref.Hash()))
+}
+
+func (g *Git) untrackedFiles() []string {
+	status, err := g.status()
+	if err != nil {
+		return []string{fmt.Sprintf("ERROR: %s", err)}
+	}
+	uf := []string{}
+	for name, fs := range status {
+		if fs.Worktree == git.Untracked {
+			uf = append(uf, name)
+		}
+	}
+	sort.Strings(uf)
+	return uf
+}
+
+// Push pushes to the git remote
+func (g *Git) Push(ctx context.Context, remote, branch string) error {
+	if ctxutil.IsNoNetwork(ctx) {
+		debug.Log("Skipping network ops. NoNetwork=true")
+		return nil
+	}
+	return g.PushPull(ctx, "push", remote, branch)
+}
+
+// Pull pulls from the git remote
+func (g *Git) Pull(ctx context.Context, remote, branch string) error {
+	if ctxutil.IsNoNetwork(ctx) {
+		debug.Log("Skipping network ops. NoNetwork=true")
+		return nil
+	}
+	return g.PushPull(ctx, "pull", remote, branch)
+}
+
+// AddRemote adds a new remote
+func (g *Git) AddRemote(ctx context.Context, remote, location string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	return g.updateConfig(func(raw *format.Config) error {
+		if _, found := getOption(raw, "remote."+remote+".url"); found {
+			return fmt.Errorf("remote %s already exists", remote)
+		}
+		setOption(raw, "remote."+remote+".url", location)
+		setOption(raw, "remote."+remote+".fetch", "+refs/heads/*:refs/remotes/"+remote+"/*")
+		return nil
+	})
+}
+
+// RemoveRemote removes a remote
+func (g *Git) RemoveRemote(ctx context.Context, remote string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if err := g.updateConfig(func(raw *format.Config) error {
+		if !raw.Section("remote").HasSubsection(remote) {
+			return fmt.Errorf("no such remote: %s", remote)
+		}
+		raw.RemoveSubsection("remote", remote)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	refs, err := g.repo.Storer.IterReferences()
+	if err != nil {
+		return err
+	}
+	prefix := "refs/remotes/" + remote + "/"
+	return refs.ForEach(func(ref *plumbing.Reference) error {
+		if !strings.HasPrefix(ref.Name().String(), prefix) {
+			return nil
+		}
+		return g.repo.Storer.RemoveReference(ref.Name())
+	})
+}
+
+// lookupPath returns the entry of the file in the commit. "." is the root
+// of the tree.
+func lookupPath(c *object.Commit, name string) (fileEntry, bool, error) {
+	if name == "." {
+		return fileEntry{mode: filemode.Dir, hash: c.TreeHash}, true, nil
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return fileEntry{}, false, err
+	}
+	e, err := tree.FindEntry(name)
+	if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+		return fileEntry{}, false, nil
+	}
+	if err != nil {
+		return fileEntry{}, false, err
+	}
+	return fileEntry{mode: e.Mode, hash: e.Hash}, true, nil
+}
+
+// Revisions will list all available revisions of the named entity, like git
+// log does. An empty name lists the revisions of the whole store.
+func (g *Git) Revisions(ctx context.Context, name string) ([]backend.Revision, error) {
+	g.Lock()
+	defer g.Unlock()
+
+	name = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(name)), "./")
+	_, head, err := g.head()
+	if err != nil {
+		return nil, err
+	}
+	if head.IsZero() {
+		return nil, fmt.Errorf("your current branch does not have any commits yet")
+	}
+
+	var revs []backend.Revision
+	seen := map[plumbing.Hash]bool{}
+	queue := []*object.Commit{}
+	push := func(h plumbing.Hash) error {
+		if seen[h] {
+			return nil
+		}
+		seen[h] = true
+		c, err := g.repo.CommitObject(h)
+		if err != nil {
+			return err
+		}
+		queue = append(queue, c)
+		// newest first
+		sort.SliceStable(queue, func(i, j int) bool {
+			return queue[i].Committer.When.After(queue[j].Committer.When)
+		})
+		return nil
+	}
+	if err := push(head); err != nil {
+		return nil, err
+	}
+
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+
+		e, found, err := lookupPath(c, name)
+		if err != nil {
+			return nil, err
+		}
+		// history simplification: follow a parent with the same content
+		include := found && c.NumParents() < 1
+		follow := c.ParentHashes
+		for i, p := range c.ParentHashes {
+			pc, err := g.repo.CommitObject(p)
+			if err != nil {
+				return nil, err
+			}
+			pe, pfound, err := lookupPath(pc, name)
+			if err != nil {
+				return nil, err
+			}
+			if pfound == found && pe == e {
+				include, follow = false, c.ParentHashes[i:i+1]
+				break
+			}
+			include = true
+		}
+		if include {
+			revs = append(revs, backend.Revision{
+				Hash:        c.Hash.String(),
+				AuthorName:  c.Author.Name,
+				AuthorEmail: c.Author.Email,
+				Date:        time.Unix(c.Author.When.Unix(), 0),
+				Subject:     subject(c.Message),
+				Body:        body(c.Message),
+			})
+		}
+		for _, p := range follow {
+			if err := push(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return revs, nil
+}
+
+// GetRevision will return the content of any revision of the named entity
+func (g *Git) GetRevision(ctx context.Context, name, revision string) ([]byte, error) {
+	g.Lock()
+	defer g.Unlock()
+
+	name = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(strings.TrimSpace(name))), "./")
+	revision = strings.TrimSpace(revision)
+	h, err := g.repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, fmt.Errorf("unknown revision %s: %w", revision, err)
+	}
+	c, err := g.repo.CommitObject(*h)
+	if err != nil {
+		return nil, err
+	}
+	e, found, err := lookupPath(c, name)
+	if err != nil {
+		return nil, err
+	}
+	if !found || !e.mode.IsFile() {
+		return nil, fmt.Errorf("path '%s' does not exist in '%s'", name, revision)
+	}
+	return g.readBlob(e.hash)
+}
+
+// ListRevision lists all files of the store at the revision
+func (g *Git) ListRevision(ctx context.Context, revision string) ([]string, error) {
+	g.Lock()
+	defer g.Unlock()
+
+	revision = strings.TrimSpace(revision)
+	h, err := g.repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, fmt.Errorf("unknown revision %s: %w", revision, err)
+	}
+	c, err := g.repo.CommitObject(*h)
+	if err != nil {
+		return nil, err
+	}
+	files, err := g.flattenTree(c.TreeHash)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Status returns a summary of the staged and unstaged changes, similar to
+// git status
+func (g *Git) Status(ctx context.Context) ([]byte, error) {
+	g.Lock()
+	defer g.Unlock()
+
+	branch, head, err := g.head()
+	if err != nil {
+		return nil, err
+	}
+	status, err := g.status()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(status))
+	for name := range status {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	labels := map[git.StatusCode]string{git.Added: "new file:", git.Modified: "modified:", git.Deleted: "deleted:"}
+	var staged, unstaged, untracked []string
+	for _, name := range names {
+		fs := status[name]
+		if fs.Worktree == git.Untracked {
+			untracked = append(untracked, "\t"+name)
+			continue
+		}
+		if l, found := labels[fs.Staging]; found {
+			staged = append(staged, fmt.Sprintf("\t%-12s%s", l, name))
+		}
+		if l, found := labels[fs.Worktree]; found {
+			unstaged = append(unstaged, fmt.Sprintf("\t%-12s%s", l, name))
+		}
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "On branch %s\n", strings.TrimPrefix(branch.String(), "refs/heads/"))
+	if head.IsZero() {
+		sb.WriteString("\nNo commits yet\n")
+	}
+	for _, section := range []struct {
+		title string
+		lines []string
+	}{
+		{"Changes to be committed:", staged},
+		{"Changes not staged for commit:", unstaged},
+		{"Untracked files:", untracked},
+	} {
+		if len(section.lines) > 0 {
+			fmt.Fprintf(sb, "\n%s\n%s\n", section.title, strings.Join(section.lines, "\n"))
+		}
+	}
+	if len(names) < 1 {
+		sb.WriteString("\nnothing to commit, working tree clean\n")
+	}
+	return []byte(sb.String()), nil
+}
+
+// reachableObjects returns all objects reachable from any ref. Must be
+// called with the lock held.
+func (g *Git) reachableObjects() ([]plumbing.Hash, error) {
+	refs, err := g.repo.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+	var tips []plumbing.Hash
+	if err := refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			tips = append(tips, ref.Hash())
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return revlist.Objects(g.repo.Storer, tips, nil)
+}
+
+// Compact packs all objects reachable from any ref into a single pack and
+// removes them from the loose objects
+func (g *Git) Compact(ctx context.Context) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if err := g.repo.RepackObjects(&git.RepackConfig{}); err != nil {
+		return err
+	}
+	los, ok := g.repo.Storer.(storer.LooseObjectStorer)
+	if !ok {
+		return nil
+	}
+	objs, err := g.reachableObjects()
+	if err != nil {
+		return err
+	}
+	packed := make(map[plumbing.Hash]bool, len(objs))
+	for _, h := range objs {
+		packed[h] = true
+	}
+	// objects that are only referenced by the index are kept
+	var loose []plumbing.Hash
+	if err := los.ForEachObjectHash(func(h plumbing.Hash) error {
+		if packed[h] {
+			loose = append(loose, h)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, h := range loose {
+		if err := los.DeleteLooseObject(h); err != nil {
+			return err
+		}
+	}
+	debug.Log("packed %d objects, removed %d loose objects", len(objs), len(loose))
+	return nil
+}
+
+// fixConfig sets up the git config like gitfs does, so both backends can be
+// used with the same repository
+func (g *Git) fixConfig(ctx context.Context) error {
+	return g.updateConfig(func(raw *format.Config) error {
+		setOption(raw, "push.default", "matching")
+		setOption(raw, "pull.rebase", "false")
+		setOption(raw, "diff.gpg.binary", "true")
+		setOption(raw, "diff.gpg.textconv", "gpg --no-tty --decrypt")
+		return nil
+	})
+}
+
+// InitConfig initialized and preparse the git config
+func (g *Git) InitConfig(ctx context.Context, userName, userEmail string) error {
+	g.Lock()
+	// set commit identity
+	if err := g.updateConfig(func(raw *format.Config) error {
+		if userName != "" {
+			setOption(raw, "user.name", userName)
+		} else {
+			out.Printf(ctx, "Git Username not set")
+		}
+		if userEmail != "" && strings.Contains(userEmail, "@") {
+			setOption(raw, "user.email", userEmail)
+		} else {
+			out.Printf(ctx, "Git Email not set")
+		}
+		return nil
+	}); err != nil {
+		g.Unlock()
+		return fmt.Errorf("failed to write git config: %w", err)
+	}
+	// ensure sane git config
+	err := g.fixConfig(ctx)
+	g.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to fix git config: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(g.fs.Path(), ".gitattributes"), []byte("*.gpg diff=gpg\n"), fileMode); err != nil {
+		return fmt.Errorf("failed to initialize git: %w", err)
+	}
+	if err := g.Add(ctx, g.fs.Path()+"/.gitattributes"); err != nil {
+		out.Warningf(ctx, "Failed to add .gitattributes to git")
+	}
+	if err := g.Commit(ctx, "Configure git repository for gpg file diff."); err != nil && !errors.Is(err, store.ErrGitNothingToCommit) {
+		out.Warningf(ctx, "Failed to commit .gitattributes to git")
+	}
+
+	return nil
+}
+
+// ConfigSet sets a local config value
+func (g *Git) ConfigSet(ctx context.Context, key, value string) error {
+	if !g.IsInitialized() {
+		return store.ErrGitNotInit
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	return g.updateConfig(func(raw *format.Config) error {
+		setOption(raw, key, value)
+		return nil
+	})
+}
+
+// ConfigGet returns a given config value
+func (g *Git) ConfigGet(ctx context.Context, key string) (string, error) {
+	if !g.IsInitialized() {
+		return "", store.ErrGitNotInit
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	cfg, err := g.config()
+	if err != nil {
+		return "", err
+	}
+	v, found := getOption(cfg, key)
+	if !found {
+		return "", fmt.Errorf("config key %s not set", key)
+	}
+	return v, nil
+}
+
+// ConfigList returns all local git config settings
+func (g *Git) ConfigList(ctx context.Context) (map[string]string, error) {
+	if !g.IsInitialized() {
+		return nil, store.ErrGitNotInit
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	cfg, err := g.config()
+	if err != nil {
+		return nil, err
+	}
+	return listOptions(cfg), nil
+}
@@ -0,0 +1,332 @@
+package gogit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	out.Stdout = buf
+	t.Cleanup(func() {
+		out.Stdout = os.Stdout
+	})
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	return ctxutil.WithGitInit(ctx, true)
+}
+
+// initBare creates a bare repository to be used as a remote
+func initBare(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "remote.git")
+	_, err := git.PlainInit(path, true)
+	require.NoError(t, err)
+	return path
+}
+
+func writeAndCommit(ctx context.Context, t *testing.T, g *Git, name, content, msg string) {
+	t.Helper()
+
+	require.NoError(t, g.Set(ctx, name, []byte(content)))
+	require.NoError(t, g.Add(ctx, filepath.Join(g.Path(), name)))
+	require.NoError(t, g.Commit(ctx, msg))
+}
+
+func TestGit(t *testing.T) {
+	ctx := testContext(t)
+	td := t.TempDir()
+
+	gitdir := filepath.Join(td, "git")
+	require.NoError(t, os.Mkdir(gitdir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(gitdir, "existing"), []byte("foo"), 0600))
+
+	git, err := Init(ctx, gitdir, "Dead Beef", "dead.beef@example.org")
+	require.NoError(t, err)
+	assert.Equal(t, "gogit", git.Name())
+	assert.Equal(t, "1.0.0", git.Version(ctx).String())
+	assert.True(t, git.IsInitialized())
+	assert.False(t, git.HasStagedChanges(ctx))
+
+	un, err := git.ConfigGet(ctx, "user.name")
+	require.NoError(t, err)
+	assert.Equal(t, "Dead Beef", un)
+	cl, err := git.ConfigList(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "matching", cl["push.default"])
+
+	revs, err := git.Revisions(ctx, "existing")
+	require.NoError(t, err)
+	require.Len(t, revs, 1)
+	assert.Equal(t, "Add current content of password store", revs[0].Subject)
+
+	t.Run("commit", func(t *testing.T) {
+		assert.True(t, errors.Is(git.Commit(ctx, "nothing"), store.ErrGitNothingToCommit))
+
+		require.NoError(t, os.WriteFile(filepath.Join(gitdir, "some-file"), []byte("foobar"), 0644))
+		require.NoError(t, git.Add(ctx, "some-file"))
+		assert.True(t, git.HasStagedChanges(ctx))
+		require.NoError(t, git.Commit(ctx, "added some-file\n\n  with a body  \n"))
+		assert.False(t, git.HasStagedChanges(ctx))
+
+		revs, err := git.Revisions(ctx, "some-file")
+		require.NoError(t, err)
+		require.Len(t, revs, 1)
+		assert.Equal(t, "added some-file", revs[0].Subject)
+		assert.Equal(t, "with a body", revs[0].Body)
+
+		require.NoError(t, git.Delete(ctx, "some-file"))
+		require.NoError(t, git.Add(ctx, gitdir))
+		require.NoError(t, git.Commit(ctx, "removed some-file"))
+
+		revs, err = git.Revisions(ctx, "some-file")
+		require.NoError(t, err)
+		require.Len(t, revs, 2)
+		assert.Equal(t, "removed some-file", revs[0].Subject)
+
+		buf, err := git.GetRevision(ctx, "some-file", revs[1].Hash)
+		require.NoError(t, err)
+		assert.Equal(t, "foobar", string(buf))
+		buf, err = git.GetRevision(ctx, "some-file", "HEAD~1")
+		require.NoError(t, err)
+		assert.Equal(t, "foobar", string(buf))
+		_, err = git.GetRevision(ctx, "some-file", "HEAD")
+		assert.Error(t, err)
+
+		files, err := git.ListRevision(ctx, "HEAD~1")
+		require.NoError(t, err)
+		assert.Contains(t, files, "some-file")
+		files, err = git.ListRevision(ctx, "HEAD")
+		require.NoError(t, err)
+		assert.NotContains(t, files, "some-file")
+
+		all, err := git.Revisions(ctx, "")
+		require.NoError(t, err)
+		require.True(t, len(all) > len(revs))
+		assert.Equal(t, "removed some-file", all[0].Subject)
+	})
+
+	t.Run("status", func(t *testing.T) {
+		buf, err := git.Status(ctx)
+		require.NoError(t, err)
+		assert.Contains(t, string(buf), "On branch master")
+		assert.Contains(t, string(buf), "nothing to commit, working tree clean")
+
+		require.NoError(t, os.WriteFile(filepath.Join(gitdir, "existing"), []byte("bar"), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(gitdir, "staged"), []byte("bar"), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(gitdir, "untracked"), []byte("bar"), 0600))
+		require.NoError(t, git.Add(ctx, "staged"))
+
+		buf, err = git.Status(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, `On branch master
+
+Changes to be committed:
+	new file:   staged
+
+Changes not staged for commit:
+	modified:   existing
+
+Untracked files:
+	untracked
+`, string(buf))
+
+		require.NoError(t, git.Add(ctx, gitdir))
+		require.NoError(t, git.Commit(ctx, "more files"))
+	})
+
+	t.Run("index lock", func(t *testing.T) {
+		lock := filepath.Join(gitdir, ".git", "index.lock")
+		require.NoError(t, os.WriteFile(lock, nil, 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(gitdir, "locked"), []byte("foo"), 0600))
+		err := git.Add(ctx, "locked")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "another git process seems to be running")
+		assert.False(t, git.HasStagedChanges(ctx))
+
+		require.NoError(t, os.Remove(lock))
+		require.NoError(t, git.Add(ctx, "locked"))
+		assert.True(t, git.HasStagedChanges(ctx))
+		require.NoError(t, git.Commit(ctx, "add locked"))
+		assert.NoFileExists(t, lock)
+	})
+
+	t.Run("remotes", func(t *testing.T) {
+		assert.True(t, errors.Is(git.Push(ctx, "", ""), store.ErrGitNoRemote))
+		assert.NoError(t, git.Push(ctxutil.WithNoNetwork(ctx, true), "", ""))
+
+		assert.NoError(t, git.AddRemote(ctx, "foo", "/tmp/foo"))
+		assert.Error(t, git.AddRemote(ctx, "foo", "/tmp/bar"))
+		assert.NoError(t, git.RemoveRemote(ctx, "foo"))
+		assert.Error(t, git.RemoveRemote(ctx, "foo"))
+	})
+
+	t.Run("compact", func(t *testing.T) {
+		before, err := git.Revisions(ctx, "existing")
+		require.NoError(t, err)
+
+		require.NoError(t, git.Compact(ctx))
+		loose, err := filepath.Glob(filepath.Join(gitdir, ".git", "objects", "??", "*"))
+		require.NoError(t, err)
+		assert.Len(t, loose, 0)
+		packs, err := filepath.Glob(filepath.Join(gitdir, ".git", "objects", "pack", "*.pack"))
+		require.NoError(t, err)
+		assert.Len(t, packs, 1)
+
+		git, err := New(gitdir)
+		require.NoError(t, err)
+		after, err := git.Revisions(ctx, "existing")
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+		assert.NoError(t, git.Fsck(ctx))
+	})
+}
+
+func TestPushPull(t *testing.T) {
+	ctx := testContext(t)
+	td := t.TempDir()
+	remote := initBare(t)
+
+	alice, err := Init(ctx, filepath.Join(td, "alice"), "Alice", "alice@example.org")
+	require.NoError(t, err)
+	require.NoError(t, alice.AddRemote(ctx, "origin", remote))
+	writeAndCommit(ctx, t, alice, "foo", "foo", "add foo")
+	require.NoError(t, alice.Push(ctx, "", ""))
+
+	bob, err := Clone(ctx, remote, filepath.Join(td, "bob"))
+	require.NoError(t, err)
+	require.NoError(t, bob.InitConfig(ctx, "Bob", "bob@example.org"))
+	buf, err := bob.Get(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(buf))
+
+	t.Run("fast forward", func(t *testing.T) {
+		require.NoError(t, bob.Push(ctx, "", ""))
+		require.NoError(t, alice.Pull(ctx, "", ""))
+		assert.True(t, alice.Exists(ctx, ".gitattributes"))
+	})
+
+	t.Run("rejected push", func(t *testing.T) {
+		writeAndCommit(ctx, t, alice, "alice", "alice", "add alice")
+		writeAndCommit(ctx, t, bob, "bob", "bob", "add bob")
+		require.NoError(t, alice.Push(ctx, "", ""))
+
+		// push pulls first, so it merges the changes of alice
+		require.NoError(t, bob.Push(ctx, "", ""))
+		assert.True(t, bob.Exists(ctx, "alice"))
+
+		revs, err := bob.Revisions(ctx, "alice")
+		require.NoError(t, err)
+		require.Len(t, revs, 1)
+		assert.Equal(t, "add alice", revs[0].Subject)
+
+		require.NoError(t, alice.Pull(ctx, "", ""))
+		assert.True(t, alice.Exists(ctx, "bob"))
+		revs, err = alice.Revisions(ctx, "bob")
+		require.NoError(t, err)
+		require.Len(t, revs, 1)
+
+		r, err := git.PlainOpen(remote)
+		require.NoError(t, err)
+		ref, err := r.Reference(plumbing.NewBranchReferenceName("master"), true)
+		require.NoError(t, err)
+		c, err := r.CommitObject(ref.Hash())
+		require.NoError(t, err)
+		assert.Len(t, c.ParentHashes, 2)
+		assert.Equal(t, "Merge branch 'master' of "+remote, subject(c.Message))
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		writeAndCommit(ctx, t, alice, "foo", "from alice", "alice changed foo")
+		writeAndCommit(ctx, t, bob, "foo", "from bob", "bob changed foo")
+		require.NoError(t, alice.Push(ctx, "", ""))
+
+		err := bob.Pull(ctx, "", "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "merge conflict in foo")
+		assert.Error(t, bob.Push(ctx, "", ""))
+
+		buf, err := bob.Get(ctx, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "from bob", string(buf))
+	})
+
+	t.Run("local changes", func(t *testing.T) {
+		carol, err := Clone(ctx, remote, filepath.Join(td, "carol"))
+		require.NoError(t, err)
+		require.NoError(t, carol.InitConfig(ctx, "Carol", "carol@example.org"))
+		writeAndCommit(ctx, t, carol, "alice", "from carol", "carol changed alice")
+		require.NoError(t, carol.Push(ctx, "", ""))
+
+		require.NoError(t, alice.Set(ctx, "alice", []byte("uncommitted")))
+		err = alice.Pull(ctx, "", "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "your local changes to alice would be overwritten")
+
+		buf, err := alice.Get(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, "uncommitted", string(buf))
+
+		require.NoError(t, alice.Set(ctx, "alice", []byte("alice")))
+		require.NoError(t, alice.Pull(ctx, "", ""))
+		buf, err = alice.Get(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, "from carol", string(buf))
+	})
+}
+
+func TestPushCheckedOutBranch(t *testing.T) {
+	ctx := testContext(t)
+	td := t.TempDir()
+
+	alice, err := Init(ctx, filepath.Join(td, "alice"), "Alice", "alice@example.org")
+	require.NoError(t, err)
+
+	bob, err := Clone(ctx, alice.Path(), filepath.Join(td, "bob"))
+	require.NoError(t, err)
+	require.NoError(t, bob.InitConfig(ctx, "Bob", "bob@example.org"))
+
+	err = bob.PushPull(ctx, "push", "", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "refusing to update checked out branch")
+
+	// alice can pull from bob, though
+	require.NoError(t, alice.AddRemote(ctx, "bob", bob.Path()))
+	require.NoError(t, alice.Pull(ctx, "bob", "master"))
+	assert.True(t, alice.Exists(ctx, ".gitattributes"))
+}
+
+func TestCloneEmpty(t *testing.T) {
+	ctx := testContext(t)
+	remote := initBare(t)
+
+	g, err := Clone(ctx, remote, filepath.Join(t.TempDir(), "clone"))
+	require.NoError(t, err)
+	_, err = g.Revisions(ctx, "foo")
+	assert.Error(t, err)
+
+	require.NoError(t, g.InitConfig(ctx, "Alice", "alice@example.org"))
+	require.NoError(t, g.Push(ctx, "", ""))
+
+	r, err := git.PlainOpen(remote)
+	require.NoError(t, err)
+	_, err = r.Reference(plumbing.NewBranchReferenceName("master"), true)
+	assert.NoError(t, err)
+}
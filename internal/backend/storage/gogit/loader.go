@@ -0,0 +1,55 @@
+package gogit
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/pkg/fsutil"
+	"github.com/itsonlycode/gosecret/pkg/termio"
+)
+
+const (
+	name = "gogit"
+)
+
+func init() {
+	backend.RegisterStorage(backend.GoGit, name, &loader{})
+}
+
+type loader struct{}
+
+func (l loader) New(ctx context.Context, path string) (backend.Storage, error) {
+	return New(path)
+}
+
+// Open implements backend.RCSLoader
+func (l loader) Open(ctx context.Context, path string) (backend.Storage, error) {
+	return New(path)
+}
+
+// Clone implements backend.RCSLoader
+func (l loader) Clone(ctx context.Context, repo, path string) (backend.Storage, error) {
+	return Clone(ctx, repo, path)
+}
+
+// Init implements backend.RCSLoader
+func (l loader) Init(ctx context.Context, path string) (backend.Storage, error) {
+	return Init(ctx, path, termio.DetectName(ctx, nil), termio.DetectEmail(ctx, nil))
+}
+
+func (l loader) Handles(path string) error {
+	if !fsutil.IsDir(filepath.Join(path, ".git")) {
+		return fmt.Errorf("no .git")
+	}
+	return nil
+}
+
+// Priority is lower than gitfs, so gitfs is used if git is installed
+func (l loader) Priority() int {
+	return 2
+}
+func (l loader) String() string {
+	return name
+}
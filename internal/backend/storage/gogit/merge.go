@@ -0,0 +1,164 @@
+package gogit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/itsonlycode/gosecret/pkg/debug"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// isAncestor returns true if a is reachable from b. The zero hash is an
+// ancestor of everything.
+func isAncestor(s storer.EncodedObjectStorer, a, b plumbing.Hash) (bool, error) {
+	if a.IsZero() || a == b {
+		return true, nil
+	}
+	if b.IsZero() || s.HasEncodedObject(a) != nil {
+		return false, nil
+	}
+	ca, err := object.GetCommit(s, a)
+	if err != nil {
+		return false, err
+	}
+	cb, err := object.GetCommit(s, b)
+	if err != nil {
+		return false, err
+	}
+	return ca.IsAncestor(cb)
+}
+
+// mergeFiles does a three way merge of flattened trees. Conflicts are only
+// detected on the file level, contents are never merged.
+func mergeFiles(base, ours, theirs map[string]fileEntry) (map[string]fileEntry, error) {
+	names := map[string]bool{}
+	for _, m := range []map[string]fileEntry{base, ours, theirs} {
+		for name := range m {
+			names[name] = true
+		}
+	}
+
+	merged := make(map[string]fileEntry, len(names))
+	var conflicts []string
+	for name := range names {
+		o, inBase := base[name]
+		a, inOurs := ours[name]
+		b, inTheirs := theirs[name]
+		switch {
+		case inOurs == inTheirs && a == b:
+			// same on both sides
+		case inBase == inTheirs && o == b:
+			// only changed by us
+		case inBase == inOurs && o == a:
+			// only changed by them
+			a, inOurs = b, inTheirs
+		default:
+			conflicts = append(conflicts, name)
+			continue
+		}
+		if inOurs {
+			merged[name] = a
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, fmt.Errorf("merge conflict in %s", strings.Join(conflicts, ", "))
+	}
+	return merged, nil
+}
+
+// merge merges the commit into the current branch. Fast forwards are
+// preferred, otherwise a merge commit with the given message is created.
+// Local changes are never overwritten. Must be called with the lock held.
+func (g *Git) merge(ctx context.Context, theirs plumbing.Hash, msg string) error {
+	branch, ours, oursFiles, err := g.headFiles()
+	if err != nil {
+		return err
+	}
+	if up, err := isAncestor(g.repo.Storer, theirs, ours); err != nil || up {
+		debug.Log("already up to date with %s", theirs)
+		return err
+	}
+
+	c, err := g.repo.CommitObject(theirs)
+	if err != nil {
+		return err
+	}
+	target, err := g.flattenTree(c.TreeHash)
+	if err != nil {
+		return err
+	}
+	ff := true
+	if !ours.IsZero() {
+		if ff, err = isAncestor(g.repo.Storer, ours, theirs); err != nil {
+			return err
+		}
+	}
+	if !ff {
+		oc, err := g.repo.CommitObject(ours)
+		if err != nil {
+			return err
+		}
+		bases, err := oc.MergeBase(c)
+		if err != nil {
+			return err
+		}
+		baseFiles := map[string]fileEntry{}
+		if len(bases) > 0 {
+			if baseFiles, err = g.flattenTree(bases[0].TreeHash); err != nil {
+				return err
+			}
+		}
+		if target, err = mergeFiles(baseFiles, oursFiles, target); err != nil {
+			return err
+		}
+	}
+
+	return g.updateIndex(func(idx *index.Index) error {
+		if err := g.checkLocalChanges(oursFiles, target); err != nil {
+			return err
+		}
+
+		head := theirs
+		if !ff {
+			tree, err := g.writeTree(target)
+			if err != nil {
+				return err
+			}
+			if head, err = g.commit(ctx, tree, []plumbing.Hash{ours, theirs}, msg); err != nil {
+				return err
+			}
+		}
+		if err := g.checkout(idx, oursFiles, target); err != nil {
+			return err
+		}
+		debug.Log("updated %s to %s (fast forward: %t)", branch, head, ff)
+		return g.repo.Storer.SetReference(plumbing.NewHashReference(branch, head))
+	})
+}
+
+// checkLocalChanges fails if a merge would overwrite staged or unstaged
+// changes or untracked files
+func (g *Git) checkLocalChanges(from, to map[string]fileEntry) error {
+	status, err := g.status()
+	if err != nil {
+		return err
+	}
+	var conflicts []string
+	for _, c := range diffFiles(from, to) {
+		if fs, found := status[c.name]; found && (fs.Staging != git.Unmodified || fs.Worktree != git.Unmodified) {
+			conflicts = append(conflicts, c.name)
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("your local changes to %s would be overwritten by merge, commit them first", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+)
+
+// signersFile is the allowlist of the keys that may sign commits, see
+// gitfs.SignersFile
+const signersFile = ".gosecret-signers"
+
+// ErrSigningUnsupported is returned if the store requires signed commits.
+// gogit can neither sign nor verify commits.
+var ErrSigningUnsupported = errors.New("gogit can not sign or verify commits, use the gitfs storage backend")
+
+// gitConfig returns the value of the key from the local or the global git
+// config. The local config takes precedence.
+func (g *Git) gitConfig(key string) (string, bool) {
+	cfgs := globalConfigs()
+	if local, err := g.config(); err == nil {
+		cfgs = append([]*format.Config{local}, cfgs...)
+	}
+	for _, cfg := range cfgs {
+		if v, found := getOption(cfg, key); found {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// checkSigning fails if commits of the store must be signed or pulled
+// commits must be verified, instead of silently creating or merging
+// unsigned commits
+func (g *Git) checkSigning(ctx context.Context) error {
+	if v, _ := g.gitConfig("commit.gpgsign"); v == "true" {
+		return fmt.Errorf("commit.gpgsign is set: %w", ErrSigningUnsupported)
+	}
+	if g.fs.Exists(ctx, signersFile) {
+		return fmt.Errorf("the store has a %s: %w", signersFile, ErrSigningUnsupported)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package gogit
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigning(t *testing.T) {
+	ctx := testContext(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	remote := initBare(t)
+
+	g, err := Init(ctx, filepath.Join(t.TempDir(), "store"), "Alice", "alice@example.org")
+	require.NoError(t, err)
+	require.NoError(t, g.AddRemote(ctx, "origin", remote))
+	writeAndCommit(ctx, t, g, "foo", "foo", "add foo")
+	require.NoError(t, g.Push(ctx, "", ""))
+
+	t.Run("commit.gpgsign", func(t *testing.T) {
+		require.NoError(t, g.ConfigSet(ctx, "commit.gpgsign", "true"))
+		require.NoError(t, g.Set(ctx, "bar", []byte("bar")))
+		require.NoError(t, g.Add(ctx, "bar"))
+		err := g.Commit(ctx, "add bar")
+		assert.True(t, errors.Is(err, ErrSigningUnsupported), "%s", err)
+		assert.True(t, g.HasStagedChanges(ctx))
+		assert.True(t, errors.Is(g.Pull(ctx, "", ""), ErrSigningUnsupported))
+
+		require.NoError(t, g.ConfigSet(ctx, "commit.gpgsign", "false"))
+		require.NoError(t, g.Commit(ctx, "add bar"))
+	})
+
+	t.Run("signers file", func(t *testing.T) {
+		require.NoError(t, g.Set(ctx, signersFile, []byte("DEADBEEF\n")))
+		require.NoError(t, g.Add(ctx, signersFile))
+		err := g.Commit(ctx, "add signers")
+		assert.True(t, errors.Is(err, ErrSigningUnsupported), "%s", err)
+		assert.True(t, errors.Is(g.Pull(ctx, "", ""), ErrSigningUnsupported))
+	})
+}
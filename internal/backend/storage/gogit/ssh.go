@@ -0,0 +1,208 @@
+package gogit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/itsonlycode/gosecret/pkg/debug"
+
+	"github.com/go-git/go-git/v5/plumbing/format/pktline"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// sshTransport runs git-upload-pack and git-receive-pack on the remote with
+// the ssh client, like git does. The protocol is handled by go-git, only
+// the ssh client is required, not git.
+type sshTransport struct {
+	command string
+}
+
+// conn is the connection to a service on the remote. CloseWrite signals the
+// end of the input, the output can still be read.
+type conn interface {
+	io.ReadWriteCloser
+	CloseWrite() error
+}
+
+// sshDial starts the service on the remote and returns a connection to its
+// stdin and stdout
+var sshDial = func(ctx context.Context, command string, ep *transport.Endpoint, service string) (conn, error) {
+	args := strings.Fields(command)
+	if len(args) < 1 {
+		args = []string{"ssh"}
+	}
+	if ep.Port > 0 {
+		args = append(args, "-p", strconv.Itoa(ep.Port))
+	}
+	host := ep.Host
+	if ep.User != "" {
+		host = ep.User + "@" + host
+	}
+	args = append(args, host, service+" "+shellQuote(ep.Path))
+
+	c := &sshConn{cmd: exec.CommandContext(ctx, args[0], args[1:]...)}
+	c.cmd.Stderr = &c.stderr
+	var err error
+	if c.stdin, err = c.cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	if c.stdout, err = c.cmd.StdoutPipe(); err != nil {
+		return nil, err
+	}
+	debug.Log("running %+v", c.cmd.Args)
+	if err := c.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run ssh: %w", err)
+	}
+	return c, nil
+}
+
+// shellQuote quotes the path for the remote shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+type sshConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+}
+
+func (c *sshConn) Read(p []byte) (int, error) {
+	return c.stdout.Read(p)
+}
+
+func (c *sshConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+func (c *sshConn) CloseWrite() error {
+	return c.stdin.Close()
+}
+
+func (c *sshConn) Close() error {
+	_ = c.stdin.Close()
+	_, _ = io.Copy(io.Discard, c.stdout)
+	if err := c.cmd.Wait(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(c.stderr.String()))
+	}
+	return nil
+}
+
+// NewUploadPackSession implements transport.Transport
+func (t *sshTransport) NewUploadPackSession(ep *transport.Endpoint, _ transport.AuthMethod) (transport.UploadPackSession, error) {
+	return &sshSession{t: t, ep: ep, service: transport.UploadPackServiceName}, nil
+}
+
+// NewReceivePackSession implements transport.Transport
+func (t *sshTransport) NewReceivePackSession(ep *transport.Endpoint, _ transport.AuthMethod) (transport.ReceivePackSession, error) {
+	return &sshSession{t: t, ep: ep, service: transport.ReceivePackServiceName}, nil
+}
+
+// sshSession is a single upload-pack or receive-pack run on the remote. The
+// connection is opened when the refs are requested.
+type sshSession struct {
+	t       *sshTransport
+	ep      *transport.Endpoint
+	service string
+	conn    conn
+	adv     *packp.AdvRefs
+	// sent is true once a request was sent, otherwise the session is ended
+	// with a flush
+	sent bool
+}
+
+func (s *sshSession) AdvertisedReferences() (*packp.AdvRefs, error) {
+	return s.AdvertisedReferencesContext(context.TODO())
+}
+
+func (s *sshSession) AdvertisedReferencesContext(ctx context.Context) (*packp.AdvRefs, error) {
+	if s.adv != nil {
+		return s.adv, nil
+	}
+	conn, err := sshDial(ctx, s.t.command, s.ep, s.service)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+
+	ar := packp.NewAdvRefs()
+	if err := ar.Decode(conn); err != nil && !errors.Is(err, packp.ErrEmptyAdvRefs) {
+		s.sent = true
+		if cerr := s.Close(); cerr != nil {
+			return nil, fmt.Errorf("failed to read refs: %w", cerr)
+		}
+		return nil, fmt.Errorf("failed to read refs: %w", err)
+	}
+	// empty repositories only advertise their capabilities
+	if s.service == transport.UploadPackServiceName && ar.IsEmpty() {
+		return nil, transport.ErrEmptyRemoteRepository
+	}
+	s.adv = ar
+	return ar, nil
+}
+
+func (s *sshSession) UploadPack(ctx context.Context, req *packp.UploadPackRequest) (*packp.UploadPackResponse, error) {
+	if _, err := s.AdvertisedReferencesContext(ctx); err != nil {
+		return nil, err
+	}
+	s.sent = true
+
+	if err := req.UploadRequest.Encode(s.conn); err != nil {
+		return nil, fmt.Errorf("failed to send wants: %w", err)
+	}
+	if err := req.UploadHaves.Encode(s.conn, true); err != nil {
+		return nil, fmt.Errorf("failed to send haves: %w", err)
+	}
+	if err := pktline.NewEncoder(s.conn).Encodef("done\n"); err != nil {
+		return nil, err
+	}
+	resp := packp.NewUploadPackResponse(req)
+	if err := resp.Decode(io.NopCloser(s.conn)); err != nil {
+		return nil, fmt.Errorf("failed to negotiate: %w", err)
+	}
+	return resp, nil
+}
+
+func (s *sshSession) ReceivePack(ctx context.Context, req *packp.ReferenceUpdateRequest) (*packp.ReportStatus, error) {
+	if _, err := s.AdvertisedReferencesContext(ctx); err != nil {
+		return nil, err
+	}
+	s.sent = true
+
+	if err := req.Encode(s.conn); err != nil {
+		return nil, err
+	}
+	// the remote may read the pack until the end of the input
+	if err := s.conn.CloseWrite(); err != nil {
+		return nil, err
+	}
+	if !req.Capabilities.Supports(capability.ReportStatus) {
+		return nil, nil
+	}
+	report := packp.NewReportStatus()
+	if err := report.Decode(s.conn); err != nil {
+		return nil, fmt.Errorf("failed to read push status: %w", err)
+	}
+	return report, report.Error()
+}
+
+func (s *sshSession) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	conn := s.conn
+	s.conn = nil
+	if !s.sent {
+		_ = pktline.NewEncoder(conn).Flush()
+	}
+	return conn.Close()
+}
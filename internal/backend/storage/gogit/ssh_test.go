@@ -0,0 +1,200 @@
+package gogit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/pktline"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pipeConn is the client side of an in-process connection
+type pipeConn struct {
+	io.Reader
+	io.WriteCloser
+	done chan error
+}
+
+func (c *pipeConn) CloseWrite() error {
+	return c.WriteCloser.Close()
+}
+
+func (c *pipeConn) Close() error {
+	_ = c.WriteCloser.Close()
+	_, _ = io.Copy(io.Discard, c.Reader)
+	return <-c.done
+}
+
+// fakeServer serves git-upload-pack and git-receive-pack for the
+// repositories in root with the go-git server, so the ssh transport can be
+// tested without ssh and git
+func fakeServer(t *testing.T, root string) {
+	t.Helper()
+
+	orig := sshDial
+	sshDial = func(ctx context.Context, command string, ep *transport.Endpoint, service string) (conn, error) {
+		local := &transport.Endpoint{Protocol: "file", Path: filepath.Join(root, ep.Path)}
+		cr, sw := io.Pipe()
+		sr, cw := io.Pipe()
+		c := &pipeConn{Reader: cr, WriteCloser: cw, done: make(chan error, 1)}
+		go func() {
+			br := bufio.NewReader(sr)
+			var err error
+			switch service {
+			case transport.UploadPackServiceName:
+				err = serveUploadPack(ctx, local, br, sw)
+			case transport.ReceivePackServiceName:
+				err = serveReceivePack(ctx, local, br, sw)
+			default:
+				err = fmt.Errorf("unknown service %s", service)
+			}
+			_ = sw.CloseWithError(err)
+			_, _ = io.Copy(io.Discard, sr)
+			c.done <- err
+		}()
+		return c, nil
+	}
+	t.Cleanup(func() {
+		sshDial = orig
+	})
+}
+
+// isFlush returns true if the client ends the session without a request
+func isFlush(br *bufio.Reader) bool {
+	buf, err := br.Peek(len(pktline.FlushPkt))
+	return err != nil || bytes.Equal(buf, pktline.FlushPkt)
+}
+
+func serveUploadPack(ctx context.Context, ep *transport.Endpoint, br *bufio.Reader, w io.Writer) error {
+	s, err := server.NewServer(fileLoader{}).NewUploadPackSession(ep, nil)
+	if err != nil {
+		return err
+	}
+	ar, err := s.AdvertisedReferences()
+	if err != nil {
+		return err
+	}
+	if err := ar.Encode(w); err != nil {
+		return err
+	}
+	if isFlush(br) {
+		return nil
+	}
+
+	req := packp.NewUploadPackRequest()
+	if err := req.UploadRequest.Decode(br); err != nil {
+		return err
+	}
+	sc := pktline.NewScanner(br)
+	for sc.Scan() {
+		line := strings.TrimSpace(string(sc.Bytes()))
+		if line == "done" {
+			break
+		}
+		if strings.HasPrefix(line, "have ") {
+			req.Haves = append(req.Haves, plumbing.NewHash(strings.TrimPrefix(line, "have ")))
+		}
+	}
+	resp, err := s.UploadPack(ctx, req)
+	if err != nil {
+		return err
+	}
+	return resp.Encode(w)
+}
+
+func serveReceivePack(ctx context.Context, ep *transport.Endpoint, br *bufio.Reader, w io.Writer) error {
+	s, err := server.NewServer(fileLoader{}).NewReceivePackSession(ep, nil)
+	if err != nil {
+		return err
+	}
+	ar, err := s.AdvertisedReferences()
+	if err != nil {
+		return err
+	}
+	if err := ar.Encode(w); err != nil {
+		return err
+	}
+	if isFlush(br) {
+		return nil
+	}
+
+	req := packp.NewReferenceUpdateRequest()
+	if err := req.Decode(br); err != nil {
+		return err
+	}
+	rs, err := s.ReceivePack(ctx, req)
+	if rs != nil {
+		if err := rs.Encode(w); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func TestSSH(t *testing.T) {
+	ctx := testContext(t)
+	td := t.TempDir()
+	remote := initBare(t)
+	fakeServer(t, filepath.Dir(remote))
+	url := "git@example.org:" + filepath.Base(remote)
+
+	alice, err := Init(ctx, filepath.Join(td, "alice"), "Alice", "alice@example.org")
+	require.NoError(t, err)
+	require.NoError(t, alice.AddRemote(ctx, "origin", url))
+	require.NoError(t, alice.Push(ctx, "", ""))
+
+	bob, err := Clone(ctx, "ssh://git@example.org:2222/"+filepath.Base(remote), filepath.Join(td, "bob"))
+	require.NoError(t, err)
+	require.NoError(t, bob.InitConfig(ctx, "Bob", "bob@example.org"))
+	assert.True(t, bob.Exists(ctx, ".gitattributes"))
+
+	writeAndCommit(ctx, t, alice, "alice", "alice", "add alice")
+	writeAndCommit(ctx, t, bob, "bob", "bob", "add bob")
+	require.NoError(t, alice.Push(ctx, "", ""))
+	require.NoError(t, bob.Push(ctx, "", ""))
+	require.NoError(t, alice.Pull(ctx, "", ""))
+
+	for _, g := range []*Git{alice, bob} {
+		assert.True(t, g.Exists(ctx, "alice"))
+		assert.True(t, g.Exists(ctx, "bob"))
+	}
+
+	// nothing to do
+	require.NoError(t, alice.Push(ctx, "", ""))
+}
+
+func TestNewEndpoint(t *testing.T) {
+	for _, tc := range []struct {
+		remote string
+		want   transport.Endpoint
+	}{
+		{"/srv/git/store.git", transport.Endpoint{Protocol: "file", Path: "/srv/git/store.git"}},
+		{"file:///srv/git/store.git", transport.Endpoint{Protocol: "file", Path: "/srv/git/store.git"}},
+		{"./foo:bar", transport.Endpoint{Protocol: "file", Path: "./foo:bar"}},
+		{"example.org:store.git", transport.Endpoint{Protocol: "ssh", Host: "example.org", Path: "store.git"}},
+		{"git@example.org:store.git", transport.Endpoint{Protocol: "ssh", User: "git", Host: "example.org", Path: "store.git"}},
+		{"ssh://git@example.org:2222/srv/store.git", transport.Endpoint{Protocol: "ssh", User: "git", Host: "example.org", Port: 2222, Path: "/srv/store.git"}},
+		{"ssh://example.org/~/store.git", transport.Endpoint{Protocol: "ssh", Host: "example.org", Path: "~/store.git"}},
+	} {
+		got, err := newEndpoint(tc.remote, "ssh -i key")
+		require.NoError(t, err, tc.remote)
+		assert.Equal(t, tc.want, *got.ep, tc.remote)
+		if tc.want.Protocol == "ssh" {
+			assert.Equal(t, &sshTransport{command: "ssh -i key"}, got.t, tc.remote)
+		}
+	}
+
+	_, err := newEndpoint("https://example.org/store.git", "")
+	assert.ErrorIs(t, err, ErrUnsupportedRemote)
+}
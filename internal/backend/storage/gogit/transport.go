@@ -0,0 +1,308 @@
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/itsonlycode/gosecret/pkg/debug"
+	"github.com/itsonlycode/gosecret/pkg/fsutil"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/revlist"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+var (
+	// ErrRejected is returned if a push would lose commits of the remote
+	ErrRejected = errors.New("updates were rejected because the remote contains work that you do not have locally, pull first")
+	// ErrUnsupportedRemote is returned for remotes that are neither local
+	// paths nor SSH URLs
+	ErrUnsupportedRemote = errors.New("unsupported remote, only local paths and ssh are supported")
+)
+
+// remoteRefs are the refs advertised by a remote
+type remoteRefs struct {
+	refs map[plumbing.ReferenceName]plumbing.Hash
+	// head is the branch HEAD points to, if known
+	head plumbing.ReferenceName
+}
+
+func newRemoteRefs(ar *packp.AdvRefs) *remoteRefs {
+	rr := &remoteRefs{refs: make(map[plumbing.ReferenceName]plumbing.Hash, len(ar.References))}
+	for name, h := range ar.References {
+		rr.refs[plumbing.ReferenceName(name)] = h
+	}
+	if ar.Head != nil {
+		rr.refs[plumbing.HEAD] = *ar.Head
+	}
+	for _, v := range ar.Capabilities.Get(capability.SymRef) {
+		if p := strings.SplitN(v, ":", 2); len(p) == 2 && p[0] == plumbing.HEAD.String() {
+			rr.head = plumbing.ReferenceName(p[1])
+		}
+	}
+	return rr
+}
+
+// headBranch returns the ref of the branch HEAD of the remote points to
+func (rr *remoteRefs) headBranch() plumbing.ReferenceName {
+	if _, found := rr.refs[rr.head]; found && rr.head.IsBranch() {
+		return rr.head
+	}
+	h, found := rr.refs[plumbing.HEAD]
+	if !found {
+		return ""
+	}
+	for _, name := range []plumbing.ReferenceName{"refs/heads/main", "refs/heads/master"} {
+		if rh, found := rr.refs[name]; found && rh == h {
+			return name
+		}
+	}
+	for name, rh := range rr.refs {
+		if name.IsBranch() && rh == h {
+			return name
+		}
+	}
+	return ""
+}
+
+// endpoint is a remote repository and the transport to access it
+type endpoint struct {
+	t  transport.Transport
+	ep *transport.Endpoint
+}
+
+func (e *endpoint) String() string {
+	if e.ep.Protocol == "file" {
+		return e.ep.Path
+	}
+	return e.ep.Host + ":" + e.ep.Path
+}
+
+// newEndpoint returns the endpoint for the remote URL. Supported are local
+// paths, file:// URLs and SSH remotes (ssh:// URLs and the scp-like
+// [user@]host:path syntax). Local remotes are served by go-git in process,
+// SSH remotes use the ssh client.
+func newEndpoint(remote, sshCommand string) (*endpoint, error) {
+	if strings.HasPrefix(remote, "file://") {
+		return newFileEndpoint(strings.TrimPrefix(remote, "file://")), nil
+	}
+	if strings.HasPrefix(remote, "ssh://") || strings.HasPrefix(remote, "git+ssh://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote %q: %w", remote, err)
+		}
+		ep := &transport.Endpoint{Protocol: "ssh", Host: u.Hostname(), Path: u.Path}
+		if strings.HasPrefix(ep.Path, "/~") {
+			ep.Path = ep.Path[1:]
+		}
+		if u.Port() != "" {
+			if ep.Port, err = strconv.Atoi(u.Port()); err != nil {
+				return nil, fmt.Errorf("invalid port in remote %q: %w", remote, err)
+			}
+		}
+		if u.User != nil {
+			ep.User = u.User.Username()
+		}
+		return &endpoint{t: &sshTransport{command: sshCommand}, ep: ep}, nil
+	}
+	if strings.Contains(remote, "://") {
+		return nil, fmt.Errorf("%s: %w", remote, ErrUnsupportedRemote)
+	}
+	// scp-like syntax. A colon after a slash is part of a local path.
+	if i := strings.Index(remote, ":"); i > 1 && !strings.Contains(remote[:i], "/") {
+		ep := &transport.Endpoint{Protocol: "ssh", Host: remote[:i], Path: remote[i+1:]}
+		if j := strings.LastIndex(ep.Host, "@"); j >= 0 {
+			ep.User, ep.Host = ep.Host[:j], ep.Host[j+1:]
+		}
+		return &endpoint{t: &sshTransport{command: sshCommand}, ep: ep}, nil
+	}
+	return newFileEndpoint(remote), nil
+}
+
+func newFileEndpoint(path string) *endpoint {
+	return &endpoint{
+		t:  server.NewClient(fileLoader{}),
+		ep: &transport.Endpoint{Protocol: "file", Path: path},
+	}
+}
+
+// fileLoader opens local repositories for the in process server of go-git.
+// Unlike the default loader it also accepts repositories with a work tree.
+type fileLoader struct{}
+
+func (fileLoader) Load(ep *transport.Endpoint) (storer.Storer, error) {
+	path := ep.Path
+	if fsutil.IsDir(filepath.Join(path, ".git")) {
+		path = filepath.Join(path, ".git")
+	}
+	if !fsutil.IsFile(filepath.Join(path, "config")) {
+		return nil, fmt.Errorf("failed to open remote %s: %w", ep.Path, transport.ErrRepositoryNotFound)
+	}
+	return filesystem.NewStorage(osfs.New(path), cache.NewObjectLRUDefault()), nil
+}
+
+// fetch downloads all branches of the remote
+func (e *endpoint) fetch(ctx context.Context, s storer.Storer) (*remoteRefs, error) {
+	sess, err := e.t.NewUploadPackSession(e.ep, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = sess.Close()
+	}()
+
+	ar, err := sess.AdvertisedReferencesContext(ctx)
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return &remoteRefs{refs: map[plumbing.ReferenceName]plumbing.Hash{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rr := newRemoteRefs(ar)
+
+	req := packp.NewUploadPackRequestFromCapabilities(ar.Capabilities)
+	if ar.Capabilities.Supports(capability.OFSDelta) {
+		if err := req.Capabilities.Set(capability.OFSDelta); err != nil {
+			return nil, err
+		}
+	}
+	wants := map[plumbing.Hash]bool{}
+	haves := map[plumbing.Hash]bool{}
+	for name, h := range rr.refs {
+		// only commits the remote has are sent as haves, since go-git's
+		// server fails on unknown ones
+		if s.HasEncodedObject(h) == nil {
+			haves[h] = true
+			continue
+		}
+		if name.IsBranch() {
+			wants[h] = true
+		}
+	}
+	if len(wants) < 1 {
+		debug.Log("%s is up to date", e)
+		return rr, nil
+	}
+	req.Wants = sortedHashes(wants)
+	req.Haves = sortedHashes(haves)
+
+	resp, err := sess.UploadPack(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch from %s: %w", e, err)
+	}
+	defer func() {
+		_ = resp.Close()
+	}()
+	if err := packfile.UpdateObjectStorage(s, resp); err != nil {
+		return nil, fmt.Errorf("failed to receive pack: %w", err)
+	}
+	debug.Log("fetched %d refs from %s", len(req.Wants), e)
+	return rr, nil
+}
+
+func sortedHashes(m map[plumbing.Hash]bool) []plumbing.Hash {
+	hs := make([]plumbing.Hash, 0, len(m))
+	for h := range m {
+		hs = append(hs, h)
+	}
+	sort.Slice(hs, func(i, j int) bool {
+		return hs[i].String() < hs[j].String()
+	})
+	return hs
+}
+
+// checkedOutBranch returns the branch HEAD of a local repository with a
+// work tree points to
+func (e *endpoint) checkedOutBranch() plumbing.ReferenceName {
+	if e.ep.Protocol != "file" || !fsutil.IsDir(filepath.Join(e.ep.Path, ".git")) {
+		return ""
+	}
+	r, err := git.PlainOpen(e.ep.Path)
+	if err != nil {
+		return ""
+	}
+	ref, err := r.Storer.Reference(plumbing.HEAD)
+	if err != nil || ref.Type() != plumbing.SymbolicReference {
+		return ""
+	}
+	return ref.Target()
+}
+
+// push updates the ref of the remote. It fails with ErrRejected if this is
+// not a fast forward.
+func (e *endpoint) push(ctx context.Context, s storer.Storer, ref plumbing.ReferenceName, h plumbing.Hash) error {
+	if e.checkedOutBranch() == ref {
+		return fmt.Errorf("refusing to update checked out branch %s of %s", ref, e)
+	}
+
+	sess, err := e.t.NewReceivePackSession(e.ep, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = sess.Close()
+	}()
+
+	ar, err := sess.AdvertisedReferencesContext(ctx)
+	if err != nil {
+		return err
+	}
+	old := ar.References[ref.String()]
+	if old == h {
+		debug.Log("%s is up to date", e)
+		return nil
+	}
+	if ok, err := isAncestor(s, old, h); err != nil || !ok {
+		return fmt.Errorf("failed to push to %s: %w", e, ErrRejected)
+	}
+
+	var exclude []plumbing.Hash
+	for _, rh := range ar.References {
+		if s.HasEncodedObject(rh) == nil {
+			exclude = append(exclude, rh)
+		}
+	}
+	objs, err := revlist.Objects(s, []plumbing.Hash{h}, exclude)
+	if err != nil {
+		return err
+	}
+
+	req := packp.NewReferenceUpdateRequestFromCapabilities(ar.Capabilities)
+	if ar.Capabilities.Supports(capability.ReportStatus) {
+		if err := req.Capabilities.Set(capability.ReportStatus); err != nil {
+			return err
+		}
+	}
+	req.Commands = []*packp.Command{{Name: ref, Old: old, New: h}}
+	pr, pw := io.Pipe()
+	defer func() {
+		_ = pr.Close()
+	}()
+	go func() {
+		_, err := packfile.NewEncoder(pw, s, false).Encode(objs, 10)
+		_ = pw.CloseWithError(err)
+	}()
+	req.Packfile = pr
+
+	if _, err := sess.ReceivePack(ctx, req); err != nil {
+		return fmt.Errorf("failed to push to %s: %w", e, err)
+	}
+	debug.Log("sent %d objects to %s", len(objs), e)
+	return nil
+}
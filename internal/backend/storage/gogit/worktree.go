@@ -0,0 +1,302 @@
+package gogit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// fileEntry is a file of a tree or the index
+type fileEntry struct {
+	mode filemode.FileMode
+	hash plumbing.Hash
+}
+
+type fileChange struct {
+	name   string
+	action byte // A, M, D
+}
+
+// inPathspec returns true if the file is one of the paths or inside of one
+// of them. An empty path matches everything.
+func inPathspec(name string, paths []string) bool {
+	for _, p := range paths {
+		if p == "" || p == "." || name == p || strings.HasPrefix(name, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// diffFiles compares two flattened trees
+func diffFiles(from, to map[string]fileEntry) []fileChange {
+	var changes []fileChange
+	for name, fe := range to {
+		old, found := from[name]
+		switch {
+		case !found:
+			changes = append(changes, fileChange{name: name, action: 'A'})
+		case old != fe:
+			changes = append(changes, fileChange{name: name, action: 'M'})
+		}
+	}
+	for name := range from {
+		if _, found := to[name]; !found {
+			changes = append(changes, fileChange{name: name, action: 'D'})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].name < changes[j].name
+	})
+	return changes
+}
+
+// flattenTree returns all files of the tree by their full path
+func (g *Git) flattenTree(h plumbing.Hash) (map[string]fileEntry, error) {
+	files := map[string]fileEntry{}
+	if h.IsZero() {
+		return files, nil
+	}
+	tree, err := g.repo.TreeObject(h)
+	if err != nil {
+		return nil, err
+	}
+	w := object.NewTreeWalker(tree, true, nil)
+	defer w.Close()
+	for {
+		name, e, err := w.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e.Mode == filemode.Dir {
+			continue
+		}
+		files[name] = fileEntry{mode: e.Mode, hash: e.Hash}
+	}
+}
+
+// writeTree stores the files as a tree and its subtrees
+func (g *Git) writeTree(files map[string]fileEntry) (plumbing.Hash, error) {
+	t := &object.Tree{}
+	dirs := map[string]map[string]fileEntry{}
+	for name, fe := range files {
+		if i := strings.IndexByte(name, '/'); i >= 0 {
+			if dirs[name[:i]] == nil {
+				dirs[name[:i]] = map[string]fileEntry{}
+			}
+			dirs[name[:i]][name[i+1:]] = fe
+			continue
+		}
+		t.Entries = append(t.Entries, object.TreeEntry{Name: name, Mode: fe.mode, Hash: fe.hash})
+	}
+	for name, sub := range dirs {
+		h, err := g.writeTree(sub)
+		if err != nil {
+			return h, err
+		}
+		t.Entries = append(t.Entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: h})
+	}
+	// git sorts directories as if they had a trailing slash
+	sortName := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(t.Entries, func(i, j int) bool {
+		return sortName(t.Entries[i]) < sortName(t.Entries[j])
+	})
+
+	obj := g.repo.Storer.NewEncodedObject()
+	if err := t.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return g.repo.Storer.SetEncodedObject(obj)
+}
+
+func (g *Git) readBlob(h plumbing.Hash) ([]byte, error) {
+	blob, err := g.repo.BlobObject(h)
+	if err != nil {
+		return nil, err
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	return io.ReadAll(r)
+}
+
+// updateIndex reads, changes and writes the index while holding
+// .git/index.lock, like git does. Must be called with the lock held.
+func (g *Git) updateIndex(update func(*index.Index) error) error {
+	fn := filepath.Join(g.fs.Path(), ".git", "index")
+	lock, err := os.OpenFile(fn+".lock", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("unable to create %s.lock: another git process seems to be running in this repository", fn)
+		}
+		return err
+	}
+	defer func() {
+		_ = lock.Close()
+		_ = os.Remove(fn + ".lock")
+	}()
+
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	if err := update(idx); err != nil {
+		return err
+	}
+
+	// go-git can only write version 2 without extensions. This drops the
+	// cache tree, which is fine since it's optional.
+	idx.Version = index.EncodeVersionSupported
+	bw := bufio.NewWriter(lock)
+	if err := index.NewEncoder(bw).Encode(idx); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if err := lock.Close(); err != nil {
+		return err
+	}
+	return os.Rename(fn+".lock", fn)
+}
+
+// addFile stores the file of the work tree as a blob and adds it to the
+// index
+func (g *Git) addFile(idx *index.Index, name string) error {
+	fn := filepath.Join(g.fs.Path(), filepath.FromSlash(name))
+	fi, err := os.Lstat(fn)
+	if err != nil {
+		return err
+	}
+	var buf []byte
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(fn)
+		if err != nil {
+			return err
+		}
+		buf = []byte(filepath.ToSlash(target))
+	} else if buf, err = os.ReadFile(fn); err != nil {
+		return err
+	}
+
+	obj := g.repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(buf)))
+	w, err := obj.Writer()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	h, err := g.repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return err
+	}
+	return setIndexEntry(idx, name, h, fi)
+}
+
+func setIndexEntry(idx *index.Index, name string, h plumbing.Hash, fi os.FileInfo) error {
+	mode, err := filemode.NewFromOSFileMode(fi.Mode())
+	if err != nil {
+		return err
+	}
+	e, err := idx.Entry(name)
+	if errors.Is(err, index.ErrEntryNotFound) {
+		e = idx.Add(name)
+	} else if err != nil {
+		return err
+	}
+	e.Hash = h
+	e.Mode = mode
+	e.ModifiedAt = fi.ModTime()
+	e.Size = uint32(fi.Size())
+	return nil
+}
+
+// checkout updates the work tree and the index from one tree to another.
+// Files that are not part of the change are left alone.
+func (g *Git) checkout(idx *index.Index, from, to map[string]fileEntry) error {
+	root := g.fs.Path()
+	for _, c := range diffFiles(from, to) {
+		fn := filepath.Join(root, filepath.FromSlash(c.name))
+		if c.action == 'D' {
+			if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if _, err := idx.Remove(c.name); err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+				return err
+			}
+			removeEmptyDirs(root, filepath.Dir(fn))
+			continue
+		}
+
+		fe := to[c.name]
+		buf, err := g.readBlob(fe.hash)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+			return err
+		}
+		if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		switch fe.mode {
+		case filemode.Symlink:
+			err = os.Symlink(filepath.FromSlash(string(buf)), fn)
+		case filemode.Executable:
+			err = os.WriteFile(fn, buf, 0700)
+		default:
+			err = os.WriteFile(fn, buf, 0600)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", c.name, err)
+		}
+
+		fi, err := os.Lstat(fn)
+		if err != nil {
+			return err
+		}
+		if err := setIndexEntry(idx, c.name, fe.hash, fi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeEmptyDirs removes dir and its parents up to the root if they are
+// empty
+func removeEmptyDirs(root, dir string) {
+	for dir != root && strings.HasPrefix(dir, root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
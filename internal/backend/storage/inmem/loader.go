@@ -0,0 +1,101 @@
+package inmem
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+)
+
+const (
+	name = "inmem"
+)
+
+var (
+	storesMu sync.Mutex
+	// stores keeps all in-memory stores of this process by their path
+	stores = map[string]*InMem{}
+)
+
+func init() {
+	backend.RegisterStorage(backend.InMem, name, &loader{})
+}
+
+// Open returns the in-memory store at path. It's created if it does not
+// exist, yet.
+func Open(path string) *InMem {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+
+	if m, found := stores[path]; found {
+		return m
+	}
+	m := New(path)
+	stores[path] = m
+	return m
+}
+
+// Lookup returns the in-memory store at path, if it exists
+func Lookup(path string) (*InMem, bool) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+
+	m, found := stores[path]
+	return m, found
+}
+
+// Discard forgets the in-memory store at path
+func Discard(path string) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+
+	delete(stores, path)
+}
+
+type loader struct{}
+
+// New implements backend.StorageLoader
+func (l loader) New(ctx context.Context, path string) (backend.Storage, error) {
+	be := Open(path)
+	debug.Log("Using Storage Backend: %s", be.String())
+	return be, nil
+}
+
+// Init implements backend.StorageLoader
+func (l loader) Init(ctx context.Context, path string) (backend.Storage, error) {
+	return l.New(ctx, path)
+}
+
+// Clone implements backend.StorageLoader. The repo must be the path of
+// another in-memory store.
+func (l loader) Clone(ctx context.Context, repo, path string) (backend.Storage, error) {
+	if _, found := Lookup(repo); !found {
+		return nil, fmt.Errorf("no in-memory store at %s", repo)
+	}
+	m := Open(path)
+	if err := m.AddRemote(ctx, defaultRemote, repo); err != nil {
+		return nil, err
+	}
+	if err := m.Pull(ctx, defaultRemote, ""); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Handles only accepts paths of existing in-memory stores
+func (l loader) Handles(path string) error {
+	if _, found := Lookup(path); found {
+		return nil
+	}
+	return fmt.Errorf("no in-memory store at %s", path)
+}
+
+func (l loader) Priority() int {
+	return 0
+}
+
+func (l loader) String() string {
+	return name
+}
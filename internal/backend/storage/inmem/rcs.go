@@ -0,0 +1,322 @@
+package inmem
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+)
+
+const defaultRemote = "origin"
+
+var (
+	// ErrRejected is returned by Push if the remote has commits that were
+	// not pulled, yet
+	ErrRejected = errors.New("push rejected, pull first")
+	// ErrDiverged is returned by Pull if neither history contains the other
+	ErrDiverged = errors.New("histories have diverged")
+)
+
+// Commit is a commit in the history of an in-memory store
+type Commit struct {
+	Hash        string
+	AuthorName  string
+	AuthorEmail string
+	Date        time.Time
+	Subject     string
+	Body        string
+	// Files lists the files changed by this commit
+	Files []string
+
+	tree tree
+}
+
+// Add does nothing. Like gitfs, all changes are committed.
+func (m *InMem) Add(ctx context.Context, args ...string) error {
+	return nil
+}
+
+// Commit records the current content with the given message. It returns
+// store.ErrGitNothingToCommit if nothing changed since the last commit.
+func (m *InMem) Commit(ctx context.Context, msg string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	files := m.tree.changed(m.head().tree)
+	if len(files) < 1 {
+		return store.ErrGitNothingToCommit
+	}
+
+	c := Commit{
+		AuthorName:  m.name,
+		AuthorEmail: m.email,
+		Date:        ctxutil.GetCommitTimestamp(ctx),
+		Files:       files,
+		tree:        m.tree.clone(),
+	}
+	c.Subject, c.Body = msg, ""
+	if i := strings.Index(msg, "\n"); i >= 0 {
+		c.Subject, c.Body = msg[:i], strings.TrimSpace(msg[i+1:])
+	}
+	c.Hash = m.hash(c)
+	debug.Log("committed %s to %s: %s", c.Hash, m.path, c.Subject)
+	m.commits = append(m.commits, c)
+	return nil
+}
+
+// hash derives a hash from the commit and its parent
+func (m *InMem) hash(c Commit) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%d\x00%s\x00%s\x00", m.head().Hash, c.AuthorName, c.AuthorEmail, c.Date.UnixNano(), c.Subject, c.Body)
+	for _, name := range c.tree.names() {
+		buf, _ := c.tree.get(name)
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", name, c.tree.links[name], buf)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// head returns the last commit. It must be called with the lock held.
+func (m *InMem) head() Commit {
+	if len(m.commits) < 1 {
+		return Commit{tree: newTree()}
+	}
+	return m.commits[len(m.commits)-1]
+}
+
+// Commits returns the history of the store, latest commit first
+func (m *InMem) Commits() []Commit {
+	m.Lock()
+	defer m.Unlock()
+
+	cs := make([]Commit, 0, len(m.commits))
+	for i := len(m.commits) - 1; i >= 0; i-- {
+		cs = append(cs, m.commits[i])
+	}
+	return cs
+}
+
+// history returns a copy of all commits, oldest first
+func (m *InMem) history() []Commit {
+	m.Lock()
+	defer m.Unlock()
+
+	return append([]Commit(nil), m.commits...)
+}
+
+// Push makes the remote store fast forward to this history. It fails with
+// ErrRejected if the remote has commits this store has not pulled.
+func (m *InMem) Push(ctx context.Context, remote, branch string) error {
+	if ctxutil.IsNoNetwork(ctx) {
+		debug.Log("Skipping network operation push as requested")
+		return nil
+	}
+	r, err := m.remote(remote)
+	if err != nil {
+		return err
+	}
+	ours := m.history()
+	theirs := r.history()
+	if isPrefix(ours, theirs) {
+		debug.Log("%s is up to date", r.path)
+		return nil
+	}
+	if !isPrefix(theirs, ours) {
+		return fmt.Errorf("failed to push to %s: %w", r.path, ErrRejected)
+	}
+	return r.fastForward(ours)
+}
+
+// Pull makes this store fast forward to the history of the remote store
+func (m *InMem) Pull(ctx context.Context, remote, branch string) error {
+	if ctxutil.IsNoNetwork(ctx) {
+		debug.Log("Skipping network operation pull as requested")
+		return nil
+	}
+	r, err := m.remote(remote)
+	if err != nil {
+		return err
+	}
+	ours := m.history()
+	theirs := r.history()
+	if isPrefix(theirs, ours) {
+		debug.Log("%s is up to date", m.path)
+		return nil
+	}
+	if !isPrefix(ours, theirs) {
+		return fmt.Errorf("failed to pull from %s: %w", r.path, ErrDiverged)
+	}
+	return m.fastForward(theirs)
+}
+
+func (m *InMem) remote(remote string) (*InMem, error) {
+	if remote == "" {
+		remote = defaultRemote
+	}
+	m.Lock()
+	location, found := m.remotes[remote]
+	m.Unlock()
+	if !found {
+		return nil, store.ErrGitNoRemote
+	}
+	r, found := Lookup(location)
+	if !found {
+		return nil, fmt.Errorf("remote %s not found at %s", remote, location)
+	}
+	return r, nil
+}
+
+// fastForward replaces the history with a longer one. Uncommitted changes
+// are never overwritten.
+func (m *InMem) fastForward(commits []Commit) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if len(m.tree.changed(m.head().tree)) > 0 {
+		return fmt.Errorf("%s has uncommitted changes", m.path)
+	}
+	if !isPrefix(m.commits, commits) {
+		return fmt.Errorf("failed to update %s: %w", m.path, ErrDiverged)
+	}
+	m.commits = append([]Commit(nil), commits...)
+	m.tree = m.head().tree.clone()
+	return nil
+}
+
+// isPrefix returns true if the history a is contained in b
+func isPrefix(a, b []Commit) bool {
+	if len(a) > len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Hash != b[i].Hash {
+			return false
+		}
+	}
+	return true
+}
+
+// InitConfig sets the author of new commits
+func (m *InMem) InitConfig(ctx context.Context, name, email string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.name = name
+	m.email = email
+	return nil
+}
+
+// AddRemote adds a remote. The location is the path of another in-memory
+// store.
+func (m *InMem) AddRemote(ctx context.Context, remote, location string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.remotes[remote] = location
+	return nil
+}
+
+// RemoveRemote removes a remote
+func (m *InMem) RemoveRemote(ctx context.Context, remote string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, found := m.remotes[remote]; !found {
+		return fmt.Errorf("no such remote: %s", remote)
+	}
+	delete(m.remotes, remote)
+	return nil
+}
+
+// Revisions returns the commits that changed the named file, latest first
+func (m *InMem) Revisions(ctx context.Context, name string) ([]backend.Revision, error) {
+	name = path.Clean(name)
+	var revs []backend.Revision
+	for _, c := range m.Commits() {
+		for _, f := range c.Files {
+			if f != name {
+				continue
+			}
+			revs = append(revs, backend.Revision{
+				Hash:        c.Hash,
+				AuthorName:  c.AuthorName,
+				AuthorEmail: c.AuthorEmail,
+				Date:        c.Date,
+				Subject:     c.Subject,
+				Body:        c.Body,
+			})
+			break
+		}
+	}
+	if len(revs) < 1 {
+		return nil, fmt.Errorf("no revisions found for %s", name)
+	}
+	return revs, nil
+}
+
+// GetRevision returns the content of the named file at the given revision.
+// Revisions can be given by a hash (or an unique prefix of at least four
+// characters) or as HEAD.
+func (m *InMem) GetRevision(ctx context.Context, name, revision string) ([]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	name = path.Clean(strings.TrimSpace(name))
+	revision = strings.TrimSpace(revision)
+
+	var found []Commit
+	for _, c := range m.commits {
+		if strings.HasPrefix(c.Hash, revision) && len(revision) >= 4 {
+			found = append(found, c)
+		}
+	}
+	if revision == "HEAD" && len(m.commits) > 0 {
+		found = []Commit{m.head()}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("unknown revision %q", revision)
+	case 1:
+	default:
+		return nil, fmt.Errorf("ambiguous revision %q", revision)
+	}
+
+	buf, ok := found[0].tree.get(name)
+	if !ok {
+		return nil, fmt.Errorf("%s does not exist in %s", name, revision)
+	}
+	return append([]byte(nil), buf...), nil
+}
+
+// Status lists the changes since the last commit, like git status --short
+func (m *InMem) Status(ctx context.Context) ([]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	head := m.head().tree
+	buf := &bytes.Buffer{}
+	for _, name := range m.tree.changed(head) {
+		switch {
+		case !head.has(name):
+			fmt.Fprintf(buf, "?? %s\n", name)
+		case !m.tree.has(name):
+			fmt.Fprintf(buf, " D %s\n", name)
+		default:
+			fmt.Fprintf(buf, " M %s\n", name)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Compact does nothing
+func (m *InMem) Compact(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,278 @@
+// Package inmem implements a storage backend that keeps everything in memory.
+// It has a simple revision history and can push to and pull from other
+// in-memory stores, so it behaves much like gitfs without touching the disk.
+// Stores live as long as the process.
+package inmem
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/itsonlycode/gosecret/pkg/debug"
+
+	"github.com/blang/semver/v4"
+)
+
+// tree is the content of a store, i.e. the working tree or a commit
+type tree struct {
+	files map[string][]byte
+	// links maps the name of a link to its target
+	links map[string]string
+}
+
+func newTree() tree {
+	return tree{
+		files: make(map[string][]byte, 10),
+		links: make(map[string]string),
+	}
+}
+
+func (t tree) clone() tree {
+	c := tree{
+		files: make(map[string][]byte, len(t.files)),
+		links: make(map[string]string, len(t.links)),
+	}
+	for k, v := range t.files {
+		c.files[k] = append([]byte(nil), v...)
+	}
+	for k, v := range t.links {
+		c.links[k] = v
+	}
+	return c
+}
+
+func (t tree) names() []string {
+	names := make([]string, 0, len(t.files)+len(t.links))
+	for k := range t.files {
+		names = append(names, k)
+	}
+	for k := range t.links {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// changed returns the files that differ between both trees
+func (t tree) changed(o tree) []string {
+	var names []string
+	for _, name := range t.names() {
+		if !t.same(o, name) {
+			names = append(names, name)
+		}
+	}
+	for _, name := range o.names() {
+		if !t.has(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t tree) has(name string) bool {
+	if _, found := t.files[name]; found {
+		return true
+	}
+	_, found := t.links[name]
+	return found
+}
+
+func (t tree) same(o tree, name string) bool {
+	if l, found := t.links[name]; found {
+		ol, ofound := o.links[name]
+		return ofound && l == ol
+	}
+	v, found := t.files[name]
+	ov, ofound := o.files[name]
+	return found == ofound && bytes.Equal(v, ov)
+}
+
+// get returns the content of a file, following links
+func (t tree) get(name string) ([]byte, bool) {
+	for i := 0; i < 10; i++ {
+		target, found := t.links[name]
+		if !found {
+			break
+		}
+		name = target
+	}
+	buf, found := t.files[name]
+	return buf, found
+}
+
+// InMem is an in-memory storage
+type InMem struct {
+	sync.Mutex
+
+	path    string
+	tree    tree
+	commits []Commit
+	name    string
+	email   string
+	remotes map[string]string
+}
+
+// New creates a new, empty in-memory store. Use the loader (or Open) to get
+// stores that can be found by their path.
+func New(path string) *InMem {
+	return &InMem{
+		path:    path,
+		tree:    newTree(),
+		remotes: make(map[string]string),
+	}
+}
+
+func notFound(name string) error {
+	return fmt.Errorf("%s: %w", name, os.ErrNotExist)
+}
+
+// Get retrieves the named content
+func (m *InMem) Get(ctx context.Context, name string) ([]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	buf, found := m.tree.get(path.Clean(name))
+	if !found {
+		return nil, notFound(name)
+	}
+	return append([]byte(nil), buf...), nil
+}
+
+// Set writes the given content
+func (m *InMem) Set(ctx context.Context, name string, value []byte) error {
+	m.Lock()
+	defer m.Unlock()
+
+	name = path.Clean(name)
+	debug.Log("Writing %s to %s", name, m.path)
+	delete(m.tree.links, name)
+	m.tree.files[name] = append([]byte(nil), value...)
+	return nil
+}
+
+// Delete removes the named entity
+func (m *InMem) Delete(ctx context.Context, name string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	name = path.Clean(name)
+	if !m.tree.has(name) {
+		return notFound(name)
+	}
+	delete(m.tree.files, name)
+	delete(m.tree.links, name)
+	return nil
+}
+
+// Exists checks if the named entity exists
+func (m *InMem) Exists(ctx context.Context, name string) bool {
+	m.Lock()
+	defer m.Unlock()
+
+	_, found := m.tree.get(path.Clean(name))
+	return found
+}
+
+// List returns a list of all entities that start with the prefix. Like on
+// disk, files in hidden directories are skipped.
+func (m *InMem) List(ctx context.Context, prefix string) ([]string, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	prefix = strings.TrimPrefix(prefix, "/")
+	files := make([]string, 0, len(m.tree.files))
+	for _, name := range m.tree.names() {
+		if !strings.HasPrefix(name, prefix) || inHiddenDir(name) {
+			continue
+		}
+		files = append(files, name)
+	}
+	return files, nil
+}
+
+func inHiddenDir(name string) bool {
+	for _, p := range strings.Split(path.Dir(name), "/") {
+		if strings.HasPrefix(p, ".") && p != "." {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDir returns true if the named entity is a directory
+func (m *InMem) IsDir(ctx context.Context, name string) bool {
+	m.Lock()
+	defer m.Unlock()
+
+	dir := path.Clean(name) + "/"
+	for _, k := range m.tree.names() {
+		if strings.HasPrefix(k, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prune removes a named directory or file
+func (m *InMem) Prune(ctx context.Context, prefix string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	prefix = path.Clean(strings.TrimPrefix(prefix, "/"))
+	debug.Log("Pruning %s from %s", prefix, m.path)
+	for _, k := range m.tree.names() {
+		if k == prefix || strings.HasPrefix(k, prefix+"/") {
+			delete(m.tree.files, k)
+			delete(m.tree.links, k)
+		}
+	}
+	return nil
+}
+
+// Link creates an alias to reach the same content through a different name
+func (m *InMem) Link(ctx context.Context, from, to string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	from, to = path.Clean(from), path.Clean(to)
+	if _, found := m.tree.get(from); !found {
+		return notFound(from)
+	}
+	if m.tree.has(to) {
+		return fmt.Errorf("%s: %w", to, os.ErrExist)
+	}
+	m.tree.links[to] = from
+	return nil
+}
+
+// Name returns the name of this backend
+func (m *InMem) Name() string {
+	return name
+}
+
+// Version returns the version of this backend
+func (m *InMem) Version(context.Context) semver.Version {
+	return semver.Version{Major: 1}
+}
+
+// String implements fmt.Stringer
+func (m *InMem) String() string {
+	return fmt.Sprintf("inmem(v1.0.0,path:%s)", m.path)
+}
+
+// Path returns the path this store is registered at
+func (m *InMem) Path() string {
+	return m.path
+}
+
+// Fsck does nothing, the in-memory tree is always consistent
+func (m *InMem) Fsck(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,104 @@
+package inmem
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMem(t *testing.T) {
+	ctx := context.Background()
+	m := New("/test")
+
+	require.NoError(t, m.Set(ctx, "foo/bar.txt", []byte("bar")))
+	require.NoError(t, m.Set(ctx, ".gpg-id", []byte("id")))
+	require.NoError(t, m.Set(ctx, ".public-keys/id", []byte("key")))
+	require.NoError(t, m.Link(ctx, "foo/bar.txt", "baz.txt"))
+	assert.Error(t, m.Link(ctx, "foo/bar.txt", "baz.txt"))
+
+	buf, err := m.Get(ctx, "baz.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(buf))
+	assert.True(t, m.IsDir(ctx, "foo"))
+	assert.False(t, m.IsDir(ctx, "foo/bar.txt"))
+
+	files, err := m.List(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{".gpg-id", "baz.txt", "foo/bar.txt"}, files)
+
+	_, err = m.Get(ctx, "nope")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.True(t, errors.Is(m.Delete(ctx, "nope"), os.ErrNotExist))
+
+	require.NoError(t, m.Prune(ctx, "foo"))
+	assert.False(t, m.Exists(ctx, "foo/bar.txt"))
+	assert.False(t, m.Exists(ctx, "baz.txt"), "dangling link")
+}
+
+func TestRCS(t *testing.T) {
+	ctx := context.Background()
+	m := Open("/test/rcs")
+	defer Discard("/test/rcs")
+
+	require.NoError(t, m.InitConfig(ctx, "Alice", "alice@example.org"))
+	require.NoError(t, m.Set(ctx, "a", []byte("1")))
+	require.NoError(t, m.Set(ctx, "b", []byte("1")))
+	st, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "?? a\n?? b\n", string(st))
+
+	require.NoError(t, m.Commit(ctx, "first\n\nbody"))
+	assert.True(t, errors.Is(m.Commit(ctx, "again"), store.ErrGitNothingToCommit))
+
+	require.NoError(t, m.Set(ctx, "a", []byte("2")))
+	require.NoError(t, m.Delete(ctx, "b"))
+	st, err = m.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, " M a\n D b\n", string(st))
+	require.NoError(t, m.Commit(ctx, "second"))
+
+	revs, err := m.Revisions(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	assert.Equal(t, "second", revs[0].Subject)
+	assert.Equal(t, "first", revs[1].Subject)
+	assert.Equal(t, "body", revs[1].Body)
+	assert.Equal(t, "Alice", revs[1].AuthorName)
+
+	buf, err := m.GetRevision(ctx, "a", revs[1].Hash[:7])
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(buf))
+	buf, err = m.GetRevision(ctx, "a", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, "2", string(buf))
+	_, err = m.GetRevision(ctx, "b", "HEAD")
+	assert.Error(t, err)
+
+	t.Run("push and pull", func(t *testing.T) {
+		assert.True(t, errors.Is(m.Push(ctx, "", ""), store.ErrGitNoRemote))
+
+		cs, err := loader{}.Clone(ctx, "/test/rcs", "/test/clone")
+		require.NoError(t, err)
+		defer Discard("/test/clone")
+		c := cs.(*InMem)
+		assert.Equal(t, m.Commits(), c.Commits())
+
+		require.NoError(t, c.Set(ctx, "c", []byte("1")))
+		require.NoError(t, c.Commit(ctx, "third"))
+		require.NoError(t, c.Push(ctx, "", ""))
+		assert.True(t, m.Exists(ctx, "c"))
+
+		require.NoError(t, m.Set(ctx, "d", []byte("1")))
+		require.NoError(t, m.Commit(ctx, "fourth"))
+		require.NoError(t, c.Set(ctx, "e", []byte("1")))
+		require.NoError(t, c.Commit(ctx, "fifth"))
+		assert.True(t, errors.Is(c.Push(ctx, "", ""), ErrRejected))
+		assert.True(t, errors.Is(c.Pull(ctx, "", ""), ErrDiverged))
+	})
+}
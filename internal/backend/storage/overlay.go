@@ -0,0 +1,3 @@
+package storage
+
+import _ "github.com/itsonlycode/gosecret/internal/backend/storage/overlay" // register overlay backend
@@ -0,0 +1,110 @@
+package overlay
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	_ "github.com/itsonlycode/gosecret/internal/backend/storage/fs"    // register fs backend for the base
+	_ "github.com/itsonlycode/gosecret/internal/backend/storage/gitfs" // register gitfs backend for the base
+	"github.com/itsonlycode/gosecret/pkg/debug"
+	"github.com/itsonlycode/gosecret/pkg/fsutil"
+)
+
+const (
+	name = "overlay"
+)
+
+var (
+	overlaysMu sync.Mutex
+	// overlays keeps all overlays of this process by the path of their base
+	overlays = map[string]*Overlay{}
+)
+
+func init() {
+	backend.RegisterStorage(backend.Overlay, name, &loader{})
+}
+
+// Lookup returns the overlay over the store at path, if it exists
+func Lookup(path string) (*Overlay, bool) {
+	overlaysMu.Lock()
+	defer overlaysMu.Unlock()
+
+	o, found := overlays[path]
+	return o, found
+}
+
+// open returns the overlay over the store at path. The base is created by
+// the given function if there is no overlay, yet.
+func open(path string, base func() (backend.Storage, error)) (*Overlay, error) {
+	overlaysMu.Lock()
+	defer overlaysMu.Unlock()
+
+	if o, found := overlays[path]; found {
+		return o, nil
+	}
+	b, err := base()
+	if err != nil {
+		return nil, err
+	}
+	o := New(b)
+	overlays[path] = o
+	debug.Log("Using Storage Backend: %s", o.String())
+	return o, nil
+}
+
+// baseBackend returns the backend of an existing store. Stores that are
+// not tracked with git are used as plain fs stores.
+func baseBackend(path string) backend.StorageBackend {
+	if fsutil.IsDir(filepath.Join(path, ".git")) {
+		return backend.GitFS
+	}
+	return backend.FS
+}
+
+type loader struct{}
+
+// New implements backend.StorageLoader
+func (l loader) New(ctx context.Context, path string) (backend.Storage, error) {
+	return open(path, func() (backend.Storage, error) {
+		return backend.NewStorage(ctx, baseBackend(path), path)
+	})
+}
+
+// Init implements backend.StorageLoader. New stores are initialized with
+// gitfs as their base. Only the base is written to disk, the overlay is
+// gone when the process exits. That's why the CLI refuses this backend.
+func (l loader) Init(ctx context.Context, path string) (backend.Storage, error) {
+	return open(path, func() (backend.Storage, error) {
+		if be := baseBackend(path); be == backend.GitFS {
+			return backend.NewStorage(ctx, be, path)
+		}
+		return backend.InitStorage(ctx, backend.GitFS, path)
+	})
+}
+
+// Clone implements backend.StorageLoader. The repo is cloned with gitfs.
+func (l loader) Clone(ctx context.Context, repo, path string) (backend.Storage, error) {
+	return open(path, func() (backend.Storage, error) {
+		return backend.Clone(ctx, backend.GitFS, repo, path)
+	})
+}
+
+// Handles only accepts paths that have an overlay in this process. The
+// overlay must be selected explicitly.
+func (l loader) Handles(path string) error {
+	if _, found := Lookup(path); found {
+		return nil
+	}
+	return fmt.Errorf("no overlay for %s", path)
+}
+
+func (l loader) Priority() int {
+	return 0
+}
+
+func (l loader) String() string {
+	return name
+}
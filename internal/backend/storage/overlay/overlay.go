@@ -0,0 +1,284 @@
+// Package overlay implements a storage backend that layers a writable,
+// in-memory scratch store over a base storage. All changes are staged in
+// memory and the base is left alone until Apply lands them in a single
+// commit. This allows to review bulk changes before they reach the real
+// store.
+package overlay
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/backend/storage/inmem"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+
+	"github.com/blang/semver/v4"
+)
+
+// Overlay is a writable layer over a base storage
+type Overlay struct {
+	sync.Mutex
+
+	base    backend.Storage
+	scratch *inmem.InMem
+	// written are the files in the scratch store
+	written map[string]bool
+	// deleted are the files of the base that were removed
+	deleted map[string]bool
+	// links maps new links to their targets
+	links map[string]string
+	// pending are the files changed since the last staged commit
+	pending map[string]bool
+	staged  []stagedCommit
+}
+
+// New creates a new overlay over the base storage
+func New(base backend.Storage) *Overlay {
+	o := &Overlay{base: base}
+	o.reset()
+	return o
+}
+
+func (o *Overlay) reset() {
+	o.scratch = inmem.New(o.base.Path())
+	o.written = make(map[string]bool)
+	o.deleted = make(map[string]bool)
+	o.links = make(map[string]string)
+	o.pending = make(map[string]bool)
+	o.staged = nil
+}
+
+// Base returns the underlying storage
+func (o *Overlay) Base() backend.Storage {
+	return o.base
+}
+
+func notFound(name string) error {
+	return fmt.Errorf("%s: %w", name, os.ErrNotExist)
+}
+
+// resolve follows links created in the overlay
+func (o *Overlay) resolve(name string) string {
+	for i := 0; i < 10; i++ {
+		target, found := o.links[name]
+		if !found {
+			break
+		}
+		name = target
+	}
+	return name
+}
+
+// exists must be called with the lock held
+func (o *Overlay) exists(ctx context.Context, name string) bool {
+	name = o.resolve(name)
+	if o.written[name] {
+		return true
+	}
+	return !o.deleted[name] && o.base.Exists(ctx, name)
+}
+
+// Get retrieves the named content
+func (o *Overlay) Get(ctx context.Context, name string) ([]byte, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	name = o.resolve(path.Clean(name))
+	if o.written[name] {
+		return o.scratch.Get(ctx, name)
+	}
+	if o.deleted[name] {
+		return nil, notFound(name)
+	}
+	return o.base.Get(ctx, name)
+}
+
+// Set stages the given content
+func (o *Overlay) Set(ctx context.Context, name string, value []byte) error {
+	o.Lock()
+	defer o.Unlock()
+
+	name = path.Clean(name)
+	delete(o.links, name)
+	delete(o.deleted, name)
+	o.written[name] = true
+	o.pending[name] = true
+	return o.scratch.Set(ctx, name, value)
+}
+
+// Delete stages removing the named entity
+func (o *Overlay) Delete(ctx context.Context, name string) error {
+	o.Lock()
+	defer o.Unlock()
+
+	return o.delete(ctx, path.Clean(name))
+}
+
+func (o *Overlay) delete(ctx context.Context, name string) error {
+	_, isLink := o.links[name]
+	inBase := !o.deleted[name] && o.base.Exists(ctx, name)
+	if !isLink && !o.written[name] && !inBase {
+		return notFound(name)
+	}
+
+	delete(o.links, name)
+	if o.written[name] {
+		delete(o.written, name)
+		if err := o.scratch.Delete(ctx, name); err != nil {
+			return err
+		}
+	}
+	if inBase {
+		o.deleted[name] = true
+	}
+	o.pending[name] = true
+	return nil
+}
+
+// Exists checks if the named entity exists
+func (o *Overlay) Exists(ctx context.Context, name string) bool {
+	o.Lock()
+	defer o.Unlock()
+
+	return o.exists(ctx, path.Clean(name))
+}
+
+// hidden returns true if the base file was deleted and not replaced. Must
+// be called with the lock held.
+func (o *Overlay) hidden(name string) bool {
+	_, isLink := o.links[name]
+	return o.deleted[name] && !o.written[name] && !isLink
+}
+
+// List returns all entities of the base and the overlay that start with
+// the prefix
+func (o *Overlay) List(ctx context.Context, prefix string) ([]string, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	return o.list(ctx, prefix)
+}
+
+func (o *Overlay) list(ctx context.Context, prefix string) ([]string, error) {
+	files, err := o.base.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	scratch, err := o.scratch.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	prefix = strings.TrimPrefix(prefix, "/")
+	for name := range o.links {
+		if strings.HasPrefix(name, prefix) {
+			scratch = append(scratch, name)
+		}
+	}
+
+	seen := make(map[string]bool, len(files)+len(scratch))
+	list := make([]string, 0, len(files)+len(scratch))
+	for _, name := range append(files, scratch...) {
+		if seen[name] || o.hidden(name) {
+			continue
+		}
+		seen[name] = true
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// IsDir returns true if the named entity is a directory
+func (o *Overlay) IsDir(ctx context.Context, name string) bool {
+	o.Lock()
+	defer o.Unlock()
+
+	name = path.Clean(name)
+	if o.scratch.IsDir(ctx, name) {
+		return true
+	}
+	if !o.base.IsDir(ctx, name) {
+		return false
+	}
+	files, err := o.base.List(ctx, name+"/")
+	if err != nil || len(files) < 1 {
+		// e.g. hidden directories that are not listed
+		return true
+	}
+	for _, f := range files {
+		if !o.hidden(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prune stages removing a directory or file
+func (o *Overlay) Prune(ctx context.Context, prefix string) error {
+	o.Lock()
+	defer o.Unlock()
+
+	prefix = path.Clean(strings.TrimPrefix(prefix, "/"))
+	files, err := o.list(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f != prefix && !strings.HasPrefix(f, prefix+"/") {
+			continue
+		}
+		if err := o.delete(ctx, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Link stages an alias to reach the same content through a different name
+func (o *Overlay) Link(ctx context.Context, from, to string) error {
+	o.Lock()
+	defer o.Unlock()
+
+	from, to = path.Clean(from), path.Clean(to)
+	if !o.exists(ctx, from) {
+		return notFound(from)
+	}
+	if o.exists(ctx, to) {
+		return fmt.Errorf("%s: %w", to, os.ErrExist)
+	}
+	o.links[to] = from
+	o.pending[to] = true
+	return nil
+}
+
+// Name returns the name of this backend
+func (o *Overlay) Name() string {
+	return name
+}
+
+// Version returns the version of this backend
+func (o *Overlay) Version(context.Context) semver.Version {
+	return semver.Version{Major: 1}
+}
+
+// String implements fmt.Stringer
+func (o *Overlay) String() string {
+	return fmt.Sprintf("overlay(v1.0.0,base:%s)", o.base)
+}
+
+// Path returns the path of the base storage
+func (o *Overlay) Path() string {
+	return o.base.Path()
+}
+
+// Fsck checks the base storage
+func (o *Overlay) Fsck(ctx context.Context) error {
+	debug.Log("checking the base of %s", o)
+	return o.base.Fsck(ctx)
+}
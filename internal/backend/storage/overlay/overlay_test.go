@@ -0,0 +1,139 @@
+package overlay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/backend/storage/fs"
+	"github.com/itsonlycode/gosecret/internal/backend/storage/gitfs"
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlay(t *testing.T) {
+	ctx := context.Background()
+	td := t.TempDir()
+
+	base := fs.New(td)
+	require.NoError(t, base.Set(ctx, "a.txt", []byte("a")))
+	require.NoError(t, base.Set(ctx, "dir/b.txt", []byte("b")))
+	require.NoError(t, base.Set(ctx, "dir/c.txt", []byte("c")))
+
+	o := New(base)
+	require.NoError(t, o.Set(ctx, "a.txt", []byte("A")))
+	require.NoError(t, o.Set(ctx, "new/d.txt", []byte("d")))
+	require.NoError(t, o.Delete(ctx, "dir/b.txt"))
+	require.NoError(t, o.Link(ctx, "dir/c.txt", "e.txt"))
+	assert.True(t, errors.Is(o.Delete(ctx, "dir/b.txt"), os.ErrNotExist))
+
+	buf, err := o.Get(ctx, "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "A", string(buf))
+	buf, err = o.Get(ctx, "e.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "c", string(buf))
+	_, err = o.Get(ctx, "dir/b.txt")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.False(t, o.Exists(ctx, "dir/b.txt"))
+	assert.True(t, o.IsDir(ctx, "new"))
+
+	files, err := o.List(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "dir/c.txt", "e.txt", "new/d.txt"}, files)
+
+	// the base is untouched
+	buf, err = base.Get(ctx, "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(buf))
+	assert.True(t, base.Exists(ctx, "dir/b.txt"))
+
+	st, err := o.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "M  a.txt\nD  dir/b.txt\nA  e.txt\nA  new/d.txt\n", string(st))
+
+	require.NoError(t, o.Commit(ctx, "first"))
+	assert.True(t, errors.Is(o.Commit(ctx, "again"), store.ErrGitNothingToCommit))
+	require.NoError(t, o.Set(ctx, "a.txt", []byte("AA")))
+	require.NoError(t, o.Commit(ctx, "second"))
+	assert.Equal(t, []string{"first", "second"}, o.Staged())
+
+	revs, err := o.Revisions(ctx, "a.txt")
+	require.NoError(t, err)
+	require.Len(t, revs, 3)
+	assert.Equal(t, "second", revs[0].Subject)
+	assert.Equal(t, "latest", revs[2].Hash, "revision of the fs base")
+	buf, err = o.GetRevision(ctx, "a.txt", revs[1].Hash[:8])
+	require.NoError(t, err)
+	assert.Equal(t, "A", string(buf))
+
+	t.Run("discard", func(t *testing.T) {
+		o := New(base)
+		require.NoError(t, o.Set(ctx, "x.txt", []byte("x")))
+		require.NoError(t, o.Prune(ctx, "dir"))
+		assert.False(t, o.Exists(ctx, "dir/c.txt"))
+		require.NoError(t, o.Discard(ctx))
+		assert.False(t, o.Exists(ctx, "x.txt"))
+		assert.True(t, o.Exists(ctx, "dir/c.txt"))
+	})
+
+	require.NoError(t, o.Apply(ctx))
+	files, err = base.List(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "dir/c.txt", "e.txt", "new/d.txt"}, files)
+	buf, err = base.Get(ctx, "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "AA", string(buf))
+	assert.Empty(t, o.Staged())
+	assert.True(t, errors.Is(o.Apply(ctx), store.ErrGitNothingToCommit))
+}
+
+func TestApplyGit(t *testing.T) {
+	ctx := context.Background()
+	td := t.TempDir()
+
+	out.Stdout = &bytes.Buffer{}
+	defer func() {
+		out.Stdout = os.Stdout
+	}()
+
+	_, err := gitfs.Init(ctx, td, "Alice", "alice@example.org")
+	require.NoError(t, err)
+	s, err := loader{}.New(ctx, td)
+	require.NoError(t, err)
+	defer func() {
+		overlaysMu.Lock()
+		delete(overlays, td)
+		overlaysMu.Unlock()
+	}()
+	assert.NoError(t, loader{}.Handles(td))
+	assert.Error(t, loader{}.Handles(filepath.Join(td, "other")))
+
+	o := s.(*Overlay)
+	assert.Equal(t, "git", o.Base().Name())
+	require.NoError(t, o.Set(ctx, "a.txt", []byte("a")))
+	require.NoError(t, o.Commit(ctx, "Add a"))
+	require.NoError(t, o.Set(ctx, "b.txt", []byte("b")))
+	require.NoError(t, o.Commit(ctx, "Add b"))
+	revs, err := o.Base().Revisions(ctx, "a.txt")
+	require.NoError(t, err)
+	assert.Empty(t, revs, "nothing is committed to the base before Apply")
+
+	require.NoError(t, o.Apply(ctx))
+	revs, err = o.Revisions(ctx, "b.txt")
+	require.NoError(t, err)
+	require.Len(t, revs, 1)
+	assert.Equal(t, "Apply 2 staged changes", revs[0].Subject)
+	assert.Equal(t, "- Add a\n- Add b", revs[0].Body)
+
+	be, err := backend.NewStorage(ctx, backend.Overlay, td)
+	require.NoError(t, err)
+	assert.Equal(t, o, be)
+}
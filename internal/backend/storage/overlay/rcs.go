@@ -0,0 +1,352 @@
+package overlay
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+)
+
+// stagedCommit is a commit that only exists in the overlay. It keeps a
+// snapshot of the overlay layer so older revisions can be retrieved.
+type stagedCommit struct {
+	hash    string
+	date    time.Time
+	subject string
+	body    string
+	files   []string
+	written map[string][]byte
+	deleted map[string]bool
+	links   map[string]string
+}
+
+// get returns the content of name at this commit. Names that were not
+// changed in the overlay are read from the base.
+func (c stagedCommit) get(ctx context.Context, base backend.Storage, name string) ([]byte, error) {
+	for i := 0; i < 10; i++ {
+		target, found := c.links[name]
+		if !found {
+			break
+		}
+		name = target
+	}
+	if buf, found := c.written[name]; found {
+		return append([]byte(nil), buf...), nil
+	}
+	if c.deleted[name] {
+		return nil, notFound(name)
+	}
+	return base.Get(ctx, name)
+}
+
+// Add does nothing. All changes are staged on Commit.
+func (o *Overlay) Add(ctx context.Context, args ...string) error {
+	return nil
+}
+
+// Commit stages the pending changes with the given message. Nothing is
+// written to the base until Apply is called.
+func (o *Overlay) Commit(ctx context.Context, msg string) error {
+	o.Lock()
+	defer o.Unlock()
+
+	if len(o.pending) < 1 {
+		return store.ErrGitNothingToCommit
+	}
+
+	c := stagedCommit{
+		date:    ctxutil.GetCommitTimestamp(ctx),
+		files:   make([]string, 0, len(o.pending)),
+		written: make(map[string][]byte, len(o.written)),
+		deleted: make(map[string]bool, len(o.deleted)),
+		links:   make(map[string]string, len(o.links)),
+	}
+	c.subject, c.body = msg, ""
+	if i := strings.Index(msg, "\n"); i >= 0 {
+		c.subject, c.body = msg[:i], strings.TrimSpace(msg[i+1:])
+	}
+	for name := range o.pending {
+		c.files = append(c.files, name)
+	}
+	sort.Strings(c.files)
+	for name := range o.written {
+		buf, err := o.scratch.Get(ctx, name)
+		if err != nil {
+			return err
+		}
+		c.written[name] = buf
+	}
+	for name := range o.deleted {
+		c.deleted[name] = true
+	}
+	for name, target := range o.links {
+		c.links[name] = target
+	}
+
+	h := sha1.New()
+	if len(o.staged) > 0 {
+		fmt.Fprint(h, o.staged[len(o.staged)-1].hash)
+	}
+	fmt.Fprintf(h, "\x00%d\x00%s\x00%s\x00%s", c.date.UnixNano(), c.subject, c.body, strings.Join(c.files, "\x00"))
+	c.hash = fmt.Sprintf("%x", h.Sum(nil))
+
+	debug.Log("staged commit %s over %s: %s", c.hash, o.base.Path(), c.subject)
+	o.staged = append(o.staged, c)
+	o.pending = make(map[string]bool)
+	return nil
+}
+
+// Staged returns the subjects of all staged commits, oldest first
+func (o *Overlay) Staged() []string {
+	o.Lock()
+	defer o.Unlock()
+
+	subjects := make([]string, 0, len(o.staged))
+	for _, c := range o.staged {
+		subjects = append(subjects, c.subject)
+	}
+	return subjects
+}
+
+// Apply writes all changes to the base and records them in a single commit.
+// Afterwards the overlay is empty.
+func (o *Overlay) Apply(ctx context.Context) error {
+	o.Lock()
+	defer o.Unlock()
+
+	if len(o.written)+len(o.deleted)+len(o.links) < 1 {
+		return store.ErrGitNothingToCommit
+	}
+
+	files := make([]string, 0, len(o.written)+len(o.deleted)+len(o.links))
+	for _, name := range sortedKeys(o.written) {
+		buf, err := o.scratch.Get(ctx, name)
+		if err != nil {
+			return err
+		}
+		if err := o.base.Set(ctx, name, buf); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		files = append(files, name)
+	}
+	for _, name := range sortedKeys(o.deleted) {
+		if err := o.base.Delete(ctx, name); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", name, err)
+		}
+		files = append(files, name)
+	}
+	for name, target := range o.links {
+		if err := o.base.Link(ctx, target, name); err != nil {
+			return fmt.Errorf("failed to link %s to %s: %w", name, target, err)
+		}
+		files = append(files, name)
+	}
+
+	if err := o.base.Add(ctx, files...); err != nil && !errors.Is(err, store.ErrGitNotInit) {
+		return fmt.Errorf("failed to add files: %w", err)
+	}
+	msg := o.message()
+	if err := o.base.Commit(ctx, msg); err != nil {
+		if !errors.Is(err, store.ErrGitNotInit) && !errors.Is(err, store.ErrGitNothingToCommit) {
+			return fmt.Errorf("failed to commit: %w", err)
+		}
+	}
+	debug.Log("applied %d staged commits to %s", len(o.staged), o.base.Path())
+	o.reset()
+
+	if err := o.base.Push(ctx, "", ""); err != nil {
+		if errors.Is(err, store.ErrGitNotInit) || errors.Is(err, store.ErrGitNoRemote) {
+			return nil
+		}
+		return fmt.Errorf("failed to push: %w", err)
+	}
+	return nil
+}
+
+// message summarizes the staged commits. Must be called with the lock held.
+func (o *Overlay) message() string {
+	switch len(o.staged) {
+	case 0:
+		return "Apply staged changes"
+	case 1:
+		c := o.staged[0]
+		if c.body == "" {
+			return c.subject
+		}
+		return c.subject + "\n\n" + c.body
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Apply %d staged changes\n", len(o.staged))
+	for _, c := range o.staged {
+		fmt.Fprintf(&sb, "\n- %s", c.subject)
+	}
+	return sb.String()
+}
+
+// Discard drops all staged and pending changes
+func (o *Overlay) Discard(ctx context.Context) error {
+	o.Lock()
+	defer o.Unlock()
+
+	debug.Log("discarding %d staged commits over %s", len(o.staged), o.base.Path())
+	o.reset()
+	return nil
+}
+
+// Push pushes the base. Staged changes are not pushed, use Apply to land
+// them first.
+func (o *Overlay) Push(ctx context.Context, remote, branch string) error {
+	return o.base.Push(ctx, remote, branch)
+}
+
+// Pull pulls the base. The staged changes stay on top of it.
+func (o *Overlay) Pull(ctx context.Context, remote, branch string) error {
+	return o.base.Pull(ctx, remote, branch)
+}
+
+// InitConfig configures the base
+func (o *Overlay) InitConfig(ctx context.Context, name, email string) error {
+	return o.base.InitConfig(ctx, name, email)
+}
+
+// AddRemote adds a remote to the base
+func (o *Overlay) AddRemote(ctx context.Context, remote, location string) error {
+	return o.base.AddRemote(ctx, remote, location)
+}
+
+// RemoveRemote removes a remote from the base
+func (o *Overlay) RemoveRemote(ctx context.Context, remote string) error {
+	return o.base.RemoveRemote(ctx, remote)
+}
+
+// Revisions returns the staged commits that changed the named file,
+// followed by the revisions of the base, latest first
+func (o *Overlay) Revisions(ctx context.Context, name string) ([]backend.Revision, error) {
+	name = path.Clean(name)
+
+	o.Lock()
+	var revs []backend.Revision
+	for i := len(o.staged) - 1; i >= 0; i-- {
+		c := o.staged[i]
+		for _, f := range c.files {
+			if f != name {
+				continue
+			}
+			revs = append(revs, backend.Revision{
+				Hash:    c.hash,
+				Date:    c.date,
+				Subject: c.subject,
+				Body:    c.body,
+			})
+			break
+		}
+	}
+	o.Unlock()
+
+	baseRevs, err := o.base.Revisions(ctx, name)
+	if err != nil {
+		if len(revs) > 0 {
+			debug.Log("failed to get revisions of %s from the base: %s", name, err)
+			return revs, nil
+		}
+		return nil, err
+	}
+	return append(revs, baseRevs...), nil
+}
+
+// GetRevision returns the content of the named file at the given revision.
+// Staged commits are referred to by their hash (or an unique prefix of at
+// least four characters). All other revisions are looked up in the base.
+func (o *Overlay) GetRevision(ctx context.Context, name, revision string) ([]byte, error) {
+	name = path.Clean(strings.TrimSpace(name))
+	revision = strings.TrimSpace(revision)
+
+	o.Lock()
+	var found []stagedCommit
+	if len(revision) >= 4 {
+		for _, c := range o.staged {
+			if strings.HasPrefix(c.hash, revision) {
+				found = append(found, c)
+			}
+		}
+	}
+	o.Unlock()
+
+	switch len(found) {
+	case 0:
+		return o.base.GetRevision(ctx, name, revision)
+	case 1:
+		return found[0].get(ctx, o.base, name)
+	default:
+		return nil, fmt.Errorf("ambiguous revision %q", revision)
+	}
+}
+
+// Status lists everything that Apply would change in the base
+func (o *Overlay) Status(ctx context.Context) ([]byte, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	buf := &bytes.Buffer{}
+	for _, name := range o.changed() {
+		switch {
+		case o.hidden(name):
+			fmt.Fprintf(buf, "D  %s\n", name)
+		case o.base.Exists(ctx, name):
+			fmt.Fprintf(buf, "M  %s\n", name)
+		default:
+			fmt.Fprintf(buf, "A  %s\n", name)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// changed returns all names changed in the overlay. Must be called with the
+// lock held.
+func (o *Overlay) changed() []string {
+	names := make([]string, 0, len(o.written)+len(o.deleted)+len(o.links))
+	for name := range o.written {
+		names = append(names, name)
+	}
+	for name := range o.deleted {
+		names = append(names, name)
+	}
+	for name := range o.links {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// written and linked names may also be deleted in the base
+	uniq := names[:0]
+	for i, name := range names {
+		if i > 0 && names[i-1] == name {
+			continue
+		}
+		uniq = append(uniq, name)
+	}
+	names = uniq
+	return names
+}
+
+// Compact compacts the base
+func (o *Overlay) Compact(ctx context.Context) error {
+	return o.base.Compact(ctx)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,3 @@
+package storage
+
+import _ "github.com/itsonlycode/gosecret/internal/backend/storage/s3" // register s3 backend
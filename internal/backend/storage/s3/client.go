@@ -0,0 +1,348 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itsonlycode/gosecret/pkg/debug"
+)
+
+var (
+	// ErrConflict is returned if an object was changed by someone else
+	// since it was read
+	ErrConflict = errors.New("changed concurrently")
+)
+
+// client is a minimal client for the S3 REST API. It only uses path style
+// requests, which are supported by all S3-compatible services.
+type client struct {
+	hc       *http.Client
+	endpoint *url.URL
+	region   string
+	bucket   string
+	creds    credentials
+	// now is used to sign requests
+	now func() time.Time
+}
+
+// apiError is the error document returned by S3
+type apiError struct {
+	Status  int    `xml:"-"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e *apiError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("s3: %s (%d)", e.Code, e.Status)
+	}
+	return fmt.Sprintf("s3: %s: %s (%d)", e.Code, e.Message, e.Status)
+}
+
+// Is maps S3 errors to the errors of this package and os.ErrNotExist
+func (e *apiError) Is(target error) bool {
+	switch target {
+	case os.ErrNotExist:
+		return e.Status == http.StatusNotFound
+	case ErrConflict:
+		return e.Status == http.StatusPreconditionFailed || e.Code == "ConditionalRequestConflict"
+	}
+	return false
+}
+
+// object is an entry of a listing or the result of a request
+type object struct {
+	Key          string    `xml:"Key"`
+	VersionID    string    `xml:"VersionId"`
+	IsLatest     bool      `xml:"IsLatest"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+	DeleteMarker bool      `xml:"-"`
+	// Header holds the response headers of single object requests
+	Header http.Header `xml:"-"`
+}
+
+// meta returns the user defined metadata of the object
+func (o object) meta(key string) string {
+	v := o.Header.Get("X-Amz-Meta-" + key)
+	if uv, err := url.QueryUnescape(v); err == nil {
+		return uv
+	}
+	return v
+}
+
+func (c *client) url(key string, query url.Values) *url.URL {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, true)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+// do sends a signed request. Error responses are returned as *apiError.
+func (c *client) do(ctx context.Context, method, key string, query url.Values, hdr http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url(key, query).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range hdr {
+		req.Header[k] = vs
+	}
+	req.ContentLength = int64(len(body))
+	if body == nil {
+		req.Body = http.NoBody
+	}
+	req.Header.Set("X-Amz-Content-Sha256", hashHex(body))
+	if c.creds.accessKey != "" {
+		sign(req, c.creds, c.region, c.now())
+	}
+
+	debug.Log("%s %s", method, req.URL)
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	e := &apiError{Status: resp.StatusCode}
+	buf, _ := io.ReadAll(resp.Body)
+	if len(buf) > 0 {
+		_ = xml.Unmarshal(buf, e)
+	}
+	if e.Code == "" {
+		e.Code = http.StatusText(resp.StatusCode)
+	}
+	return nil, e
+}
+
+// doXML sends a request and decodes the XML response into v
+func (c *client) doXML(ctx context.Context, method, key string, query url.Values, body []byte, v interface{}) error {
+	resp, err := c.do(ctx, method, key, query, nil, body)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if v == nil {
+		return nil
+	}
+	return xml.NewDecoder(resp.Body).Decode(v)
+}
+
+func versionQuery(versionID string) url.Values {
+	if versionID == "" {
+		return nil
+	}
+	return url.Values{"versionId": []string{versionID}}
+}
+
+func objectFromResponse(key string, resp *http.Response) object {
+	o := object{
+		Key:       key,
+		VersionID: resp.Header.Get("X-Amz-Version-Id"),
+		ETag:      resp.Header.Get("ETag"),
+		Header:    resp.Header,
+	}
+	o.LastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return o
+}
+
+// getObject returns the content of an object. The latest version is
+// returned if versionID is empty.
+func (c *client) getObject(ctx context.Context, key, versionID string) ([]byte, object, error) {
+	resp, err := c.do(ctx, http.MethodGet, key, versionQuery(versionID), nil, nil)
+	if err != nil {
+		return nil, object{}, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, object{}, err
+	}
+	return buf, objectFromResponse(key, resp), nil
+}
+
+// headObject returns the metadata of an object
+func (c *client) headObject(ctx context.Context, key, versionID string) (object, error) {
+	resp, err := c.do(ctx, http.MethodHead, key, versionQuery(versionID), nil, nil)
+	if err != nil {
+		return object{}, err
+	}
+	_ = resp.Body.Close()
+	return objectFromResponse(key, resp), nil
+}
+
+// putObject writes an object. The header can hold metadata and conditions,
+// e.g. If-Match.
+func (c *client) putObject(ctx context.Context, key string, body []byte, hdr http.Header) (object, error) {
+	resp, err := c.do(ctx, http.MethodPut, key, nil, hdr, body)
+	if err != nil {
+		return object{}, err
+	}
+	_ = resp.Body.Close()
+	return objectFromResponse(key, resp), nil
+}
+
+// deleteObject removes an object. In a versioned bucket this only adds a
+// delete marker.
+func (c *client) deleteObject(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+type listResult struct {
+	Contents              []object `xml:"Contents"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken"`
+}
+
+// listObjects returns up to max objects with the prefix. All objects are
+// returned if max is zero.
+func (c *client) listObjects(ctx context.Context, prefix string, max int) ([]object, error) {
+	var objs []object
+	token := ""
+	for {
+		q := url.Values{
+			"list-type": []string{"2"},
+			"prefix":    []string{prefix},
+		}
+		if max > 0 {
+			q.Set("max-keys", strconv.Itoa(max))
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		var res listResult
+		if err := c.doXML(ctx, http.MethodGet, "", q, nil, &res); err != nil {
+			return nil, err
+		}
+		objs = append(objs, res.Contents...)
+		if !res.IsTruncated || res.NextContinuationToken == "" || (max > 0 && len(objs) >= max) {
+			return objs, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+// versionsResult keeps versions and delete markers in the order of the
+// response, i.e. the latest version of each key first
+type versionsResult struct {
+	Versions            []object
+	IsTruncated         bool
+	NextKeyMarker       string
+	NextVersionIDMarker string
+}
+
+// UnmarshalXML implements xml.Unmarshaler
+func (r *versionsResult) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "Version", "DeleteMarker":
+				var o object
+				if err := d.DecodeElement(&o, &t); err != nil {
+					return err
+				}
+				o.DeleteMarker = t.Name.Local == "DeleteMarker"
+				r.Versions = append(r.Versions, o)
+			case "IsTruncated":
+				if err := d.DecodeElement(&r.IsTruncated, &t); err != nil {
+					return err
+				}
+			case "NextKeyMarker":
+				if err := d.DecodeElement(&r.NextKeyMarker, &t); err != nil {
+					return err
+				}
+			case "NextVersionIdMarker":
+				if err := d.DecodeElement(&r.NextVersionIDMarker, &t); err != nil {
+					return err
+				}
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// listVersions returns all versions and delete markers of the objects with
+// the prefix
+func (c *client) listVersions(ctx context.Context, prefix string) ([]object, error) {
+	var objs []object
+	keyMarker, versionMarker := "", ""
+	for {
+		q := url.Values{
+			"versions": []string{""},
+			"prefix":   []string{prefix},
+		}
+		if keyMarker != "" {
+			q.Set("key-marker", keyMarker)
+			q.Set("version-id-marker", versionMarker)
+		}
+		var res versionsResult
+		if err := c.doXML(ctx, http.MethodGet, "", q, nil, &res); err != nil {
+			return nil, err
+		}
+		objs = append(objs, res.Versions...)
+		if !res.IsTruncated || res.NextKeyMarker == "" {
+			return objs, nil
+		}
+		keyMarker, versionMarker = res.NextKeyMarker, res.NextVersionIDMarker
+	}
+}
+
+type versioningConfiguration struct {
+	XMLName xml.Name `xml:"VersioningConfiguration"`
+	Status  string   `xml:"Status,omitempty"`
+}
+
+// versioning returns the versioning status of the bucket, i.e. Enabled,
+// Suspended or an empty string if it was never enabled
+func (c *client) versioning(ctx context.Context) (string, error) {
+	var vc versioningConfiguration
+	if err := c.doXML(ctx, http.MethodGet, "", url.Values{"versioning": []string{""}}, nil, &vc); err != nil {
+		return "", err
+	}
+	return vc.Status, nil
+}
+
+// enableVersioning turns on versioning for the bucket
+func (c *client) enableVersioning(ctx context.Context) error {
+	buf, err := xml.Marshal(versioningConfiguration{Status: "Enabled"})
+	if err != nil {
+		return err
+	}
+	return c.doXML(ctx, http.MethodPut, "", url.Values{"versioning": []string{""}}, buf, nil)
+}
@@ -0,0 +1,149 @@
+package s3
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ConfigFile is the name of the file in the store directory that
+	// points to the bucket
+	ConfigFile = ".gosecret-s3.yml"
+	// EnvURL is used by init if the store directory has no config, yet
+	EnvURL = "GOPASS_S3_URL"
+
+	defaultRegion = "us-east-1"
+)
+
+// location is the place of a store in a bucket, it's given as
+// s3://bucket/prefix?region=...&endpoint=...
+type location struct {
+	bucket   string
+	prefix   string
+	region   string
+	endpoint string
+}
+
+func parseLocation(s string) (location, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return location{}, fmt.Errorf("invalid location %q: %w", s, err)
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return location{}, fmt.Errorf("invalid location %q: must be s3://bucket[/prefix]", s)
+	}
+	l := location{
+		bucket:   u.Host,
+		prefix:   strings.Trim(u.Path, "/"),
+		region:   u.Query().Get("region"),
+		endpoint: u.Query().Get("endpoint"),
+	}
+	if l.region == "" {
+		l.region = firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	}
+	if l.region == "" {
+		l.region = defaultRegion
+	}
+	if l.endpoint == "" {
+		l.endpoint = firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL")
+	}
+	if l.endpoint == "" {
+		l.endpoint = "https://s3." + l.region + ".amazonaws.com"
+	}
+	if _, err := url.Parse(l.endpoint); err != nil {
+		return location{}, fmt.Errorf("invalid endpoint %q: %w", l.endpoint, err)
+	}
+	return l, nil
+}
+
+func firstEnv(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// key returns the object key of a file in the store
+func (l location) key(name string) string {
+	name = strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+name)), "/")
+	if l.prefix == "" {
+		return name
+	}
+	if name == "" {
+		return l.prefix + "/"
+	}
+	return l.prefix + "/" + name
+}
+
+// name returns the name of a file in the store for an object key
+func (l location) name(key string) string {
+	if l.prefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(key, l.prefix+"/")
+}
+
+func (l location) String() string {
+	return fmt.Sprintf("s3://%s/%s", l.bucket, l.prefix)
+}
+
+// newClient creates a client for the bucket. The credentials are taken from
+// the usual AWS environment variables. Requests are not signed if there are
+// none.
+func (l location) newClient() (*client, error) {
+	ep, err := url.Parse(l.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &client{
+		hc:       http.DefaultClient,
+		endpoint: ep,
+		region:   l.region,
+		bucket:   l.bucket,
+		creds: credentials{
+			accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		now: time.Now,
+	}, nil
+}
+
+// config is kept in ConfigFile. It never contains any credentials.
+type config struct {
+	URL   string `yaml:"url"`
+	Name  string `yaml:"name,omitempty"`
+	Email string `yaml:"email,omitempty"`
+}
+
+func loadConfig(dir string) (*config, error) {
+	buf, err := os.ReadFile(filepath.Join(dir, ConfigFile))
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config{}
+	if err := yaml.Unmarshal(buf, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ConfigFile, err)
+	}
+	return cfg, nil
+}
+
+func (c *config) save(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	buf, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ConfigFile), buf, 0600)
+}
@@ -0,0 +1,346 @@
+package s3
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var fakeCreds = credentials{
+	accessKey: "AKIDFAKE",
+	secretKey: "fake-secret",
+}
+
+type fakeVersion struct {
+	id           string
+	data         []byte
+	etag         string
+	meta         http.Header
+	deleteMarker bool
+	modified     time.Time
+}
+
+type fakeBucket struct {
+	versioning string
+	// objects holds all versions of every key, latest last
+	objects map[string][]*fakeVersion
+}
+
+// latest returns the current version of key or nil if it does not exist
+func (b *fakeBucket) latest(key string) *fakeVersion {
+	vs := b.objects[key]
+	if len(vs) < 1 || vs[len(vs)-1].deleteMarker {
+		return nil
+	}
+	return vs[len(vs)-1]
+}
+
+func (b *fakeBucket) add(key string, v *fakeVersion) {
+	if b.versioning != "Enabled" {
+		v.id = "null"
+		b.objects[key] = []*fakeVersion{v}
+		return
+	}
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	v.id = hex.EncodeToString(buf)
+	b.objects[key] = append(b.objects[key], v)
+}
+
+// fakeS3 is a small, in-process S3 server. It supports just enough of the
+// API for the backend: versioning, conditional writes, listings and
+// signature checks.
+type fakeS3 struct {
+	sync.Mutex
+
+	buckets  map[string]*fakeBucket
+	pageSize int
+}
+
+// newFakeS3 starts a server with an empty bucket without versioning
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *httptest.Server) {
+	t.Helper()
+
+	f := &fakeS3{
+		buckets: map[string]*fakeBucket{
+			bucket: {objects: map[string][]*fakeVersion{}},
+		},
+		pageSize: 1000,
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+// verify checks the signature, if the request has one
+func (f *fakeS3) verify(r *http.Request, body []byte) bool {
+	if r.Header.Get("X-Amz-Content-Sha256") != hashHex(body) {
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return true
+	}
+	p := strings.SplitN(auth, "SignedHeaders=", 2)
+	if len(p) != 2 {
+		return false
+	}
+	signed := strings.Split(strings.SplitN(p[1], ",", 2)[0], ";")
+	date, err := time.Parse(amzDateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	req, err := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	if err != nil {
+		return false
+	}
+	for _, h := range signed {
+		if h != "host" {
+			req.Header.Set(h, r.Header.Get(h))
+		}
+	}
+	sign(req, fakeCreds, defaultRegion, date)
+	return req.Header.Get("Authorization") == auth
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if !f.verify(r, body) {
+		writeError(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	p := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	b, found := f.buckets[p[0]]
+	if !found {
+		writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	if len(p) < 2 || p[1] == "" {
+		f.serveBucket(w, r, b, body)
+		return
+	}
+	f.serveObject(w, r, b, p[1], body)
+}
+
+func has(q url.Values, key string) bool {
+	_, found := q[key]
+	return found
+}
+
+func (f *fakeS3) serveBucket(w http.ResponseWriter, r *http.Request, b *fakeBucket, body []byte) {
+	q := r.URL.Query()
+	switch {
+	case has(q, "versioning") && r.Method == http.MethodGet:
+		writeXML(w, versioningConfiguration{Status: b.versioning})
+	case has(q, "versioning") && r.Method == http.MethodPut:
+		var vc versioningConfiguration
+		if err := xml.Unmarshal(body, &vc); err != nil {
+			writeError(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		b.versioning = vc.Status
+	case has(q, "versions"):
+		f.listVersions(w, b, q.Get("prefix"), q.Get("key-marker"), q.Get("version-id-marker"))
+	case q.Get("list-type") == "2":
+		f.listObjects(w, b, q.Get("prefix"), q.Get("continuation-token"), q.Get("max-keys"))
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeS3) keys(b *fakeBucket, prefix string) []string {
+	keys := make([]string, 0, len(b.objects))
+	for k := range b.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *fakeS3) listObjects(w http.ResponseWriter, b *fakeBucket, prefix, token, maxKeys string) {
+	max := f.pageSize
+	if n, err := strconv.Atoi(maxKeys); err == nil && n < max {
+		max = n
+	}
+	res := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []object `xml:"Contents"`
+		IsTruncated           bool     `xml:"IsTruncated"`
+		NextContinuationToken string   `xml:"NextContinuationToken,omitempty"`
+	}{}
+	for _, k := range f.keys(b, prefix) {
+		v := b.latest(k)
+		if v == nil || k <= token {
+			continue
+		}
+		if len(res.Contents) >= max {
+			res.IsTruncated = true
+			break
+		}
+		res.Contents = append(res.Contents, object{Key: k, ETag: v.etag, LastModified: v.modified})
+		res.NextContinuationToken = k
+	}
+	if !res.IsTruncated {
+		res.NextContinuationToken = ""
+	}
+	writeXML(w, res)
+}
+
+type fakeVersionEntry struct {
+	XMLName      xml.Name
+	Key          string    `xml:"Key"`
+	VersionID    string    `xml:"VersionId"`
+	IsLatest     bool      `xml:"IsLatest"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag,omitempty"`
+}
+
+func (f *fakeS3) listVersions(w http.ResponseWriter, b *fakeBucket, prefix, keyMarker, versionMarker string) {
+	res := struct {
+		XMLName             xml.Name           `xml:"ListVersionsResult"`
+		Entries             []fakeVersionEntry `xml:",any"`
+		IsTruncated         bool               `xml:"IsTruncated"`
+		NextKeyMarker       string             `xml:"NextKeyMarker,omitempty"`
+		NextVersionIDMarker string             `xml:"NextVersionIdMarker,omitempty"`
+	}{}
+	skip := keyMarker != ""
+	for _, k := range f.keys(b, prefix) {
+		vs := b.objects[k]
+		for i := len(vs) - 1; i >= 0; i-- {
+			v := vs[i]
+			if skip {
+				if k == keyMarker && v.id == versionMarker {
+					skip = false
+				}
+				continue
+			}
+			if len(res.Entries) >= f.pageSize {
+				res.IsTruncated = true
+				break
+			}
+			e := fakeVersionEntry{
+				XMLName:      xml.Name{Local: "Version"},
+				Key:          k,
+				VersionID:    v.id,
+				IsLatest:     i == len(vs)-1,
+				LastModified: v.modified,
+				ETag:         v.etag,
+			}
+			if v.deleteMarker {
+				e.XMLName.Local = "DeleteMarker"
+			}
+			res.Entries = append(res.Entries, e)
+			res.NextKeyMarker, res.NextVersionIDMarker = k, v.id
+		}
+		if res.IsTruncated {
+			break
+		}
+	}
+	if !res.IsTruncated {
+		res.NextKeyMarker, res.NextVersionIDMarker = "", ""
+	}
+	writeXML(w, res)
+}
+
+func (f *fakeS3) serveObject(w http.ResponseWriter, r *http.Request, b *fakeBucket, key string, body []byte) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		v := b.latest(key)
+		if id := r.URL.Query().Get("versionId"); id != "" {
+			v = nil
+			for _, cand := range b.objects[key] {
+				if cand.id == id {
+					v = cand
+				}
+			}
+			if v != nil && v.deleteMarker {
+				writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+				return
+			}
+		}
+		if v == nil {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		for k, vs := range v.meta {
+			w.Header()[k] = vs
+		}
+		w.Header().Set("ETag", v.etag)
+		w.Header().Set("X-Amz-Version-Id", v.id)
+		w.Header().Set("Last-Modified", v.modified.Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(v.data)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(v.data)
+		}
+	case http.MethodPut:
+		cur := b.latest(key)
+		if m := r.Header.Get("If-Match"); m != "" {
+			if cur == nil {
+				writeError(w, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			if cur.etag != m {
+				writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+				return
+			}
+		}
+		if r.Header.Get("If-None-Match") == "*" && cur != nil {
+			writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		sum := md5.Sum(body)
+		v := &fakeVersion{
+			data:     body,
+			etag:     `"` + hex.EncodeToString(sum[:]) + `"`,
+			meta:     http.Header{},
+			modified: time.Now().UTC(),
+		}
+		for k, vs := range r.Header {
+			if strings.HasPrefix(k, "X-Amz-Meta-") {
+				v.meta[k] = vs
+			}
+		}
+		b.add(key, v)
+		w.Header().Set("ETag", v.etag)
+		w.Header().Set("X-Amz-Version-Id", v.id)
+	case http.MethodDelete:
+		if b.versioning == "Enabled" {
+			b.add(key, &fakeVersion{deleteMarker: true, modified: time.Now().UTC()})
+		} else {
+			delete(b.objects, key)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
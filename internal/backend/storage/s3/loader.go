@@ -0,0 +1,116 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+	"github.com/itsonlycode/gosecret/pkg/fsutil"
+	"github.com/itsonlycode/gosecret/pkg/termio"
+)
+
+const (
+	name = "s3"
+)
+
+func init() {
+	backend.RegisterStorage(backend.S3, name, &loader{})
+}
+
+type loader struct{}
+
+// New implements backend.StorageLoader
+func (l loader) New(ctx context.Context, path string) (backend.Storage, error) {
+	be, err := New(path)
+	if err != nil {
+		return nil, err
+	}
+	debug.Log("Using Storage Backend: %s", be.String())
+	return be, nil
+}
+
+// Init implements backend.StorageLoader. The location of a new store is
+// taken from GOPASS_S3_URL. Versioning is enabled for the bucket, if
+// necessary.
+func (l loader) Init(ctx context.Context, path string) (backend.Storage, error) {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		url := os.Getenv(EnvURL)
+		if url == "" {
+			return nil, fmt.Errorf("no bucket configured for %s, set %s to s3://bucket/prefix", path, EnvURL)
+		}
+		cfg = &config{
+			URL:   url,
+			Name:  termio.DetectName(ctx, nil),
+			Email: termio.DetectEmail(ctx, nil),
+		}
+	}
+	be, err := newS3(path, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := be.ensureVersioning(ctx); err != nil {
+		return nil, err
+	}
+	if err := cfg.save(path); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", ConfigFile, err)
+	}
+	debug.Log("Initialized Storage Backend: %s", be.String())
+	return be, nil
+}
+
+// Clone implements backend.StorageLoader. The repo is the location of the
+// store, i.e. s3://bucket/prefix.
+func (l loader) Clone(ctx context.Context, repo, path string) (backend.Storage, error) {
+	be, err := newS3(path, &config{URL: repo})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := be.c.listObjects(ctx, be.loc.key(""), 1); err != nil {
+		return nil, fmt.Errorf("failed to access %s: %w", be.loc, err)
+	}
+	if err := be.cfg.save(path); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", ConfigFile, err)
+	}
+	return be, nil
+}
+
+// Handles accepts directories with a ConfigFile
+func (l loader) Handles(path string) error {
+	if !fsutil.IsFile(filepath.Join(path, ConfigFile)) {
+		return fmt.Errorf("no %s", ConfigFile)
+	}
+	return nil
+}
+
+func (l loader) Priority() int {
+	return 0
+}
+
+func (l loader) String() string {
+	return name
+}
+
+// ensureVersioning enables versioning for the bucket, so the history of
+// the secrets is kept
+func (s *S3) ensureVersioning(ctx context.Context) error {
+	status, err := s.c.versioning(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to access bucket %s: %w", s.loc.bucket, err)
+	}
+	if status == "Enabled" {
+		return nil
+	}
+	debug.Log("enabling versioning for %s", s.loc.bucket)
+	if err := s.c.enableVersioning(ctx); err != nil {
+		return fmt.Errorf("failed to enable versioning for bucket %s: %w", s.loc.bucket, err)
+	}
+	return nil
+}
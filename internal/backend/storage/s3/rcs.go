@@ -0,0 +1,150 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+)
+
+// Add does nothing, every write is a revision already
+func (s *S3) Add(ctx context.Context, args ...string) error {
+	return nil
+}
+
+// Commit does nothing, every write is a revision already. The commit
+// message from the context is stored with each object.
+func (s *S3) Commit(ctx context.Context, msg string) error {
+	return nil
+}
+
+// Push does nothing, all changes are written to the bucket immediately
+func (s *S3) Push(ctx context.Context, remote, branch string) error {
+	return nil
+}
+
+// Pull does nothing, everything is read from the bucket
+func (s *S3) Pull(ctx context.Context, remote, branch string) error {
+	return nil
+}
+
+// InitConfig sets the author of new revisions
+func (s *S3) InitConfig(ctx context.Context, name, email string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.cfg.Name = name
+	s.cfg.Email = email
+	return s.cfg.save(s.path)
+}
+
+// AddRemote changes the location of the store, the name of the remote is
+// ignored. The content is not copied to the new location.
+func (s *S3) AddRemote(ctx context.Context, remote, location string) error {
+	loc, err := parseLocation(location)
+	if err != nil {
+		return err
+	}
+	c, err := loc.newClient()
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.cfg.URL = location
+	s.loc, s.c = loc, c
+	s.etags = make(map[string]string)
+	return s.cfg.save(s.path)
+}
+
+// RemoveRemote is not supported, a store always needs a bucket
+func (s *S3) RemoveRemote(ctx context.Context, remote string) error {
+	return backend.ErrNotSupported
+}
+
+// Revisions returns the versions of the named file, latest first.
+// Deletions are included.
+func (s *S3) Revisions(ctx context.Context, name string) ([]backend.Revision, error) {
+	name = cleanName(name)
+	key := s.loc.key(name)
+	versions, err := s.c.listVersions(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var revs []backend.Revision
+	for _, v := range versions {
+		if v.Key != key {
+			continue
+		}
+		r := backend.Revision{
+			Hash: v.VersionID,
+			Date: v.LastModified,
+		}
+		if v.DeleteMarker {
+			r.Subject = "Delete " + name
+			revs = append(revs, r)
+			continue
+		}
+		o, err := s.c.headObject(ctx, key, v.VersionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read version %s of %s: %w", v.VersionID, name, err)
+		}
+		r.AuthorName = o.meta(metaAuthor)
+		r.AuthorEmail = o.meta(metaEmail)
+		if d, err := time.Parse(time.RFC3339, o.meta(metaDate)); err == nil {
+			r.Date = d
+		}
+		r.Subject = "Save " + name
+		if msg := o.meta(metaMessage); msg != "" {
+			r.Subject, r.Body = msg, ""
+			if i := strings.Index(msg, "\n"); i >= 0 {
+				r.Subject, r.Body = msg[:i], strings.TrimSpace(msg[i+1:])
+			}
+		}
+		revs = append(revs, r)
+	}
+	if len(revs) < 1 {
+		return nil, fmt.Errorf("no revisions found for %s", name)
+	}
+	return revs, nil
+}
+
+// GetRevision returns the content of the named file at the given version.
+// HEAD is the latest version.
+func (s *S3) GetRevision(ctx context.Context, name, revision string) ([]byte, error) {
+	name = cleanName(name)
+	revision = strings.TrimSpace(revision)
+	if revision == "HEAD" {
+		return s.Get(ctx, name)
+	}
+	buf, _, err := s.c.getObject(ctx, s.loc.key(name), revision)
+	if err != nil {
+		var ae *apiError
+		if errors.Is(err, os.ErrNotExist) || (errors.As(err, &ae) && ae.Status == http.StatusMethodNotAllowed) {
+			return nil, fmt.Errorf("%s does not exist in %s", name, revision)
+		}
+		return nil, err
+	}
+	debug.Log("read %s@%s", name, revision)
+	return buf, nil
+}
+
+// Status returns nothing, there are never any pending changes
+func (s *S3) Status(ctx context.Context) ([]byte, error) {
+	return []byte{}, nil
+}
+
+// Compact does nothing. Old versions can be removed with the lifecycle
+// rules of the bucket.
+func (s *S3) Compact(ctx context.Context) error {
+	return nil
+}
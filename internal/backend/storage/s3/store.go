@@ -0,0 +1,369 @@
+// Package s3 implements a storage backend that keeps the encrypted secrets
+// in an S3-compatible bucket. The revisions of a secret are the versions of
+// its object, so the bucket should have versioning enabled. Writes are
+// conditional, so changes made by someone else since a secret was read are
+// never overwritten.
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+
+	"github.com/blang/semver/v4"
+)
+
+const (
+	// metadata of the objects
+	metaAuthor  = "Gosecret-Author"
+	metaEmail   = "Gosecret-Email"
+	metaMessage = "Gosecret-Message"
+	metaDate    = "Gosecret-Date"
+	// metaLink holds the target of links, which are empty objects
+	metaLink = "Gosecret-Link"
+
+	maxLinkDepth = 10
+)
+
+// S3 is a storage backed by an S3-compatible bucket
+type S3 struct {
+	sync.Mutex
+
+	path string
+	cfg  *config
+	loc  location
+	c    *client
+	// etags holds the last seen ETag of the objects by name. It's empty for
+	// objects that did not exist.
+	etags map[string]string
+}
+
+// New opens the store in the directory path, which must contain a
+// ConfigFile
+func New(path string) (*S3, error) {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("no s3 store at %s: %w", path, err)
+	}
+	return newS3(path, cfg)
+}
+
+func newS3(path string, cfg *config) (*S3, error) {
+	loc, err := parseLocation(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	c, err := loc.newClient()
+	if err != nil {
+		return nil, err
+	}
+	return &S3{
+		path:  path,
+		cfg:   cfg,
+		loc:   loc,
+		c:     c,
+		etags: make(map[string]string),
+	}, nil
+}
+
+func notFound(name string) error {
+	return fmt.Errorf("%s: %w", name, os.ErrNotExist)
+}
+
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.TrimSpace(name)), "/")
+}
+
+// seen records the ETag of an object, an empty ETag records that it did not
+// exist
+func (s *S3) seen(name, etag string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.etags[name] = etag
+}
+
+// observe records the ETag of an object that was not read before. Unlike
+// seen it never replaces the ETag of an object that was read, so checking
+// for its existence can't hide concurrent changes.
+func (s *S3) observe(name, etag string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, found := s.etags[name]; !found {
+		s.etags[name] = etag
+	}
+}
+
+// conditions returns the headers that make a write fail if the object was
+// changed since it was seen
+func (s *S3) conditions(name string) http.Header {
+	s.Lock()
+	defer s.Unlock()
+
+	hdr := http.Header{}
+	etag, found := s.etags[name]
+	switch {
+	case !found:
+	case etag == "":
+		hdr.Set("If-None-Match", "*")
+	default:
+		hdr.Set("If-Match", etag)
+	}
+	return hdr
+}
+
+// conflict explains conflicts. The outdated ETag is kept, so all writes
+// fail until the object is read again.
+func (s *S3) conflict(name string, err error) error {
+	if !errors.Is(err, ErrConflict) {
+		return err
+	}
+	return fmt.Errorf("%s was %w, read it again: %s", name, ErrConflict, err)
+}
+
+// Get retrieves the named content, links are followed
+func (s *S3) Get(ctx context.Context, name string) ([]byte, error) {
+	name = cleanName(name)
+	for i := 0; i < maxLinkDepth; i++ {
+		buf, o, err := s.c.getObject(ctx, s.loc.key(name), "")
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				s.seen(name, "")
+				return nil, notFound(name)
+			}
+			return nil, err
+		}
+		s.seen(name, o.ETag)
+		target := o.meta(metaLink)
+		if target == "" {
+			return buf, nil
+		}
+		name = target
+	}
+	return nil, fmt.Errorf("too many levels of links at %s", name)
+}
+
+// metadata returns the headers describing a change
+func (s *S3) metadata(ctx context.Context) http.Header {
+	hdr := http.Header{}
+	name, email := s.author(ctx)
+	for k, v := range map[string]string{
+		metaAuthor:  name,
+		metaEmail:   email,
+		metaMessage: strings.TrimSpace(ctxutil.GetCommitMessage(ctx)),
+		metaDate:    ctxutil.GetCommitTimestamp(ctx).UTC().Format(time.RFC3339),
+	} {
+		setMeta(hdr, k, v)
+	}
+	return hdr
+}
+
+func setMeta(hdr http.Header, key, value string) {
+	if value == "" {
+		return
+	}
+	// metadata must be ASCII
+	hdr.Set("X-Amz-Meta-"+key, url.QueryEscape(value))
+}
+
+func (s *S3) author(ctx context.Context) (string, string) {
+	s.Lock()
+	name, email := s.cfg.Name, s.cfg.Email
+	s.Unlock()
+
+	if name == "" {
+		name = ctxutil.GetUsername(ctx)
+	}
+	if email == "" {
+		email = ctxutil.GetEmail(ctx)
+	}
+	return name, email
+}
+
+func (s *S3) put(ctx context.Context, name string, value []byte, hdr http.Header) error {
+	for k, vs := range s.conditions(name) {
+		hdr[k] = vs
+	}
+	debug.Log("Writing %s to %s", name, s.loc)
+	o, err := s.c.putObject(ctx, s.loc.key(name), value, hdr)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && hdr.Get("If-Match") != "" {
+			// the object was deleted
+			err = fmt.Errorf("%s: %w", name, ErrConflict)
+		}
+		return s.conflict(name, err)
+	}
+	s.seen(name, o.ETag)
+	return nil
+}
+
+// Set writes the given content. It fails with ErrConflict if the object
+// was changed by someone else since it was read.
+func (s *S3) Set(ctx context.Context, name string, value []byte) error {
+	return s.put(ctx, cleanName(name), value, s.metadata(ctx))
+}
+
+// Delete removes the named entity. It fails with ErrConflict if the object
+// was changed by someone else since it was read.
+func (s *S3) Delete(ctx context.Context, name string) error {
+	name = cleanName(name)
+	o, err := s.c.headObject(ctx, s.loc.key(name), "")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			s.seen(name, "")
+			return notFound(name)
+		}
+		return err
+	}
+
+	// S3 has no conditional deletes, so this only narrows the window
+	s.Lock()
+	etag, found := s.etags[name]
+	s.Unlock()
+	if found && etag != o.ETag {
+		return s.conflict(name, fmt.Errorf("%s: %w", name, ErrConflict))
+	}
+
+	debug.Log("Deleting %s from %s", name, s.loc)
+	if err := s.c.deleteObject(ctx, s.loc.key(name)); err != nil {
+		return err
+	}
+	s.seen(name, "")
+	return nil
+}
+
+// Exists checks if the named entity exists
+func (s *S3) Exists(ctx context.Context, name string) bool {
+	name = cleanName(name)
+	o, err := s.c.headObject(ctx, s.loc.key(name), "")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			s.observe(name, "")
+		} else {
+			debug.Log("failed to check %s: %s", name, err)
+		}
+		return false
+	}
+	s.observe(name, o.ETag)
+	return true
+}
+
+// List returns a list of all entities that start with the prefix. Like on
+// disk, files in hidden directories are skipped.
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	prefix = strings.TrimPrefix(prefix, "/")
+	objs, err := s.c.listObjects(ctx, s.loc.key("")+prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(objs))
+	for _, o := range objs {
+		name := s.loc.name(o.Key)
+		if name == "" || strings.HasSuffix(name, "/") || inHiddenDir(name) {
+			continue
+		}
+		s.observe(name, o.ETag)
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func inHiddenDir(name string) bool {
+	for _, p := range strings.Split(path.Dir(name), "/") {
+		if strings.HasPrefix(p, ".") && p != "." {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDir returns true if the named entity is a directory
+func (s *S3) IsDir(ctx context.Context, name string) bool {
+	objs, err := s.c.listObjects(ctx, s.loc.key(name)+"/", 1)
+	if err != nil {
+		debug.Log("failed to list %s: %s", name, err)
+		return false
+	}
+	return len(objs) > 0
+}
+
+// Prune removes a named directory or file
+func (s *S3) Prune(ctx context.Context, prefix string) error {
+	prefix = cleanName(prefix)
+	debug.Log("Pruning %s from %s", prefix, s.loc)
+	objs, err := s.c.listObjects(ctx, s.loc.key(prefix), 0)
+	if err != nil {
+		return err
+	}
+	for _, o := range objs {
+		name := s.loc.name(o.Key)
+		if name != prefix && !strings.HasPrefix(name, prefix+"/") {
+			continue
+		}
+		if err := s.c.deleteObject(ctx, o.Key); err != nil {
+			return err
+		}
+		s.seen(name, "")
+	}
+	return nil
+}
+
+// Link creates an alias to reach the same content through a different
+// name. Links are empty objects that point to their target.
+func (s *S3) Link(ctx context.Context, from, to string) error {
+	from, to = cleanName(from), cleanName(to)
+	if !s.Exists(ctx, from) {
+		return notFound(from)
+	}
+	if s.Exists(ctx, to) {
+		return fmt.Errorf("%s: %w", to, os.ErrExist)
+	}
+	hdr := s.metadata(ctx)
+	setMeta(hdr, metaLink, from)
+	return s.put(ctx, to, nil, hdr)
+}
+
+// Name returns the name of this backend
+func (s *S3) Name() string {
+	return name
+}
+
+// Version returns the version of this backend
+func (s *S3) Version(context.Context) semver.Version {
+	return semver.Version{Major: 1}
+}
+
+// String implements fmt.Stringer
+func (s *S3) String() string {
+	return fmt.Sprintf("s3(v1.0.0,path:%s,location:%s)", s.path, s.loc)
+}
+
+// Path returns the directory of the config
+func (s *S3) Path() string {
+	return s.path
+}
+
+// Fsck checks that the bucket keeps the revisions of the secrets
+func (s *S3) Fsck(ctx context.Context) error {
+	status, err := s.c.versioning(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check versioning of bucket %s: %w", s.loc.bucket, err)
+	}
+	if status != "Enabled" {
+		return fmt.Errorf("versioning is not enabled for bucket %s, old revisions are lost", s.loc.bucket)
+	}
+	return nil
+}
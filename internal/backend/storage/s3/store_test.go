@@ -0,0 +1,236 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStore opens the store at s3://secrets/<prefix> of the server with
+// signed requests
+func newTestStore(t *testing.T, endpoint, prefix string) *S3 {
+	t.Helper()
+
+	s, err := newS3(t.TempDir(), &config{URL: "s3://secrets/" + prefix + "?endpoint=" + endpoint})
+	require.NoError(t, err)
+	s.c.creds = fakeCreds
+	s.c.region = defaultRegion
+	return s
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	_, srv := newFakeS3(t, "secrets")
+	s := newTestStore(t, srv.URL, "team")
+
+	assert.Equal(t, "s3", s.Name())
+	assert.False(t, s.Exists(ctx, "foo"))
+	_, err := s.Get(ctx, "foo")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	for _, name := range []string{"foo", "bar/baz", "bar/zab", ".hidden/file", ".gpg-id", "ü mlaut"} {
+		require.NoError(t, s.Set(ctx, name, []byte("content of "+name)))
+	}
+	buf, err := s.Get(ctx, "/bar/baz")
+	require.NoError(t, err)
+	assert.Equal(t, "content of bar/baz", string(buf))
+	assert.True(t, s.Exists(ctx, "ü mlaut"))
+
+	list, err := s.List(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{".gpg-id", "bar/baz", "bar/zab", "foo", "ü mlaut"}, list)
+	list, err = s.List(ctx, "bar/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bar/baz", "bar/zab"}, list)
+	assert.True(t, s.IsDir(ctx, "bar"))
+	assert.False(t, s.IsDir(ctx, "foo"))
+
+	t.Run("links", func(t *testing.T) {
+		require.NoError(t, s.Link(ctx, "bar/baz", "link/to/baz"))
+		buf, err := s.Get(ctx, "link/to/baz")
+		require.NoError(t, err)
+		assert.Equal(t, "content of bar/baz", string(buf))
+		assert.Error(t, s.Link(ctx, "foo", "link/to/baz"))
+		assert.Error(t, s.Link(ctx, "nope", "link/to/nope"))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, s.Delete(ctx, "foo"))
+		assert.False(t, s.Exists(ctx, "foo"))
+		assert.Error(t, s.Delete(ctx, "foo"))
+
+		require.NoError(t, s.Prune(ctx, "bar"))
+		list, err := s.List(ctx, "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{".gpg-id", "link/to/baz", "ü mlaut"}, list)
+	})
+
+	t.Run("other prefix", func(t *testing.T) {
+		other := newTestStore(t, srv.URL, "")
+		list, err := other.List(ctx, "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"team/.gpg-id", "team/link/to/baz", "team/ü mlaut"}, list)
+	})
+}
+
+func TestPagination(t *testing.T) {
+	ctx := context.Background()
+	f, srv := newFakeS3(t, "secrets")
+	f.pageSize = 2
+	s := newTestStore(t, srv.URL, "")
+	require.NoError(t, s.c.enableVersioning(ctx))
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.Set(ctx, "foo/"+strconv.Itoa(i), []byte("foo")))
+		require.NoError(t, s.Set(ctx, "foo/"+strconv.Itoa(i), []byte("bar")))
+	}
+	list, err := s.List(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, list, 5)
+	versions, err := s.c.listVersions(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, versions, 10)
+}
+
+func TestConflict(t *testing.T) {
+	ctx := context.Background()
+	_, srv := newFakeS3(t, "secrets")
+	alice := newTestStore(t, srv.URL, "")
+	bob := newTestStore(t, srv.URL, "")
+
+	require.NoError(t, alice.Set(ctx, "foo", []byte("alice")))
+	_, err := bob.Get(ctx, "foo")
+	require.NoError(t, err)
+	require.NoError(t, alice.Set(ctx, "foo", []byte("alice again")))
+
+	err = bob.Set(ctx, "foo", []byte("bob"))
+	assert.True(t, errors.Is(err, ErrConflict))
+	assert.Error(t, bob.Delete(ctx, "foo"))
+	buf, err := bob.Get(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "alice again", string(buf))
+	// bob saw the latest changes now
+	require.NoError(t, bob.Set(ctx, "foo", []byte("bob")))
+
+	t.Run("new secrets", func(t *testing.T) {
+		assert.False(t, alice.Exists(ctx, "bar"))
+		assert.False(t, bob.Exists(ctx, "bar"))
+		require.NoError(t, bob.Set(ctx, "bar", []byte("bob")))
+		assert.True(t, errors.Is(alice.Set(ctx, "bar", []byte("alice")), ErrConflict))
+	})
+
+	t.Run("exists does not refresh", func(t *testing.T) {
+		_, err := alice.Get(ctx, "foo")
+		require.NoError(t, err)
+		require.NoError(t, bob.Set(ctx, "foo", []byte("bob again")))
+		assert.True(t, alice.Exists(ctx, "foo"))
+		assert.True(t, errors.Is(alice.Set(ctx, "foo", []byte("alice")), ErrConflict))
+	})
+
+	t.Run("deleted secrets", func(t *testing.T) {
+		assert.True(t, alice.Exists(ctx, "foo"))
+		require.NoError(t, bob.Delete(ctx, "foo"))
+		assert.True(t, errors.Is(alice.Set(ctx, "foo", []byte("alice")), ErrConflict))
+	})
+}
+
+func TestRevisions(t *testing.T) {
+	ctx := context.Background()
+	ctx = ctxutil.WithUsername(ctx, "Alice")
+	ctx = ctxutil.WithEmail(ctx, "alice@example.org")
+	_, srv := newFakeS3(t, "secrets")
+	s := newTestStore(t, srv.URL, "team")
+	require.NoError(t, s.ensureVersioning(ctx))
+	require.NoError(t, s.Fsck(ctx))
+
+	ts := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, s.Set(ctxutil.WithCommitTimestamp(ctxutil.WithCommitMessage(ctx, "first\n\nwith a body"), ts), "foo", []byte("one")))
+	require.NoError(t, s.Set(ctx, "foo", []byte("two")))
+	require.NoError(t, s.Set(ctx, "foobar", []byte("other")))
+	require.NoError(t, s.Delete(ctx, "foo"))
+	require.NoError(t, s.InitConfig(ctx, "Bob", "bob@example.org"))
+	require.NoError(t, s.Set(ctx, "foo", []byte("three")))
+
+	revs, err := s.Revisions(ctx, "foo")
+	require.NoError(t, err)
+	require.Len(t, revs, 4)
+	assert.Equal(t, "Bob", revs[0].AuthorName)
+	assert.Equal(t, "Delete foo", revs[1].Subject)
+	assert.Equal(t, "Save foo", revs[2].Subject)
+	assert.Equal(t, "alice@example.org", revs[2].AuthorEmail)
+	assert.Equal(t, "first", revs[3].Subject)
+	assert.Equal(t, "with a body", revs[3].Body)
+	assert.Equal(t, ts, revs[3].Date)
+
+	buf, err := s.GetRevision(ctx, "foo", revs[3].Hash)
+	require.NoError(t, err)
+	assert.Equal(t, "one", string(buf))
+	buf, err = s.GetRevision(ctx, "foo", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, "three", string(buf))
+	_, err = s.GetRevision(ctx, "foo", revs[1].Hash)
+	assert.Error(t, err)
+
+	_, err = s.Revisions(ctx, "nope")
+	assert.Error(t, err)
+}
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+	_, srv := newFakeS3(t, "secrets")
+	td := t.TempDir()
+	be := backend.S3
+
+	_, err := backend.InitStorage(ctx, be, filepath.Join(td, "nourl"))
+	assert.Error(t, err)
+
+	url := "s3://secrets/team?endpoint=" + srv.URL
+	require.NoError(t, os.Setenv(EnvURL, url))
+	defer func() {
+		_ = os.Unsetenv(EnvURL)
+	}()
+
+	st, err := backend.InitStorage(ctx, be, filepath.Join(td, "alice"))
+	require.NoError(t, err)
+	assert.NoError(t, st.Fsck(ctx))
+	require.NoError(t, st.Set(ctx, "foo", []byte("foo")))
+
+	st, err = backend.Clone(ctx, be, url, filepath.Join(td, "bob"))
+	require.NoError(t, err)
+	assert.True(t, st.Exists(ctx, "foo"))
+
+	st, err = backend.DetectStorage(ctx, filepath.Join(td, "bob"))
+	require.NoError(t, err)
+	assert.Equal(t, "s3", st.Name())
+
+	_, err = backend.Clone(ctx, be, "s3://missing?endpoint="+srv.URL, filepath.Join(td, "carol"))
+	assert.Error(t, err)
+}
+
+func TestParseLocation(t *testing.T) {
+	l, err := parseLocation("s3://bucket/some/prefix/?region=eu-central-1")
+	require.NoError(t, err)
+	assert.Equal(t, location{
+		bucket:   "bucket",
+		prefix:   "some/prefix",
+		region:   "eu-central-1",
+		endpoint: "https://s3.eu-central-1.amazonaws.com",
+	}, l)
+	assert.Equal(t, "some/prefix/foo/bar", l.key("/foo/bar"))
+	assert.Equal(t, "foo/bar", l.name("some/prefix/foo/bar"))
+
+	for _, in := range []string{"bucket/prefix", "https://example.org/bucket", "s3:///prefix"} {
+		_, err := parseLocation(in)
+		assert.Error(t, err, in)
+	}
+}
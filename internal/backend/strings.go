@@ -38,6 +38,19 @@ func StorageBackends() []string {
 	return bes
 }
 
+// PersistentStorageBackends returns the list of registered storage backends
+// that keep the secrets after gosecret exits.
+func PersistentStorageBackends() []string {
+	bes := make([]string, 0, len(storageNameToBackendMap))
+	for k, v := range storageNameToBackendMap {
+		if v.IsPersistent() {
+			bes = append(bes, k)
+		}
+	}
+	sort.Strings(bes)
+	return bes
+}
+
 // CryptoBackendFromName parses the identifier into a crypto backend
 func CryptoBackendFromName(name string) CryptoBackend {
 	if name == "gpg" {
@@ -17,16 +17,20 @@ var (
 
 // Config is the current config struct
 type Config struct {
-	AutoClip      bool              `yaml:"autoclip"`      // decide whether passwords are automatically copied or not
-	AutoImport    bool              `yaml:"autoimport"`    // import missing public keys w/o asking
-	ClipTimeout   int               `yaml:"cliptimeout"`   // clear clipboard after seconds
-	ExportKeys    bool              `yaml:"exportkeys"`    // automatically export public keys of all recipients
-	NoPager       bool              `yaml:"nopager"`       // do not invoke a pager to display long lists
-	Notifications bool              `yaml:"notifications"` // enable desktop notifications
-	Parsing       bool              `yaml:"parsing"`       // allows to switch off all output parsing
-	Path          string            `yaml:"path"`
-	SafeContent   bool              `yaml:"safecontent"` // avoid showing passwords in terminal
-	Mounts        map[string]string `yaml:"mounts"`
+	AutoClip      bool                   `yaml:"autoclip"`      // decide whether passwords are automatically copied or not
+	AutoImport    bool                   `yaml:"autoimport"`    // import missing public keys w/o asking
+	Clipboard     string                 `yaml:"clipboard"`     // clipboard provider, empty for automatic selection
+	ClipTimeout   int                    `yaml:"cliptimeout"`   // clear clipboard after seconds
+	ExportKeys    bool                   `yaml:"exportkeys"`    // automatically export public keys of all recipients
+	NoPager       bool                   `yaml:"nopager"`       // do not invoke a pager to display long lists
+	Notifications bool                   `yaml:"notifications"` // enable desktop notifications
+	Parsing       bool                   `yaml:"parsing"`       // allows to switch off all output parsing
+	Path          string                 `yaml:"path"`
+	SafeContent   bool                   `yaml:"safecontent"` // avoid showing passwords in terminal
+	Mounts        map[string]MountConfig `yaml:"mounts"`
+	DurableQueue  bool                   `yaml:"durablequeue"` // retry failed background tasks, e.g. pushes, on the next run
+	SyncInterval  int                    `yaml:"syncinterval"` // pull before reads if the last sync is older (minutes), 0 disables auto-sync
+	AutoLock      int                    `yaml:"autolock"`     // lock the shell after being idle (minutes), 0 disables it
 
 	ConfigPath string `yaml:"-"`
 
@@ -38,9 +42,10 @@ type Config struct {
 func New() *Config {
 	return &Config{
 		AutoImport:    true,
+		AutoLock:      15,
 		ClipTimeout:   45,
 		ExportKeys:    true,
-		Mounts:        make(map[string]string),
+		Mounts:        make(map[string]MountConfig),
 		Notifications: true,
 		Parsing:       true,
 		Path:          PwStoreDir(""),
@@ -21,17 +21,17 @@ func TestNewConfig(t *testing.T) {
 
 	cfg := config.New()
 	cs := cfg.String()
-	assert.Contains(t, cs, `&config.Config{AutoClip:false, AutoImport:true, ClipTimeout:45, ExportKeys:true, NoPager:false, Notifications:true,`)
-	assert.Contains(t, cs, `SafeContent:false, Mounts:map[string]string{},`)
+	assert.Contains(t, cs, `&config.Config{AutoClip:false, AutoImport:true, Clipboard:"", ClipTimeout:45, ExportKeys:true, NoPager:false, Notifications:true,`)
+	assert.Contains(t, cs, `SafeContent:false, Mounts:map[string]config.MountConfig{},`)
 
 	cfg = &config.Config{
-		Mounts: make(map[string]string, 2),
+		Mounts: make(map[string]config.MountConfig, 2),
 	}
-	cfg.Mounts["foo"] = ""
-	cfg.Mounts["bar"] = ""
+	cfg.Mounts["foo"] = config.MountConfig{}
+	cfg.Mounts["bar"] = config.MountConfig{ReadOnly: true}
 	cs = cfg.String()
-	assert.Contains(t, cs, `&config.Config{AutoClip:false, AutoImport:false, ClipTimeout:0, ExportKeys:false, NoPager:false, Notifications:false,`)
-	assert.Contains(t, cs, `SafeContent:false, Mounts:map[string]string{"bar":"", "foo":""},`)
+	assert.Contains(t, cs, `&config.Config{AutoClip:false, AutoImport:false, Clipboard:"", ClipTimeout:0, ExportKeys:false, NoPager:false, Notifications:false,`)
+	assert.Contains(t, cs, `SafeContent:false, Mounts:map[string]config.MountConfig{"bar":config.MountConfig{Path:"", ReadOnly:true, NoAutoSync:false, RequireSigning:false, Writers:[]string(nil), Managed:false}, "foo":config.MountConfig{Path:"", ReadOnly:false, NoAutoSync:false, RequireSigning:false, Writers:[]string(nil), Managed:false}},`)
 }
 
 func TestSetConfigValue(t *testing.T) {
@@ -2,6 +2,7 @@ package config
 
 import (
 	"context"
+	"time"
 
 	"github.com/itsonlycode/gosecret/pkg/ctxutil"
 )
@@ -27,5 +28,11 @@ func (c *Config) WithContext(ctx context.Context) context.Context {
 	if !ctxutil.HasShowParsing(ctx) {
 		ctx = ctxutil.WithShowParsing(ctx, c.Parsing)
 	}
+	if !ctxutil.HasClipboard(ctx) {
+		ctx = ctxutil.WithClipboard(ctx, c.Clipboard)
+	}
+	if !ctxutil.HasSyncInterval(ctx) {
+		ctx = ctxutil.WithSyncInterval(ctx, time.Duration(c.SyncInterval)*time.Minute)
+	}
 	return ctx
 }
@@ -78,7 +78,7 @@ func load(cf string, relaxed bool) (*Config, error) {
 		return nil, ErrConfigNotParsed
 	}
 	if cfg.Mounts == nil {
-		cfg.Mounts = make(map[string]string)
+		cfg.Mounts = make(map[string]MountConfig)
 	}
 	cfg.ConfigPath = cf
 	return cfg, nil
@@ -105,6 +105,7 @@ type configer interface {
 func decode(buf []byte, relaxed bool) (*Config, error) {
 	mostRecent := &Config{
 		AutoImport:    true,
+		AutoLock:      15,
 		ClipTimeout:   45,
 		ExportKeys:    true,
 		Notifications: true,
@@ -37,6 +37,7 @@ mounts:
 			want: &Config{
 				AutoClip:      true,
 				AutoImport:    false,
+				AutoLock:      15,
 				ClipTimeout:   45,
 				ExportKeys:    true,
 				NoPager:       false,
@@ -44,9 +45,9 @@ mounts:
 				Parsing:       true,
 				Path:          "/home/johndoe/.password-store",
 				SafeContent:   false,
-				Mounts: map[string]string{
-					"foo/sub": "/home/johndoe/.password-store-foo-sub",
-					"work":    "/home/johndoe/.password-store-work",
+				Mounts: map[string]MountConfig{
+					"foo/sub": {Path: "/home/johndoe/.password-store-foo-sub"},
+					"work":    {Path: "/home/johndoe/.password-store-work"},
 				},
 			},
 		}, {
@@ -66,6 +67,7 @@ mounts:
 			want: &Config{
 				AutoClip:      true,
 				AutoImport:    false,
+				AutoLock:      15,
 				ClipTimeout:   45,
 				ExportKeys:    true,
 				NoPager:       false,
@@ -73,9 +75,9 @@ mounts:
 				Parsing:       true,
 				Path:          "/home/johndoe/.password-store",
 				SafeContent:   false,
-				Mounts: map[string]string{
-					"foo/sub": "/home/johndoe/.password-store-foo-sub",
-					"work":    "/home/johndoe/.password-store-work",
+				Mounts: map[string]MountConfig{
+					"foo/sub": {Path: "/home/johndoe/.password-store-foo-sub"},
+					"work":    {Path: "/home/johndoe/.password-store-work"},
 				},
 				XXX: map[string]interface{}{"foo": string("bar")},
 			},
@@ -138,9 +140,9 @@ mounts:
 				Parsing:       true,
 				Path:          "/home/johndoe/.password-store",
 				SafeContent:   false,
-				Mounts: map[string]string{
-					"foo/sub": "/home/johndoe/.password-store-foo-sub",
-					"work":    "/home/johndoe/.password-store-work",
+				Mounts: map[string]MountConfig{
+					"foo/sub": {Path: "/home/johndoe/.password-store-foo-sub"},
+					"work":    {Path: "/home/johndoe/.password-store-work"},
 				},
 			},
 		}, {
@@ -184,9 +186,9 @@ version: 1.4.0`,
 				Parsing:       true,
 				Path:          "/home/johndoe/.password-store",
 				SafeContent:   false,
-				Mounts: map[string]string{
-					"foo/sub": "/home/johndoe/.password-store-foo-sub",
-					"work":    "/home/johndoe/.password-store-work",
+				Mounts: map[string]MountConfig{
+					"foo/sub": {Path: "/home/johndoe/.password-store-foo-sub"},
+					"work":    {Path: "/home/johndoe/.password-store-work"},
 				},
 			},
 		}, {
@@ -214,11 +216,11 @@ version: "1.3.0"`,
 				Parsing:       true,
 				Path:          "/home/foo/.password-store",
 				SafeContent:   true,
-				Mounts: map[string]string{
-					"dev":       "/Users/johndoe/.password-store-dev",
-					"ops":       "/Users/johndoe/.password-store-ops",
-					"personal":  "/Users/johndoe/secrets",
-					"teststore": "/Users/johndoe/tmp/teststore",
+				Mounts: map[string]MountConfig{
+					"dev":       {Path: "/Users/johndoe/.password-store-dev"},
+					"ops":       {Path: "/Users/johndoe/.password-store-ops"},
+					"personal":  {Path: "/Users/johndoe/secrets"},
+					"teststore": {Path: "/Users/johndoe/tmp/teststore"},
 				},
 			},
 		}, {
@@ -252,11 +254,11 @@ version: "1.2.0"`,
 				Parsing:       true,
 				Path:          "/home/foo/.password-store",
 				SafeContent:   true,
-				Mounts: map[string]string{
-					"dev":       "/Users/johndoe/.password-store-dev",
-					"ops":       "/Users/johndoe/.password-store-ops",
-					"personal":  "/Users/johndoe/secrets",
-					"teststore": "/Users/johndoe/tmp/teststore",
+				Mounts: map[string]MountConfig{
+					"dev":       {Path: "/Users/johndoe/.password-store-dev"},
+					"ops":       {Path: "/Users/johndoe/.password-store-ops"},
+					"personal":  {Path: "/Users/johndoe/secrets"},
+					"teststore": {Path: "/Users/johndoe/tmp/teststore"},
 				},
 			},
 		}, {
@@ -288,11 +290,11 @@ version: 1.1.0`,
 				Parsing:       true,
 				Path:          "/home/johndoe/.password-store",
 				SafeContent:   false,
-				Mounts: map[string]string{
-					"dev":       "/home/johndoe/.password-store-dev",
-					"ops":       "/home/johndoe/.password-store-ops",
-					"personal":  "/home/johndoe/secrets",
-					"teststore": "/home/johndoe/tmp/teststore",
+				Mounts: map[string]MountConfig{
+					"dev":       {Path: "/home/johndoe/.password-store-dev"},
+					"ops":       {Path: "/home/johndoe/.password-store-ops"},
+					"personal":  {Path: "/home/johndoe/secrets"},
+					"teststore": {Path: "/home/johndoe/tmp/teststore"},
 				},
 			},
 		}, {
@@ -322,11 +324,11 @@ version: "1.0.0"`,
 				Parsing:       true,
 				Path:          "/home/foo/.password-store",
 				SafeContent:   false,
-				Mounts: map[string]string{
-					"dev":       "/Users/johndoe/.password-store-dev",
-					"ops":       "/Users/johndoe/.password-store-ops",
-					"personal":  "/Users/johndoe/secrets",
-					"teststore": "/Users/johndoe/tmp/teststore",
+				Mounts: map[string]MountConfig{
+					"dev":       {Path: "/Users/johndoe/.password-store-dev"},
+					"ops":       {Path: "/Users/johndoe/.password-store-ops"},
+					"personal":  {Path: "/Users/johndoe/secrets"},
+					"teststore": {Path: "/Users/johndoe/tmp/teststore"},
 				},
 			},
 		},
@@ -37,10 +37,10 @@ func (c *Pre1127) Config() *Config {
 		Parsing:       c.Parsing,
 		Path:          c.Path,
 		SafeContent:   c.SafeContent,
-		Mounts:        make(map[string]string, len(c.Mounts)),
+		Mounts:        make(map[string]MountConfig, len(c.Mounts)),
 	}
 	for k, v := range c.Mounts {
-		cfg.Mounts[k] = v
+		cfg.Mounts[k] = MountConfig{Path: v}
 	}
 	return cfg
 }
@@ -85,10 +85,10 @@ func (c *Pre1102) Config() *Config {
 		Parsing:       true,
 		Path:          c.Path,
 		SafeContent:   c.SafeContent,
-		Mounts:        make(map[string]string, len(c.Mounts)),
+		Mounts:        make(map[string]MountConfig, len(c.Mounts)),
 	}
 	for k, v := range c.Mounts {
-		cfg.Mounts[k] = v
+		cfg.Mounts[k] = MountConfig{Path: v}
 	}
 	return cfg
 }
@@ -139,7 +139,7 @@ func (c *Pre193) Config() *Config {
 		Parsing:       true,
 		Path:          c.Root.Path,
 		SafeContent:   c.Root.SafeContent,
-		Mounts:        make(map[string]string, len(c.Mounts)),
+		Mounts:        make(map[string]MountConfig, len(c.Mounts)),
 	}
 	if p, err := pathFromURL(c.Root.Path); err == nil {
 		cfg.Path = p
@@ -149,7 +149,7 @@ func (c *Pre193) Config() *Config {
 		if err != nil {
 			continue
 		}
-		cfg.Mounts[k] = p
+		cfg.Mounts[k] = MountConfig{Path: p}
 	}
 	return cfg
 }
@@ -201,7 +201,7 @@ func (c *Pre182) Config() *Config {
 		Parsing:       true,
 		Path:          c.Root.Path,
 		SafeContent:   c.Root.SafeContent,
-		Mounts:        make(map[string]string, len(c.Mounts)),
+		Mounts:        make(map[string]MountConfig, len(c.Mounts)),
 	}
 	if p, err := pathFromURL(c.Root.Path); err == nil {
 		cfg.Path = p
@@ -211,7 +211,7 @@ func (c *Pre182) Config() *Config {
 		if err != nil {
 			continue
 		}
-		cfg.Mounts[k] = p
+		cfg.Mounts[k] = MountConfig{Path: p}
 	}
 	return cfg
 }
@@ -245,10 +245,10 @@ func (c *Pre140) Config() *Config {
 		Parsing:     true,
 		Path:        c.Path,
 		SafeContent: c.SafeContent,
-		Mounts:      make(map[string]string, len(c.Mounts)),
+		Mounts:      make(map[string]MountConfig, len(c.Mounts)),
 	}
 	for k, v := range c.Mounts {
-		cfg.Mounts[k] = v
+		cfg.Mounts[k] = MountConfig{Path: v}
 	}
 	return cfg
 }
@@ -289,10 +289,10 @@ func (c *Pre130) Config() *Config {
 		Parsing:     true,
 		Path:        c.Path,
 		SafeContent: c.SafeContent,
-		Mounts:      make(map[string]string, len(c.Mounts)),
+		Mounts:      make(map[string]MountConfig, len(c.Mounts)),
 	}
 	for k, v := range c.Mounts {
-		cfg.Mounts[k] = v
+		cfg.Mounts[k] = MountConfig{Path: v}
 	}
 	return cfg
 }
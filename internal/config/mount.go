@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MountConfig is the config of a mounted store. Mounts without any options
+// are written as a plain path to stay compatible with older versions.
+type MountConfig struct {
+	Path string `yaml:"path"`
+	// ReadOnly rejects any change to the mount
+	ReadOnly bool `yaml:"readonly,omitempty"`
+	// NoAutoSync disables pushing to the remote after each change. The
+	// mount can still be synced explicitly.
+	NoAutoSync bool `yaml:"noautosync,omitempty"`
+	// RequireSigning rejects changes unless the storage signs its commits
+	RequireSigning bool `yaml:"requiresigning,omitempty"`
+	// Writers are the recipients that may change the mount. Changes are
+	// only allowed if one of them is a private key we own. Empty allows
+	// everyone.
+	Writers []string `yaml:"writers,omitempty"`
+	// Managed is set for mounts that were added from the mounts manifest of
+	// the root store. They are removed once the manifest drops them.
+	Managed bool `yaml:"managed,omitempty"`
+}
+
+var mountOptions = map[string]bool{
+	"path":           true,
+	"readonly":       true,
+	"noautosync":     true,
+	"requiresigning": true,
+	"writers":        true,
+	"managed":        true,
+}
+
+// mountConfig avoids recursing into the custom (un)marshalers
+type mountConfig MountConfig
+
+// HasOptions returns true if any option besides the path is set
+func (m MountConfig) HasOptions() bool {
+	return m.ReadOnly || m.NoAutoSync || m.RequireSigning || len(m.Writers) > 0 || m.Managed
+}
+
+// Options returns a short description of the options that are set
+func (m MountConfig) Options() []string {
+	var opts []string
+	if m.ReadOnly {
+		opts = append(opts, "read-only")
+	}
+	if m.NoAutoSync {
+		opts = append(opts, "no-autosync")
+	}
+	if m.RequireSigning {
+		opts = append(opts, "signed")
+	}
+	if len(m.Writers) > 0 {
+		opts = append(opts, "writers: "+strings.Join(m.Writers, ","))
+	}
+	if m.Managed {
+		opts = append(opts, "managed")
+	}
+	return opts
+}
+
+// MarshalYAML implements yaml.Marshaler
+func (m MountConfig) MarshalYAML() (interface{}, error) {
+	if !m.HasOptions() {
+		return m.Path, nil
+	}
+	return mountConfig(m), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler. It accepts both a plain path
+// and a map of options. Unknown options are rejected so that the mounts of
+// legacy configs are not mistaken for the current format.
+func (m *MountConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*m = MountConfig{}
+		return value.Decode(&m.Path)
+	}
+	if value.Kind == yaml.MappingNode {
+		for i := 0; i < len(value.Content); i += 2 {
+			if k := value.Content[i].Value; !mountOptions[k] {
+				return fmt.Errorf("line %d: unknown mount option %q", value.Content[i].Line, k)
+			}
+		}
+	}
+	var mc mountConfig
+	if err := value.Decode(&mc); err != nil {
+		return err
+	}
+	*m = MountConfig(mc)
+	return nil
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMountConfigYAML(t *testing.T) {
+	in := map[string]MountConfig{
+		"plain": {Path: "/tmp/plain"},
+		"team": {
+			Path:           "/tmp/team",
+			ReadOnly:       true,
+			NoAutoSync:     true,
+			RequireSigning: true,
+			Writers:        []string{"0xDEADBEEF"},
+		},
+	}
+
+	buf, err := yaml.Marshal(in)
+	require.NoError(t, err)
+	assert.Contains(t, string(buf), "plain: /tmp/plain\n")
+	assert.Contains(t, string(buf), "readonly: true")
+
+	out := map[string]MountConfig{}
+	require.NoError(t, yaml.Unmarshal(buf, &out))
+	assert.Equal(t, in, out)
+
+	assert.Error(t, yaml.Unmarshal([]byte("foo:\n  path: /tmp/foo\n  autosync: true\n"), &out))
+}
+
+func TestDecodeMountOptions(t *testing.T) {
+	cfg, err := decode([]byte(`autoclip: true
+path: /home/johndoe/.password-store
+mounts:
+  work: /home/johndoe/.password-store-work
+  team:
+    path: /home/johndoe/.password-store-team
+    readonly: true
+    writers:
+      - 0xDEADBEEF`), false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]MountConfig{
+		"work": {Path: "/home/johndoe/.password-store-work"},
+		"team": {
+			Path:     "/home/johndoe/.password-store-team",
+			ReadOnly: true,
+			Writers:  []string{"0xDEADBEEF"},
+		},
+	}, cfg.Mounts)
+	assert.Equal(t, []string{"read-only", "writers: 0xDEADBEEF"}, cfg.Mounts["team"].Options())
+}
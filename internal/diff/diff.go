@@ -1,5 +1,7 @@
 package diff
 
+import "sort"
+
 // List returnes the number of items added to and removed from the first to
 // the second list
 func List(l, r []string) (int, int) {
@@ -22,6 +24,22 @@ func List(l, r []string) (int, int) {
 	return added, removed
 }
 
+// Missing returns the sorted items of the first list that are not contained
+// in the second list
+func Missing(l, r []string) []string {
+	mr := listToMap(r)
+
+	missing := make([]string, 0, len(l))
+	for k := range listToMap(l) {
+		if _, found := mr[k]; !found {
+			missing = append(missing, k)
+		}
+	}
+	sort.Strings(missing)
+
+	return missing
+}
+
 func listToMap(l []string) map[string]struct{} {
 	m := make(map[string]struct{}, len(l))
 	for _, e := range l {
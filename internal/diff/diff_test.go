@@ -35,3 +35,40 @@ func TestList(t *testing.T) {
 		assert.Equal(t, tc.removed, r)
 	}
 }
+
+func TestLines(t *testing.T) {
+	for _, tc := range []struct {
+		old  []string
+		new  []string
+		diff []string
+	}{
+		{
+			old:  []string{"foo", "bar"},
+			new:  []string{"foo", "bar"},
+			diff: []string{"  foo", "  bar"},
+		},
+		{
+			old:  []string{"foo", "bar"},
+			new:  []string{"foo", "baz", "bar"},
+			diff: []string{"  foo", "+ baz", "  bar"},
+		},
+		{
+			old:  []string{"pw", "user: foo"},
+			new:  []string{"other", "url: bar", "user: foo"},
+			diff: []string{"- pw", "+ other", "+ url: bar", "  user: foo"},
+		},
+		{
+			old:  nil,
+			new:  []string{"foo"},
+			diff: []string{"+ foo"},
+		},
+	} {
+		assert.Equal(t, tc.diff, Lines(tc.old, tc.new))
+	}
+}
+
+func TestMissing(t *testing.T) {
+	assert.Equal(t, []string{}, Missing(nil, []string{"foo"}))
+	assert.Equal(t, []string{"bar", "baz"}, Missing([]string{"foo", "baz", "bar", "bar"}, []string{"foo"}))
+	assert.Equal(t, []string{}, Missing([]string{"foo"}, []string{"foo", "bar"}))
+}
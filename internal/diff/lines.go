@@ -0,0 +1,50 @@
+package diff
+
+// Lines computes a line based diff between l and r. Each returned line is
+// prefixed with "+ " if it was added, "- " if it was removed or "  " if it
+// is present in both.
+func Lines(l, r []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of l[i:]
+	// and r[j:]
+	lcs := make([][]int, len(l)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(r)+1)
+	}
+	for i := len(l) - 1; i >= 0; i-- {
+		for j := len(r) - 1; j >= 0; j-- {
+			if l[i] == r[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+				continue
+			}
+			if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	out := make([]string, 0, len(l)+len(r))
+	var i, j int
+	for i < len(l) && j < len(r) {
+		switch {
+		case l[i] == r[j]:
+			out = append(out, "  "+l[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "- "+l[i])
+			i++
+		default:
+			out = append(out, "+ "+r[j])
+			j++
+		}
+	}
+	for ; i < len(l); i++ {
+		out = append(out, "- "+l[i])
+	}
+	for ; j < len(r); j++ {
+		out = append(out, "+ "+r[j])
+	}
+	return out
+}
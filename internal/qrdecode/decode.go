@@ -0,0 +1,465 @@
+// Package qrdecode implements a small QR code decoder. It is meant to read
+// QR codes from screenshots or exported images, i.e. reasonably clean images
+// without perspective distortion. The code may be scaled and rotated.
+package qrdecode
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"sort"
+
+	// image decoders for DecodeFile
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"rsc.io/qr/coding"
+)
+
+// ErrNotFound is returned if no QR code was found in the image
+var ErrNotFound = errors.New("no QR code found")
+
+// DecodeFile reads an image file (PNG, JPEG or GIF) and decodes the QR code
+// it contains
+func DecodeFile(path string) (string, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = fh.Close()
+	}()
+
+	img, _, err := image.Decode(fh)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image %s: %w", path, err)
+	}
+	return Decode(img)
+}
+
+// Decode finds a QR code in the image and returns its content
+func Decode(img image.Image) (string, error) {
+	bm := binarize(img)
+
+	tl, tr, bl, err := bm.findCode()
+	if err != nil {
+		return "", err
+	}
+
+	module := (tl.size + tr.size + bl.size) / 3 / 7
+	est := (dist(tl, tr)+dist(tl, bl))/2/module + 7
+
+	// the estimated size might be off for larger codes, so we try the
+	// closest valid sizes
+	sizes := make([]int, 0, 3)
+	for v := 1; v <= 40; v++ {
+		sizes = append(sizes, 17+4*v)
+	}
+	sort.Slice(sizes, func(i, j int) bool {
+		return math.Abs(float64(sizes[i])-est) < math.Abs(float64(sizes[j])-est)
+	})
+
+	var lastErr error
+	for _, siz := range sizes[:3] {
+		txt, err := decodeGrid(bm.sample(tl, tr, bl, siz))
+		if err == nil {
+			return txt, nil
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+// bitmap is a black and white image. true is black.
+type bitmap struct {
+	w, h int
+	bits []bool
+}
+
+func (b *bitmap) at(x, y int) bool {
+	if x < 0 || y < 0 || x >= b.w || y >= b.h {
+		return false
+	}
+	return b.bits[y*b.w+x]
+}
+
+// binarize converts the image to black and white using a global threshold.
+// Transparent pixels are considered white.
+func binarize(img image.Image) *bitmap {
+	r := img.Bounds()
+	bm := &bitmap{
+		w:    r.Dx(),
+		h:    r.Dy(),
+		bits: make([]bool, r.Dx()*r.Dy()),
+	}
+
+	lum := make([]uint32, len(bm.bits))
+	var min, max uint32 = math.MaxUint32, 0
+	for y := 0; y < bm.h; y++ {
+		for x := 0; x < bm.w; x++ {
+			cr, cg, cb, ca := img.At(r.Min.X+x, r.Min.Y+y).RGBA()
+			// the color components are premultiplied, so adding the
+			// missing alpha blends the pixel onto a white background
+			l := (299*cr+587*cg+114*cb)/1000 + (0xffff - ca)
+			lum[y*bm.w+x] = l
+			if l < min {
+				min = l
+			}
+			if l > max {
+				max = l
+			}
+		}
+	}
+
+	threshold := (min + max) / 2
+	for i, l := range lum {
+		bm.bits[i] = l < threshold
+	}
+	return bm
+}
+
+// finder is the center of a finder pattern (the large squares in the
+// corners) and its width in pixels
+type finder struct {
+	x, y  float64
+	size  float64
+	count int
+}
+
+func dist(a, b finder) float64 {
+	return math.Hypot(a.x-b.x, a.y-b.y)
+}
+
+// findCode locates the three finder patterns and returns them in the
+// order top left, top right, bottom left
+func (b *bitmap) findCode() (finder, finder, finder, error) {
+	var fs []finder
+	for y := 0; y < b.h; y++ {
+		for _, f := range b.findInRow(y) {
+			fs = merge(fs, f)
+		}
+	}
+
+	if len(fs) < 3 {
+		return finder{}, finder{}, finder{}, ErrNotFound
+	}
+	sort.Slice(fs, func(i, j int) bool {
+		return fs[i].count > fs[j].count
+	})
+	a, c, d := fs[0], fs[1], fs[2]
+
+	// the top left pattern is opposite of the longest side
+	switch {
+	case dist(c, d) >= dist(a, c) && dist(c, d) >= dist(a, d):
+	case dist(a, d) >= dist(a, c):
+		a, c = c, a
+	default:
+		a, d = d, a
+	}
+
+	// make sure top right and bottom left are not mirrored. The y axis
+	// points down, so the cross product has to be positive.
+	if (c.x-a.x)*(d.y-a.y)-(c.y-a.y)*(d.x-a.x) < 0 {
+		c, d = d, c
+	}
+
+	return a, c, d, nil
+}
+
+// merge adds the finder to the list or merges it with a close one
+func merge(fs []finder, f finder) []finder {
+	for i, o := range fs {
+		if math.Abs(o.x-f.x) > o.size/4 || math.Abs(o.y-f.y) > o.size/4 {
+			continue
+		}
+		n := float64(o.count)
+		fs[i] = finder{
+			x:     (o.x*n + f.x) / (n + 1),
+			y:     (o.y*n + f.y) / (n + 1),
+			size:  (o.size*n + f.size) / (n + 1),
+			count: o.count + 1,
+		}
+		return fs
+	}
+	return append(fs, f)
+}
+
+// findInRow looks for the 1:1:3:1:1 pattern of black and white runs that
+// crosses a finder pattern and verifies it vertically and horizontally
+func (b *bitmap) findInRow(y int) []finder {
+	type run struct {
+		start, length int
+	}
+
+	var runs []run
+	for x := 0; x < b.w; {
+		start := x
+		black := b.at(x, y)
+		for x < b.w && b.at(x, y) == black {
+			x++
+		}
+		runs = append(runs, run{start: start, length: x - start})
+	}
+
+	var fs []finder
+	// runs alternate between black and white
+	first := 0
+	if len(runs) > 0 && !b.at(0, y) {
+		first = 1
+	}
+	for i := first; i+4 < len(runs); i += 2 {
+		var counts [5]int
+		for j := range counts {
+			counts[j] = runs[i+j].length
+		}
+		if !isFinder(counts) {
+			continue
+		}
+
+		cx := runs[i+2].start + runs[i+2].length/2
+		cy, vsize, ok := b.crossCheck(cx, y, 0, 1)
+		if !ok {
+			continue
+		}
+		fx, hsize, ok := b.crossCheck(cx, int(cy), 1, 0)
+		if !ok {
+			continue
+		}
+		fs = append(fs, finder{
+			x:     fx,
+			y:     cy,
+			size:  (vsize + hsize) / 2,
+			count: 1,
+		})
+	}
+	return fs
+}
+
+// crossCheck verifies the finder pattern along the given direction. It
+// returns the center coordinate along that direction and the pattern size.
+func (b *bitmap) crossCheck(x, y, dx, dy int) (float64, float64, bool) {
+	if !b.at(x, y) {
+		return 0, 0, false
+	}
+
+	var counts [5]int
+	px, py := x, y
+	for b.at(px, py) {
+		counts[2]++
+		px, py = px-dx, py-dy
+	}
+	for i := 1; i >= 0; i-- {
+		black := i == 0
+		for px >= 0 && py >= 0 && b.at(px, py) == black {
+			counts[i]++
+			px, py = px-dx, py-dy
+		}
+	}
+
+	px, py = x+dx, y+dy
+	for b.at(px, py) {
+		counts[2]++
+		px, py = px+dx, py+dy
+	}
+	end := px*dx + py*dy
+	for i := 3; i <= 4; i++ {
+		black := i == 4
+		for px < b.w && py < b.h && b.at(px, py) == black {
+			counts[i]++
+			px, py = px+dx, py+dy
+		}
+	}
+
+	if !isFinder(counts) {
+		return 0, 0, false
+	}
+
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	return float64(end) - float64(counts[2])/2, float64(total), true
+}
+
+// isFinder checks if the run lengths match the 1:1:3:1:1 ratio
+func isFinder(counts [5]int) bool {
+	total := 0
+	for _, c := range counts {
+		if c == 0 {
+			return false
+		}
+		total += c
+	}
+	if total < 7 {
+		return false
+	}
+
+	module := float64(total) / 7
+	variance := module / 2
+	for i, c := range counts {
+		want := module
+		if i == 2 {
+			want *= 3
+		}
+		if math.Abs(want-float64(c)) >= variance*want/module {
+			return false
+		}
+	}
+	return true
+}
+
+// sample reads the modules of a code with siz modules per side. The
+// coordinates are mapped with the affine transformation defined by the
+// centers of the finder patterns.
+func (b *bitmap) sample(tl, tr, bl finder, siz int) [][]bool {
+	span := float64(siz - 7)
+	g := make([][]bool, siz)
+	for my := range g {
+		g[my] = make([]bool, siz)
+		for mx := range g[my] {
+			u := (float64(mx) + 0.5 - 3.5) / span
+			v := (float64(my) + 0.5 - 3.5) / span
+			x := tl.x + u*(tr.x-tl.x) + v*(bl.x-tl.x)
+			y := tl.y + u*(tr.y-tl.y) + v*(bl.y-tl.y)
+			g[my][mx] = b.at(int(math.Floor(x)), int(math.Floor(y)))
+		}
+	}
+	return g
+}
+
+// decodeGrid decodes the modules of a QR code. g is indexed by row, then
+// column.
+func decodeGrid(g [][]bool) (string, error) {
+	siz := len(g)
+	v := (siz - 17) / 4
+	if v < 1 || v > 40 || 17+4*v != siz {
+		return "", fmt.Errorf("invalid QR code size %d", siz)
+	}
+
+	level, mask, err := readFormat(g)
+	if err != nil {
+		return "", err
+	}
+
+	plan, err := coding.NewPlan(coding.Version(v), level, mask)
+	if err != nil {
+		return "", err
+	}
+
+	// the pixel offsets are relative to the blocks, not to the interleaved
+	// order in the image, so we get the blocks without further work
+	raw := make([]byte, plan.DataBytes+plan.CheckBytes)
+	for y, row := range plan.Pixel {
+		for x, pix := range row {
+			if r := pix.Role(); r != coding.Data && r != coding.Check {
+				continue
+			}
+			black := g[y][x]
+			if pix&coding.Black != 0 {
+				black = !black
+			}
+			if black {
+				o := pix.Offset()
+				raw[o/8] |= 1 << (7 - o%8)
+			}
+		}
+	}
+
+	nblock := plan.Blocks
+	ne := plan.CheckBytes / nblock
+	nde := plan.DataBytes / nblock
+	extra := plan.DataBytes % nblock
+
+	dat, chk := raw[:plan.DataBytes], raw[plan.DataBytes:]
+	data := make([]byte, 0, plan.DataBytes)
+	for i := 0; i < nblock; i++ {
+		nd := nde
+		if i >= nblock-extra {
+			nd++
+		}
+		block := make([]byte, 0, nd+ne)
+		block = append(block, dat[:nd]...)
+		block = append(block, chk[:ne]...)
+		dat, chk = dat[nd:], chk[ne:]
+
+		if err := correct(block, ne); err != nil {
+			return "", err
+		}
+		data = append(data, block[:nd]...)
+	}
+
+	return parseSegments(data, v)
+}
+
+// readFormat reads the error correction level and the mask from the format
+// information next to the finder patterns
+func readFormat(g [][]bool) (coding.Level, coding.Mask, error) {
+	siz := len(g)
+	var tl, other uint32
+	for i := 0; i < 15; i++ {
+		// see fplan in rsc.io/qr/coding for the positions
+		var y, x int
+		switch {
+		case i < 6:
+			y, x = i, 8
+		case i < 8:
+			y, x = i+1, 8
+		case i < 9:
+			y, x = 8, 7
+		default:
+			y, x = 8, 14-i
+		}
+		if g[y][x] {
+			tl |= 1 << i
+		}
+
+		if i < 8 {
+			y, x = 8, siz-1-i
+		} else {
+			y, x = siz-1-(14-i), 8
+		}
+		if g[y][x] {
+			other |= 1 << i
+		}
+	}
+
+	best, bestDist := -1, 16
+	for f := 0; f < 32; f++ {
+		want := formatBits(coding.Level(f>>3), coding.Mask(f&7))
+		for _, got := range []uint32{tl, other} {
+			if d := hamming(want, got); d < bestDist {
+				best, bestDist = f, d
+			}
+		}
+	}
+	// the BCH code can correct up to three errors
+	if bestDist > 3 {
+		return 0, 0, fmt.Errorf("invalid format information")
+	}
+	return coding.Level(best >> 3), coding.Mask(best & 7), nil
+}
+
+// formatBits returns the 15 format bits as they appear in the image
+func formatBits(l coding.Level, m coding.Mask) uint32 {
+	fb := uint32(l^1) << 13
+	fb |= uint32(m) << 10
+	const formatPoly = 0x537
+	rem := fb
+	for i := 14; i >= 10; i-- {
+		if rem&(1<<uint(i)) != 0 {
+			rem ^= formatPoly << uint(i-10)
+		}
+	}
+	return (fb | rem) ^ 0x5412
+}
+
+func hamming(a, b uint32) int {
+	d := 0
+	for x := a ^ b; x != 0; x &= x - 1 {
+		d++
+	}
+	return d
+}
@@ -0,0 +1,116 @@
+package qrdecode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// render draws the modules with the given scale. rotate turns the image by
+// 90 degrees clockwise.
+func render(bm [][]bool, scale int, rotate bool) image.Image {
+	siz := len(bm)
+	img := image.NewGray(image.Rect(0, 0, siz*scale, siz*scale))
+	for y := range bm {
+		for x := range bm[y] {
+			c := color.Gray{Y: 255}
+			if bm[y][x] {
+				c = color.Gray{Y: 0}
+			}
+			px, py := x, y
+			if rotate {
+				px, py = siz-1-y, x
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetGray(px*scale+dx, py*scale+dy, c)
+				}
+			}
+		}
+	}
+	return img
+}
+
+func TestDecode(t *testing.T) {
+	for _, tc := range []struct {
+		content string
+		level   qrcode.RecoveryLevel
+	}{
+		{"12345", qrcode.Low},
+		{"HELLO WORLD", qrcode.Medium},
+		{"otpauth://totp/Example:alice@example.org?secret=JBSWY3DPEHPK3PXP&issuer=Example", qrcode.Medium},
+		{"otpauth-migration://offline?data=" + strings.Repeat("CjEKCkhlbGxvId6tvu8SGGFsaWNlQGV4YW1wbGUub3JnGgdFeGFtcGxl", 4), qrcode.High},
+		{strings.Repeat("gosecret ", 80), qrcode.Low},
+	} {
+		png, err := qrcode.Encode(tc.content, tc.level, 512)
+		require.NoError(t, err)
+		img, err := decodePNG(png)
+		require.NoError(t, err)
+
+		got, err := Decode(img)
+		require.NoError(t, err, tc.content)
+		assert.Equal(t, tc.content, got)
+
+		q, err := qrcode.New(tc.content, tc.level)
+		require.NoError(t, err)
+		got, err = Decode(render(q.Bitmap(), 3, true))
+		require.NoError(t, err, tc.content)
+		assert.Equal(t, tc.content, got, "rotated")
+	}
+}
+
+func TestDecodeCorrectsErrors(t *testing.T) {
+	content := "otpauth://totp/test?secret=JBSWY3DPEHPK3PXP"
+	q, err := qrcode.New(content, qrcode.High)
+	require.NoError(t, err)
+	bm := q.Bitmap()
+
+	// flip a few modules in the data area, away from the finder patterns
+	siz := len(bm)
+	for i := 0; i < 6; i++ {
+		x, y := siz-6-i, siz-6-2*i
+		bm[y][x] = !bm[y][x]
+	}
+
+	got, err := Decode(render(bm, 4, false))
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+}
+
+func TestDecodeFile(t *testing.T) {
+	td, err := os.MkdirTemp("", "gosecret-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(td)
+	}()
+
+	fn := filepath.Join(td, "qr.png")
+	require.NoError(t, qrcode.WriteFile("foo", qrcode.Medium, 256, fn))
+	got, err := DecodeFile(fn)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", got)
+
+	_, err = DecodeFile(filepath.Join(td, "nope.png"))
+	assert.Error(t, err)
+
+	blank := filepath.Join(td, "blank.png")
+	fh, err := os.Create(blank)
+	require.NoError(t, err)
+	require.NoError(t, png.Encode(fh, image.NewGray(image.Rect(0, 0, 64, 64))))
+	require.NoError(t, fh.Close())
+	_, err = DecodeFile(blank)
+	assert.Error(t, err)
+}
+
+func decodePNG(buf []byte) (image.Image, error) {
+	return png.Decode(bytes.NewReader(buf))
+}
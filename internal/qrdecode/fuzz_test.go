@@ -0,0 +1,46 @@
+package qrdecode
+
+import (
+	"image"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// FuzzDecode feeds arbitrary images to Decode. The input is the width of the
+// image minus one followed by its gray pixels. Decode must never panic, no
+// matter how broken the image is.
+func FuzzDecode(f *testing.F) {
+	for _, content := range []string{
+		"12345",
+		"HELLO WORLD",
+		"otpauth://totp/test?secret=JBSWY3DPEHPK3PXP",
+	} {
+		q, err := qrcode.New(content, qrcode.Medium)
+		if err != nil {
+			f.Fatal(err)
+		}
+		img := render(q.Bitmap(), 1, false).(*image.Gray)
+		if got, err := Decode(img); err != nil || got != content {
+			f.Fatalf("seed %q does not decode: %q, %v", content, got, err)
+		}
+		f.Add(append([]byte{byte(img.Rect.Dx() - 1)}, img.Pix...))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) < 2 {
+			return
+		}
+		w := int(data[0]) + 1
+		h := (len(data) - 1) / w
+		if h < 1 {
+			return
+		}
+		img := &image.Gray{
+			Pix:    data[1 : 1+w*h],
+			Stride: w,
+			Rect:   image.Rect(0, 0, w, h),
+		}
+		_, _ = Decode(img)
+	})
+}
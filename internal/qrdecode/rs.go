@@ -0,0 +1,124 @@
+package qrdecode
+
+import (
+	"fmt"
+
+	"rsc.io/qr/coding"
+)
+
+// correct fixes errors in a Reed-Solomon block in place. block holds the data
+// bytes followed by ne check bytes. The generator polynomial has the roots
+// α^0 … α^(ne-1), as used by QR codes.
+func correct(block []byte, ne int) error {
+	f := coding.Field
+	n := len(block)
+
+	// syndromes, S_j = block(α^j)
+	synd := make([]byte, ne)
+	clean := true
+	for j := range synd {
+		var s byte
+		for k, c := range block {
+			s ^= f.Mul(c, f.Exp(j*(n-1-k)))
+		}
+		synd[j] = s
+		if s != 0 {
+			clean = false
+		}
+	}
+	if clean {
+		return nil
+	}
+
+	// Berlekamp-Massey to find the error locator polynomial. Polynomials
+	// are stored with the lowest degree first.
+	loc := []byte{1}
+	prev := []byte{1}
+	l, m, b := 0, 1, byte(1)
+	for i := 0; i < ne; i++ {
+		d := synd[i]
+		for j := 1; j <= l && j < len(loc); j++ {
+			d ^= f.Mul(loc[j], synd[i-j])
+		}
+		if d == 0 {
+			m++
+			continue
+		}
+
+		coef := f.Mul(d, f.Inv(b))
+		next := make([]byte, maxInt(len(loc), len(prev)+m))
+		copy(next, loc)
+		for j, c := range prev {
+			next[j+m] ^= f.Mul(coef, c)
+		}
+
+		if 2*l <= i {
+			prev = loc
+			l = i + 1 - l
+			b = d
+			m = 1
+		} else {
+			m++
+		}
+		loc = next
+	}
+	if 2*l > ne {
+		return fmt.Errorf("too many errors")
+	}
+
+	// Chien search for the error positions. An error at position k
+	// corresponds to the locator X = α^(n-1-k) and a root at X^-1.
+	var pos []int
+	for k := 0; k < n; k++ {
+		xinv := f.Exp(255 - (n-1-k)%255)
+		if eval(loc, xinv) == 0 {
+			pos = append(pos, k)
+		}
+	}
+	if len(pos) != l {
+		return fmt.Errorf("too many errors")
+	}
+
+	// error evaluator Ω(x) = S(x)Λ(x) mod x^ne
+	omega := make([]byte, ne)
+	for i := range omega {
+		for j := 0; j <= i && j < len(loc); j++ {
+			omega[i] ^= f.Mul(loc[j], synd[i-j])
+		}
+	}
+
+	// formal derivative of Λ, only the odd terms remain in GF(2^8)
+	deriv := make([]byte, len(loc))
+	for j := 1; j < len(loc); j += 2 {
+		deriv[j-1] = loc[j]
+	}
+
+	// Forney, e_k = X_k * Ω(X_k^-1) / Λ'(X_k^-1)
+	for _, k := range pos {
+		x := f.Exp(n - 1 - k)
+		xinv := f.Inv(x)
+		den := eval(deriv, xinv)
+		if den == 0 {
+			return fmt.Errorf("failed to correct errors")
+		}
+		block[k] ^= f.Mul(x, f.Mul(eval(omega, xinv), f.Inv(den)))
+	}
+	return nil
+}
+
+// eval evaluates the polynomial p (lowest degree first) at x
+func eval(p []byte, x byte) byte {
+	f := coding.Field
+	var y byte
+	for i := len(p) - 1; i >= 0; i-- {
+		y = f.Mul(y, x) ^ p[i]
+	}
+	return y
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,158 @@
+package qrdecode
+
+import (
+	"fmt"
+	"strings"
+)
+
+const alphanumeric = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// bitReader reads big endian bit fields from the data bytes
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) remaining() int {
+	return len(r.data)*8 - r.pos
+}
+
+func (r *bitReader) read(n int) (int, error) {
+	if n > r.remaining() {
+		return 0, fmt.Errorf("unexpected end of data")
+	}
+	v := 0
+	for i := 0; i < n; i++ {
+		bit := r.data[r.pos/8] >> (7 - r.pos%8) & 1
+		v = v<<1 | int(bit)
+		r.pos++
+	}
+	return v, nil
+}
+
+// parseSegments decodes the data segments of a QR code of the given version.
+// Kanji segments are not supported.
+func parseSegments(data []byte, v int) (string, error) {
+	class := 0
+	switch {
+	case v >= 27:
+		class = 2
+	case v >= 10:
+		class = 1
+	}
+
+	r := &bitReader{data: data}
+	var sb strings.Builder
+	for r.remaining() >= 4 {
+		mode, _ := r.read(4)
+		var err error
+		switch mode {
+		case 0: // terminator
+			return sb.String(), nil
+		case 1:
+			err = readNumeric(r, &sb, [3]int{10, 12, 14}[class])
+		case 2:
+			err = readAlphanumeric(r, &sb, [3]int{9, 11, 13}[class])
+		case 4:
+			err = readBytes(r, &sb, [3]int{8, 16, 16}[class])
+		case 3: // structured append header
+			_, err = r.read(16)
+		case 5: // FNC1 in first position
+		case 9: // FNC1 in second position
+			_, err = r.read(8)
+		case 7:
+			err = skipECI(r)
+		default:
+			return "", fmt.Errorf("unsupported segment mode %d", mode)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return sb.String(), nil
+}
+
+func readNumeric(r *bitReader, sb *strings.Builder, countBits int) error {
+	n, err := r.read(countBits)
+	if err != nil {
+		return err
+	}
+	for n > 0 {
+		digits, bits := 3, 10
+		switch n {
+		case 2:
+			digits, bits = 2, 7
+		case 1:
+			digits, bits = 1, 4
+		}
+		v, err := r.read(bits)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(sb, "%0*d", digits, v)
+		n -= digits
+	}
+	return nil
+}
+
+func readAlphanumeric(r *bitReader, sb *strings.Builder, countBits int) error {
+	n, err := r.read(countBits)
+	if err != nil {
+		return err
+	}
+	for ; n >= 2; n -= 2 {
+		v, err := r.read(11)
+		if err != nil {
+			return err
+		}
+		if v/45 >= len(alphanumeric) {
+			return fmt.Errorf("invalid alphanumeric value %d", v)
+		}
+		sb.WriteByte(alphanumeric[v/45])
+		sb.WriteByte(alphanumeric[v%45])
+	}
+	if n == 1 {
+		v, err := r.read(6)
+		if err != nil {
+			return err
+		}
+		if v >= len(alphanumeric) {
+			return fmt.Errorf("invalid alphanumeric value %d", v)
+		}
+		sb.WriteByte(alphanumeric[v])
+	}
+	return nil
+}
+
+func readBytes(r *bitReader, sb *strings.Builder, countBits int) error {
+	n, err := r.read(countBits)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		v, err := r.read(8)
+		if err != nil {
+			return err
+		}
+		sb.WriteByte(byte(v))
+	}
+	return nil
+}
+
+// skipECI skips an extended channel interpretation header. We always assume
+// UTF-8 which is what otpauth URLs use.
+func skipECI(r *bitReader) error {
+	v, err := r.read(8)
+	if err != nil {
+		return err
+	}
+	switch {
+	case v&0x80 == 0:
+		return nil
+	case v&0xc0 == 0x80:
+		_, err = r.read(8)
+	default:
+		_, err = r.read(16)
+	}
+	return err
+}
@@ -1,18 +1,22 @@
-// Package queue implements an experimental background queue for cleanup jobs.
-// Beware: It's likely broken.
-// We can easily close a channel which might later be written to.
-// The current locking is but a poor workaround.
-// A better implementation would create a queue object in main, pass
-// it through and wait for the channel to be empty before leaving main.
-// Will do that later.
+// Package queue implements a background queue for tasks that don't have to
+// finish before a command returns, e.g. pushing to a remote. The queue is
+// created in main and closed before main returns. Jobs of the same kind for
+// the same argument are coalesced, failed jobs are retried with a backoff
+// and all failures are reported when the queue is closed. A durable queue
+// persists the jobs that could not be completed and replays them on the
+// next invocation.
 package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/itsonlycode/gosecret/pkg/debug"
+
+	"github.com/hashicorp/go-multierror"
 )
 
 type contextKey int
@@ -21,11 +25,20 @@ const (
 	ctxKeyQueue contextKey = iota
 )
 
+const (
+	// DefaultAttempts is the number of times a job is run before it fails
+	DefaultAttempts = 3
+	// DefaultBackoff is the delay before the first retry. It doubles with
+	// every retry.
+	DefaultBackoff = time.Second
+)
+
 // Queuer is a queue interface
 type Queuer interface {
 	Add(Task) Task
-	Wait(context.Context) error
+	AddJob(Job) Task
 	Idle(time.Duration) error
+	Close(context.Context) error
 }
 
 // WithQueue adds the given queue to the context
@@ -49,9 +62,9 @@ func (n *noop) Add(t Task) Task {
 	return t
 }
 
-// Wait always returns nil
-func (n *noop) Wait(_ context.Context) error {
-	return nil
+// AddJob always returns the task of the job
+func (n *noop) AddJob(j Job) Task {
+	return j.Run
 }
 
 // Idle always returns nil
@@ -59,72 +72,250 @@ func (n *noop) Idle(_ time.Duration) error {
 	return nil
 }
 
+// Close always returns nil
+func (n *noop) Close(_ context.Context) error {
+	return nil
+}
+
 // Task is a background task
 type Task func(ctx context.Context) error
 
+func done(_ context.Context) error {
+	return nil
+}
+
+// Job is a task that can be coalesced and persisted. A job is only queued
+// once for each kind and argument. Run is used within the process, jobs
+// restored by a durable queue use the handler registered for their kind.
+// Jobs without a kind are neither coalesced nor persisted.
+type Job struct {
+	Kind string `json:"kind"`
+	Arg  string `json:"arg"`
+	Run  Task   `json:"-"`
+}
+
+func (j Job) String() string {
+	if j.Kind == "" {
+		return "task"
+	}
+	return j.Kind + " " + j.Arg
+}
+
+// same returns true if both jobs do the same
+func (j Job) same(other Job) bool {
+	return j.Kind != "" && j.Kind == other.Kind && j.Arg == other.Arg
+}
+
+type permanentError struct {
+	err error
+}
+
+func (p permanentError) Error() string {
+	return p.err.Error()
+}
+
+func (p permanentError) Unwrap() error {
+	return p.err
+}
+
+// Permanent marks an error of a task that should not be retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+type entry struct {
+	job      Job
+	attempts int
+}
+
+type failure struct {
+	job Job
+	err error
+}
+
 // Queue is a serialized background processing unit
 type Queue struct {
-	work chan Task
-	done chan struct{}
+	sync.Mutex
+	cond *sync.Cond
+
+	pending []*entry
+	running bool
+	closed  bool
+	failed  []failure
+	// reported is set once Close returned the failures
+	reported bool
+	done     chan struct{}
+
+	// file persists jobs of a durable queue
+	file string
+	// restored are the jobs loaded from file
+	restored map[string]bool
+	attempts int
+	backoff  time.Duration
 }
 
-// New creates a new queue
+// New creates a new queue and starts processing its tasks
 func New(ctx context.Context) *Queue {
 	q := &Queue{
-		work: make(chan Task, 1024),
-		done: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		attempts: DefaultAttempts,
+		backoff:  DefaultBackoff,
 	}
+	q.cond = sync.NewCond(q)
 	go q.run(ctx)
 	return q
 }
 
 func (q *Queue) run(ctx context.Context) {
-	for t := range q.work {
-		if err := t(ctx); err != nil {
-			debug.Log("Task failed: %s", err)
+	defer close(q.done)
+
+	for {
+		q.Lock()
+		for len(q.pending) < 1 && !q.closed {
+			q.cond.Wait()
 		}
-		debug.Log("Task done")
+		if len(q.pending) < 1 {
+			q.Unlock()
+			debug.Log("all tasks done")
+			return
+		}
+		e := q.pending[0]
+		q.pending = q.pending[1:]
+		q.running = true
+		q.Unlock()
+
+		err := q.try(ctx, e)
+
+		q.Lock()
+		q.running = false
+		if err != nil {
+			debug.Log("%s failed: %s", e.job, err)
+			q.failed = append(q.failed, failure{job: e.job, err: err})
+		}
+		q.cond.Broadcast()
+		q.Unlock()
 	}
-	debug.Log("all tasks done")
-	q.done <- struct{}{}
 }
 
-// Add enqueues a new task
+// try runs the job until it succeeds, fails permanently or runs out of
+// attempts
+func (q *Queue) try(ctx context.Context, e *entry) error {
+	delay := q.backoff
+	for {
+		e.attempts++
+		err := e.job.Run(ctx)
+		if err == nil {
+			debug.Log("%s done", e.job)
+			return nil
+		}
+		var pe permanentError
+		if errors.As(err, &pe) || e.attempts >= q.attempts || ctx.Err() != nil {
+			return err
+		}
+
+		debug.Log("%s failed (attempt %d of %d), retrying in %s: %s", e.job, e.attempts, q.attempts, delay, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// Add enqueues a new task. The returned task must be run by the caller, it
+// does nothing unless the queue is closed already.
 func (q *Queue) Add(t Task) Task {
-	q.work <- t
-	debug.Log("enqueued task")
-	return func(_ context.Context) error { return nil }
+	return q.AddJob(Job{Run: t})
+}
+
+// AddJob enqueues a new job unless the same job is waiting to be run
+// already. The returned task must be run by the caller, it only runs the
+// job if the queue is closed already.
+func (q *Queue) AddJob(j Job) Task {
+	return q.add(&entry{job: j})
+}
+
+func (q *Queue) add(e *entry) Task {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.closed {
+		debug.Log("queue closed, running %s inline", e.job)
+		return e.job.Run
+	}
+	for _, p := range q.pending {
+		if p.job.same(e.job) {
+			debug.Log("%s is queued already", e.job)
+			return done
+		}
+	}
+	q.pending = append(q.pending, e)
+	q.cond.Broadcast()
+	debug.Log("enqueued %s", e.job)
+	return done
 }
 
-// Idle returns nil the next time the queue is empty
+// Idle returns nil once all tasks are processed
 func (q *Queue) Idle(maxWait time.Duration) error {
-	done := make(chan struct{})
-	go func() {
-		for {
-			if len(q.work) < 1 {
-				done <- struct{}{}
-			}
-			time.Sleep(20 * time.Millisecond)
+	deadline := time.Now().Add(maxWait)
+	for {
+		q.Lock()
+		idle := len(q.pending) < 1 && !q.running
+		q.Unlock()
+		if idle {
+			return nil
 		}
-	}()
-	select {
-	case <-done:
-		return nil
-	case <-time.After(maxWait):
-		return fmt.Errorf("timed out waiting for empty queue")
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for empty queue")
+		}
+		time.Sleep(20 * time.Millisecond)
 	}
 }
 
-// Wait waits for all tasks to be processed. Must only be called once on
-// shutdown.
-// TODO: This should be called Close
-func (q *Queue) Wait(ctx context.Context) error {
-	close(q.work)
+// Close waits for all tasks to be processed and returns the errors of the
+// tasks that failed. Tasks added later are not queued anymore, but run
+// by the caller. A durable queue persists failed and unfinished jobs.
+// Failures are only returned by the first call.
+func (q *Queue) Close(ctx context.Context) error {
+	q.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.Unlock()
+
 	select {
 	case <-q.done:
-		return nil
 	case <-ctx.Done():
 		debug.Log("context canceled")
-		return ctx.Err()
 	}
+
+	q.Lock()
+	defer q.Unlock()
+
+	if q.reported {
+		return nil
+	}
+	q.reported = true
+
+	var result *multierror.Error
+	kept := q.persist(ctx)
+	for _, f := range q.failed {
+		if kept[f.job.String()] {
+			continue
+		}
+		result = multierror.Append(result, fmt.Errorf("%s: %w", f.job, f.err))
+	}
+	var lost int
+	for _, e := range q.pending {
+		if !kept[e.job.String()] {
+			lost++
+		}
+	}
+	if lost > 0 {
+		result = multierror.Append(result, fmt.Errorf("%d tasks were not run: %w", lost, ctx.Err()))
+	}
+	return result.ErrorOrNil()
 }
@@ -0,0 +1,193 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counter counts the runs of a task and fails the first fails runs
+type counter struct {
+	sync.Mutex
+	runs  int
+	fails int
+	err   error
+}
+
+func (c *counter) run(ctx context.Context) error {
+	c.Lock()
+	defer c.Unlock()
+
+	c.runs++
+	if c.runs <= c.fails {
+		return c.err
+	}
+	return nil
+}
+
+func (c *counter) count() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.runs
+}
+
+func newTestQueue(ctx context.Context) *Queue {
+	q := New(ctx)
+	q.backoff = time.Millisecond
+	return q
+}
+
+func TestNoop(t *testing.T) {
+	ctx := context.Background()
+	q := GetQueue(ctx)
+
+	c := &counter{}
+	require.NoError(t, q.Add(c.run)(ctx))
+	require.NoError(t, q.AddJob(Job{Kind: "push", Arg: "foo", Run: c.run})(ctx))
+	assert.Equal(t, 2, c.count())
+	assert.NoError(t, q.Idle(time.Second))
+	assert.NoError(t, q.Close(ctx))
+}
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+	q := newTestQueue(ctx)
+	ctx = WithQueue(ctx, q)
+	assert.Equal(t, q, GetQueue(ctx))
+
+	// block the queue until all jobs are added
+	block := make(chan struct{})
+	q.Add(func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+
+	foo, bar, task := &counter{}, &counter{}, &counter{}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, q.AddJob(Job{Kind: "push", Arg: "foo", Run: foo.run})(ctx))
+		require.NoError(t, q.AddJob(Job{Kind: "push", Arg: "bar", Run: bar.run})(ctx))
+		require.NoError(t, q.Add(task.run)(ctx))
+	}
+	assert.Equal(t, 0, foo.count())
+	close(block)
+
+	require.NoError(t, q.Idle(time.Second))
+	assert.Equal(t, 1, foo.count())
+	assert.Equal(t, 1, bar.count())
+	assert.Equal(t, 3, task.count())
+
+	// jobs are queued again once they ran
+	q.AddJob(Job{Kind: "push", Arg: "foo", Run: foo.run})
+	require.NoError(t, q.Close(ctx))
+	assert.Equal(t, 2, foo.count())
+
+	// the queue is closed, tasks run inline
+	require.NoError(t, q.AddJob(Job{Kind: "push", Arg: "foo", Run: foo.run})(ctx))
+	assert.Equal(t, 3, foo.count())
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	q := newTestQueue(ctx)
+	errOffline := errors.New("offline")
+
+	flaky := &counter{fails: 2, err: errOffline}
+	broken := &counter{fails: 10, err: errOffline}
+	permanent := &counter{fails: 10, err: Permanent(errOffline)}
+	q.AddJob(Job{Kind: "push", Arg: "flaky", Run: flaky.run})
+	q.AddJob(Job{Kind: "push", Arg: "broken", Run: broken.run})
+	q.AddJob(Job{Kind: "push", Arg: "permanent", Run: permanent.run})
+
+	err := q.Close(ctx)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, errOffline))
+	assert.Contains(t, err.Error(), "push broken")
+	assert.Contains(t, err.Error(), "push permanent")
+	assert.NotContains(t, err.Error(), "push flaky")
+	assert.Equal(t, 3, flaky.count())
+	assert.Equal(t, DefaultAttempts, broken.count())
+	assert.Equal(t, 1, permanent.count())
+
+	// failures are only reported once
+	assert.NoError(t, q.Close(ctx))
+}
+
+func TestDurable(t *testing.T) {
+	ctx := context.Background()
+	fn := filepath.Join(t.TempDir(), "queue", "queue.json")
+
+	var mu sync.Mutex
+	var replayed []string
+	online := false
+	RegisterHandler("test-push", func(ctx context.Context, arg string) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		replayed = append(replayed, arg)
+		if !online {
+			return errors.New("offline")
+		}
+		return nil
+	})
+
+	q := NewDurable(ctx, fn)
+	q.backoff = time.Millisecond
+	offline := &counter{fails: 10, err: errors.New("offline")}
+	q.AddJob(Job{Kind: "test-push", Arg: "foo", Run: offline.run})
+	q.AddJob(Job{Kind: "unknown", Arg: "bar", Run: offline.run})
+	q.Add(offline.run)
+	err := q.Close(ctx)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "test-push foo")
+	assert.Contains(t, err.Error(), "unknown bar")
+	assert.FileExists(t, fn)
+
+	// still offline, the job is only tried once and kept
+	q = NewDurable(ctx, fn)
+	assert.NoError(t, q.Close(ctx))
+	assert.Equal(t, []string{"foo"}, replayed)
+	assert.FileExists(t, fn)
+
+	online = true
+	q = NewDurable(ctx, fn)
+	assert.NoError(t, q.Close(ctx))
+	assert.Equal(t, []string{"foo", "foo"}, replayed)
+	_, err = os.Stat(fn)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestDurableMerge(t *testing.T) {
+	ctx := context.Background()
+	fn := filepath.Join(t.TempDir(), "queue.json")
+
+	RegisterHandler("test-merge", func(ctx context.Context, arg string) error {
+		return nil
+	})
+	require.NoError(t, writeJobs(fn, []Job{{Kind: "test-merge", Arg: "restored"}}))
+
+	q := NewDurable(ctx, fn)
+	q.backoff = time.Millisecond
+	offline := &counter{fails: 10, err: errors.New("offline")}
+	q.AddJob(Job{Kind: "test-merge", Arg: "mine", Run: offline.run})
+
+	// another process persists its jobs while this one is running
+	require.NoError(t, writeJobs(fn, []Job{
+		{Kind: "test-merge", Arg: "restored"},
+		{Kind: "test-merge", Arg: "other"},
+	}))
+	assert.NoError(t, q.Close(ctx))
+
+	var args []string
+	for _, j := range loadJobs(fn) {
+		args = append(args, j.Arg)
+	}
+	assert.ElementsMatch(t, []string{"mine", "other"}, args)
+}
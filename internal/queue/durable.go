@@ -0,0 +1,187 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+)
+
+// Handler runs a job restored by a durable queue
+type Handler func(ctx context.Context, arg string) error
+
+var (
+	handlers   = map[string]Handler{}
+	handlersMu sync.Mutex
+)
+
+// RegisterHandler registers the handler for restored jobs of the given
+// kind. Jobs of kinds without a handler are never persisted.
+func RegisterHandler(kind string, h Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+
+	handlers[kind] = h
+}
+
+func handler(kind string) (Handler, bool) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+
+	h, found := handlers[kind]
+	return h, found
+}
+
+// NewDurable creates a new queue that persists the jobs that failed or were
+// not run to file when it's closed. The jobs persisted by an earlier
+// invocation are queued again right away, but they are only tried once.
+// The file is shared by all processes of the user, so it's only accessed
+// with a lock held.
+func NewDurable(ctx context.Context, file string) *Queue {
+	q := New(ctx)
+
+	unlock := lockJobs(file)
+	jobs := loadJobs(file)
+	unlock()
+
+	q.Lock()
+	q.file = file
+	q.restored = make(map[string]bool, len(jobs))
+	for _, j := range jobs {
+		q.restored[j.String()] = true
+	}
+	q.Unlock()
+
+	for _, j := range jobs {
+		h, found := handler(j.Kind)
+		if !found {
+			debug.Log("no handler for %s, dropping it", j)
+			continue
+		}
+		arg := j.Arg
+		j.Run = func(ctx context.Context) error {
+			return h(ctx, arg)
+		}
+		debug.Log("restoring %s", j)
+		q.add(&entry{job: j, attempts: q.attempts - 1})
+	}
+	return q
+}
+
+func loadJobs(file string) []Job {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			debug.Log("failed to read %s: %s", file, err)
+		}
+		return nil
+	}
+	var jobs []Job
+	if err := json.Unmarshal(buf, &jobs); err != nil {
+		debug.Log("failed to decode %s: %s", file, err)
+		return nil
+	}
+	return jobs
+}
+
+// lockJobs takes an exclusive lock of the jobs file. The returned function
+// releases it. Without a lock the file is used anyway, like before there
+// were concurrent processes.
+func lockJobs(file string) func() {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		debug.Log("failed to create the dir of %s: %s", file, err)
+		return func() {}
+	}
+	fh, err := os.OpenFile(file+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		debug.Log("failed to open the lock of %s: %s", file, err)
+		return func() {}
+	}
+	if err := lockFile(fh); err != nil {
+		debug.Log("failed to lock %s: %s", file, err)
+		_ = fh.Close()
+		return func() {}
+	}
+	return func() {
+		if err := unlockFile(fh); err != nil {
+			debug.Log("failed to unlock %s: %s", file, err)
+		}
+		_ = fh.Close()
+	}
+}
+
+// persist writes the failed and pending jobs of a durable queue to its
+// file. Jobs that other processes added to the file in the meantime are
+// kept. It returns the jobs of this queue that were persisted. Must be
+// called with the lock held.
+func (q *Queue) persist(ctx context.Context) map[string]bool {
+	if q.file == "" {
+		return nil
+	}
+
+	unlock := lockJobs(q.file)
+	defer unlock()
+
+	kept := make(map[string]bool)
+	var jobs []Job
+	keep := func(j Job) {
+		if j.Kind == "" || kept[j.String()] {
+			return
+		}
+		if _, found := handler(j.Kind); !found {
+			return
+		}
+		kept[j.String()] = true
+		jobs = append(jobs, j)
+	}
+	for _, f := range q.failed {
+		keep(f.job)
+	}
+	for _, e := range q.pending {
+		keep(e.job)
+	}
+	// the jobs this queue restored are done unless they were kept above
+	others := map[string]bool{}
+	for _, j := range loadJobs(q.file) {
+		if q.restored[j.String()] || kept[j.String()] || others[j.String()] {
+			continue
+		}
+		others[j.String()] = true
+		jobs = append(jobs, j)
+	}
+
+	if len(jobs) < 1 {
+		if err := os.Remove(q.file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			debug.Log("failed to remove %s: %s", q.file, err)
+		}
+		return kept
+	}
+
+	if err := writeJobs(q.file, jobs); err != nil {
+		debug.Log("failed to persist %d jobs to %s: %s", len(jobs), q.file, err)
+		return nil
+	}
+	for _, f := range q.failed {
+		if kept[f.job.String()] {
+			out.Warningf(ctx, "%s failed, it will be retried on the next run: %s", f.job, f.err)
+		}
+	}
+	debug.Log("persisted %d jobs (%d of other processes) to %s", len(jobs), len(others), q.file)
+	return kept
+}
+
+func writeJobs(file string, jobs []Job) error {
+	buf, err := json.Marshal(jobs)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(file, buf, 0600)
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package queue
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock of the file. It blocks until the lock
+// is available.
+func lockFile(fh *os.File) error {
+	return syscall.Flock(int(fh.Fd()), syscall.LOCK_EX)
+}
+
+func unlockFile(fh *os.File) error {
+	return syscall.Flock(int(fh.Fd()), syscall.LOCK_UN)
+}
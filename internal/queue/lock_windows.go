@@ -0,0 +1,22 @@
+//go:build windows
+// +build windows
+
+package queue
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile takes an exclusive lock of the file. It blocks until the lock
+// is available.
+func lockFile(fh *os.File) error {
+	ol := new(windows.Overlapped)
+	return windows.LockFileEx(windows.Handle(fh.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, ol)
+}
+
+func unlockFile(fh *os.File) error {
+	ol := new(windows.Overlapped)
+	return windows.UnlockFileEx(windows.Handle(fh.Fd()), 0, 1, 0, ol)
+}
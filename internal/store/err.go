@@ -21,6 +21,8 @@ var (
 	ErrGitNoRemote = fmt.Errorf("git has no remote origin")
 	// ErrGitNothingToCommit is returned if there are no staged changes
 	ErrGitNothingToCommit = fmt.Errorf("git has nothing to commit")
+	// ErrGitUnverified is returned if pulled commits are not signed by an allowed key
+	ErrGitUnverified = fmt.Errorf("commits are not signed by an allowed key")
 	// ErrEmptySecret is returned if a secret exists but has no content
 	ErrEmptySecret = fmt.Errorf("empty secret")
 	// ErrNoBody is returned if a secret exists but has no content beyond a password
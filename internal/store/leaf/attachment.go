@@ -0,0 +1,275 @@
+package leaf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+	"github.com/itsonlycode/gosecret/pkg/gosecret"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+)
+
+const (
+	// AttachmentKey is the key of a secret that references its attachments.
+	// It has one value per attachment.
+	AttachmentKey = "attachment"
+
+	attachmentDirSuffix = ".attachments"
+	attachmentManifest  = "manifest"
+)
+
+// Attachments returns the names of the attachments referenced by a secret
+func Attachments(sec gosecret.Secret) []string {
+	atts, _ := sec.Values(AttachmentKey)
+	return atts
+}
+
+func hasAttachment(sec gosecret.Secret, att string) bool {
+	for _, a := range Attachments(sec) {
+		if a == att {
+			return true
+		}
+	}
+	return false
+}
+
+func validAttachmentName(att string) error {
+	if att == "" || strings.HasPrefix(att, ".") || strings.ContainsAny(att, "/\\\n") {
+		return fmt.Errorf("invalid attachment name %q", att)
+	}
+	return nil
+}
+
+// attachmentsDir returns the directory that holds all attachments of a
+// secret. Like the chunk directory it's never listed as a secret.
+func (s *Store) attachmentsDir(name string) string {
+	return strings.TrimPrefix(name+attachmentDirSuffix, "/")
+}
+
+// attachmentDir holds the manifest and the chunks of one attachment
+func (s *Store) attachmentDir(name, att string) string {
+	return path.Join(s.attachmentsDir(name), att)
+}
+
+// ListAttachments returns the names of the attachments of the secret name
+func (s *Store) ListAttachments(ctx context.Context, name string) ([]string, error) {
+	sec, err := s.getParent(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return Attachments(sec), nil
+}
+
+// SetAttachment stores the content of r as the attachment att of the secret
+// name, replacing an existing attachment of the same name. The secret must
+// exist. The attachment is encrypted in chunks, just like a large binary
+// secret, and referenced from the secret.
+func (s *Store) SetAttachment(ctx context.Context, name, att string, r io.Reader) (*Manifest, error) {
+	release, err := s.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := validAttachmentName(att); err != nil {
+		return nil, err
+	}
+	sec, err := s.getParent(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	recipients, err := s.recipientsFor(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := s.attachmentDir(name, att)
+	var old *Manifest
+	if hasAttachment(sec, att) {
+		old, _ = s.attachmentManifest(ctx, dir)
+	}
+
+	m, err := s.writeChunks(ctx, dir, att, r, old, recipients)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setAttachmentManifest(ctx, dir, m, recipients); err != nil {
+		return nil, err
+	}
+
+	if !hasAttachment(sec, att) {
+		if err := sec.Add(AttachmentKey, att); err != nil {
+			return nil, fmt.Errorf("failed to reference attachment: %w", err)
+		}
+		// writing the secret adds the attachment to git, too
+		return m, s.Set(ctx, name, sec)
+	}
+
+	if IsNoGitOps(ctx) || !ctxutil.IsGitCommit(ctx) {
+		return m, nil
+	}
+	return m, s.gitCommitAndPush(ctx, name)
+}
+
+// GetAttachment writes the content of the attachment att of the secret name
+// to w. Like GetBinary it holds only one chunk in memory at a time.
+func (s *Store) GetAttachment(ctx context.Context, name, att string, w io.Writer) (*Manifest, error) {
+	sec, err := s.getParent(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !hasAttachment(sec, att) {
+		return nil, fmt.Errorf("%s has no attachment %q: %w", name, att, store.ErrNotFound)
+	}
+
+	dir := s.attachmentDir(name, att)
+	m, err := s.attachmentManifest(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.readChunks(ctx, dir, m, w); err != nil {
+		return nil, fmt.Errorf("failed to read attachment %q of %s: %w", att, name, err)
+	}
+	return m, nil
+}
+
+// AttachmentManifest returns the manifest of an attachment without reading
+// its content
+func (s *Store) AttachmentManifest(ctx context.Context, name, att string) (*Manifest, error) {
+	sec, err := s.getParent(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !hasAttachment(sec, att) {
+		return nil, fmt.Errorf("%s has no attachment %q: %w", name, att, store.ErrNotFound)
+	}
+	return s.attachmentManifest(ctx, s.attachmentDir(name, att))
+}
+
+// RemoveAttachment removes the attachment att from the secret name
+func (s *Store) RemoveAttachment(ctx context.Context, name, att string) error {
+	release, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	sec, err := s.getParent(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !hasAttachment(sec, att) {
+		return fmt.Errorf("%s has no attachment %q: %w", name, att, store.ErrNotFound)
+	}
+
+	if err := s.pruneDir(ctx, s.attachmentDir(name, att)); err != nil {
+		return fmt.Errorf("failed to delete attachment %q: %w", att, err)
+	}
+
+	remaining := Attachments(sec)
+	sec.Del(AttachmentKey)
+	for _, a := range remaining {
+		if a == att {
+			continue
+		}
+		if err := sec.Add(AttachmentKey, a); err != nil {
+			return fmt.Errorf("failed to reference attachment: %w", err)
+		}
+	}
+	return s.Set(ctx, name, sec)
+}
+
+// CopyAttachments copies all attachments of the secret name to dstName in
+// the store dst, which may be this store. The destination secret must
+// exist already and reference the attachments, e.g. because it's a copy of
+// the source secret. The content is encrypted for the recipients of the
+// destination.
+func (s *Store) CopyAttachments(ctx context.Context, name string, dst *Store, dstName string) error {
+	sec, err := s.getParent(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	for _, att := range Attachments(sec) {
+		att := att
+		debug.Log("Copying attachment %q of %s to %s", att, name, dstName)
+		if err := pipe(func(w io.Writer) error {
+			_, err := s.GetAttachment(ctx, name, att, w)
+			return err
+		}, func(r io.Reader) error {
+			_, err := dst.SetAttachment(ctx, dstName, att, r)
+			return err
+		}); err != nil {
+			return fmt.Errorf("failed to copy attachment %q: %w", att, err)
+		}
+	}
+	return nil
+}
+
+// getParent returns the secret attachments belong to. It's always parsed
+// since attachments are referenced from its keys.
+func (s *Store) getParent(ctx context.Context, name string) (gosecret.Secret, error) {
+	if !s.Exists(ctx, name) {
+		return nil, store.ErrNotFound
+	}
+	return s.Get(ctxutil.WithShowParsing(ctx, true), name)
+}
+
+func (s *Store) attachmentManifest(ctx context.Context, dir string) (*Manifest, error) {
+	p := path.Join(dir, attachmentManifest)
+	ciphertext, err := s.storage.Get(ctx, p)
+	if err != nil {
+		debug.Log("attachment manifest %s not found: %s", p, err)
+		return nil, store.ErrNotFound
+	}
+	content, err := s.crypto.Decrypt(ctx, ciphertext)
+	if err != nil {
+		debug.Log("Failed to decrypt %s: %s", p, err)
+		return nil, store.ErrDecrypt
+	}
+	return ParseManifest(secrets.ParsePlain(content))
+}
+
+func (s *Store) setAttachmentManifest(ctx context.Context, dir string, m *Manifest, recipients []string) error {
+	p := path.Join(dir, attachmentManifest)
+	ciphertext, err := s.crypto.Encrypt(ctx, m.Secret().Bytes(), recipients)
+	if err != nil {
+		debug.Log("Failed to encrypt %s: %s", p, err)
+		return store.ErrEncrypt
+	}
+	if err := s.storage.Set(ctx, p, ciphertext); err != nil {
+		return fmt.Errorf("failed to write attachment manifest: %w", err)
+	}
+	if IsNoGitOps(ctx) {
+		return nil
+	}
+	if err := s.storage.Add(ctx, p); err != nil && !errors.Is(err, store.ErrGitNotInit) {
+		return fmt.Errorf("failed to add %q to git: %w", p, err)
+	}
+	return nil
+}
+
+// reencryptAttachments encrypts all attachments of a secret for the current
+// recipients
+func (s *Store) reencryptAttachments(ctx context.Context, name string, sec gosecret.Secret, recipients []string) error {
+	for _, att := range Attachments(sec) {
+		dir := s.attachmentDir(name, att)
+		m, err := s.attachmentManifest(ctx, dir)
+		if err != nil {
+			return fmt.Errorf("failed to read attachment %q: %w", att, err)
+		}
+		if err := s.reencryptDir(ctx, dir, m, recipients); err != nil {
+			return err
+		}
+		if err := s.setAttachmentManifest(ctx, dir, m, recipients); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package leaf
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+	ctx = WithChunkSize(ctx, 8)
+
+	tempdir, err := os.MkdirTemp("", "gosecret-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tempdir)
+	}()
+
+	s, err := createSubStore(tempdir)
+	require.NoError(t, err)
+
+	sec := secrets.NewKV()
+	require.NoError(t, sec.Set("user", "admin"))
+	require.NoError(t, s.Set(ctx, "certs/web", sec))
+
+	cert := []byte("-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n")
+	_, err = s.SetAttachment(ctx, "certs/missing", "cert.pem", bytes.NewReader(cert))
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.SetAttachment(ctx, "certs/web", "../cert.pem", bytes.NewReader(cert))
+	assert.Error(t, err)
+
+	m, err := s.SetAttachment(ctx, "certs/web", "cert.pem", bytes.NewReader(cert))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(cert)), m.Size)
+	_, err = s.SetAttachment(ctx, "certs/web", "key.pem", bytes.NewReader([]byte("key")))
+	require.NoError(t, err)
+
+	atts, err := s.ListAttachments(ctx, "certs/web")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cert.pem", "key.pem"}, atts)
+
+	// the secret keeps its content and attachments are not listed
+	sec2, err := s.Get(ctx, "certs/web")
+	require.NoError(t, err)
+	v, _ := sec2.Get("user")
+	assert.Equal(t, "admin", v)
+	list, err := s.List(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"baz/ing/a", "certs/web", "foo/bar/baz"}, list)
+
+	buf := &bytes.Buffer{}
+	_, err = s.GetAttachment(ctx, "certs/web", "cert.pem", buf)
+	require.NoError(t, err)
+	assert.Equal(t, cert, buf.Bytes())
+	_, err = s.GetAttachment(ctx, "certs/web", "nope", buf)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	// replacing an attachment doesn't add a second reference
+	_, err = s.SetAttachment(ctx, "certs/web", "key.pem", bytes.NewReader([]byte("new key")))
+	require.NoError(t, err)
+	atts, err = s.ListAttachments(ctx, "certs/web")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cert.pem", "key.pem"}, atts)
+
+	// copy and move carry the attachments, delete removes them
+	require.NoError(t, s.Copy(ctx, "certs/web", "certs/copy"))
+	require.NoError(t, s.Move(ctx, "certs/copy", "certs/moved"))
+	assert.False(t, s.storage.IsDir(ctx, s.attachmentsDir("certs/copy")))
+	buf.Reset()
+	_, err = s.GetAttachment(ctx, "certs/moved", "key.pem", buf)
+	require.NoError(t, err)
+	assert.Equal(t, "new key", buf.String())
+
+	require.NoError(t, s.RemoveAttachment(ctx, "certs/moved", "key.pem"))
+	atts, err = s.ListAttachments(ctx, "certs/moved")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cert.pem"}, atts)
+	assert.False(t, s.storage.IsDir(ctx, s.attachmentDir("certs/moved", "key.pem")))
+	assert.Error(t, s.RemoveAttachment(ctx, "certs/moved", "key.pem"))
+
+	require.NoError(t, s.Delete(ctx, "certs/moved"))
+	assert.False(t, s.storage.IsDir(ctx, s.attachmentsDir("certs/moved")))
+}
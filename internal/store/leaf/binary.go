@@ -0,0 +1,404 @@
+package leaf
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+	"github.com/itsonlycode/gosecret/pkg/gosecret"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+)
+
+const (
+	// ChunkedEncoding is the Content-Transfer-Encoding of the manifest of a
+	// binary secret that is stored in chunks
+	ChunkedEncoding = "chunked"
+	// DefaultChunkSize is the size of the plaintext of one chunk
+	DefaultChunkSize = 1 << 20
+
+	chunkDirSuffix = ".chunks"
+)
+
+// ErrNotChunked is returned if a secret is not the manifest of a chunked
+// binary secret
+var ErrNotChunked = errors.New("not a chunked binary secret")
+
+// Manifest describes a binary secret that is stored in chunks. The manifest
+// itself is stored as a regular secret. The chunks are encrypted one by one
+// and stored next to it, so large files never have to be held in memory and
+// a change only rewrites the chunks that actually changed.
+type Manifest struct {
+	Filename  string
+	Size      int64
+	ChunkSize int
+	// Sum is the hex encoded SHA256 of the whole content
+	Sum string
+	// Chunks contains the hex encoded SHA256 of each chunk
+	Chunks []string
+}
+
+// ParseManifest reads the manifest of a chunked binary secret
+func ParseManifest(sec gosecret.Secret) (*Manifest, error) {
+	kv, err := secrets.ParseKV(sec.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if cte, _ := kv.Get("Content-Transfer-Encoding"); cte != ChunkedEncoding {
+		return nil, ErrNotChunked
+	}
+
+	m := &Manifest{}
+	if cd, found := kv.Get("Content-Disposition"); found {
+		if i := strings.Index(cd, "filename="); i >= 0 {
+			m.Filename = strings.Trim(cd[i+len("filename="):], `"`)
+		}
+	}
+	size, _ := kv.Get("Content-Length")
+	if m.Size, err = strconv.ParseInt(size, 10, 64); err != nil || m.Size < 0 {
+		return nil, fmt.Errorf("invalid content length %q", size)
+	}
+	cs, _ := kv.Get("Chunk-Size")
+	if m.ChunkSize, err = strconv.Atoi(cs); err != nil || m.ChunkSize < 1 {
+		return nil, fmt.Errorf("invalid chunk size %q", cs)
+	}
+	m.Sum, _ = kv.Get("Sha256")
+	for _, line := range strings.Split(kv.Body(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			m.Chunks = append(m.Chunks, line)
+		}
+	}
+	if want := (m.Size + int64(m.ChunkSize) - 1) / int64(m.ChunkSize); int64(len(m.Chunks)) != want {
+		return nil, fmt.Errorf("manifest lists %d chunks, expected %d", len(m.Chunks), want)
+	}
+	return m, nil
+}
+
+// Secret returns the manifest as a secret
+func (m *Manifest) Secret() gosecret.Secret {
+	sec := secrets.NewKV()
+	_ = sec.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", m.Filename))
+	_ = sec.Set("Content-Transfer-Encoding", ChunkedEncoding)
+	_ = sec.Set("Content-Length", strconv.FormatInt(m.Size, 10))
+	_ = sec.Set("Chunk-Size", strconv.Itoa(m.ChunkSize))
+	_ = sec.Set("Sha256", m.Sum)
+	for _, sum := range m.Chunks {
+		_, _ = sec.Write([]byte(sum + "\n"))
+	}
+	return sec
+}
+
+// chunkDir returns the directory that holds the chunks of a binary secret.
+// It doesn't use the extension of the crypto backend so chunks are never
+// listed as secrets.
+func (s *Store) chunkDir(name string) string {
+	return strings.TrimPrefix(name+chunkDirSuffix, "/")
+}
+
+func chunkFile(dir string, i int) string {
+	return path.Join(dir, fmt.Sprintf("%06d", i))
+}
+
+// recipientsFor returns the recipients a secret and its chunks are encrypted
+// for
+func (s *Store) recipientsFor(ctx context.Context, name string) ([]string, error) {
+	recipients, err := s.useableKeys(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list useable keys for %q: %w", name, err)
+	}
+	// make sure the encryptor can decrypt later
+	return s.ensureOurKeyID(ctx, recipients), nil
+}
+
+// SetBinary reads r until EOF and stores it as a chunked binary secret.
+// Chunks that didn't change since the last version are not rewritten.
+// Returns the manifest of the new version.
+func (s *Store) SetBinary(ctx context.Context, name, filename string, r io.Reader) (*Manifest, error) {
+	release, err := s.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if strings.Contains(name, "//") {
+		return nil, fmt.Errorf("invalid secret name: %s", name)
+	}
+
+	recipients, err := s.recipientsFor(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var old *Manifest
+	var atts []string
+	if s.Exists(ctx, name) {
+		if sec, err := s.Get(ctxutil.WithShowParsing(ctx, true), name); err == nil {
+			old, _ = ParseManifest(sec)
+			atts = Attachments(sec)
+		}
+	}
+
+	m, err := s.writeChunks(ctx, s.chunkDir(name), filename, r, old, recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	// the manifest is written last, so readers never see a manifest that
+	// references chunks that were not written, yet
+	sec := m.Secret()
+	for _, att := range atts {
+		_ = sec.Add(AttachmentKey, att)
+	}
+	if err := s.Set(ctx, name, sec); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// writeChunks encrypts the content of r in chunks and writes them to dir.
+// Leftover chunks of the old version are removed.
+func (s *Store) writeChunks(ctx context.Context, dir, filename string, r io.Reader, old *Manifest, recipients []string) (*Manifest, error) {
+	size := GetChunkSize(ctx)
+	m := &Manifest{
+		Filename:  filepath.Base(filename),
+		ChunkSize: size,
+	}
+	total := sha256.New()
+	buf := make([]byte, size)
+	for i := 0; ; i++ {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if err := s.setChunk(ctx, dir, i, buf[:n], old, m, recipients); err != nil {
+				return nil, err
+			}
+			_, _ = total.Write(buf[:n])
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+	}
+	m.Sum = fmt.Sprintf("%x", total.Sum(nil))
+
+	// remove the leftovers of a larger version
+	if old != nil {
+		for i := len(m.Chunks); i < len(old.Chunks); i++ {
+			if err := s.storage.Delete(ctx, chunkFile(dir, i)); err != nil {
+				debug.Log("failed to remove old chunk %s: %s", chunkFile(dir, i), err)
+			}
+		}
+	}
+
+	if IsNoGitOps(ctx) || (len(m.Chunks) < 1 && (old == nil || len(old.Chunks) < 1)) {
+		return m, nil
+	}
+	if err := s.storage.Add(ctx, dir); err != nil && !errors.Is(err, store.ErrGitNotInit) {
+		return nil, fmt.Errorf("failed to add %q to git: %w", dir, err)
+	}
+	return m, nil
+}
+
+func (s *Store) setChunk(ctx context.Context, dir string, i int, chunk []byte, old, m *Manifest, recipients []string) error {
+	sum := fmt.Sprintf("%x", sha256.Sum256(chunk))
+	m.Chunks = append(m.Chunks, sum)
+	m.Size += int64(len(chunk))
+
+	// encryption is not deterministic, so rewriting an unchanged chunk would
+	// still add a new blob to the history
+	if old != nil && old.ChunkSize == m.ChunkSize && i < len(old.Chunks) && old.Chunks[i] == sum {
+		if cur, err := s.getChunk(ctx, dir, i); err == nil && fmt.Sprintf("%x", sha256.Sum256(cur)) == sum {
+			debug.Log("chunk %s is unchanged", chunkFile(dir, i))
+			return nil
+		}
+	}
+
+	ciphertext, err := s.crypto.Encrypt(ctx, chunk, recipients)
+	if err != nil {
+		debug.Log("Failed to encrypt chunk %s: %s", chunkFile(dir, i), err)
+		return store.ErrEncrypt
+	}
+	if err := s.storage.Set(ctx, chunkFile(dir, i), ciphertext); err != nil {
+		return fmt.Errorf("failed to write chunk %d: %w", i, err)
+	}
+	return nil
+}
+
+// GetBinary decrypts a chunked binary secret and writes its content to w.
+// Only one chunk is held in memory at a time. Every chunk is verified
+// before it is written, the checksum of the whole content when the last
+// chunk was written. Returns ErrNotChunked for any other secret.
+func (s *Store) GetBinary(ctx context.Context, name string, w io.Writer) (*Manifest, error) {
+	sec, err := s.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	m, err := ParseManifest(sec)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.readChunks(ctx, s.chunkDir(name), m, w); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return m, nil
+}
+
+// readChunks decrypts and verifies the chunks in dir and writes them to w
+func (s *Store) readChunks(ctx context.Context, dir string, m *Manifest, w io.Writer) error {
+	total := sha256.New()
+	var size int64
+	for i, want := range m.Chunks {
+		chunk, err := s.getChunk(ctx, dir, i)
+		if err != nil {
+			return err
+		}
+		if got := fmt.Sprintf("%x", sha256.Sum256(chunk)); got != want {
+			return fmt.Errorf("checksum mismatch in chunk %d (want: %s, got: %s)", i, want, got)
+		}
+		_, _ = total.Write(chunk)
+		size += int64(len(chunk))
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+
+	if size != m.Size {
+		return fmt.Errorf("size mismatch (want: %d, got: %d)", m.Size, size)
+	}
+	if got := fmt.Sprintf("%x", total.Sum(nil)); got != m.Sum {
+		return fmt.Errorf("checksum mismatch (want: %s, got: %s)", m.Sum, got)
+	}
+	return nil
+}
+
+func (s *Store) getChunk(ctx context.Context, dir string, i int) ([]byte, error) {
+	ciphertext, err := s.storage.Get(ctx, chunkFile(dir, i))
+	if err != nil {
+		debug.Log("chunk %s not found: %s", chunkFile(dir, i), err)
+		return nil, fmt.Errorf("chunk %d is missing: %w", i, store.ErrNotFound)
+	}
+	chunk, err := s.crypto.Decrypt(ctx, ciphertext)
+	if err != nil {
+		debug.Log("Failed to decrypt chunk %s: %s", chunkFile(dir, i), err)
+		return nil, store.ErrDecrypt
+	}
+	return chunk, nil
+}
+
+// CopyBinary copies the chunked binary secret name to dstName in the store
+// dst, which may be this store. The content is streamed and encrypted for
+// the recipients of the destination.
+func (s *Store) CopyBinary(ctx context.Context, name string, dst *Store, dstName string) error {
+	sec, err := s.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	m, err := ParseManifest(sec)
+	if err != nil {
+		return err
+	}
+
+	return pipe(func(w io.Writer) error {
+		_, err := s.GetBinary(ctx, name, w)
+		return err
+	}, func(r io.Reader) error {
+		_, err := dst.SetBinary(ctx, dstName, m.Filename, r)
+		return err
+	})
+}
+
+// pipe connects read and write, which run concurrently
+func pipe(read func(io.Writer) error, write func(io.Reader) error) error {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := read(pw)
+		_ = pw.CloseWithError(err)
+		done <- err
+	}()
+
+	err := write(pr)
+	// unblock the reader if the writer gave up early
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+	if rerr := <-done; rerr != nil && !errors.Is(rerr, io.ErrClosedPipe) {
+		return rerr
+	}
+	return err
+}
+
+// reencryptChunks encrypts the chunks of a binary secret and the attachments
+// of a secret for the current recipients. It does nothing for other secrets.
+func (s *Store) reencryptChunks(ctx context.Context, name string, sec gosecret.Secret) error {
+	m, err := ParseManifest(sec)
+	if err != nil && len(Attachments(sec)) < 1 {
+		return nil
+	}
+
+	recipients, err := s.recipientsFor(ctx, name)
+	if err != nil {
+		return err
+	}
+	if m != nil {
+		if err := s.reencryptDir(ctx, s.chunkDir(name), m, recipients); err != nil {
+			return err
+		}
+	}
+	return s.reencryptAttachments(ctx, name, sec, recipients)
+}
+
+func (s *Store) reencryptDir(ctx context.Context, dir string, m *Manifest, recipients []string) error {
+	for i := range m.Chunks {
+		chunk, err := s.getChunk(ctx, dir, i)
+		if err != nil {
+			return err
+		}
+		ciphertext, err := s.crypto.Encrypt(ctx, chunk, recipients)
+		if err != nil {
+			debug.Log("Failed to encrypt chunk %s: %s", chunkFile(dir, i), err)
+			return store.ErrEncrypt
+		}
+		if err := s.storage.Set(ctx, chunkFile(dir, i), ciphertext); err != nil {
+			return fmt.Errorf("failed to write chunk %d: %w", i, err)
+		}
+	}
+
+	if IsNoGitOps(ctx) || len(m.Chunks) < 1 {
+		return nil
+	}
+	if err := s.storage.Add(ctx, dir); err != nil && !errors.Is(err, store.ErrGitNotInit) {
+		return fmt.Errorf("failed to add %q to git: %w", dir, err)
+	}
+	return nil
+}
+
+// deleteChunks removes the chunks and the attachments of a secret, if any
+func (s *Store) deleteChunks(ctx context.Context, name string) error {
+	if err := s.pruneDir(ctx, s.chunkDir(name)); err != nil {
+		return err
+	}
+	return s.pruneDir(ctx, s.attachmentsDir(name))
+}
+
+func (s *Store) pruneDir(ctx context.Context, dir string) error {
+	if !s.storage.IsDir(ctx, dir) {
+		return nil
+	}
+
+	debug.Log("Deleting %s", dir)
+	if err := s.storage.Prune(ctx, dir); err != nil {
+		return err
+	}
+	if err := s.storage.Add(ctx, dir); err != nil && !errors.Is(err, store.ErrGitNotInit) {
+		return fmt.Errorf("failed to add %q to git: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package leaf
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinary(t *testing.T) {
+	ctx := context.Background()
+	ctx = WithChunkSize(ctx, 1024)
+
+	tempdir, err := os.MkdirTemp("", "gosecret-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tempdir)
+	}()
+
+	s, err := createSubStore(tempdir)
+	require.NoError(t, err)
+
+	content := make([]byte, 3*1024+100)
+	_, _ = rand.New(rand.NewSource(42)).Read(content)
+
+	m, err := s.SetBinary(ctx, "bin/blob", "/tmp/blob.raw", bytes.NewReader(content))
+	require.NoError(t, err)
+	assert.Equal(t, "blob.raw", m.Filename)
+	assert.Equal(t, int64(len(content)), m.Size)
+	assert.Len(t, m.Chunks, 4)
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256(content)), m.Sum)
+
+	// chunks are not listed as secrets
+	list, err := s.List(ctx, "")
+	require.NoError(t, err)
+	assert.NotContains(t, list, "bin/blob.chunks/000000")
+	assert.Contains(t, list, "bin/blob")
+
+	buf := &bytes.Buffer{}
+	_, err = s.GetBinary(ctx, "bin/blob", buf)
+	require.NoError(t, err)
+	assert.Equal(t, content, buf.Bytes())
+
+	// only changed chunks are rewritten, extra chunks are removed
+	first := filepath.Join(s.storage.Path(), "bin", "blob.chunks", "000000")
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(first, old, old))
+	content = content[:2*1024]
+	content[1500] ^= 0xff
+	m, err = s.SetBinary(ctx, "bin/blob", "blob.raw", bytes.NewReader(content))
+	require.NoError(t, err)
+	assert.Len(t, m.Chunks, 2)
+	fi, err := os.Stat(first)
+	require.NoError(t, err)
+	assert.True(t, fi.ModTime().Equal(old))
+	assert.False(t, s.storage.Exists(ctx, chunkFile(s.chunkDir("bin/blob"), 2)))
+
+	buf.Reset()
+	_, err = s.GetBinary(ctx, "bin/blob", buf)
+	require.NoError(t, err)
+	assert.Equal(t, content, buf.Bytes())
+
+	// a corrupted chunk is detected
+	require.NoError(t, s.storage.Set(ctx, chunkFile(s.chunkDir("bin/blob"), 1), []byte("garbage")))
+	_, err = s.GetBinary(ctx, "bin/blob", &bytes.Buffer{})
+	assert.Error(t, err)
+
+	// copy, move and delete take the chunks along
+	_, err = s.SetBinary(ctx, "bin/blob", "blob.raw", bytes.NewReader(content))
+	require.NoError(t, err)
+	require.NoError(t, s.Copy(ctx, "bin/blob", "bin/copy"))
+	require.NoError(t, s.Move(ctx, "bin/copy", "bin/moved"))
+	assert.False(t, s.storage.IsDir(ctx, "bin/copy.chunks"))
+	buf.Reset()
+	_, err = s.GetBinary(ctx, "bin/moved", buf)
+	require.NoError(t, err)
+	assert.Equal(t, content, buf.Bytes())
+
+	require.NoError(t, s.Delete(ctx, "bin/moved"))
+	assert.False(t, s.storage.IsDir(ctx, "bin/moved.chunks"))
+
+	// other secrets are not chunked
+	sec := &secrets.Plain{}
+	sec.SetPassword("foo")
+	require.NoError(t, s.Set(ctx, "plain", sec))
+	_, err = s.GetBinary(ctx, "plain", buf)
+	assert.ErrorIs(t, err, ErrNotChunked)
+	_, err = s.GetBinary(ctx, "missing", buf)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func TestManifest(t *testing.T) {
+	m := &Manifest{
+		Filename:  "foo.bin",
+		Size:      5,
+		ChunkSize: 2,
+		Sum:       "abc",
+		Chunks:    []string{"a", "b", "c"},
+	}
+	got, err := ParseManifest(m.Secret())
+	require.NoError(t, err)
+	assert.Equal(t, m, got)
+
+	m.Chunks = m.Chunks[:2]
+	_, err = ParseManifest(m.Secret())
+	assert.Error(t, err)
+}
@@ -2,6 +2,8 @@ package leaf
 
 import (
 	"context"
+	"os"
+	"time"
 
 	"github.com/itsonlycode/gosecret/internal/store"
 )
@@ -15,6 +17,10 @@ const (
 	ctxKeyCheckRecipients
 	ctxKeyFsckDecrypt
 	ctxKeyNoGitOps
+	ctxKeyFsckPruneLinks
+	ctxKeyChunkSize
+	ctxKeyEncryptedNames
+	ctxKeyLockTimeout
 )
 
 // WithFsckCheck returns a context with the flag for fscks check set
@@ -115,6 +121,18 @@ func IsFsckDecrypt(ctx context.Context) bool {
 	return is(ctx, ctxKeyFsckDecrypt, false)
 }
 
+// WithFsckPruneLinks will return a context with the value for removing
+// dangling links during fsck set.
+func WithFsckPruneLinks(ctx context.Context, d bool) context.Context {
+	return context.WithValue(ctx, ctxKeyFsckPruneLinks, d)
+}
+
+// IsFsckPruneLinks will return the value for removing dangling links during
+// fsck, defaulting to false.
+func IsFsckPruneLinks(ctx context.Context) bool {
+	return is(ctx, ctxKeyFsckPruneLinks, false)
+}
+
 // WithNoGitOps returns a context with the value for NoGitOps set.
 // This will skip any git operations in concurrent goroutines.
 func WithNoGitOps(ctx context.Context, d bool) context.Context {
@@ -127,6 +145,34 @@ func IsNoGitOps(ctx context.Context) bool {
 	return is(ctx, ctxKeyNoGitOps, false)
 }
 
+// WithChunkSize returns a context with the size of the chunks of binary
+// secrets set
+func WithChunkSize(ctx context.Context, size int) context.Context {
+	return context.WithValue(ctx, ctxKeyChunkSize, size)
+}
+
+// GetChunkSize returns the size of the chunks of binary secrets or the
+// default
+func GetChunkSize(ctx context.Context) int {
+	iv, ok := ctx.Value(ctxKeyChunkSize).(int)
+	if !ok || iv < 1 {
+		return DefaultChunkSize
+	}
+	return iv
+}
+
+// WithEncryptedNames returns a context with the flag for encrypted secret
+// names set. It's used when initializing or converting a store.
+func WithEncryptedNames(ctx context.Context, en bool) context.Context {
+	return context.WithValue(ctx, ctxKeyEncryptedNames, en)
+}
+
+// IsEncryptedNames returns the value of the encrypted names flag or the
+// default (false)
+func IsEncryptedNames(ctx context.Context) bool {
+	return is(ctx, ctxKeyEncryptedNames, false)
+}
+
 // hasBool is a helper function for checking if a bool has been set in
 // the provided context.
 func hasBool(ctx context.Context, key contextKey) bool {
@@ -143,3 +189,21 @@ func is(ctx context.Context, key contextKey, def bool) bool {
 	}
 	return bv
 }
+
+// WithLockTimeout returns a context with the time to wait for the lock of a
+// store set
+func WithLockTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, ctxKeyLockTimeout, d)
+}
+
+// GetLockTimeout returns the time to wait for the lock of a store. It's
+// taken from the context, GOPASS_LOCK_TIMEOUT or the default.
+func GetLockTimeout(ctx context.Context) time.Duration {
+	if d, ok := ctx.Value(ctxKeyLockTimeout).(time.Duration); ok && d > 0 {
+		return d
+	}
+	if d, err := time.ParseDuration(os.Getenv(EnvLockTimeout)); err == nil && d > 0 {
+		return d
+	}
+	return DefaultLockTimeout
+}
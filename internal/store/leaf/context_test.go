@@ -45,3 +45,11 @@ func TestCheckRecipients(t *testing.T) {
 	assert.False(t, IsCheckRecipients(WithCheckRecipients(ctx, false)))
 	assert.True(t, HasCheckRecipients(WithCheckRecipients(ctx, true)))
 }
+
+func TestEncryptedNames(t *testing.T) {
+	ctx := context.Background()
+
+	assert.False(t, IsEncryptedNames(ctx))
+	assert.True(t, IsEncryptedNames(WithEncryptedNames(ctx, true)))
+	assert.False(t, IsEncryptedNames(WithEncryptedNames(ctx, false)))
+}
@@ -67,10 +67,7 @@ func (s *Store) Convert(ctx context.Context, cryptoBe backend.CryptoBackend, sto
 
 	out.Printf(ctx, "Converting store ...")
 	bar := termio.NewProgressBar(int64(len(entries)))
-	bar.Hidden = ctxutil.IsHidden(ctx)
-	if !ctxutil.IsTerminal(ctx) || ctxutil.IsHidden(ctx) {
-		bar = nil
-	}
+	bar.Hidden = !ctxutil.IsTerminal(ctx) || ctxutil.IsHidden(ctx)
 
 	ctx = ctxutil.WithNoNetwork(ctx, true)
 	for _, e := range entries {
@@ -86,9 +83,10 @@ func (s *Store) Convert(ctx context.Context, cryptoBe backend.CryptoBackend, sto
 			if err != nil {
 				return err
 			}
-			if err := tmpStore.Set(ctx, e, sec); err != nil {
+			if err := s.CopyEntry(ctx, e, sec, tmpStore, e); err != nil {
 				return err
 			}
+			bar.Inc()
 			continue
 		}
 		sort.Sort(sort.Reverse(backend.Revisions(revs)))
@@ -112,10 +110,18 @@ func (s *Store) Convert(ctx context.Context, cryptoBe backend.CryptoBackend, sto
 				return err
 			}
 		}
+		// the chunks of binaries and attachments have no history of their own
+		if err := s.convertBlobs(ctx, e, tmpStore); err != nil {
+			return err
+		}
 		bar.Inc()
 	}
 	bar.Done()
 
+	if err := s.convertLinksAndTemplates(ctx, tmpStore); err != nil {
+		return err
+	}
+
 	if !move {
 		return nil
 	}
@@ -127,3 +133,41 @@ func (s *Store) Convert(ctx context.Context, cryptoBe backend.CryptoBackend, sto
 	// rename temp to old
 	return os.Rename(tmpPath, s.path)
 }
+
+// convertBlobs copies the latest content of the binary secret or the
+// attachments of name to dst
+func (s *Store) convertBlobs(ctx context.Context, name string, dst *Store) error {
+	sec, err := s.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	if _, err := ParseManifest(sec); err != nil && len(Attachments(sec)) < 1 {
+		return nil
+	}
+	return s.CopyEntry(ctx, name, sec, dst, name)
+}
+
+// convertLinksAndTemplates copies all links and templates to dst
+func (s *Store) convertLinksAndTemplates(ctx context.Context, dst *Store) error {
+	for name, target := range s.ListLinks(ctx, "") {
+		debug.Log("converting link %s", name)
+		if err := dst.SetLink(ctx, name, target); err != nil {
+			return err
+		}
+	}
+	for _, name := range s.ListTemplates(ctx, "") {
+		if name == TemplateFile {
+			// the template at the root of the store
+			name = ""
+		}
+		debug.Log("converting template %s", name)
+		content, err := s.GetTemplate(ctx, name)
+		if err != nil {
+			return err
+		}
+		if err := dst.SetTemplate(ctx, name, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
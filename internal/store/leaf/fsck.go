@@ -50,6 +50,14 @@ func (s *Store) Fsck(ctx context.Context, path string) error {
 		}
 		ctx := ctxutil.WithNoNetwork(ctx, true)
 		debug.Log("[%s] Checking %s", path, name)
+		// a listed entry that can not be read is most likely a symlink whose
+		// target has been moved or deleted
+		if !s.storage.Exists(ctx, s.passfile(name)) {
+			if err := s.fsckDanglingSymlink(ctx, name); err != nil {
+				return err
+			}
+			continue
+		}
 		if err := s.fsckCheckEntry(ctx, name); err != nil {
 			return fmt.Errorf("failed to check %q: %w", name, err)
 		}
@@ -64,6 +72,31 @@ func (s *Store) Fsck(ctx context.Context, path string) error {
 	return nil
 }
 
+func (s *Store) fsckDanglingSymlink(ctx context.Context, name string) error {
+	out.Errorf(ctx, "Dangling symlink %s\nRun fsck with the --prune-links flag to remove it.", name)
+	if !IsFsckPruneLinks(ctx) {
+		return nil
+	}
+	if err := s.storage.Delete(ctx, s.passfile(name)); err != nil {
+		return fmt.Errorf("failed to remove dangling symlink %q: %w", name, err)
+	}
+	if err := s.storage.Add(ctx, s.passfile(name)); err != nil && !errors.Is(err, store.ErrGitNotInit) {
+		return fmt.Errorf("failed to add %q to git: %w", name, err)
+	}
+	if err := s.storage.Commit(ctx, fmt.Sprintf("fsck: Remove dangling symlink %s", name)); err != nil {
+		switch {
+		case errors.Is(err, store.ErrGitNotInit):
+			debug.Log("skipping git commit - git not initialized")
+		case errors.Is(err, store.ErrGitNothingToCommit):
+			debug.Log("skipping git commit - nothing to commit")
+		default:
+			return fmt.Errorf("failed to commit changes to git: %w", err)
+		}
+	}
+	out.Printf(ctx, "Removed dangling symlink %s", name)
+	return nil
+}
+
 type convertedSecret interface {
 	gosecret.Secret
 	FromMime() bool
@@ -119,6 +152,9 @@ func (s *Store) fsckCheckEntry(ctx context.Context, name string) error {
 		if err != nil {
 			return fmt.Errorf("failed to decode secret: %w", err)
 		}
+		if err := s.reencryptChunks(ctx, name, sec); err != nil {
+			return fmt.Errorf("failed to write chunks: %w", err)
+		}
 		if err := s.Set(ctxutil.WithCommitMessage(ctx, "fsck fix recipients"), name, sec); err != nil {
 			return fmt.Errorf("failed to write secret: %w", err)
 		}
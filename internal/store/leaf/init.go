@@ -62,5 +62,11 @@ You can add secondary stores with gosecret init --path <path to secondary store>
 		return fmt.Errorf("failed to initialize store: %w", err)
 	}
 
+	if IsEncryptedNames(ctx) {
+		if err := s.enableEncryptedNames(ctx); err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+	}
+
 	return nil
 }
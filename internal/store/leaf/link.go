@@ -4,14 +4,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
-	"github.com/itsonlycode/gosecret/internal/queue"
 	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
 	"github.com/itsonlycode/gosecret/pkg/debug"
 )
 
+const (
+	// LinkExt is the file extension of store-level links. Unlike symlinks
+	// these contain the full name of the target (including any mount point)
+	// and are resolved by the root store.
+	LinkExt = ".pass-link"
+)
+
 // Link creates a symlink
 func (s *Store) Link(ctx context.Context, from, to string) error {
+	release, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	if !s.Exists(ctx, from) {
 		return fmt.Errorf("source %q does not exists", from)
 	}
@@ -31,10 +45,107 @@ func (s *Store) Link(ctx context.Context, from, to string) error {
 		return fmt.Errorf("failed to add %q to git: %w", to, err)
 	}
 
-	// try to enqueue this task, if the queue is not available
-	// it will return the task and we will execute it inline
-	t := queue.GetQueue(ctx).Add(func(ctx context.Context) error {
-		return s.gitCommitAndPush(ctx, to)
-	})
-	return t(ctx)
+	return s.gitCommitAndPush(ctx, to)
+}
+
+// linkfile returns the name of the given link on disk
+func (s *Store) linkfile(name string) string {
+	return strings.TrimPrefix(name+LinkExt, "/")
+}
+
+// IsLink returns true if the named entry is a store-level link
+func (s *Store) IsLink(ctx context.Context, name string) bool {
+	return s.storage.Exists(ctx, s.linkfile(name))
+}
+
+// GetLink returns the target of the named link
+func (s *Store) GetLink(ctx context.Context, name string) (string, error) {
+	buf, err := s.storage.Get(ctx, s.linkfile(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to read link %q: %w", name, err)
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+// SetLink will (over)write the link to point to target
+func (s *Store) SetLink(ctx context.Context, name, target string) error {
+	release, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	p := s.linkfile(name)
+
+	if err := s.storage.Set(ctx, p, []byte(target+"\n")); err != nil {
+		return fmt.Errorf("failed to write link: %w", err)
+	}
+	debug.Log("created link from %q to %q", name, target)
+
+	if err := s.storage.Add(ctx, p); err != nil {
+		if errors.Is(err, store.ErrGitNotInit) {
+			return nil
+		}
+		return fmt.Errorf("failed to add %q to git: %w", p, err)
+	}
+
+	if !ctxutil.IsGitCommit(ctx) {
+		return nil
+	}
+
+	return s.gitCommitAndPush(ctx, name)
+}
+
+// RemoveLink will delete the named link
+func (s *Store) RemoveLink(ctx context.Context, name string) error {
+	release, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	p := s.linkfile(name)
+
+	if err := s.storage.Delete(ctx, p); err != nil {
+		return fmt.Errorf("failed to remove link: %w", err)
+	}
+
+	if err := s.storage.Add(ctx, p); err != nil {
+		if errors.Is(err, store.ErrGitNotInit) {
+			return nil
+		}
+		return fmt.Errorf("failed to add %q to git: %w", p, err)
+	}
+
+	if !ctxutil.IsGitCommit(ctx) {
+		return nil
+	}
+
+	return s.gitCommitAndPush(ctx, name)
+}
+
+// ListLinks will list all links in this store and their targets
+func (s *Store) ListLinks(ctx context.Context, prefix string) map[string]string {
+	lst, err := s.storage.List(ctx, "")
+	if err != nil {
+		debug.Log("failed to list links: %s", err)
+		return map[string]string{}
+	}
+	links := make(map[string]string, len(lst))
+	for _, path := range lst {
+		if !strings.HasSuffix(path, LinkExt) {
+			continue
+		}
+		name := strings.TrimSuffix(path, LinkExt)
+		target, err := s.GetLink(ctx, name)
+		if err != nil {
+			debug.Log("failed to read link %q: %s", name, err)
+			continue
+		}
+		if prefix != "" {
+			name = prefix + Sep + name
+		}
+		links[name] = target
+	}
+	return links
 }
@@ -3,6 +3,7 @@ package leaf
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
@@ -33,4 +34,42 @@ func TestLink(t *testing.T) {
 	p, err := s.Get(ctx, "foo/123")
 	require.NoError(t, err)
 	assert.Equal(t, "foo", p.Password())
+
+	// removing the target leaves a dangling symlink which fsck can prune
+	require.NoError(t, s.Delete(ctx, "zab/zab"))
+	assert.NoError(t, s.Fsck(ctx, ""))
+	assert.NoError(t, s.Fsck(WithFsckPruneLinks(ctx, true), ""))
+	_, err = os.Lstat(filepath.Join(tempdir, "foo", "123.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLinkFile(t *testing.T) {
+	ctx := context.Background()
+
+	tempdir, err := os.MkdirTemp("", "gosecret-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tempdir)
+	}()
+
+	s, err := createSubStore(tempdir)
+	require.NoError(t, err)
+
+	assert.False(t, s.IsLink(ctx, "foo/bar"))
+	require.NoError(t, s.SetLink(ctx, "foo/bar", "other/mount/zab"))
+	assert.True(t, s.IsLink(ctx, "foo/bar"))
+	assert.False(t, s.Exists(ctx, "foo/bar"))
+
+	target, err := s.GetLink(ctx, "foo/bar")
+	require.NoError(t, err)
+	assert.Equal(t, "other/mount/zab", target)
+
+	assert.Equal(t, map[string]string{"sub/foo/bar": "other/mount/zab"}, s.ListLinks(ctx, "sub"))
+
+	lst, err := s.List(ctx, "")
+	require.NoError(t, err)
+	assert.NotContains(t, lst, "foo/bar")
+
+	require.NoError(t, s.RemoveLink(ctx, "foo/bar"))
+	assert.False(t, s.IsLink(ctx, "foo/bar"))
 }
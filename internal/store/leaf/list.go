@@ -17,6 +17,7 @@ func (s *Store) List(ctx context.Context, prefix string) ([]string, error) {
 	if s.storage == nil || s.crypto == nil {
 		return nil, nil
 	}
+	s.autoSync(ctx)
 
 	lst, err := s.storage.List(ctx, prefix)
 	if err != nil {
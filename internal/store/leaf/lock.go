@@ -0,0 +1,378 @@
+package leaf
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/pkg/appdir"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+)
+
+const (
+	// DefaultLockTimeout is how long a process waits for the lock of a store
+	DefaultLockTimeout = 30 * time.Second
+	// EnvLockTimeout can change the lock timeout, e.g. 2m
+	EnvLockTimeout = "GOPASS_LOCK_TIMEOUT"
+
+	// lockStaleAfter is the age after which locks held by processes on other
+	// hosts are considered stale. Locks are refreshed while they are used.
+	lockStaleAfter = 10 * time.Minute
+	// lockIncomplete is the age after which a lock file without an owner is
+	// considered stale, i.e. its process died while writing it
+	lockIncomplete   = 5 * time.Second
+	lockPollInterval = 100 * time.Millisecond
+)
+
+// ErrLocked is returned if a store is still locked by another process when
+// the lock timeout expires
+var ErrLocked = errors.New("store is locked by another process")
+
+var (
+	storeLocks   = map[string]*storeLock{}
+	storeLocksMu sync.Mutex
+)
+
+// lockOwner is the content of a lock file
+type lockOwner struct {
+	PID   int       `json:"pid"`
+	Host  string    `json:"host"`
+	Since time.Time `json:"since"`
+}
+
+// storeLock is an advisory lock of a store shared by all gosecret processes
+// of the user. It's a file in the cache dir, so it works for every storage
+// backend. The lock is reentrant and shared by all goroutines of a
+// process, it only keeps other processes out.
+type storeLock struct {
+	sync.Mutex
+
+	path  string
+	file  string
+	held  int
+	owner lockOwner
+}
+
+// cacheFile returns the name of a file about the store at path in the
+// given dir of the cache
+func cacheFile(path, dir, ext string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(appdir.UserCache(), dir, hex.EncodeToString(sum[:8])+ext)
+}
+
+// lockFile returns the name of the lock file of the store at path
+func lockFile(path string) string {
+	return cacheFile(path, "locks", ".lock")
+}
+
+// lockFor returns the lock of the store at path. All stores of a process
+// with the same path share it.
+func lockFor(path string) *storeLock {
+	file := lockFile(path)
+
+	storeLocksMu.Lock()
+	defer storeLocksMu.Unlock()
+
+	if l, found := storeLocks[file]; found {
+		return l
+	}
+	l := &storeLock{path: path, file: file}
+	storeLocks[file] = l
+	return l
+}
+
+// acquire takes the lock. It waits until the lock is released, found to
+// be stale or the timeout expires.
+func (l *storeLock) acquire(ctx context.Context) error {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.held > 0 {
+		l.held++
+		// keep the lock from looking stale during long operations
+		now := time.Now()
+		_ = os.Chtimes(l.file, now, now)
+		return nil
+	}
+
+	timeout := GetLockTimeout(ctx)
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		err := l.create()
+		if err == nil {
+			l.held = 1
+			return nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("failed to lock store %s: %w", l.path, err)
+		}
+
+		owner, buf, stale := l.check()
+		if stale {
+			debug.Log("removing stale lock %s of %s: %+v", l.file, l.path, owner)
+			l.removeIf(buf)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return l.lockedError(owner, timeout)
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// release gives up the lock once it was released as often as it was
+// acquired
+func (l *storeLock) release() {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.held < 1 {
+		return
+	}
+	l.held--
+	if l.held > 0 {
+		return
+	}
+
+	// the lock might have been taken over if it looked stale
+	buf, err := json.Marshal(l.owner)
+	if err != nil {
+		return
+	}
+	l.removeIf(buf)
+}
+
+// create writes a new lock file. It fails with os.ErrExist if the store is
+// locked already.
+func (l *storeLock) create() error {
+	if err := os.MkdirAll(filepath.Dir(l.file), 0700); err != nil {
+		return err
+	}
+	fh, err := os.OpenFile(l.file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	host, _ := os.Hostname()
+	l.owner = lockOwner{
+		PID:   os.Getpid(),
+		Host:  host,
+		Since: time.Now().UTC().Truncate(time.Second),
+	}
+	buf, err := json.Marshal(l.owner)
+	if err != nil {
+		_ = fh.Close()
+		_ = os.Remove(l.file)
+		return err
+	}
+	if _, err := fh.Write(buf); err != nil {
+		_ = fh.Close()
+		_ = os.Remove(l.file)
+		return err
+	}
+	return fh.Close()
+}
+
+// check reads the current lock file and decides if it's stale. Locks of
+// processes on this host are stale once the process is gone, locks from
+// other hosts once they were not refreshed for a while.
+func (l *storeLock) check() (lockOwner, []byte, bool) {
+	var owner lockOwner
+
+	fi, err := os.Stat(l.file)
+	if err != nil {
+		// released in the meantime
+		return owner, nil, false
+	}
+	age := time.Since(fi.ModTime())
+
+	buf, err := os.ReadFile(l.file)
+	if err != nil {
+		return owner, nil, false
+	}
+	if err := json.Unmarshal(buf, &owner); err != nil || owner.PID < 1 {
+		return owner, buf, age > lockIncomplete
+	}
+
+	if host, _ := os.Hostname(); owner.Host != host {
+		return owner, buf, age > lockStaleAfter
+	}
+	// a lock of this process is never held by someone else, see acquire
+	if owner.PID == os.Getpid() {
+		return owner, buf, true
+	}
+	return owner, buf, !processAlive(owner.PID)
+}
+
+// removeIf removes the lock file if it still has the given content
+func (l *storeLock) removeIf(content []byte) {
+	buf, err := os.ReadFile(l.file)
+	if err != nil || string(buf) != string(content) {
+		return
+	}
+	if err := os.Remove(l.file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		debug.Log("failed to remove lock %s: %s", l.file, err)
+	}
+}
+
+func (l *storeLock) lockedError(owner lockOwner, timeout time.Duration) error {
+	if owner.PID < 1 {
+		return fmt.Errorf("%w: gave up on %s after %s", ErrLocked, l.path, timeout)
+	}
+	return fmt.Errorf(
+		"%w: %s is locked by process %d on %s since %s, gave up after %s. Remove %s if that process is not running anymore",
+		ErrLocked, l.path, owner.PID, owner.Host, owner.Since.Local().Format(time.RFC3339), timeout, l.file,
+	)
+}
+
+// lock takes the lock of the store for a whole operation. The returned
+// function releases it.
+func (s *Store) lock(ctx context.Context) (func(), error) {
+	l := lockFor(s.path)
+	if err := l.acquire(ctx); err != nil {
+		return func() {}, err
+	}
+	return l.release, nil
+}
+
+// lockedStorage takes the lock of the store for every change of the
+// storage and every RCS operation. Reads don't need the lock.
+type lockedStorage struct {
+	backend.Storage
+
+	l *storeLock
+}
+
+// wrapLock makes sure all changes of the storage are made with the lock of
+// the store held
+func (s *Store) wrapLock() {
+	if _, ok := s.storage.(*lockedStorage); ok {
+		return
+	}
+	s.storage = &lockedStorage{Storage: s.storage, l: lockFor(s.path)}
+}
+
+func (ls *lockedStorage) do(ctx context.Context, op func() error) error {
+	if err := ls.l.acquire(ctx); err != nil {
+		return err
+	}
+	defer ls.l.release()
+
+	return op()
+}
+
+// Set implements backend.Storage
+func (ls *lockedStorage) Set(ctx context.Context, name string, value []byte) error {
+	return ls.do(ctx, func() error { return ls.Storage.Set(ctx, name, value) })
+}
+
+// Delete implements backend.Storage
+func (ls *lockedStorage) Delete(ctx context.Context, name string) error {
+	return ls.do(ctx, func() error { return ls.Storage.Delete(ctx, name) })
+}
+
+// Prune implements backend.Storage
+func (ls *lockedStorage) Prune(ctx context.Context, prefix string) error {
+	return ls.do(ctx, func() error { return ls.Storage.Prune(ctx, prefix) })
+}
+
+// Link implements backend.Storage
+func (ls *lockedStorage) Link(ctx context.Context, from, to string) error {
+	return ls.do(ctx, func() error { return ls.Storage.Link(ctx, from, to) })
+}
+
+// Fsck implements backend.Storage
+func (ls *lockedStorage) Fsck(ctx context.Context) error {
+	return ls.do(ctx, func() error { return ls.Storage.Fsck(ctx) })
+}
+
+// Add implements backend.rcs
+func (ls *lockedStorage) Add(ctx context.Context, args ...string) error {
+	return ls.do(ctx, func() error { return ls.Storage.Add(ctx, args...) })
+}
+
+// Commit implements backend.rcs
+func (ls *lockedStorage) Commit(ctx context.Context, msg string) error {
+	return ls.do(ctx, func() error { return ls.Storage.Commit(ctx, msg) })
+}
+
+// Push implements backend.rcs
+func (ls *lockedStorage) Push(ctx context.Context, remote, branch string) error {
+	return ls.do(ctx, func() error { return ls.Storage.Push(ctx, remote, branch) })
+}
+
+// Pull implements backend.rcs
+func (ls *lockedStorage) Pull(ctx context.Context, remote, branch string) error {
+	return ls.do(ctx, func() error { return ls.Storage.Pull(ctx, remote, branch) })
+}
+
+// InitConfig implements backend.rcs
+func (ls *lockedStorage) InitConfig(ctx context.Context, name, email string) error {
+	return ls.do(ctx, func() error { return ls.Storage.InitConfig(ctx, name, email) })
+}
+
+// AddRemote implements backend.rcs
+func (ls *lockedStorage) AddRemote(ctx context.Context, remote, location string) error {
+	return ls.do(ctx, func() error { return ls.Storage.AddRemote(ctx, remote, location) })
+}
+
+// RemoveRemote implements backend.rcs
+func (ls *lockedStorage) RemoveRemote(ctx context.Context, remote string) error {
+	return ls.do(ctx, func() error { return ls.Storage.RemoveRemote(ctx, remote) })
+}
+
+// Compact implements backend.rcs
+func (ls *lockedStorage) Compact(ctx context.Context) error {
+	return ls.do(ctx, func() error { return ls.Storage.Compact(ctx) })
+}
+
+// ConfigGet returns a config value of the wrapped storage, if it has any
+func (ls *lockedStorage) ConfigGet(ctx context.Context, key string) (string, error) {
+	cg, ok := ls.Storage.(interface {
+		ConfigGet(context.Context, string) (string, error)
+	})
+	if !ok {
+		return "", backend.ErrNotSupported
+	}
+	return cg.ConfigGet(ctx, key)
+}
+
+// ListRevision lists the files of the wrapped storage at the revision, if
+// it can
+func (ls *lockedStorage) ListRevision(ctx context.Context, revision string) ([]string, error) {
+	lr, ok := ls.Storage.(interface {
+		ListRevision(context.Context, string) ([]string, error)
+	})
+	if !ok {
+		return nil, backend.ErrNotSupported
+	}
+	return lr.ListRevision(ctx, revision)
+}
+
+// SignsCommits reports if the wrapped storage signs its commits, if it can
+func (ls *lockedStorage) SignsCommits(ctx context.Context) (bool, error) {
+	sc, ok := ls.Storage.(interface {
+		SignsCommits(context.Context) (bool, error)
+	})
+	if !ok {
+		return false, backend.ErrNotSupported
+	}
+	return sc.SignsCommits(ctx)
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package leaf
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// processAlive returns true if a process with the given pid is running
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,171 @@
+package leaf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/itsonlycode/gosecret/internal/backend/storage/inmem"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupLockTest keeps the lock files of a test in a temporary dir
+func setupLockTest(t *testing.T) string {
+	t.Helper()
+
+	td := t.TempDir()
+	old, found := os.LookupEnv("GOPASS_HOMEDIR")
+	require.NoError(t, os.Setenv("GOPASS_HOMEDIR", td))
+	t.Cleanup(func() {
+		if found {
+			_ = os.Setenv("GOPASS_HOMEDIR", old)
+			return
+		}
+		_ = os.Unsetenv("GOPASS_HOMEDIR")
+	})
+	return filepath.Join(td, "store")
+}
+
+// lockAs creates the lock file of the store at path as if another process
+// held it
+func lockAs(t *testing.T, path string, owner lockOwner, age time.Duration) {
+	t.Helper()
+
+	fn := lockFile(path)
+	require.NoError(t, os.MkdirAll(filepath.Dir(fn), 0700))
+	buf, err := json.Marshal(owner)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(fn, buf, 0600))
+	mt := time.Now().Add(-age)
+	require.NoError(t, os.Chtimes(fn, mt, mt))
+}
+
+func TestStoreLock(t *testing.T) {
+	ctx := WithLockTimeout(context.Background(), 300*time.Millisecond)
+	path := setupLockTest(t)
+	host, _ := os.Hostname()
+
+	t.Run("reentrant", func(t *testing.T) {
+		l := lockFor(path)
+		assert.Equal(t, l, lockFor(path+"/"))
+		require.NoError(t, l.acquire(ctx))
+		require.NoError(t, l.acquire(ctx))
+		assert.FileExists(t, l.file)
+		l.release()
+		assert.FileExists(t, l.file)
+		l.release()
+		assert.NoFileExists(t, l.file)
+		l.release()
+	})
+
+	t.Run("locked by a running process", func(t *testing.T) {
+		lockAs(t, path, lockOwner{PID: os.Getppid(), Host: host, Since: time.Now()}, 0)
+		defer func() {
+			_ = os.Remove(lockFile(path))
+		}()
+
+		l := lockFor(path)
+		err := l.acquire(ctx)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrLocked))
+		assert.Contains(t, err.Error(), lockFile(path))
+
+		// the lock of the other process is not removed
+		l.release()
+		assert.FileExists(t, lockFile(path))
+	})
+
+	t.Run("stale locks", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("no true on windows")
+		}
+		cmd := exec.Command("true")
+		require.NoError(t, cmd.Run())
+
+		for _, tc := range []struct {
+			name  string
+			owner lockOwner
+			age   time.Duration
+		}{
+			{"dead process", lockOwner{PID: cmd.Process.Pid, Host: host}, 0},
+			{"other host", lockOwner{PID: 1, Host: host + ".invalid"}, lockStaleAfter + time.Minute},
+			{"incomplete", lockOwner{}, time.Minute},
+		} {
+			lockAs(t, path, tc.owner, tc.age)
+			l := lockFor(path)
+			require.NoError(t, l.acquire(ctx), tc.name)
+			l.release()
+			assert.NoFileExists(t, l.file, tc.name)
+		}
+
+		lockAs(t, path, lockOwner{PID: 1, Host: host + ".invalid"}, time.Minute)
+		assert.Error(t, lockFor(path).acquire(ctx))
+		require.NoError(t, os.Remove(lockFile(path)))
+	})
+}
+
+func TestLockedStore(t *testing.T) {
+	ctx := WithLockTimeout(context.Background(), 300*time.Millisecond)
+	path := setupLockTest(t)
+	host, _ := os.Hostname()
+
+	s := &Store{
+		path:    path,
+		storage: inmem.New(path),
+	}
+	s.wrapLock()
+	s.wrapLock()
+	_, ok := s.storage.(*lockedStorage).Storage.(*lockedStorage)
+	assert.False(t, ok)
+
+	require.NoError(t, s.storage.Set(ctx, "foo", []byte("bar")))
+	assert.NoFileExists(t, lockFile(path))
+
+	lockAs(t, path, lockOwner{PID: os.Getppid(), Host: host, Since: time.Now()}, 0)
+	defer func() {
+		_ = os.Remove(lockFile(path))
+	}()
+
+	// reads work while another process holds the lock
+	buf, err := s.storage.Get(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(buf))
+	assert.True(t, s.storage.Exists(ctx, "foo"))
+
+	assert.True(t, errors.Is(s.storage.Set(ctx, "foo", []byte("baz")), ErrLocked))
+	assert.True(t, errors.Is(s.storage.Commit(ctx, "foo"), ErrLocked))
+	sec := &secrets.Plain{}
+	sec.SetPassword("foo")
+	assert.True(t, errors.Is(s.Set(ctx, "foo", sec), ErrLocked))
+	assert.True(t, errors.Is(s.Delete(ctx, "foo"), ErrLocked))
+	// transactions can't take a context
+	require.NoError(t, os.Setenv(EnvLockTimeout, "300ms"))
+	defer func() {
+		_ = os.Unsetenv(EnvLockTimeout)
+	}()
+	assert.True(t, errors.Is(s.BeginTx(), ErrLocked))
+	assert.False(t, s.InTx())
+}
+
+func TestGetLockTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	assert.Equal(t, DefaultLockTimeout, GetLockTimeout(ctx))
+	assert.Equal(t, time.Minute, GetLockTimeout(WithLockTimeout(ctx, time.Minute)))
+
+	require.NoError(t, os.Setenv(EnvLockTimeout, "2m"))
+	defer func() {
+		_ = os.Unsetenv(EnvLockTimeout)
+	}()
+	assert.Equal(t, 2*time.Minute, GetLockTimeout(ctx))
+	assert.Equal(t, time.Minute, GetLockTimeout(WithLockTimeout(ctx, time.Minute)))
+}
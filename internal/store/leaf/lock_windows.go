@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package leaf
+
+import (
+	"os"
+)
+
+// processAlive returns true if a process with the given pid is running
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
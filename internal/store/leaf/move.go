@@ -10,6 +10,7 @@ import (
 	"github.com/itsonlycode/gosecret/internal/store"
 	"github.com/itsonlycode/gosecret/pkg/ctxutil"
 	"github.com/itsonlycode/gosecret/pkg/debug"
+	"github.com/itsonlycode/gosecret/pkg/gosecret"
 )
 
 // Copy will copy one entry to another location. Multi-store copies are
@@ -25,7 +26,7 @@ func (s *Store) Copy(ctx context.Context, from, to string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get %q from store: %w", from, err)
 	}
-	if err := s.Set(ctxutil.WithCommitMessage(ctx, fmt.Sprintf("Copied from %s to %s", from, to)), to, content); err != nil {
+	if err := s.CopyEntry(ctxutil.WithCommitMessage(ctx, fmt.Sprintf("Copied from %s to %s", from, to)), from, content, s, to); err != nil {
 		return fmt.Errorf("failed to save %q to store: %w", to, err)
 	}
 	return nil
@@ -45,7 +46,13 @@ func (s *Store) Move(ctx context.Context, from, to string) error {
 	if err != nil {
 		return fmt.Errorf("failed to decrypt %q: %w", from, err)
 	}
-	if err := s.Set(ctxutil.WithCommitMessage(ctx, fmt.Sprintf("Move from %s to %s", from, to)), to, content); err != nil {
+
+	release, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := s.CopyEntry(ctxutil.WithCommitMessage(ctx, fmt.Sprintf("Move from %s to %s", from, to)), from, content, s, to); err != nil {
 		return fmt.Errorf("failed to write %q: %w", to, err)
 	}
 	if err := s.Delete(ctx, from); err != nil {
@@ -54,6 +61,22 @@ func (s *Store) Move(ctx context.Context, from, to string) error {
 	return nil
 }
 
+// CopyEntry writes content, the decrypted secret name, to dstName in the
+// store dst, which may be this store. The chunks of binary secrets and all
+// attachments are copied as well.
+func (s *Store) CopyEntry(ctx context.Context, name string, content gosecret.Secret, dst *Store, dstName string) error {
+	var err error
+	if _, perr := ParseManifest(content); perr == nil {
+		err = s.CopyBinary(ctx, name, dst, dstName)
+	} else {
+		err = dst.Set(ctx, dstName, content)
+	}
+	if err != nil || len(Attachments(content)) < 1 {
+		return err
+	}
+	return s.CopyAttachments(ctx, name, dst, dstName)
+}
+
 // Delete will remove an single entry from the store
 func (s *Store) Delete(ctx context.Context, name string) error {
 	return s.delete(ctx, name, false)
@@ -67,6 +90,12 @@ func (s *Store) Prune(ctx context.Context, tree string) error {
 // delete will either delete one file or an directory tree depending on the
 // recurse flag
 func (s *Store) delete(ctx context.Context, name string, recurse bool) error {
+	release, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	path := s.passfile(name)
 
 	if recurse {
@@ -81,6 +110,9 @@ func (s *Store) delete(ctx context.Context, name string, recurse bool) error {
 			return err
 		}
 	}
+	if err := s.deleteChunks(ctx, name); err != nil {
+		return fmt.Errorf("failed to delete chunks or attachments of %q: %w", name, err)
+	}
 
 	if !ctxutil.IsGitCommit(ctx) {
 		return nil
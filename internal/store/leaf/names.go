@@ -0,0 +1,589 @@
+package leaf
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+)
+
+const (
+	// NamesFile is the encrypted index of a store with encrypted names. It
+	// maps the names of secrets to the opaque file names used in the storage.
+	NamesFile = ".pass-names"
+	// namesCommitMsg replaces all commit messages, since they usually contain
+	// secret names
+	namesCommitMsg = "Update store"
+)
+
+// nameIndex wraps the storage of a store with encrypted names. Every file
+// below the top level directory is stored under a random identifier, only the
+// store metadata at the top level (e.g. the recipients, public keys and the
+// index itself) keeps its name. The mapping is kept in NamesFile, which is
+// encrypted for the recipients of the store.
+//
+// Identifiers are never removed from the index, so the history of a secret
+// is still available after it was deleted and re-creating it continues its
+// history.
+type nameIndex struct {
+	backend.Storage
+
+	sync.Mutex
+	s *Store
+	// ids maps names to identifiers, it's nil until the index is loaded
+	ids   map[string]string
+	names map[string]string
+	// sum is the checksum of the encrypted index that was loaded or saved
+	// last. Other processes or a pull can change the index at any time.
+	sum [sha256.Size]byte
+	// removed holds the identifiers of files deleted since the last Add
+	removed map[string]struct{}
+}
+
+// isPlainName returns true for files that keep their name in the storage
+func isPlainName(name string) bool {
+	name = strings.TrimPrefix(filepath.ToSlash(name), "/")
+	return name == "" || name == "." || strings.HasPrefix(name, ".")
+}
+
+func cleanName(name string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+name)), "/")
+}
+
+func newID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(buf)
+	// spread the files over some directories, like git does
+	return id[:2] + "/" + id[2:], nil
+}
+
+// load reads the index if it was not read before or if it changed since.
+// Must be called with the lock held.
+func (n *nameIndex) load(ctx context.Context) error {
+	var ciphertext []byte
+	if n.Storage.Exists(ctx, NamesFile) {
+		var err error
+		ciphertext, err = n.Storage.Get(ctx, NamesFile)
+		if err != nil {
+			return fmt.Errorf("failed to read the index of names: %w", err)
+		}
+	}
+	sum := sha256.Sum256(ciphertext)
+	if n.ids != nil && sum == n.sum {
+		return nil
+	}
+
+	ids := map[string]string{}
+	names := map[string]string{}
+	if ciphertext != nil {
+		buf, err := n.s.crypto.Decrypt(ctx, ciphertext)
+		if err != nil {
+			debug.Log("Failed to decrypt %s: %s", NamesFile, err)
+			return store.ErrDecrypt
+		}
+		sc := bufio.NewScanner(bytes.NewReader(buf))
+		for sc.Scan() {
+			p := strings.SplitN(sc.Text(), " ", 2)
+			if len(p) != 2 {
+				continue
+			}
+			ids[p[1]] = p[0]
+			names[p[0]] = p[1]
+		}
+		if err := sc.Err(); err != nil {
+			return err
+		}
+	}
+	n.ids, n.names, n.sum = ids, names, sum
+	return nil
+}
+
+// save encrypts and writes the index. Must be called with the lock held.
+func (n *nameIndex) save(ctx context.Context) error {
+	lines := make([]string, 0, len(n.ids))
+	for name, id := range n.ids {
+		lines = append(lines, id+" "+name+"\n")
+	}
+	sort.Strings(lines)
+
+	recipients, err := n.s.useableKeys(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list useable keys: %w", err)
+	}
+	recipients = n.s.ensureOurKeyID(ctx, recipients)
+	ciphertext, err := n.s.crypto.Encrypt(ctx, []byte(strings.Join(lines, "")), recipients)
+	if err != nil {
+		debug.Log("Failed to encrypt %s: %s", NamesFile, err)
+		return store.ErrEncrypt
+	}
+	if err := n.Storage.Set(ctx, NamesFile, ciphertext); err != nil {
+		return err
+	}
+	n.sum = sha256.Sum256(ciphertext)
+	return nil
+}
+
+// lookup returns the identifier of the name or an empty string
+func (n *nameIndex) lookup(ctx context.Context, name string) (string, error) {
+	n.Lock()
+	defer n.Unlock()
+
+	if err := n.load(ctx); err != nil {
+		return "", err
+	}
+	return n.ids[cleanName(name)], nil
+}
+
+// assign returns the identifier of the name. A new one is added to the
+// index if necessary. The store lock is held from reading the index until
+// it's written, so names added by other processes are not lost.
+func (n *nameIndex) assign(ctx context.Context, name string) (string, error) {
+	unlock, err := n.s.lock(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	n.Lock()
+	defer n.Unlock()
+
+	if err := n.load(ctx); err != nil {
+		return "", err
+	}
+	name = cleanName(name)
+	if id, found := n.ids[name]; found {
+		return id, nil
+	}
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+	n.ids[name], n.names[id] = id, name
+	if err := n.save(ctx); err != nil {
+		delete(n.ids, name)
+		delete(n.names, id)
+		return "", err
+	}
+	debug.Log("assigned %s to a new name", id)
+	return id, nil
+}
+
+// existing returns the identifiers of all files in the storage by their
+// names
+func (n *nameIndex) existing(ctx context.Context) (map[string]string, error) {
+	files, err := n.Storage.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	n.Lock()
+	defer n.Unlock()
+
+	if err := n.load(ctx); err != nil {
+		return nil, err
+	}
+	found := make(map[string]string, len(files))
+	for _, f := range files {
+		if name, ok := n.names[f]; ok {
+			found[name] = f
+		}
+	}
+	return found, nil
+}
+
+// below returns the names that are equal to or below the prefix
+func below(names map[string]string, prefix string) []string {
+	prefix = cleanName(prefix)
+	var out []string
+	for name := range names {
+		if prefix == "" || name == prefix || strings.HasPrefix(name, prefix+"/") {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func errNotExist(name string) error {
+	return fmt.Errorf("%s: %w", name, os.ErrNotExist)
+}
+
+// Get implements backend.Storage
+func (n *nameIndex) Get(ctx context.Context, name string) ([]byte, error) {
+	if isPlainName(name) {
+		return n.Storage.Get(ctx, name)
+	}
+	id, err := n.lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, errNotExist(name)
+	}
+	return n.Storage.Get(ctx, id)
+}
+
+// Set implements backend.Storage
+func (n *nameIndex) Set(ctx context.Context, name string, value []byte) error {
+	if isPlainName(name) {
+		return n.Storage.Set(ctx, name, value)
+	}
+	id, err := n.assign(ctx, name)
+	if err != nil {
+		return err
+	}
+	return n.Storage.Set(ctx, id, value)
+}
+
+// Delete implements backend.Storage
+func (n *nameIndex) Delete(ctx context.Context, name string) error {
+	if isPlainName(name) {
+		return n.Storage.Delete(ctx, name)
+	}
+	id, err := n.lookup(ctx, name)
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return errNotExist(name)
+	}
+	if err := n.Storage.Delete(ctx, id); err != nil {
+		return err
+	}
+	n.markRemoved(id)
+	return nil
+}
+
+// markRemoved remembers a deleted file, so Add can stage its removal
+func (n *nameIndex) markRemoved(id string) {
+	n.Lock()
+	defer n.Unlock()
+
+	if n.removed == nil {
+		n.removed = map[string]struct{}{}
+	}
+	n.removed[id] = struct{}{}
+}
+
+// Exists implements backend.Storage
+func (n *nameIndex) Exists(ctx context.Context, name string) bool {
+	if isPlainName(name) {
+		return n.Storage.Exists(ctx, name)
+	}
+	id, err := n.lookup(ctx, name)
+	if err != nil {
+		debug.Log("failed to look up %s: %s", name, err)
+		return false
+	}
+	return id != "" && n.Storage.Exists(ctx, id)
+}
+
+// List implements backend.Storage
+func (n *nameIndex) List(ctx context.Context, prefix string) ([]string, error) {
+	files, err := n.Storage.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	n.Lock()
+	defer n.Unlock()
+
+	if err := n.load(ctx); err != nil {
+		return nil, err
+	}
+	prefix = strings.TrimPrefix(prefix, "/")
+	out := make([]string, 0, len(files))
+	for _, f := range files {
+		if !isPlainName(f) {
+			name, found := n.names[f]
+			if !found {
+				debug.Log("ignoring %s, it's not in the index", f)
+				continue
+			}
+			f = name
+		}
+		if strings.HasPrefix(f, prefix) {
+			out = append(out, f)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// IsDir implements backend.Storage
+func (n *nameIndex) IsDir(ctx context.Context, name string) bool {
+	if isPlainName(name) {
+		return n.Storage.IsDir(ctx, name)
+	}
+	found, err := n.existing(ctx)
+	if err != nil {
+		debug.Log("failed to list files: %s", err)
+		return false
+	}
+	for _, f := range below(found, name) {
+		if f != cleanName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prune implements backend.Storage
+func (n *nameIndex) Prune(ctx context.Context, prefix string) error {
+	if isPlainName(prefix) {
+		return n.Storage.Prune(ctx, prefix)
+	}
+	found, err := n.existing(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range below(found, prefix) {
+		if err := n.Storage.Delete(ctx, found[name]); err != nil {
+			return err
+		}
+		n.markRemoved(found[name])
+	}
+	return nil
+}
+
+// Link implements backend.Storage
+func (n *nameIndex) Link(ctx context.Context, from, to string) error {
+	if isPlainName(from) || isPlainName(to) {
+		return n.Storage.Link(ctx, from, to)
+	}
+	fromID, err := n.lookup(ctx, from)
+	if err != nil {
+		return err
+	}
+	if fromID == "" {
+		return errNotExist(from)
+	}
+	toID, err := n.assign(ctx, to)
+	if err != nil {
+		return err
+	}
+	return n.Storage.Link(ctx, fromID, toID)
+}
+
+// Add implements backend.Storage. Directories are expanded to the files
+// below them and the index is always added.
+func (n *nameIndex) Add(ctx context.Context, files ...string) error {
+	found, err := n.existing(ctx)
+	if err != nil {
+		return err
+	}
+
+	n.Lock()
+	paths := make([]string, 0, len(files)+1)
+	for _, f := range files {
+		if isPlainName(f) {
+			paths = append(paths, f)
+			continue
+		}
+		for _, name := range below(n.ids, f) {
+			id := n.ids[name]
+			_, removed := n.removed[id]
+			if _, exists := found[name]; !exists && !removed {
+				continue
+			}
+			paths = append(paths, id)
+			delete(n.removed, id)
+		}
+	}
+	n.Unlock()
+
+	if n.Storage.Exists(ctx, NamesFile) {
+		paths = append(paths, NamesFile)
+	}
+	return n.Storage.Add(ctx, paths...)
+}
+
+// Commit implements backend.Storage. The message is replaced, so it can't
+// reveal any names.
+func (n *nameIndex) Commit(ctx context.Context, msg string) error {
+	return n.Storage.Commit(ctx, namesCommitMsg)
+}
+
+// Revisions implements backend.Storage
+func (n *nameIndex) Revisions(ctx context.Context, name string) ([]backend.Revision, error) {
+	if isPlainName(name) {
+		return n.Storage.Revisions(ctx, name)
+	}
+	id, err := n.lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, errNotExist(name)
+	}
+	return n.Storage.Revisions(ctx, id)
+}
+
+// GetRevision implements backend.Storage
+func (n *nameIndex) GetRevision(ctx context.Context, name, revision string) ([]byte, error) {
+	if isPlainName(name) {
+		return n.Storage.GetRevision(ctx, name, revision)
+	}
+	id, err := n.lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, errNotExist(name)
+	}
+	return n.Storage.GetRevision(ctx, id, revision)
+}
+
+// ListRevision lists the files at the revision by their names. Identifiers
+// are never removed from the index, so this includes deleted secrets.
+func (n *nameIndex) ListRevision(ctx context.Context, revision string) ([]string, error) {
+	lr, ok := n.Storage.(interface {
+		ListRevision(context.Context, string) ([]string, error)
+	})
+	if !ok {
+		return nil, backend.ErrNotSupported
+	}
+	files, err := lr.ListRevision(ctx, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	n.Lock()
+	defer n.Unlock()
+
+	if err := n.load(ctx); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		if isPlainName(f) {
+			names = append(names, f)
+			continue
+		}
+		if name, found := n.names[f]; found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ConfigGet returns a config value of the wrapped storage, if it has any
+func (n *nameIndex) ConfigGet(ctx context.Context, key string) (string, error) {
+	cg, ok := n.Storage.(interface {
+		ConfigGet(context.Context, string) (string, error)
+	})
+	if !ok {
+		return "", backend.ErrNotSupported
+	}
+	return cg.ConfigGet(ctx, key)
+}
+
+// SignsCommits reports if the wrapped storage signs its commits, if it can
+func (n *nameIndex) SignsCommits(ctx context.Context) (bool, error) {
+	sc, ok := n.Storage.(interface {
+		SignsCommits(context.Context) (bool, error)
+	})
+	if !ok {
+		return false, backend.ErrNotSupported
+	}
+	return sc.SignsCommits(ctx)
+}
+
+// nameIndex returns the name index of the storage or nil if the names are
+// not encrypted
+func (s *Store) nameIndex() *nameIndex {
+	st := s.storage
+	if j, ok := st.(*journal); ok {
+		st = j.Storage
+	}
+	n, _ := st.(*nameIndex)
+	return n
+}
+
+// EncryptedNames returns true if the secret names of this store are
+// encrypted
+func (s *Store) EncryptedNames() bool {
+	return s.nameIndex() != nil
+}
+
+// wrapNames enables the name index if the store has one
+func (s *Store) wrapNames(ctx context.Context) {
+	if s.nameIndex() != nil || !s.storage.Exists(ctx, NamesFile) {
+		return
+	}
+	debug.Log("%s has encrypted names", s.path)
+	s.storage = &nameIndex{Storage: s.storage, s: s}
+}
+
+// enableEncryptedNames creates an empty index of names. Only empty stores
+// can be switched, existing stores have to be converted.
+func (s *Store) enableEncryptedNames(ctx context.Context) error {
+	if s.nameIndex() != nil {
+		return nil
+	}
+	if entries, err := s.List(ctx, ""); err != nil || len(entries) > 0 {
+		return fmt.Errorf("can not encrypt the names of a store with secrets, convert it instead")
+	}
+
+	n := &nameIndex{Storage: s.storage, s: s, ids: map[string]string{}, names: map[string]string{}}
+	n.Lock()
+	err := n.save(ctx)
+	n.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", NamesFile, err)
+	}
+	s.storage = n
+
+	if err := n.Storage.Add(ctx, NamesFile); err != nil {
+		if errors.Is(err, store.ErrGitNotInit) {
+			return nil
+		}
+		return fmt.Errorf("failed to add %q to git: %w", NamesFile, err)
+	}
+	if err := n.Storage.Commit(ctx, "Enable encrypted names"); err != nil {
+		if !errors.Is(err, store.ErrGitNotInit) && !errors.Is(err, store.ErrGitNothingToCommit) {
+			return fmt.Errorf("failed to commit changes to git: %w", err)
+		}
+	}
+	return nil
+}
+
+// reencryptNames encrypts the index for the current recipients
+func (s *Store) reencryptNames(ctx context.Context) error {
+	n := s.nameIndex()
+	if n == nil {
+		return nil
+	}
+
+	n.Lock()
+	err := n.load(ctx)
+	if err == nil {
+		err = n.save(ctx)
+	}
+	n.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt %s: %w", NamesFile, err)
+	}
+
+	if err := n.Storage.Add(ctx, NamesFile); err != nil && !errors.Is(err, store.ErrGitNotInit) {
+		return fmt.Errorf("failed to add %q to git: %w", NamesFile, err)
+	}
+	return nil
+}
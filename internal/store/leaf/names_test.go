@@ -0,0 +1,257 @@
+package leaf
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEncryptedNamesStore(ctx context.Context, t *testing.T, dir string) *Store {
+	t.Helper()
+
+	s, err := New(ctx, "", dir)
+	require.NoError(t, err)
+	require.NoError(t, s.Init(WithEncryptedNames(ctx, true), dir, "0xDEADBEEF"))
+	require.True(t, s.EncryptedNames())
+	return s
+}
+
+// storedFiles returns the names of all files on disk, except for the
+// internals of git
+func storedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var files []string
+	require.NoError(t, filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			files = append(files, strings.TrimPrefix(path, dir+string(filepath.Separator)))
+		}
+		return nil
+	}))
+	return files
+}
+
+func TestEncryptedNamesStore(t *testing.T) {
+	ctx := context.Background()
+	ctx = backend.WithCryptoBackendString(ctx, "plain")
+	ctx = backend.WithStorageBackendString(ctx, "fs")
+	ctx = ctxutil.WithExportKeys(ctx, false)
+
+	obuf := &bytes.Buffer{}
+	out.Stdout = obuf
+	defer func() {
+		out.Stdout = os.Stdout
+	}()
+
+	dir := t.TempDir()
+	s := newEncryptedNamesStore(ctx, t, dir)
+
+	sec := &secrets.Plain{}
+	sec.SetPassword("foo")
+	require.NoError(t, s.Set(ctx, "web/example.org", sec))
+	sec.SetPassword("bar")
+	require.NoError(t, s.Set(ctx, "mail/personal", sec))
+	require.NoError(t, s.SetTemplate(ctx, "web", []byte("user: {{ .Name }}")))
+	require.NoError(t, s.Link(ctx, "mail/personal", "web/mail"))
+
+	for _, f := range storedFiles(t, dir) {
+		for _, name := range []string{"web", "mail", "example", "personal", "template"} {
+			assert.NotContains(t, f, name)
+		}
+	}
+
+	// the index is found again when the store is opened
+	s, err := New(ctx, "", dir)
+	require.NoError(t, err)
+	assert.True(t, s.EncryptedNames())
+
+	list, err := s.List(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mail/personal", "web/example.org", "web/mail"}, list)
+	assert.True(t, s.IsDir(ctx, "web"))
+	assert.False(t, s.IsDir(ctx, "web/example.org"))
+	assert.Equal(t, []string{"web"}, s.ListTemplates(ctx, ""))
+
+	got, err := s.Get(ctx, "web/mail")
+	require.NoError(t, err)
+	assert.Equal(t, "bar", got.Password())
+
+	require.NoError(t, s.Move(ctx, "web/example.org", "web/example.com"))
+	assert.False(t, s.Exists(ctx, "web/example.org"))
+	got, err = s.Get(ctx, "web/example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", got.Password())
+
+	require.NoError(t, s.Prune(ctx, "web"))
+	list, err = s.List(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mail/personal"}, list)
+
+	t.Run("only empty stores", func(t *testing.T) {
+		dir := t.TempDir()
+		_, _, err := createStore(dir, nil, nil)
+		require.NoError(t, err)
+		s, err := New(ctx, "", dir)
+		require.NoError(t, err)
+		assert.Error(t, s.enableEncryptedNames(ctx))
+		assert.False(t, s.EncryptedNames())
+	})
+}
+
+func TestEncryptedNamesTwoStores(t *testing.T) {
+	ctx := context.Background()
+	ctx = backend.WithCryptoBackendString(ctx, "plain")
+	ctx = backend.WithStorageBackendString(ctx, "fs")
+	ctx = ctxutil.WithExportKeys(ctx, false)
+
+	obuf := &bytes.Buffer{}
+	out.Stdout = obuf
+	defer func() {
+		out.Stdout = os.Stdout
+	}()
+
+	dir := t.TempDir()
+	s1 := newEncryptedNamesStore(ctx, t, dir)
+	s2, err := New(ctx, "", dir)
+	require.NoError(t, err)
+	require.True(t, s2.EncryptedNames())
+
+	// both stores have loaded the index before the other one changes it
+	for _, s := range []*Store{s1, s2} {
+		list, err := s.List(ctx, "")
+		require.NoError(t, err)
+		assert.Empty(t, list)
+	}
+
+	sec := &secrets.Plain{}
+	sec.SetPassword("foo")
+	require.NoError(t, s1.Set(ctx, "one", sec))
+	sec.SetPassword("bar")
+	require.NoError(t, s2.Set(ctx, "two", sec))
+
+	for _, s := range []*Store{s1, s2} {
+		list, err := s.List(ctx, "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"one", "two"}, list)
+		got, err := s.Get(ctx, "one")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", got.Password())
+	}
+
+	s3, err := New(ctx, "", dir)
+	require.NoError(t, err)
+	list, err := s3.List(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one", "two"}, list)
+}
+
+func TestEncryptedNamesHistory(t *testing.T) {
+	ctx := context.Background()
+	ctx = backend.WithCryptoBackendString(ctx, "plain")
+	ctx = backend.WithStorageBackendString(ctx, "fs")
+	ctx = ctxutil.WithExportKeys(ctx, false)
+	ctx = ctxutil.WithUsername(ctx, "foo")
+	ctx = ctxutil.WithEmail(ctx, "foo@example.org")
+
+	obuf := &bytes.Buffer{}
+	out.Stdout = obuf
+	defer func() {
+		out.Stdout = os.Stdout
+	}()
+
+	dir := t.TempDir()
+	s := newEncryptedNamesStore(ctx, t, dir)
+	require.NoError(t, s.GitInit(backend.WithStorageBackend(ctx, backend.GoGit)))
+	require.True(t, s.EncryptedNames())
+
+	for _, pw := range []string{"one", "two"} {
+		sec := &secrets.Plain{}
+		sec.SetPassword(pw)
+		require.NoError(t, s.Set(ctxutil.WithCommitMessage(ctx, "changed web/example.org"), "web/example.org", sec))
+	}
+
+	revs, err := s.ListRevisions(ctx, "web/example.org")
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	for _, r := range revs {
+		assert.NotContains(t, r.Subject, "example")
+	}
+	old, err := s.GetRevision(ctx, "web/example.org", revs[1].Hash)
+	require.NoError(t, err)
+	assert.Equal(t, "one", old.Password())
+
+	// the history survives deleting and re-creating a secret
+	require.NoError(t, s.Delete(ctx, "web/example.org"))
+	sec := &secrets.Plain{}
+	sec.SetPassword("three")
+	require.NoError(t, s.Set(ctx, "web/example.org", sec))
+	revs, err = s.ListRevisions(ctx, "web/example.org")
+	require.NoError(t, err)
+	assert.Len(t, revs, 4)
+}
+
+func TestEncryptedNamesConvert(t *testing.T) {
+	ctx := context.Background()
+	ctx = backend.WithCryptoBackendString(ctx, "plain")
+	ctx = backend.WithStorageBackendString(ctx, "fs")
+	ctx = ctxutil.WithExportKeys(ctx, false)
+
+	obuf := &bytes.Buffer{}
+	out.Stdout = obuf
+	defer func() {
+		out.Stdout = os.Stdout
+	}()
+
+	dir := filepath.Join(t.TempDir(), "store")
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	_, _, err := createStore(dir, nil, []string{})
+	require.NoError(t, err)
+	s, err := New(ctx, "", dir)
+	require.NoError(t, err)
+
+	sec := &secrets.Plain{}
+	sec.SetPassword("foo")
+	require.NoError(t, s.Set(ctx, "web/example.org", sec))
+	require.NoError(t, s.SetTemplate(ctx, "web", []byte("tpl")))
+	require.NoError(t, s.SetLink(ctx, "web/alias", "web/example.org"))
+
+	require.NoError(t, s.Convert(WithEncryptedNames(ctx, true), backend.Plain, backend.FS, true))
+
+	s, err = New(ctx, "", dir)
+	require.NoError(t, err)
+	require.True(t, s.EncryptedNames())
+	assert.NoDirExists(t, filepath.Join(dir, "web"))
+
+	got, err := s.Get(ctx, "web/example.org")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", got.Password())
+	tpl, err := s.GetTemplate(ctx, "web")
+	require.NoError(t, err)
+	assert.Equal(t, "tpl", string(tpl))
+	assert.Equal(t, map[string]string{"web/alias": "web/example.org"}, s.ListLinks(ctx, ""))
+
+	// and back again
+	require.NoError(t, os.RemoveAll(dir+"-backup"))
+	require.NoError(t, s.Convert(ctx, backend.Plain, backend.FS, true))
+	s, err = New(ctx, "", dir)
+	require.NoError(t, err)
+	assert.False(t, s.EncryptedNames())
+	assert.FileExists(t, filepath.Join(dir, "web", "example.org."+s.crypto.Ext()))
+}
@@ -3,6 +3,7 @@ package leaf
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/itsonlycode/gosecret/internal/backend"
 	"github.com/itsonlycode/gosecret/internal/out"
@@ -14,11 +15,19 @@ import (
 
 // GitInit initializes the git storage
 func (s *Store) GitInit(ctx context.Context) error {
+	release, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	storage, err := backend.InitStorage(ctx, backend.GetStorageBackend(ctx), s.path)
 	if err != nil {
 		return err
 	}
 	s.storage = storage
+	s.wrapLock()
+	s.wrapNames(ctx)
 	return nil
 }
 
@@ -28,6 +37,49 @@ func (s *Store) ListRevisions(ctx context.Context, name string) ([]backend.Revis
 	return s.storage.Revisions(ctx, p)
 }
 
+// ListFolderRevisions will list all revisions of a folder. With encrypted
+// names folders only exist in the index, so the revisions of the whole store
+// are listed instead.
+func (s *Store) ListFolderRevisions(ctx context.Context, folder string) ([]backend.Revision, error) {
+	folder = strings.Trim(folder, "/")
+	if s.nameIndex() != nil {
+		folder = ""
+	}
+	return s.storage.Revisions(ctx, folder)
+}
+
+// ListRevision will list the secrets below the folder at the revision
+func (s *Store) ListRevision(ctx context.Context, folder, revision string) ([]string, error) {
+	lr, ok := s.storage.(interface {
+		ListRevision(context.Context, string) ([]string, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("storage %s can not list revisions: %w", s.storage.Name(), backend.ErrNotSupported)
+	}
+	files, err := lr.ListRevision(ctx, revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revision %q: %w", revision, err)
+	}
+
+	folder = strings.Trim(folder, "/")
+	cExt := "." + s.crypto.Ext()
+	out := make([]string, 0, len(files))
+	for _, path := range files {
+		if !strings.HasSuffix(path, cExt) {
+			continue
+		}
+		path = strings.TrimSuffix(path, cExt)
+		if folder != "" && !strings.HasPrefix(path, folder+"/") {
+			continue
+		}
+		if s.alias != "" {
+			path = s.alias + Sep + path
+		}
+		out = append(out, path)
+	}
+	return out, nil
+}
+
 // GetRevision will retrieve a single revision from the backend
 func (s *Store) GetRevision(ctx context.Context, name, revision string) (gosecret.Secret, error) {
 	p := s.passfile(name)
@@ -15,6 +15,8 @@ import (
 
 // Get returns the plaintext of a single key
 func (s *Store) Get(ctx context.Context, name string) (gosecret.Secret, error) {
+	s.autoSync(ctx)
+
 	p := s.passfile(name)
 
 	ciphertext, err := s.storage.Get(ctx, p)
@@ -59,6 +59,12 @@ func (s *Store) RecipientsTree(ctx context.Context) map[string][]string {
 
 // AddRecipient adds a new recipient to the list
 func (s *Store) AddRecipient(ctx context.Context, id string) error {
+	release, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	rs, err := s.GetRecipients(ctx, "")
 	if err != nil {
 		return fmt.Errorf("failed to read recipient list: %w", err)
@@ -83,6 +89,12 @@ func (s *Store) AddRecipient(ctx context.Context, id string) error {
 
 // SaveRecipients persists the current recipients on disk
 func (s *Store) SaveRecipients(ctx context.Context) error {
+	release, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	rs, err := s.GetRecipients(ctx, "")
 	if err != nil {
 		return fmt.Errorf("failed to get recipients: %w", err)
@@ -92,6 +104,12 @@ func (s *Store) SaveRecipients(ctx context.Context) error {
 
 // SetRecipients will update the stored recipients and the associated checksum
 func (s *Store) SetRecipients(ctx context.Context, rs []string) error {
+	release, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	return s.saveRecipients(ctx, rs, "Set Recipients")
 }
 
@@ -99,6 +117,12 @@ func (s *Store) SetRecipients(ctx context.Context, rs []string) error {
 // but if this key is not available on this machine we
 // just try to remove it literally
 func (s *Store) RemoveRecipient(ctx context.Context, id string) error {
+	release, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	keys, err := s.crypto.FindRecipients(ctx, id)
 	if err != nil {
 		out.Printf(ctx, "Warning: Failed to get GPG Key Info for %s: %s", id, err)
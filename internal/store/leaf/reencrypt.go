@@ -52,6 +52,10 @@ func (s *Store) reencrypt(ctx context.Context) error {
 						logger.Printf("Worker %d: Failed to get current value for %s: %s\n", workerId, e, err)
 						continue
 					}
+					if err := s.reencryptChunks(WithNoGitOps(ctx, conc > 1), e, content); err != nil {
+						logger.Printf("Worker %d: Failed to re-encrypt the chunks or attachments of %s: %s\n", workerId, e, err)
+						continue
+					}
 					if err := s.Set(WithNoGitOps(ctx, conc > 1), e, content); err != nil {
 						logger.Printf("Worker %d: Failed to write %s: %s\n", workerId, e, err)
 						continue
@@ -99,9 +103,21 @@ func (s *Store) reencrypt(ctx context.Context) error {
 				return fmt.Errorf("failed to add %q to git: %w", p, err)
 			}
 			debug.Log("added %s to git", p)
+			for _, d := range []string{s.chunkDir(name), s.attachmentsDir(name)} {
+				if !s.storage.IsDir(ctx, d) {
+					continue
+				}
+				if err := s.storage.Add(ctx, d); err != nil {
+					return fmt.Errorf("failed to add %q to git: %w", d, err)
+				}
+			}
 		}
 	}
 
+	if err := s.reencryptNames(ctx); err != nil {
+		return err
+	}
+
 	if err := s.storage.Commit(ctx, ctxutil.GetCommitMessage(ctx)); err != nil {
 		switch {
 		case errors.Is(err, store.ErrGitNotInit):
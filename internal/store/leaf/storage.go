@@ -15,5 +15,6 @@ func (s *Store) initStorageBackend(ctx context.Context) error {
 		return fmt.Errorf("unknown storage backend: %w", err)
 	}
 	s.storage = store
+	s.wrapLock()
 	return nil
 }
@@ -32,6 +32,7 @@ func Init(ctx context.Context, alias, path string) (*Store, error) {
 		return nil, err
 	}
 	s.storage = st
+	s.wrapLock()
 	debug.Log("Storage initialized")
 
 	crypto, err := backend.NewCrypto(ctx, backend.GetCryptoBackend(ctx))
@@ -65,6 +66,9 @@ func New(ctx context.Context, alias, path string) (*Store, error) {
 	}
 	debug.Log("Crypto initialized")
 
+	// the index of names can only be read once the crypto backend is set up
+	s.wrapNames(ctx)
+
 	debug.Log("Instantiated %s at %s - storage: %+#v - crypto: %+#v", alias, path, s.storage, s.crypto)
 	return s, nil
 }
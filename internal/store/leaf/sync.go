@@ -0,0 +1,165 @@
+package leaf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+
+	"github.com/dustin/go-humanize"
+)
+
+// SyncState is the state of the sync of a store with its remote. It's kept
+// in the cache dir, so it's shared by all processes of the user.
+type SyncState struct {
+	// LastSync is the time of the last successful pull or push
+	LastSync time.Time `json:"lastSync,omitempty"`
+	// LastAttempt is the time of the last pull or push
+	LastAttempt time.Time `json:"lastAttempt,omitempty"`
+	// Error is set if the last attempt failed
+	Error string `json:"error,omitempty"`
+}
+
+// Offline returns true if the last attempt failed less than retry ago. The
+// remote is not tried again before that.
+func (st SyncState) Offline(now time.Time, retry time.Duration) bool {
+	return st.Error != "" && now.Sub(st.LastAttempt) < retry
+}
+
+// String implements fmt.Stringer
+func (st SyncState) String() string {
+	switch {
+	case st.Error != "":
+		return "offline, last attempt " + humanize.Time(st.LastAttempt)
+	case st.LastSync.IsZero():
+		return "never synced"
+	default:
+		return "synced " + humanize.Time(st.LastSync)
+	}
+}
+
+// syncStateFile returns the name of the file with the sync state of the
+// store at path
+func syncStateFile(path string) string {
+	return cacheFile(path, "sync", ".json")
+}
+
+// SyncState returns the state of the sync of the store with its remote
+func (s *Store) SyncState() SyncState {
+	var st SyncState
+
+	buf, err := os.ReadFile(syncStateFile(s.path))
+	if err != nil {
+		return st
+	}
+	if err := json.Unmarshal(buf, &st); err != nil {
+		debug.Log("invalid sync state of %s: %s", s.path, err)
+		return SyncState{}
+	}
+	return st
+}
+
+// saveSyncState writes the sync state. It's only informational, so
+// failures are ignored.
+func (s *Store) saveSyncState(st SyncState) {
+	fn := syncStateFile(s.path)
+	buf, err := json.Marshal(st)
+	if err != nil {
+		debug.Log("failed to encode sync state of %s: %s", s.path, err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+		debug.Log("failed to create %s: %s", filepath.Dir(fn), err)
+		return
+	}
+	tmp := fn + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0600); err != nil {
+		debug.Log("failed to write sync state of %s: %s", s.path, err)
+		return
+	}
+	if err := os.Rename(tmp, fn); err != nil {
+		debug.Log("failed to write sync state of %s: %s", s.path, err)
+		_ = os.Remove(tmp)
+	}
+}
+
+// recordSync updates the sync state after a pull or a push
+func (s *Store) recordSync(err error) {
+	st := s.SyncState()
+	st.LastAttempt = time.Now().UTC()
+	st.Error = ""
+	if err != nil {
+		st.Error = err.Error()
+	} else {
+		st.LastSync = st.LastAttempt
+	}
+	s.saveSyncState(st)
+}
+
+// noSync returns true for errors of stores that can't be synced
+func noSync(err error) bool {
+	return errors.Is(err, store.ErrGitNotInit) ||
+		errors.Is(err, store.ErrGitNoRemote) ||
+		errors.Is(err, backend.ErrNotSupported)
+}
+
+// offline returns true if auto-sync is enabled and the remote could not be
+// reached recently
+func (s *Store) offline(ctx context.Context) bool {
+	interval := ctxutil.GetSyncInterval(ctx)
+	return interval > 0 && s.SyncState().Offline(time.Now(), interval)
+}
+
+// autoSync pulls the changes of the remote before a read or a listing if
+// the last sync is older than the sync interval and pushes the commits that
+// were left over, e.g. while offline. Pushes that fail without auto-sync are
+// retried by the durable queue instead. A remote that could not be reached
+// is not tried again before the interval passed, so working offline stays
+// fast. Failures never fail the read.
+func (s *Store) autoSync(ctx context.Context) {
+	interval := ctxutil.GetSyncInterval(ctx)
+	if interval <= 0 || ctxutil.IsNoNetwork(ctx) || s.InTx() {
+		return
+	}
+	st := s.SyncState()
+	now := time.Now()
+	if now.Sub(st.LastSync) < interval || st.Offline(now, interval) {
+		return
+	}
+
+	debug.Log("auto-sync of %s, last sync %s", s.path, st.LastSync)
+	err := s.storage.Pull(ctx, "", "")
+	if err == nil {
+		err = s.storage.Push(ctx, "", "")
+	}
+	if noSync(err) {
+		debug.Log("auto-sync of %s skipped: %s", s.path, err)
+		return
+	}
+	s.recordSync(err)
+	if errors.Is(err, store.ErrGitUnverified) {
+		out.Errorf(ctx, "Rejected changes of the remote of %s: %s", s.path, err)
+		return
+	}
+	if err != nil {
+		out.Warningf(ctx, "Failed to sync %s with its remote, working offline: %s", s.path, err)
+	}
+}
+
+// Sync pushes the local changes to the remote and records the result. Git
+// stores pull the changes of the remote first.
+func (s *Store) Sync(ctx context.Context) error {
+	err := s.storage.Push(ctx, "", "")
+	if !noSync(err) && !ctxutil.IsNoNetwork(ctx) {
+		s.recordSync(err)
+	}
+	return err
+}
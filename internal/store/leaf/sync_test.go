@@ -0,0 +1,138 @@
+package leaf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/itsonlycode/gosecret/internal/backend/crypto/plain"
+	"github.com/itsonlycode/gosecret/internal/backend/storage/inmem"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func commitTo(ctx context.Context, t *testing.T, m *inmem.InMem, name string) {
+	t.Helper()
+
+	require.NoError(t, m.Set(ctx, name, []byte(name)))
+	require.NoError(t, m.Add(ctx, name))
+	require.NoError(t, m.Commit(ctx, "add "+name))
+}
+
+func TestAutoSync(t *testing.T) {
+	ctx := WithLockTimeout(context.Background(), 300*time.Millisecond)
+	path := setupLockTest(t)
+	remotePath := path + "-remote"
+
+	remote := inmem.Open(remotePath)
+	local := inmem.Open(path)
+	defer inmem.Discard(remotePath)
+	defer inmem.Discard(path)
+	require.NoError(t, local.AddRemote(ctx, "origin", remotePath))
+
+	s := &Store{path: path, storage: local}
+	s.wrapLock()
+
+	commitTo(ctx, t, remote, "foo")
+
+	t.Run("disabled", func(t *testing.T) {
+		s.autoSync(ctx)
+		assert.Equal(t, SyncState{}, s.SyncState())
+		assert.Equal(t, "never synced", s.SyncState().String())
+		assert.False(t, local.Exists(ctx, "foo"))
+	})
+
+	ctx = ctxutil.WithSyncInterval(ctx, time.Minute)
+
+	t.Run("no network", func(t *testing.T) {
+		s.autoSync(ctxutil.WithNoNetwork(ctx, true))
+		assert.Equal(t, SyncState{}, s.SyncState())
+	})
+
+	t.Run("pull", func(t *testing.T) {
+		s.autoSync(ctx)
+		assert.True(t, local.Exists(ctx, "foo"))
+		st := s.SyncState()
+		assert.False(t, st.LastSync.IsZero())
+		assert.Equal(t, "", st.Error)
+		assert.Contains(t, st.String(), "synced")
+
+		// not again before the interval passed
+		s.autoSync(ctx)
+		assert.Equal(t, st, s.SyncState())
+	})
+
+	t.Run("offline", func(t *testing.T) {
+		require.NoError(t, local.RemoveRemote(ctx, "origin"))
+		require.NoError(t, local.AddRemote(ctx, "origin", remotePath+"-unreachable"))
+		s.saveSyncState(SyncState{LastSync: time.Now().Add(-time.Hour)})
+
+		s.autoSync(ctx)
+		st := s.SyncState()
+		assert.NotEqual(t, "", st.Error)
+		assert.True(t, s.offline(ctx))
+		assert.Contains(t, st.String(), "offline")
+
+		// changes are committed, but not pushed while offline
+		commitTo(ctx, t, local, "baz")
+		require.NoError(t, s.gitCommitAndPush(ctx, "baz"))
+		assert.False(t, remote.Exists(ctx, "baz"))
+	})
+
+	t.Run("back online", func(t *testing.T) {
+		require.NoError(t, local.RemoveRemote(ctx, "origin"))
+		require.NoError(t, local.AddRemote(ctx, "origin", remotePath))
+		st := s.SyncState()
+		st.LastAttempt = time.Now().Add(-2 * time.Minute)
+		s.saveSyncState(st)
+		assert.False(t, s.offline(ctx))
+
+		s.autoSync(ctx)
+		st = s.SyncState()
+		assert.Equal(t, "", st.Error)
+		assert.True(t, remote.Exists(ctx, "baz"))
+	})
+
+	t.Run("failed push", func(t *testing.T) {
+		require.NoError(t, local.RemoveRemote(ctx, "origin"))
+		require.NoError(t, local.AddRemote(ctx, "origin", remotePath+"-unreachable"))
+		commitTo(ctx, t, local, "zab")
+
+		assert.NoError(t, s.push(ctx))
+		assert.NotEqual(t, "", s.SyncState().Error)
+		assert.Error(t, s.push(ctxutil.WithSyncInterval(ctx, 0)))
+		assert.Error(t, s.Sync(ctx))
+	})
+}
+
+func TestSyncStateOffline(t *testing.T) {
+	now := time.Now()
+
+	assert.False(t, SyncState{}.Offline(now, time.Minute))
+	assert.True(t, SyncState{Error: "offline", LastAttempt: now.Add(-time.Second)}.Offline(now, time.Minute))
+	assert.False(t, SyncState{Error: "offline", LastAttempt: now.Add(-time.Hour)}.Offline(now, time.Minute))
+}
+
+func TestAutoSyncList(t *testing.T) {
+	ctx := WithLockTimeout(context.Background(), 300*time.Millisecond)
+	ctx = ctxutil.WithSyncInterval(ctx, time.Minute)
+	path := setupLockTest(t)
+	remotePath := path + "-remote"
+
+	remote := inmem.Open(remotePath)
+	local := inmem.Open(path)
+	defer inmem.Discard(remotePath)
+	defer inmem.Discard(path)
+	require.NoError(t, local.AddRemote(ctx, "origin", remotePath))
+
+	s := &Store{path: path, storage: local, crypto: plain.New()}
+	s.wrapLock()
+
+	commitTo(ctx, t, remote, "foo.txt")
+	lst, err := s.List(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo"}, lst)
+	assert.False(t, s.SyncState().LastSync.IsZero())
+}
@@ -97,6 +97,12 @@ func (s *Store) GetTemplate(ctx context.Context, name string) ([]byte, error) {
 
 // SetTemplate will (over)write the content to the template file
 func (s *Store) SetTemplate(ctx context.Context, name string, content []byte) error {
+	release, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	p := s.templatefile(name)
 
 	if err := s.storage.Set(ctx, p, content); err != nil {
@@ -119,6 +125,12 @@ func (s *Store) SetTemplate(ctx context.Context, name string, content []byte) er
 
 // RemoveTemplate will delete the named template if it exists
 func (s *Store) RemoveTemplate(ctx context.Context, name string) error {
+	release, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	p := s.templatefile(name)
 
 	if err := s.storage.Delete(ctx, p); err != nil {
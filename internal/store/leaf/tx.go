@@ -0,0 +1,252 @@
+package leaf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+)
+
+// ErrTxActive is returned by BeginTx if the store is in a transaction already
+var ErrTxActive = errors.New("store is in a transaction already")
+
+// journal wraps the storage of a store during a transaction. It remembers
+// the original content of every file before it's changed for the first
+// time, so all changes can be rolled back. Commits and pushes are deferred
+// until the transaction ends.
+type journal struct {
+	backend.Storage
+
+	sync.Mutex
+	// orig is nil for files that did not exist
+	orig map[string][]byte
+}
+
+// remember records the content of a file before it's changed. Changes
+// that could not be rolled back are refused.
+func (j *journal) remember(ctx context.Context, name string) error {
+	j.Lock()
+	defer j.Unlock()
+
+	if _, found := j.orig[name]; found {
+		return nil
+	}
+	if !j.Storage.Exists(ctx, name) {
+		j.orig[name] = nil
+		return nil
+	}
+	buf, err := j.Storage.Get(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to read %s before changing it: %w", name, err)
+	}
+	j.orig[name] = buf
+	return nil
+}
+
+// Set implements backend.Storage
+func (j *journal) Set(ctx context.Context, name string, value []byte) error {
+	if err := j.remember(ctx, name); err != nil {
+		return err
+	}
+	return j.Storage.Set(ctx, name, value)
+}
+
+// Delete implements backend.Storage
+func (j *journal) Delete(ctx context.Context, name string) error {
+	if err := j.remember(ctx, name); err != nil {
+		return err
+	}
+	return j.Storage.Delete(ctx, name)
+}
+
+// Prune implements backend.Storage
+func (j *journal) Prune(ctx context.Context, prefix string) error {
+	files, err := j.Storage.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	dir := strings.TrimSuffix(strings.TrimPrefix(prefix, "/"), "/")
+	for _, f := range files {
+		if f == dir || strings.HasPrefix(f, dir+"/") {
+			if err := j.remember(ctx, f); err != nil {
+				return err
+			}
+		}
+	}
+	return j.Storage.Prune(ctx, prefix)
+}
+
+// Link implements backend.Storage
+func (j *journal) Link(ctx context.Context, from, to string) error {
+	if err := j.remember(ctx, to); err != nil {
+		return err
+	}
+	return j.Storage.Link(ctx, from, to)
+}
+
+// Commit is deferred until CommitTx
+func (j *journal) Commit(ctx context.Context, msg string) error {
+	debug.Log("deferring commit %q until the end of the transaction", msg)
+	return nil
+}
+
+// Push is deferred until the end of the transaction
+func (j *journal) Push(ctx context.Context, remote, location string) error {
+	return nil
+}
+
+// ConfigGet returns a config value of the wrapped storage, if it has any
+func (j *journal) ConfigGet(ctx context.Context, key string) (string, error) {
+	cg, ok := j.Storage.(interface {
+		ConfigGet(context.Context, string) (string, error)
+	})
+	if !ok {
+		return "", backend.ErrNotSupported
+	}
+	return cg.ConfigGet(ctx, key)
+}
+
+// ListRevision lists the files of the wrapped storage at the revision, if
+// it can
+func (j *journal) ListRevision(ctx context.Context, revision string) ([]string, error) {
+	lr, ok := j.Storage.(interface {
+		ListRevision(context.Context, string) ([]string, error)
+	})
+	if !ok {
+		return nil, backend.ErrNotSupported
+	}
+	return lr.ListRevision(ctx, revision)
+}
+
+// SignsCommits reports if the wrapped storage signs its commits, if it can
+func (j *journal) SignsCommits(ctx context.Context) (bool, error) {
+	sc, ok := j.Storage.(interface {
+		SignsCommits(context.Context) (bool, error)
+	})
+	if !ok {
+		return false, backend.ErrNotSupported
+	}
+	return sc.SignsCommits(ctx)
+}
+
+// paths returns the changed files in a stable order
+func (j *journal) paths() []string {
+	j.Lock()
+	defer j.Unlock()
+
+	paths := make([]string, 0, len(j.orig))
+	for p := range j.orig {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// BeginTx starts a transaction. Until it's finished with CommitTx or
+// RollbackTx all changed files are journaled and commits and pushes are
+// deferred.
+func (s *Store) BeginTx() error {
+	if s.InTx() {
+		return ErrTxActive
+	}
+	// the lock is held until the transaction ends
+	if err := lockFor(s.path).acquire(context.Background()); err != nil {
+		return err
+	}
+	s.storage = &journal{
+		Storage: s.storage,
+		orig:    make(map[string][]byte),
+	}
+	return nil
+}
+
+// InTx returns true if the store is in a transaction
+func (s *Store) InTx() bool {
+	_, ok := s.storage.(*journal)
+	return ok
+}
+
+func (s *Store) endTx() *journal {
+	j, ok := s.storage.(*journal)
+	if !ok {
+		return nil
+	}
+	s.storage = j.Storage
+	return j
+}
+
+// CommitTx ends the transaction and commits all changes at once with the
+// given message. It returns false if nothing was changed. The changes are not
+// pushed. If the commit fails, the changes are rolled back.
+func (s *Store) CommitTx(ctx context.Context, msg string) (bool, error) {
+	j := s.endTx()
+	if j == nil {
+		return false, nil
+	}
+	defer lockFor(s.path).release()
+
+	if len(j.paths()) < 1 {
+		return false, nil
+	}
+
+	if err := s.storage.Commit(ctx, msg); err != nil {
+		switch {
+		case errors.Is(err, store.ErrGitNotInit):
+			debug.Log("skipping git commit - git not initialized")
+		case errors.Is(err, store.ErrGitNothingToCommit):
+			debug.Log("skipping git commit - nothing to commit")
+		default:
+			if rerr := s.restore(ctx, j); rerr != nil {
+				debug.Log("failed to roll back: %s", rerr)
+			}
+			return true, fmt.Errorf("failed to commit changes to git: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// RollbackTx ends the transaction and restores all files that were changed
+// during the transaction.
+func (s *Store) RollbackTx(ctx context.Context) error {
+	j := s.endTx()
+	if j == nil {
+		return nil
+	}
+	defer lockFor(s.path).release()
+
+	return s.restore(ctx, j)
+}
+
+func (s *Store) restore(ctx context.Context, j *journal) error {
+	var failed []string
+	for _, p := range j.paths() {
+		buf := j.orig[p]
+		var err error
+		switch {
+		case buf != nil:
+			err = s.storage.Set(ctx, p, buf)
+		case s.storage.Exists(ctx, p):
+			err = s.storage.Delete(ctx, p)
+		}
+		if err != nil {
+			debug.Log("failed to restore %s: %s", p, err)
+			failed = append(failed, p)
+			continue
+		}
+		// reset the index, too. Files that were never added can't be.
+		if err := s.storage.Add(ctx, p); err != nil && !errors.Is(err, store.ErrGitNotInit) {
+			debug.Log("failed to add restored %s: %s", p, err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to restore %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
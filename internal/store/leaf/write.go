@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/itsonlycode/gosecret/internal/backend"
 	"github.com/itsonlycode/gosecret/internal/out"
 	"github.com/itsonlycode/gosecret/internal/queue"
 	"github.com/itsonlycode/gosecret/internal/store"
@@ -14,12 +15,25 @@ import (
 	"github.com/itsonlycode/gosecret/pkg/gosecret"
 )
 
+// pushJob is the kind of the queued pushes
+const pushJob = "push"
+
+func init() {
+	queue.RegisterHandler(pushJob, pushStore)
+}
+
 // Set encodes and writes the cipertext of one entry to disk
 func (s *Store) Set(ctx context.Context, name string, sec gosecret.Byter) error {
 	if strings.Contains(name, "//") {
 		return fmt.Errorf("invalid secret name: %s", name)
 	}
 
+	release, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	p := s.passfile(name)
 
 	recipients, err := s.useableKeys(ctx, name)
@@ -59,14 +73,11 @@ func (s *Store) Set(ctx context.Context, name string, sec gosecret.Byter) error
 		return nil
 	}
 
-	// try to enqueue this task, if the queue is not available
-	// it will return the task and we will execute it inline
-	t := queue.GetQueue(ctx).Add(func(ctx context.Context) error {
-		return s.gitCommitAndPush(ctx, name)
-	})
-	return t(ctx)
+	return s.gitCommitAndPush(ctx, name)
 }
 
+// gitCommitAndPush commits the staged changes right away. The push is left
+// to the queue, if there is one, so it doesn't hold up the command.
 func (s *Store) gitCommitAndPush(ctx context.Context, name string) error {
 	if err := s.storage.Commit(ctx, fmt.Sprintf("Save secret to %s: %s", name, ctxutil.GetCommitMessage(ctx))); err != nil {
 		switch {
@@ -78,7 +89,37 @@ func (s *Store) gitCommitAndPush(ctx context.Context, name string) error {
 			return fmt.Errorf("failed to commit changes to git: %w", err)
 		}
 	}
+	return s.Push(ctx)
+}
+
+// Push pushes the commits of this store to its remote. It does nothing
+// without network. If the remote is unreachable or the push fails with
+// auto-sync enabled, it's left to the next sync. The push is queued if a
+// queue is available.
+func (s *Store) Push(ctx context.Context) error {
+	if ctxutil.IsNoNetwork(ctx) {
+		debug.Log("commitAndPush - skipping git push - no network")
+		return nil
+	}
+	if s.offline(ctx) {
+		debug.Log("commitAndPush - skipping git push - remote unreachable, pushing with the next sync")
+		return nil
+	}
+
+	// try to enqueue the push, if the queue is not available it will return
+	// the task and we will execute it inline. Pushes of the same store are
+	// coalesced.
+	t := queue.GetQueue(ctx).AddJob(queue.Job{
+		Kind: pushJob,
+		Arg:  s.path,
+		Run:  s.push,
+	})
+	return t(ctx)
+}
 
+// push pushes all commits to the remote. With auto-sync a failed push is
+// left to the next sync.
+func (s *Store) push(ctx context.Context) error {
 	debug.Log("syncing with remote ...")
 	if err := s.storage.Push(ctx, "", ""); err != nil {
 		if errors.Is(err, store.ErrGitNotInit) {
@@ -93,8 +134,26 @@ func (s *Store) gitCommitAndPush(ctx context.Context, name string) error {
 			debug.Log(msg)
 			return nil
 		}
+		s.recordSync(err)
+		if ctxutil.GetSyncInterval(ctx) > 0 {
+			out.Warningf(ctx, "Failed to push %s, the changes are pushed with the next sync: %s", s.path, err)
+			return nil
+		}
 		return fmt.Errorf("failed to push to git remote: %w", err)
 	}
+	s.recordSync(nil)
 	debug.Log("synced with remote")
 	return nil
 }
+
+// pushStore pushes the store at path. It replays the pushes that failed in
+// an earlier run.
+func pushStore(ctx context.Context, path string) error {
+	st, err := backend.DetectStorage(ctx, path)
+	if err != nil {
+		return queue.Permanent(err)
+	}
+	s := &Store{path: path, storage: st}
+	s.wrapLock()
+	return s.push(ctx)
+}
@@ -0,0 +1,67 @@
+package root
+
+import (
+	"context"
+	"io"
+
+	"github.com/itsonlycode/gosecret/internal/store/leaf"
+)
+
+// ListAttachments returns the names of the attachments of a secret. Links
+// are followed.
+func (r *Store) ListAttachments(ctx context.Context, name string) ([]string, error) {
+	name, err := r.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	store, name := r.getStore(name)
+	return store.ListAttachments(ctx, name)
+}
+
+// SetAttachment adds or replaces the attachment att of an existing secret
+func (r *Store) SetAttachment(ctx context.Context, name, att string, in io.Reader) (*leaf.Manifest, error) {
+	name, err := r.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = r.writeCtx(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	store, name := r.getStore(name)
+	return store.SetAttachment(ctx, name, att, in)
+}
+
+// GetAttachment writes the content of the attachment att of a secret to w
+func (r *Store) GetAttachment(ctx context.Context, name, att string, w io.Writer) (*leaf.Manifest, error) {
+	name, err := r.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	store, name := r.getStore(name)
+	return store.GetAttachment(ctx, name, att, w)
+}
+
+// AttachmentManifest returns the manifest of the attachment att of a secret
+func (r *Store) AttachmentManifest(ctx context.Context, name, att string) (*leaf.Manifest, error) {
+	name, err := r.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	store, name := r.getStore(name)
+	return store.AttachmentManifest(ctx, name, att)
+}
+
+// RemoveAttachment removes the attachment att from a secret
+func (r *Store) RemoveAttachment(ctx context.Context, name, att string) error {
+	name, err := r.resolve(ctx, name)
+	if err != nil {
+		return err
+	}
+	ctx, err = r.writeCtx(ctx, name)
+	if err != nil {
+		return err
+	}
+	store, name := r.getStore(name)
+	return store.RemoveAttachment(ctx, name, att)
+}
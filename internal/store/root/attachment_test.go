@@ -0,0 +1,62 @@
+package root
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+	"github.com/itsonlycode/gosecret/tests/gptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachmentsAcrossMounts(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithHidden(ctx, true)
+	ctx = backend.WithCryptoBackend(ctx, backend.Plain)
+
+	rs, err := createRootStore(ctx, u)
+	require.NoError(t, err)
+
+	require.NoError(t, u.InitStore("sub1"))
+	require.NoError(t, rs.AddMount(ctx, "sub1", u.StoreDir("sub1")))
+
+	sec := secrets.New()
+	sec.SetPassword("keystore password")
+	require.NoError(t, rs.Set(ctx, "certs/web", sec))
+	_, err = rs.SetAttachment(ctx, "certs/web", "keystore.p12", bytes.NewReader([]byte("p12 data")))
+	require.NoError(t, err)
+
+	require.NoError(t, rs.Move(ctx, "certs/web", "sub1/certs/web"))
+	_, err = rs.ListAttachments(ctx, "certs/web")
+	assert.Error(t, err)
+	assert.False(t, rs.IsDir(ctx, "certs"))
+
+	require.NoError(t, rs.Copy(ctx, "sub1/certs/web", "web"))
+	for _, name := range []string{"sub1/certs/web", "web"} {
+		atts, err := rs.ListAttachments(ctx, name)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"keystore.p12"}, atts, name)
+
+		buf := &bytes.Buffer{}
+		_, err = rs.GetAttachment(ctx, name, "keystore.p12", buf)
+		require.NoError(t, err)
+		assert.Equal(t, "p12 data", buf.String(), name)
+	}
+
+	require.NoError(t, rs.Delete(ctx, "web"))
+	_, err = rs.AttachmentManifest(ctx, "web", "keystore.p12")
+	assert.Error(t, err)
+	require.NoError(t, rs.RemoveAttachment(ctx, "sub1/certs/web", "keystore.p12"))
+	atts, err := rs.ListAttachments(ctx, "sub1/certs/web")
+	require.NoError(t, err)
+	assert.Empty(t, atts)
+}
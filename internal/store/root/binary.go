@@ -0,0 +1,34 @@
+package root
+
+import (
+	"context"
+	"io"
+
+	"github.com/itsonlycode/gosecret/internal/store/leaf"
+)
+
+// SetBinary stores the content of r as a chunked binary secret. If name is a
+// link the secret it points to is updated.
+func (r *Store) SetBinary(ctx context.Context, name, filename string, in io.Reader) (*leaf.Manifest, error) {
+	name, err := r.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = r.writeCtx(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	store, name := r.getStore(name)
+	return store.SetBinary(ctx, name, filename, in)
+}
+
+// GetBinary writes the content of a chunked binary secret to w. It returns
+// leaf.ErrNotChunked for other secrets. Links are followed.
+func (r *Store) GetBinary(ctx context.Context, name string, w io.Writer) (*leaf.Manifest, error) {
+	name, err := r.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	store, name := r.getStore(name)
+	return store.GetBinary(ctx, name, w)
+}
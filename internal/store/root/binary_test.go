@@ -0,0 +1,56 @@
+package root
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/store/leaf"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/tests/gptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryMove(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithHidden(ctx, true)
+	ctx = backend.WithCryptoBackend(ctx, backend.Plain)
+	ctx = leaf.WithChunkSize(ctx, 16)
+
+	rs, err := createRootStore(ctx, u)
+	require.NoError(t, err)
+
+	require.NoError(t, u.InitStore("sub1"))
+	require.NoError(t, rs.AddMount(ctx, "sub1", u.StoreDir("sub1")))
+
+	content := bytes.Repeat([]byte("0123456789"), 10)
+	m, err := rs.SetBinary(ctx, "files/blob", "blob.bin", bytes.NewReader(content))
+	require.NoError(t, err)
+	assert.Len(t, m.Chunks, 7)
+
+	// moving to another mount re-encrypts the chunks for that mount
+	require.NoError(t, rs.Move(ctx, "files/", "sub1/files"))
+	assert.False(t, rs.Exists(ctx, "files/blob"))
+	assert.False(t, rs.IsDir(ctx, "files"))
+
+	buf := &bytes.Buffer{}
+	_, err = rs.GetBinary(ctx, "sub1/files/blob", buf)
+	require.NoError(t, err)
+	assert.Equal(t, content, buf.Bytes())
+
+	require.NoError(t, rs.Copy(ctx, "sub1/files/blob", "blob"))
+	buf.Reset()
+	_, err = rs.GetBinary(ctx, "blob", buf)
+	require.NoError(t, err)
+	assert.Equal(t, content, buf.Bytes())
+
+	_, err = rs.GetBinary(ctx, "foo", buf)
+	assert.ErrorIs(t, err, leaf.ErrNotChunked)
+}
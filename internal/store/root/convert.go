@@ -23,7 +23,9 @@ func (r *Store) Convert(ctx context.Context, name string, cryptoBe backend.Crypt
 		r.cfg.Path = sub.Path()
 	} else {
 		debug.Log("success. updating path for %s to %s", name, sub.Path())
-		r.cfg.Mounts[name] = sub.Path()
+		mc := r.cfg.Mounts[name]
+		mc.Path = sub.Path()
+		r.cfg.Mounts[name] = mc
 	}
 
 	return r.cfg.Save()
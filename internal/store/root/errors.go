@@ -27,3 +27,17 @@ func (n NotInitializedError) Path() string { return n.path }
 func (n NotInitializedError) Error() string {
 	return fmt.Sprintf("password store %s is not initialized. Try gosecret init --store %s --path %s", n.alias, n.alias, n.path)
 }
+
+// MountPolicyError is an error that is returned when a change
+// violates the options of a mount, e.g. because it's read-only
+type MountPolicyError struct {
+	alias  string
+	reason string
+}
+
+// Alias returns the store alias this error was generated for
+func (m MountPolicyError) Alias() string { return m.alias }
+
+func (m MountPolicyError) Error() string {
+	return fmt.Sprintf("mount %s %s", m.alias, m.reason)
+}
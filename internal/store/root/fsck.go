@@ -12,6 +12,7 @@ import (
 // Fsck checks all stores/entries matching the given prefix
 func (s *Store) Fsck(ctx context.Context, path string) error {
 	var result error
+	prefix := path
 
 	for alias, sub := range s.mounts {
 		if sub == nil {
@@ -31,6 +32,11 @@ func (s *Store) Fsck(ctx context.Context, path string) error {
 		out.Errorf(ctx, "fsck failed on root store: %s", err)
 		result = multierror.Append(result, err)
 	}
+	// links may point across mounts, so they can only be checked here
+	if err := s.fsckLinks(ctx, prefix); err != nil {
+		out.Errorf(ctx, "fsck failed to check links: %s", err)
+		result = multierror.Append(result, err)
+	}
 
 	return result
 }
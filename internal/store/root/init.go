@@ -49,7 +49,9 @@ func (r *Store) Init(ctx context.Context, alias, path string, ids ...string) err
 		r.cfg.Path = path
 	} else {
 		debug.Log("mounted %s at %s", alias, path)
-		r.cfg.Mounts[alias] = path
+		mc := r.cfg.Mounts[alias]
+		mc.Path = path
+		r.cfg.Mounts[alias] = mc
 	}
 
 	return nil
@@ -72,8 +74,8 @@ func (r *Store) initialize(ctx context.Context) error {
 	r.store = s
 
 	// initialize all mounts
-	for alias, path := range r.cfg.Mounts {
-		path := fsutil.CleanPath(path)
+	for alias, mc := range r.cfg.Mounts {
+		path := fsutil.CleanPath(mc.Path)
 		if err := r.addMount(ctx, alias, path); err != nil {
 			out.Errorf(ctx, "Failed to initialize mount %s (%s). Ignoring: %s", alias, path, err)
 			continue
@@ -3,16 +3,145 @@ package root
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/internal/store/leaf"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/debug"
 )
 
-// Link creates a symlink
+// maxLinkDepth is the maximum number of links we follow before giving up
+const maxLinkDepth = 16
+
+// Link creates a link at to that points to the secret from. Links are
+// resolved by the root store, so they can point to secrets in other mounts
+// and stay valid when the link itself is moved.
 func (r *Store) Link(ctx context.Context, from, to string) error {
-	subFrom, fName := r.getStore(from)
-	subTo, tName := r.getStore(to)
+	if !r.Exists(ctx, from) {
+		return fmt.Errorf("source %q does not exists", from)
+	}
+	if r.Exists(ctx, to) {
+		return fmt.Errorf("destination %q already exists", to)
+	}
 
-	if !subFrom.Equals(subTo) {
-		return fmt.Errorf("sylinks across stores are not supported")
+	ctx, err := r.writeCtx(ctx, to)
+	if err != nil {
+		return err
 	}
 
-	return subFrom.Link(ctx, fName, tName)
+	sub, name := r.getStore(to)
+	ctx = ctxutil.WithCommitMessage(ctx, fmt.Sprintf("Link to %s", from))
+	return sub.SetLink(ctx, name, from)
+}
+
+// IsLink returns true if the named entry is a link
+func (r *Store) IsLink(ctx context.Context, name string) bool {
+	sub, name := r.getStore(name)
+	return sub.IsLink(ctx, name)
+}
+
+// LinkTarget returns the (direct) target of the named link
+func (r *Store) LinkTarget(ctx context.Context, name string) (string, error) {
+	sub, name := r.getStore(name)
+	return sub.GetLink(ctx, name)
+}
+
+// ResolveLink follows the named link (and any links it points to) until it
+// reaches a secret. It returns an error if the link is dangling or contains
+// a cycle. Names that are not links are returned as is.
+func (r *Store) ResolveLink(ctx context.Context, name string) (string, error) {
+	orig := name
+	for i := 0; i < maxLinkDepth; i++ {
+		sub, sn := r.getStore(name)
+		if sub.Exists(ctx, sn) {
+			return name, nil
+		}
+		if !sub.IsLink(ctx, sn) {
+			if name == orig {
+				return name, nil
+			}
+			return "", fmt.Errorf("dangling link %q: target %q does not exist: %w", orig, name, store.ErrNotFound)
+		}
+		target, err := sub.GetLink(ctx, sn)
+		if err != nil {
+			return "", err
+		}
+		debug.Log("following link %q to %q", name, target)
+		name = target
+	}
+	return "", fmt.Errorf("too many levels of links resolving %q", orig)
+}
+
+// resolve returns the name of the secret the given name refers to. Dangling
+// links and cycles are errors, so nothing is written next to a link and
+// shadows it.
+func (r *Store) resolve(ctx context.Context, name string) (string, error) {
+	rn, err := r.ResolveLink(ctx, name)
+	if err != nil {
+		debug.Log("failed to resolve %q: %s", name, err)
+		return "", err
+	}
+	return rn, nil
+}
+
+// Links returns all links in all mounts and their targets
+func (r *Store) Links(ctx context.Context) map[string]string {
+	links := r.store.ListLinks(ctx, "")
+	for alias, sub := range r.mounts {
+		if sub == nil {
+			continue
+		}
+		for k, v := range sub.ListLinks(ctx, alias) {
+			links[k] = v
+		}
+	}
+	return links
+}
+
+// relink updates all links pointing to any of the moved secrets
+func (r *Store) relink(ctx context.Context, moved map[string]string) error {
+	for name, target := range r.Links(ctx) {
+		dst, found := moved[target]
+		if !found {
+			continue
+		}
+		debug.Log("updating link %q from %q to %q", name, target, dst)
+		sub, sn := r.getStore(name)
+		if err := sub.SetLink(ctxutil.WithCommitMessage(ctx, fmt.Sprintf("Link target moved from %s to %s", target, dst)), sn, dst); err != nil {
+			return fmt.Errorf("failed to update link %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// fsckLinks reports (and optionally removes) links that can not be resolved
+func (r *Store) fsckLinks(ctx context.Context, prefix string) error {
+	links := r.Links(ctx)
+	names := make([]string, 0, len(links))
+	for k := range links {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if prefix != "" && !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if _, err := r.ResolveLink(ctx, name); err == nil {
+			continue
+		}
+		out.Errorf(ctx, "Dangling link %s -> %s\nRun fsck with the --prune-links flag to remove it.", name, links[name])
+		if !leaf.IsFsckPruneLinks(ctx) {
+			continue
+		}
+		sub, sn := r.getStore(name)
+		if err := sub.RemoveLink(ctxutil.WithCommitMessage(ctx, "fsck: Remove dangling link"), sn); err != nil {
+			return fmt.Errorf("failed to remove dangling link %q: %w", name, err)
+		}
+		out.Printf(ctx, "Removed dangling link %s", name)
+	}
+	return nil
 }
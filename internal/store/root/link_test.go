@@ -0,0 +1,117 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/internal/store/leaf"
+	"github.com/itsonlycode/gosecret/internal/tree"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+	"github.com/itsonlycode/gosecret/tests/gptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLink(t *testing.T) {
+	color.NoColor = true
+
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithHidden(ctx, true)
+	ctx = backend.WithCryptoBackend(ctx, backend.Plain)
+
+	rs, err := createRootStore(ctx, u)
+	require.NoError(t, err)
+
+	require.NoError(t, u.InitStore("sub1"))
+	require.NoError(t, rs.AddMount(ctx, "sub1", u.StoreDir("sub1")))
+
+	sec := secrets.New()
+	sec.SetPassword("secret")
+	require.NoError(t, rs.Set(ctx, "sub1/db/prod", sec))
+
+	t.Run("link across mounts", func(t *testing.T) {
+		assert.Error(t, rs.Link(ctx, "does/not/exist", "links/prod"))
+		require.NoError(t, rs.Link(ctx, "sub1/db/prod", "links/prod"))
+		assert.Error(t, rs.Link(ctx, "sub1/db/prod", "links/prod"))
+
+		assert.True(t, rs.IsLink(ctx, "links/prod"))
+		assert.True(t, rs.Exists(ctx, "links/prod"))
+
+		target, err := rs.LinkTarget(ctx, "links/prod")
+		require.NoError(t, err)
+		assert.Equal(t, "sub1/db/prod", target)
+
+		got, err := rs.Get(ctx, "links/prod")
+		require.NoError(t, err)
+		assert.Equal(t, "secret", got.Password())
+	})
+
+	t.Run("links are listed", func(t *testing.T) {
+		st, err := rs.Tree(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"foo", "links/prod", "sub1/db/prod", "sub1/foo"}, st.List(tree.INF))
+		assert.Contains(t, st.Format(tree.INF), "prod -> sub1/db/prod")
+	})
+
+	t.Run("move the link", func(t *testing.T) {
+		require.NoError(t, rs.Move(ctx, "links/prod", "other/prod"))
+		assert.False(t, rs.Exists(ctx, "links/prod"))
+		got, err := rs.Get(ctx, "other/prod")
+		require.NoError(t, err)
+		assert.Equal(t, "secret", got.Password())
+	})
+
+	t.Run("move the target", func(t *testing.T) {
+		require.NoError(t, rs.Move(ctx, "sub1/db/prod", "db/prod"))
+		target, err := rs.LinkTarget(ctx, "other/prod")
+		require.NoError(t, err)
+		assert.Equal(t, "db/prod", target)
+		got, err := rs.Get(ctx, "other/prod")
+		require.NoError(t, err)
+		assert.Equal(t, "secret", got.Password())
+	})
+
+	t.Run("dangling link", func(t *testing.T) {
+		require.NoError(t, rs.Delete(ctx, "db/prod"))
+		assert.True(t, rs.IsLink(ctx, "other/prod"))
+		_, err := rs.ResolveLink(ctx, "other/prod")
+		assert.Error(t, err)
+
+		// writes must not create a secret that shadows the link
+		assert.Error(t, rs.Set(ctx, "other/prod", sec))
+		_, err = rs.SetBinary(ctx, "other/prod", "prod.bin", strings.NewReader("binary"))
+		assert.Error(t, err)
+		_, err = rs.SetAttachment(ctx, "other/prod", "note.txt", strings.NewReader("note"))
+		assert.Error(t, err)
+		assert.Error(t, rs.Begin(ctx).Set(ctx, "other/prod", sec))
+		_, err = rs.Get(ctx, "other/prod")
+		assert.True(t, errors.Is(err, store.ErrNotFound))
+		sub, sn := rs.getStore("other/prod")
+		assert.False(t, sub.Exists(ctx, sn))
+
+		assert.NoError(t, rs.Fsck(ctx, ""))
+		assert.True(t, rs.IsLink(ctx, "other/prod"))
+
+		assert.NoError(t, rs.Fsck(leaf.WithFsckPruneLinks(ctx, true), ""))
+		assert.False(t, rs.IsLink(ctx, "other/prod"))
+	})
+
+	t.Run("link cycle", func(t *testing.T) {
+		sub, _ := rs.getStore("a")
+		require.NoError(t, sub.SetLink(ctx, "a", "b"))
+		require.NoError(t, sub.SetLink(ctx, "b", "a"))
+		_, err := rs.ResolveLink(ctx, "a")
+		assert.Error(t, err)
+	})
+}
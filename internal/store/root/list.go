@@ -42,6 +42,14 @@ func (r *Store) Tree(ctx context.Context) (*tree.Root, error) {
 			}
 		}
 	}
+	addLinkFunc := func(in map[string]string) {
+		for f, target := range in {
+			if err := root.AddLink(f, target); err != nil {
+				out.Errorf(ctx, "Failed to add link %s to tree: %s", f, err)
+				continue
+			}
+		}
+	}
 	addTplFunc := func(in ...string) {
 		for _, f := range in {
 			if err := root.AddTemplate(f); err != nil {
@@ -56,6 +64,7 @@ func (r *Store) Tree(ctx context.Context) (*tree.Root, error) {
 		return nil, err
 	}
 	addFileFunc(sf...)
+	addLinkFunc(r.store.ListLinks(ctx, ""))
 	addTplFunc(r.store.ListTemplates(ctx, "")...)
 
 	mps := r.MountPoints()
@@ -73,6 +82,7 @@ func (r *Store) Tree(ctx context.Context) (*tree.Root, error) {
 			return nil, fmt.Errorf("failed to add file: %w", err)
 		}
 		addFileFunc(sf...)
+		addLinkFunc(substore.ListLinks(ctx, alias))
 		addTplFunc(substore.ListTemplates(ctx, alias)...)
 	}
 
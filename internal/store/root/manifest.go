@@ -0,0 +1,166 @@
+package root
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/config"
+	"github.com/itsonlycode/gosecret/internal/store"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// MountsManifestFile is the file in the root store that declares the
+	// mounts shared by a team
+	MountsManifestFile = ".gosecret-mounts.yml"
+	// MountsManifestVersion is the only supported version of the manifest
+	MountsManifestVersion = 1
+)
+
+// MountsManifest declares mounts with their remotes, backends and options.
+// It's versioned together with the root store, so everyone who clones the
+// root store can set up the same mounts with mounts sync.
+type MountsManifest struct {
+	Version int                      `yaml:"version"`
+	Mounts  map[string]ManifestMount `yaml:"mounts"`
+}
+
+// ManifestMount is a mount declared in the manifest
+type ManifestMount struct {
+	// Remote is cloned if the mount does not exist, yet
+	Remote string `yaml:"remote"`
+	// Path defaults to the usual location of mounted stores
+	Path string `yaml:"path,omitempty"`
+	// Crypto and Storage select the backends used for cloning
+	Crypto  string `yaml:"crypto,omitempty"`
+	Storage string `yaml:"storage,omitempty"`
+
+	ReadOnly       bool     `yaml:"readonly,omitempty"`
+	NoAutoSync     bool     `yaml:"noautosync,omitempty"`
+	RequireSigning bool     `yaml:"requiresigning,omitempty"`
+	Writers        []string `yaml:"writers,omitempty"`
+}
+
+// MountConfig returns the config of the mount. It's marked as managed by
+// the manifest.
+func (m ManifestMount) MountConfig(path string) config.MountConfig {
+	return config.MountConfig{
+		Path:           path,
+		ReadOnly:       m.ReadOnly,
+		NoAutoSync:     m.NoAutoSync,
+		RequireSigning: m.RequireSigning,
+		Writers:        m.Writers,
+		Managed:        true,
+	}
+}
+
+// WithBackends returns a context with the backends of the mount set
+func (m ManifestMount) WithBackends(ctx context.Context) context.Context {
+	if m.Crypto != "" {
+		ctx = backend.WithCryptoBackendString(ctx, m.Crypto)
+	}
+	if m.Storage != "" {
+		ctx = backend.WithStorageBackendString(ctx, m.Storage)
+	}
+	return ctx
+}
+
+// ParseMountsManifest decodes and validates a mounts manifest. Unknown
+// fields are rejected to catch typos in options.
+func ParseMountsManifest(buf []byte) (*MountsManifest, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(buf))
+	dec.KnownFields(true)
+
+	m := &MountsManifest{}
+	if err := dec.Decode(m); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse %s: %w", MountsManifestFile, err)
+	}
+	if m.Version != MountsManifestVersion {
+		return nil, fmt.Errorf("unsupported %s version %d. Supported: %d", MountsManifestFile, m.Version, MountsManifestVersion)
+	}
+
+	for alias, mm := range m.Mounts {
+		if alias == "" || path.Clean(alias) != alias || strings.HasPrefix(alias, "/") || strings.HasPrefix(alias, ".") {
+			return nil, fmt.Errorf("invalid mount point %q", alias)
+		}
+		if mm.Remote == "" && mm.Path == "" {
+			return nil, fmt.Errorf("mount %s needs a remote or a path", alias)
+		}
+		if mm.Crypto != "" && backend.CryptoBackendFromName(mm.Crypto) < 0 {
+			return nil, fmt.Errorf("mount %s uses unknown crypto backend %q", alias, mm.Crypto)
+		}
+		if mm.Storage != "" && backend.StorageNameFromBackend(backend.StorageBackendFromName(mm.Storage)) != mm.Storage {
+			return nil, fmt.Errorf("mount %s uses unknown storage backend %q", alias, mm.Storage)
+		}
+	}
+	return m, nil
+}
+
+// MountsManifest reads the mounts manifest from the root store. It returns
+// store.ErrNotFound if the root store has no manifest.
+func (r *Store) MountsManifest(ctx context.Context) (*MountsManifest, error) {
+	if !r.store.Storage().Exists(ctx, MountsManifestFile) {
+		return nil, fmt.Errorf("%s: %w", MountsManifestFile, store.ErrNotFound)
+	}
+	buf, err := r.store.Storage().Get(ctx, MountsManifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", MountsManifestFile, err)
+	}
+	return ParseMountsManifest(buf)
+}
+
+// Kinds of MountChange
+const (
+	MountChangeAdd    = "add"
+	MountChangeUpdate = "update"
+	MountChangeRemove = "remove"
+)
+
+// MountChange is a step to make the mounts match the manifest
+type MountChange struct {
+	Kind  string
+	Alias string
+	Mount ManifestMount
+}
+
+// PlanMounts returns the changes that make the mounts match the manifest.
+// Declared mounts are added or updated, parents before nested mounts.
+// Managed mounts the manifest does not declare anymore are removed, nested
+// mounts first. Other mounts are never touched.
+func (r *Store) PlanMounts(m *MountsManifest) []MountChange {
+	aliases := make([]string, 0, len(m.Mounts))
+	for alias := range m.Mounts {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	changes := make([]MountChange, 0, len(aliases))
+	for _, alias := range aliases {
+		kind := MountChangeAdd
+		if _, found := r.mounts[alias]; found {
+			kind = MountChangeUpdate
+		}
+		changes = append(changes, MountChange{Kind: kind, Alias: alias, Mount: m.Mounts[alias]})
+	}
+
+	var removed []string
+	for alias := range r.mounts {
+		if _, found := m.Mounts[alias]; found || !r.MountConfig(alias).Managed {
+			continue
+		}
+		removed = append(removed, alias)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(removed)))
+	for _, alias := range removed {
+		changes = append(changes, MountChange{Kind: MountChangeRemove, Alias: alias})
+	}
+
+	return changes
+}
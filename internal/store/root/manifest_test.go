@@ -0,0 +1,104 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/config"
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/tests/gptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMountsManifest(t *testing.T) {
+	m, err := ParseMountsManifest([]byte(`version: 1
+mounts:
+  team:
+    remote: git@example.org:team/secrets.git
+    crypto: age
+    storage: gitfs
+    readonly: true
+  team/infra:
+    remote: git@example.org:team/infra.git
+    writers:
+      - 0xDEADBEEF`))
+	require.NoError(t, err)
+	assert.Equal(t, ManifestMount{
+		Remote:   "git@example.org:team/secrets.git",
+		Crypto:   "age",
+		Storage:  "gitfs",
+		ReadOnly: true,
+	}, m.Mounts["team"])
+	assert.Equal(t, config.MountConfig{
+		Path:    "/tmp/infra",
+		Writers: []string{"0xDEADBEEF"},
+		Managed: true,
+	}, m.Mounts["team/infra"].MountConfig("/tmp/infra"))
+
+	ctx := m.Mounts["team"].WithBackends(context.Background())
+	assert.Equal(t, backend.Age, backend.GetCryptoBackend(ctx))
+	assert.Equal(t, backend.GitFS, backend.GetStorageBackend(ctx))
+
+	for _, tc := range []string{
+		"mounts: {}",
+		"version: 2\nmounts: {}",
+		"version: 1\nmounts:\n  team:\n    remote: foo\n    read-only: true",
+		"version: 1\nmounts:\n  team:\n    crypto: gpgcli",
+		"version: 1\nmounts:\n  team:\n    remote: foo\n    crypto: rot13",
+		"version: 1\nmounts:\n  team:\n    remote: foo\n    storage: floppy",
+		"version: 1\nmounts:\n  ../team:\n    remote: foo",
+		"version: 1\nmounts:\n  /team:\n    remote: foo",
+	} {
+		_, err := ParseMountsManifest([]byte(tc))
+		assert.Error(t, err, tc)
+	}
+}
+
+func TestPlanMounts(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithHidden(ctx, true)
+	ctx = backend.WithCryptoBackend(ctx, backend.Plain)
+
+	rs, err := createRootStore(ctx, u)
+	require.NoError(t, err)
+
+	_, err = rs.MountsManifest(ctx)
+	assert.True(t, errors.Is(err, store.ErrNotFound))
+
+	for _, alias := range []string{"team", "old", "old/nested", "own"} {
+		require.NoError(t, u.InitStore(alias))
+		require.NoError(t, rs.AddMount(ctx, alias, u.StoreDir(alias)))
+		if alias != "own" {
+			require.NoError(t, rs.SetMountOptions(alias, config.MountConfig{Managed: true}))
+		}
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(u.StoreDir(""), MountsManifestFile), []byte(`version: 1
+mounts:
+  team/infra:
+    remote: git@example.org:team/infra.git
+  team:
+    remote: git@example.org:team/secrets.git
+    readonly: true
+`), 0600))
+
+	m, err := rs.MountsManifest(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []MountChange{
+		{Kind: MountChangeUpdate, Alias: "team", Mount: m.Mounts["team"]},
+		{Kind: MountChangeAdd, Alias: "team/infra", Mount: m.Mounts["team/infra"]},
+		{Kind: MountChangeRemove, Alias: "old/nested"},
+		{Kind: MountChangeRemove, Alias: "old"},
+	}, rs.PlanMounts(m))
+}
@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/itsonlycode/gosecret/internal/config"
 	"github.com/itsonlycode/gosecret/internal/out"
 	"github.com/itsonlycode/gosecret/internal/store"
 	"github.com/itsonlycode/gosecret/internal/store/leaf"
@@ -47,9 +48,12 @@ func (r *Store) addMount(ctx context.Context, alias, path string, keys ...string
 
 	r.mounts[alias] = s
 	if r.cfg.Mounts == nil {
-		r.cfg.Mounts = make(map[string]string, 1)
+		r.cfg.Mounts = make(map[string]config.MountConfig, 1)
 	}
-	r.cfg.Mounts[alias] = path
+	// keep the options of a configured mount
+	mc := r.cfg.Mounts[alias]
+	mc.Path = path
+	r.cfg.Mounts[alias] = mc
 
 	debug.Log("Added mount %s -> %s (%s)", alias, path, fullPath)
 	return nil
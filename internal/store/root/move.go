@@ -12,6 +12,7 @@ import (
 	"github.com/itsonlycode/gosecret/internal/store/leaf"
 	"github.com/itsonlycode/gosecret/pkg/ctxutil"
 	"github.com/itsonlycode/gosecret/pkg/debug"
+	"github.com/itsonlycode/gosecret/pkg/gosecret"
 )
 
 // Copy will copy one entry to another location. Multi-store copies are
@@ -32,6 +33,10 @@ func (r *Store) Move(ctx context.Context, from, to string) error {
 }
 
 func (r *Store) move(ctx context.Context, from, to string, delete bool) error {
+	if err := r.checkMove(ctx, from, to, delete); err != nil {
+		return err
+	}
+
 	subFrom, fromPrefix := r.getStore(from)
 	subTo, _ := r.getStore(to)
 
@@ -41,7 +46,8 @@ func (r *Store) move(ctx context.Context, from, to string, delete bool) error {
 		return fmt.Errorf("destination is a file")
 	}
 
-	if err := r.moveFromTo(ctx, subFrom, from, to, fromPrefix, srcIsDir, dstIsDir, delete); err != nil {
+	moved, err := r.moveFromTo(ctx, subFrom, from, to, fromPrefix, srcIsDir, dstIsDir, delete)
+	if err != nil {
 		return err
 	}
 	if err := subFrom.Storage().Commit(ctx, fmt.Sprintf("Move from %s to %s", from, to)); delete && err != nil {
@@ -54,8 +60,8 @@ func (r *Store) move(ctx context.Context, from, to string, delete bool) error {
 	}
 	if !subFrom.Equals(subTo) {
 		if err := subTo.Storage().Commit(ctx, fmt.Sprintf("Move from %s to %s", from, to)); err != nil {
-			switch errors.Unwrap(err) {
-			case store.ErrGitNotInit:
+			switch {
+			case errors.Is(err, store.ErrGitNotInit):
 				debug.Log("skipping git commit - git not initialized")
 			default:
 				return fmt.Errorf("failed to commit changes to git (to): %w", err)
@@ -63,7 +69,12 @@ func (r *Store) move(ctx context.Context, from, to string, delete bool) error {
 		}
 	}
 
-	if err := subFrom.Storage().Push(ctx, "", ""); err != nil {
+	// make sure any links pointing to the moved secrets are still valid
+	if err := r.relink(ctx, moved); err != nil {
+		return err
+	}
+
+	if err := subFrom.Storage().Push(r.syncCtx(ctx, from), "", ""); err != nil {
 		if errors.Is(err, store.ErrGitNotInit) {
 			msg := "Warning: git is not initialized for this storage. Ignoring auto-push option\n" +
 				"Run: gosecret git init"
@@ -79,7 +90,7 @@ func (r *Store) move(ctx context.Context, from, to string, delete bool) error {
 		return fmt.Errorf("failed to push change to git remote: %w", err)
 	}
 	if !subFrom.Equals(subTo) {
-		if err := subTo.Storage().Push(ctx, "", ""); err != nil {
+		if err := subTo.Storage().Push(r.syncCtx(ctx, to), "", ""); err != nil {
 			if errors.Is(err, store.ErrGitNotInit) {
 				msg := "Warning: git is not initialized for this storage. Ignoring auto-push option\n" +
 					"Run: gosecret git init"
@@ -95,47 +106,127 @@ func (r *Store) move(ctx context.Context, from, to string, delete bool) error {
 			return fmt.Errorf("failed to push change to git remote: %w", err)
 		}
 	}
+
 	return nil
 }
 
-func (r *Store) moveFromTo(ctx context.Context, subFrom *leaf.Store, from, to, fromPrefix string, srcIsDir, dstIsDir, delete bool) error {
+// checkMove checks the mount options of the source, if it's removed, and of
+// the destination. Secrets are written to the target of a link at the
+// destination, so the link is resolved first.
+func (r *Store) checkMove(ctx context.Context, from, to string, delete bool) error {
+	dst, err := r.resolve(ctx, to)
+	if err != nil {
+		return err
+	}
+	if err := r.checkWrite(ctx, dst); err != nil {
+		return err
+	}
+	if !delete {
+		return nil
+	}
+	return r.checkWrite(ctx, from)
+}
+
+// moveFromTo moves (or copies) all entries and links. It returns a map of
+// the moved secrets to their new location.
+func (r *Store) moveFromTo(ctx context.Context, subFrom *leaf.Store, from, to, fromPrefix string, srcIsDir, dstIsDir, delete bool) (map[string]string, error) {
 	ctx = ctxutil.WithGitCommit(ctx, false)
 
 	entries := []string{from}
+	links := map[string]string{}
 	// if the source is a directory we enumerate all it's children
 	// and move them one by one.
 	if r.IsDir(ctx, from) {
 		var err error
 		entries, err = subFrom.List(ctx, fromPrefix+"/")
 		if err != nil {
-			return err
+			return nil, err
+		}
+		for name, target := range r.Links(ctx) {
+			if strings.HasPrefix(name, strings.TrimSuffix(from, "/")+"/") {
+				links[name] = target
+			}
+		}
+	} else if subFrom.IsLink(ctx, fromPrefix) && !subFrom.Exists(ctx, fromPrefix) {
+		// moving a link only moves the link itself, not its target
+		target, err := r.LinkTarget(ctx, from)
+		if err != nil {
+			return nil, err
 		}
+		links[from] = target
+		entries = nil
 	}
-	if len(entries) < 1 {
+	if len(entries) < 1 && len(links) < 1 {
 		debug.Log("Subtree %q has no entries", from)
-		return fmt.Errorf("no entries")
+		return nil, fmt.Errorf("no entries")
 	}
 
-	debug.Log("Moving (sub) tree %q to %q (entries: %+v)", from, to, entries)
+	debug.Log("Moving (sub) tree %q to %q (entries: %+v, links: %+v)", from, to, entries, links)
 
+	if err := r.moveLinks(ctx, links, from, to, srcIsDir, dstIsDir, delete); err != nil {
+		return nil, err
+	}
+
+	moved := make(map[string]string, len(entries))
 	for _, src := range entries {
 		dst := computeMoveDestination(src, from, to, srcIsDir, dstIsDir)
 		debug.Log("Moving entry %q (%q) => %q (%q) (srcIsDir:%t, dstIsDir:%t, delete:%t)\n", src, from, dst, to, srcIsDir, dstIsDir, delete)
 
 		content, err := r.Get(ctx, src)
 		if err != nil {
-			return fmt.Errorf("source %s does not exist in source store %s: %s", from, subFrom.Alias(), err)
+			return nil, fmt.Errorf("source %s does not exist in source store %s: %s", from, subFrom.Alias(), err)
 		}
 
-		if err := r.Set(ctxutil.WithCommitMessage(ctx, fmt.Sprintf("Move from %s to %s", src, dst)), dst, content); err != nil {
-			return fmt.Errorf("failed to save secret %q: %w", to, err)
+		if err := r.moveEntry(ctxutil.WithCommitMessage(ctx, fmt.Sprintf("Move from %s to %s", src, dst)), src, dst, content); err != nil {
+			return nil, fmt.Errorf("failed to save secret %q: %w", to, err)
 		}
 
 		if delete {
 			debug.Log("Deleting moved entry %q from source %q", from, src)
 			if err := r.Delete(ctx, src); err != nil {
-				return fmt.Errorf("failed to delete secret %q: %w", src, err)
+				return nil, fmt.Errorf("failed to delete secret %q: %w", src, err)
 			}
+			moved[src] = dst
+		}
+	}
+
+	return moved, nil
+}
+
+// moveEntry writes the content of src to dst. The chunks of binary secrets
+// and attachments are copied as well, encrypted for the destination.
+func (r *Store) moveEntry(ctx context.Context, src, dst string, content gosecret.Secret) error {
+	src, err := r.resolve(ctx, src)
+	if err != nil {
+		return err
+	}
+	dst, err = r.resolve(ctx, dst)
+	if err != nil {
+		return err
+	}
+	subFrom, sn := r.getStore(src)
+	subTo, dn := r.getStore(dst)
+	return subFrom.CopyEntry(ctx, sn, content, subTo, dn)
+}
+
+// moveLinks moves (or copies) the given links to their new location. The
+// link targets are not changed.
+func (r *Store) moveLinks(ctx context.Context, links map[string]string, from, to string, srcIsDir, dstIsDir, delete bool) error {
+	for src, target := range links {
+		dst := computeMoveDestination(src, from, to, srcIsDir, dstIsDir)
+		debug.Log("Moving link %q => %q (target: %q)", src, dst, target)
+
+		subTo, dn := r.getStore(dst)
+		if err := subTo.SetLink(ctxutil.WithCommitMessage(ctx, fmt.Sprintf("Move from %s to %s", src, dst)), dn, target); err != nil {
+			return fmt.Errorf("failed to save link %q: %w", dst, err)
+		}
+
+		if !delete {
+			continue
+		}
+		subFrom, sn := r.getStore(src)
+		if err := subFrom.RemoveLink(ctx, sn); err != nil {
+			return fmt.Errorf("failed to delete link %q: %w", src, err)
 		}
 	}
 	return nil
@@ -178,12 +269,20 @@ func computeMoveDestination(src, from, to string, srcIsDir, dstIsDir bool) strin
 	return path.Join(to, strings.TrimPrefix(src, from))
 }
 
-// Delete will remove an single entry from the store.
+// Delete will remove an single entry from the store. Deleting a link only
+// removes the link, not the secret it points to.
 func (r *Store) Delete(ctx context.Context, name string) error {
+	ctx, err := r.writeCtx(ctx, name)
+	if err != nil {
+		return err
+	}
 	store, sn := r.getStore(name)
 	if sn == "" {
 		return fmt.Errorf("can not delete a mount point. Use `gosecret mounts remove %s`", store.Alias())
 	}
+	if store.IsLink(ctx, sn) && !store.Exists(ctx, sn) {
+		return store.RemoveLink(ctxutil.WithCommitMessage(ctx, "Remove link"), sn)
+	}
 	return store.Delete(ctx, sn)
 }
 
@@ -195,6 +294,10 @@ func (r *Store) Prune(ctx context.Context, tree string) error {
 		}
 	}
 
+	ctx, err := r.writeCtx(ctx, tree)
+	if err != nil {
+		return err
+	}
 	store, tree := r.getStore(tree)
 	return store.Prune(ctx, tree)
 }
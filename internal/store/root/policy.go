@@ -0,0 +1,88 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/config"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+)
+
+// MountConfig returns the config of the mount alias, including its options
+func (r *Store) MountConfig(alias string) config.MountConfig {
+	return r.cfg.Mounts[alias]
+}
+
+// SetMountOptions replaces the options of the mount alias. The path is kept.
+func (r *Store) SetMountOptions(alias string, opts config.MountConfig) error {
+	if _, found := r.mounts[alias]; !found {
+		return fmt.Errorf("%s is not mounted", alias)
+	}
+	opts.Path = r.cfg.Mounts[alias].Path
+	r.cfg.Mounts[alias] = opts
+	return nil
+}
+
+// writeCtx checks if the options of the mount that holds name allow changes
+// and applies them to the context, e.g. it disables the automatic push for
+// mounts with noautosync.
+func (r *Store) writeCtx(ctx context.Context, name string) (context.Context, error) {
+	if err := r.checkWrite(ctx, name); err != nil {
+		return ctx, err
+	}
+	return r.syncCtx(ctx, name), nil
+}
+
+// checkWrite returns a MountPolicyError if the options of the mount that
+// holds name forbid changes. The root store has no options.
+func (r *Store) checkWrite(ctx context.Context, name string) error {
+	mp := r.MountPoint(name)
+	if mp == "" {
+		return nil
+	}
+	mc := r.MountConfig(mp)
+
+	if mc.ReadOnly {
+		return MountPolicyError{alias: mp, reason: "is read-only"}
+	}
+
+	if len(mc.Writers) > 0 {
+		ids, err := r.mounts[mp].Crypto().FindIdentities(ctx, mc.Writers...)
+		if err != nil || len(ids) < 1 {
+			debug.Log("none of the writers %+v of %s is a private key: %+v, %s", mc.Writers, mp, ids, err)
+			return MountPolicyError{alias: mp, reason: fmt.Sprintf("only allows changes by %s", strings.Join(mc.Writers, ", "))}
+		}
+	}
+
+	if mc.RequireSigning {
+		// only storages that can sign report if they do
+		sc, ok := r.mounts[mp].Storage().(interface {
+			SignsCommits(context.Context) (bool, error)
+		})
+		var signs bool
+		var err error
+		if ok {
+			signs, err = sc.SignsCommits(ctx)
+		}
+		if !ok || errors.Is(err, backend.ErrNotSupported) {
+			return MountPolicyError{alias: mp, reason: fmt.Sprintf("requires signed commits but storage %s can not sign commits", r.mounts[mp].Storage().Name())}
+		}
+		if !signs {
+			return MountPolicyError{alias: mp, reason: fmt.Sprintf("requires signed commits. Run: git -C %s config commit.gpgsign true", r.mounts[mp].Path())}
+		}
+	}
+
+	return nil
+}
+
+// syncCtx disables the automatic pull and push for mounts with noautosync
+func (r *Store) syncCtx(ctx context.Context, name string) context.Context {
+	if mp := r.MountPoint(name); mp != "" && r.MountConfig(mp).NoAutoSync {
+		return ctxutil.WithNoNetwork(ctx, true)
+	}
+	return ctx
+}
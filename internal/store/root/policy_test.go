@@ -0,0 +1,91 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/config"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+	"github.com/itsonlycode/gosecret/tests/gptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMountPolicy(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithHidden(ctx, true)
+	ctx = backend.WithCryptoBackend(ctx, backend.Plain)
+
+	rs, err := createRootStore(ctx, u)
+	require.NoError(t, err)
+
+	require.NoError(t, u.InitStore("team"))
+	require.NoError(t, rs.AddMount(ctx, "team", u.StoreDir("team")))
+	require.NoError(t, rs.Set(ctx, "team/shared", secrets.ParsePlain([]byte("shared"))))
+	require.NoError(t, rs.Link(ctx, "team/shared", "shared"))
+
+	assert.Error(t, rs.SetMountOptions("nope", config.MountConfig{ReadOnly: true}))
+	require.NoError(t, rs.SetMountOptions("team", config.MountConfig{ReadOnly: true}))
+	assert.Equal(t, u.StoreDir("team"), rs.MountConfig("team").Path)
+
+	t.Run("read-only", func(t *testing.T) {
+		err := rs.Set(ctx, "team/new", secrets.ParsePlain([]byte("new")))
+		var pe MountPolicyError
+		require.True(t, errors.As(err, &pe), "%s", err)
+		assert.Equal(t, "team", pe.Alias())
+		assert.EqualError(t, err, "mount team is read-only")
+
+		// links are resolved before checking
+		assert.Error(t, rs.Set(ctx, "shared", secrets.ParsePlain([]byte("new"))))
+		assert.Error(t, rs.Delete(ctx, "team/shared"))
+		assert.Error(t, rs.Prune(ctx, "team/shared"))
+		assert.Error(t, rs.Move(ctx, "team/shared", "mine"))
+		assert.Error(t, rs.Copy(ctx, "shared", "team/copy"))
+		assert.Error(t, rs.Link(ctx, "shared", "team/link"))
+		assert.Error(t, rs.AddRecipient(ctx, "team", "FEEDBEEF"))
+		assert.Error(t, rs.RemoveRecipient(ctx, "team", "DEADBEEF"))
+
+		// reading and copying out of the mount is fine
+		sec, err := rs.Get(ctx, "team/shared")
+		require.NoError(t, err)
+		assert.Equal(t, "shared", sec.Password())
+		assert.NoError(t, rs.Copy(ctx, "team/shared", "mine"))
+		assert.True(t, rs.Exists(ctx, "team/shared"))
+
+		// other mounts are not affected
+		assert.NoError(t, rs.Set(ctx, "other", secrets.ParsePlain([]byte("other"))))
+	})
+
+	t.Run("writers", func(t *testing.T) {
+		require.NoError(t, rs.SetMountOptions("team", config.MountConfig{Writers: []string{"0xCAFEBABE"}}))
+		assert.EqualError(t, rs.Set(ctx, "team/new", secrets.ParsePlain([]byte("new"))), "mount team only allows changes by 0xCAFEBABE")
+
+		require.NoError(t, rs.SetMountOptions("team", config.MountConfig{Writers: []string{"0xCAFEBABE", "DEADBEEF"}}))
+		assert.NoError(t, rs.Set(ctx, "team/new", secrets.ParsePlain([]byte("new"))))
+	})
+
+	t.Run("signing", func(t *testing.T) {
+		require.NoError(t, rs.SetMountOptions("team", config.MountConfig{RequireSigning: true}))
+		err := rs.Set(ctx, "team/new", secrets.ParsePlain([]byte("new")))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "requires signed commits")
+		assert.Contains(t, err.Error(), "can not sign commits")
+	})
+
+	t.Run("no autosync", func(t *testing.T) {
+		require.NoError(t, rs.SetMountOptions("team", config.MountConfig{NoAutoSync: true}))
+		wctx, err := rs.writeCtx(ctx, "team/new")
+		require.NoError(t, err)
+		assert.True(t, ctxutil.IsNoNetwork(wctx))
+		assert.False(t, ctxutil.IsNoNetwork(rs.syncCtx(ctx, "other")))
+		assert.NoError(t, rs.Move(ctx, "team/new", "team/moved"))
+	})
+}
@@ -49,12 +49,33 @@ func (r *Store) RCSPush(ctx context.Context, name, origin, remote string) error
 
 // ListRevisions will list all revisions for the named entity
 func (r *Store) ListRevisions(ctx context.Context, name string) ([]backend.Revision, error) {
+	name, err := r.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
 	store, name := r.getStore(name)
 	return store.ListRevisions(ctx, name)
 }
 
+// ListFolderRevisions will list all revisions of a folder
+func (r *Store) ListFolderRevisions(ctx context.Context, folder string) ([]backend.Revision, error) {
+	store, folder := r.getStore(folder)
+	return store.ListFolderRevisions(ctx, folder)
+}
+
+// ListRevision will list the secrets below the folder at the revision of the
+// mount that holds the folder
+func (r *Store) ListRevision(ctx context.Context, folder, revision string) ([]string, error) {
+	store, folder := r.getStore(folder)
+	return store.ListRevision(ctx, folder, revision)
+}
+
 // GetRevision will try to retrieve the given revision from the sync backend
 func (r *Store) GetRevision(ctx context.Context, name, revision string) (context.Context, gosecret.Secret, error) {
+	name, err := r.resolve(ctx, name)
+	if err != nil {
+		return ctx, nil, err
+	}
 	store, name := r.getStore(name)
 	sec, err := store.GetRevision(ctx, name, revision)
 	return ctx, sec, err
@@ -6,10 +6,14 @@ import (
 	"github.com/itsonlycode/gosecret/pkg/gosecret"
 )
 
-// Get returns the plaintext of a single key
+// Get returns the plaintext of a single key. Links are followed.
 func (r *Store) Get(ctx context.Context, name string) (gosecret.Secret, error) {
+	name, err := r.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
 	// forward to substore
-	store, name := r.getStore(name)
-	sec, err := store.Get(ctx, name)
+	store, sn := r.getStore(name)
+	sec, err := store.Get(r.syncCtx(ctx, name), sn)
 	return sec, err
 }
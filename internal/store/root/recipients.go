@@ -22,12 +22,20 @@ func (r *Store) ListRecipients(ctx context.Context, store string) []string {
 
 // AddRecipient adds a single recipient to the given store
 func (r *Store) AddRecipient(ctx context.Context, store, rec string) error {
+	ctx, err := r.writeCtx(ctx, store)
+	if err != nil {
+		return err
+	}
 	sub, _ := r.getStore(store)
 	return sub.AddRecipient(ctx, rec)
 }
 
 // RemoveRecipient removes a single recipient from the given store
 func (r *Store) RemoveRecipient(ctx context.Context, store, rec string) error {
+	ctx, err := r.writeCtx(ctx, store)
+	if err != nil {
+		return err
+	}
 	sub, _ := r.getStore(store)
 	return sub.RemoveRecipient(ctx, rec)
 }
@@ -0,0 +1,94 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/internal/store/leaf"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+)
+
+// Restore writes the given revisions of the secrets back to the store. revs
+// maps secret names to the revision that should be restored. Each secret is
+// decrypted and re-encrypted for the current recipients, so no old ciphertext
+// is resurrected. All changes to one mount are committed at once, using the
+// commit message from the context. Secrets with an empty revision are
+// deleted, e.g. because they did not exist at the revision of their folder.
+func (r *Store) Restore(ctx context.Context, revs map[string]string) error {
+	names := make([]string, 0, len(revs))
+	for name := range revs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	subs := make([]*leaf.Store, 0, 1)
+	for _, name := range names {
+		target, err := r.resolve(ctx, name)
+		if err != nil {
+			return err
+		}
+		if err := r.checkWrite(ctx, target); err != nil {
+			return err
+		}
+		sub, sn := r.getStore(target)
+
+		if revs[name] == "" {
+			debug.Log("deleting %q", name)
+			if err := sub.Delete(ctxutil.WithGitCommit(ctx, false), sn); err != nil {
+				return fmt.Errorf("failed to delete %q: %w", name, err)
+			}
+		} else {
+			_, sec, err := r.GetRevision(ctx, name, revs[name])
+			if err != nil {
+				return fmt.Errorf("failed to get revision %q of %q: %w", revs[name], name, err)
+			}
+			debug.Log("restoring %q from %q", name, revs[name])
+			if err := sub.Set(ctxutil.WithGitCommit(ctx, false), sn, sec); err != nil {
+				return fmt.Errorf("failed to restore %q: %w", name, err)
+			}
+		}
+
+		if !containsStore(subs, sub) {
+			subs = append(subs, sub)
+		}
+	}
+
+	if !ctxutil.IsGitCommit(ctx) {
+		return nil
+	}
+
+	for _, sub := range subs {
+		if err := sub.Storage().Commit(ctx, ctxutil.GetCommitMessage(ctx)); err != nil {
+			switch {
+			case errors.Is(err, store.ErrGitNotInit):
+				debug.Log("skipping git commit - git not initialized")
+			case errors.Is(err, store.ErrGitNothingToCommit):
+				debug.Log("skipping git commit - nothing to commit")
+			default:
+				return fmt.Errorf("failed to commit changes to git: %w", err)
+			}
+		}
+		if err := sub.Storage().Push(r.syncCtx(ctx, sub.Alias()), "", ""); err != nil {
+			if errors.Is(err, store.ErrGitNotInit) || errors.Is(err, store.ErrGitNoRemote) {
+				debug.Log("skipping git push: %s", err)
+				continue
+			}
+			return fmt.Errorf("failed to push change to git remote: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func containsStore(subs []*leaf.Store, sub *leaf.Store) bool {
+	for _, s := range subs {
+		if s.Equals(sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package root
+
+import (
+	"context"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/tests/gptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestore(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithHidden(ctx, true)
+	ctx = backend.WithCryptoBackend(ctx, backend.Plain)
+
+	rs, err := createRootStore(ctx, u)
+	require.NoError(t, err)
+
+	require.NoError(t, u.InitStore("sub1"))
+	require.NoError(t, rs.AddMount(ctx, "sub1", u.StoreDir("sub1")))
+
+	// the fs storage backend only knows the revision "latest", which always
+	// contains "foo\nbar"
+	ctx = ctxutil.WithCommitMessage(ctx, "Restored")
+	require.NoError(t, rs.Restore(ctx, map[string]string{
+		"foo":      "latest",
+		"sub1/foo": "latest",
+	}))
+
+	for _, name := range []string{"foo", "sub1/foo"} {
+		sec, err := rs.Get(ctx, name)
+		require.NoError(t, err)
+		assert.Equal(t, "foo", sec.Password(), name)
+		assert.Equal(t, "bar", sec.Body(), name)
+	}
+
+	// an empty revision removes the secret
+	require.NoError(t, rs.Restore(ctx, map[string]string{"sub1/foo": ""}))
+	assert.False(t, rs.Exists(ctx, "sub1/foo"))
+	assert.True(t, rs.Exists(ctx, "foo"))
+}
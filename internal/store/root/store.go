@@ -35,10 +35,11 @@ func (r *Store) WithContext(ctx context.Context) context.Context {
 	return r.cfg.WithContext(ctx)
 }
 
-// Exists checks the existence of a single entry
+// Exists checks the existence of a single entry. Links are considered to
+// exist even if they are dangling.
 func (r *Store) Exists(ctx context.Context, name string) bool {
 	store, name := r.getStore(name)
-	return store.Exists(ctx, name)
+	return store.Exists(ctx, name) || store.IsLink(ctx, name)
 }
 
 // IsDir checks if a given key is actually a folder
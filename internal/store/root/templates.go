@@ -67,12 +67,20 @@ func (r *Store) GetTemplate(ctx context.Context, name string) ([]byte, error) {
 
 // SetTemplate will (over)write the content to the template file
 func (r *Store) SetTemplate(ctx context.Context, name string, content []byte) error {
+	ctx, err := r.writeCtx(ctx, name)
+	if err != nil {
+		return err
+	}
 	store, name := r.getStore(name)
 	return store.SetTemplate(ctx, name, content)
 }
 
 // RemoveTemplate will delete the named template if it exists
 func (r *Store) RemoveTemplate(ctx context.Context, name string) error {
+	ctx, err := r.writeCtx(ctx, name)
+	if err != nil {
+		return err
+	}
 	store, name := r.getStore(name)
 	return store.RemoveTemplate(ctx, name)
 }
@@ -0,0 +1,220 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/itsonlycode/gosecret/internal/store/leaf"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+	"github.com/itsonlycode/gosecret/pkg/gosecret"
+)
+
+// ErrTxDone is returned when using a transaction that was committed or
+// rolled back already
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+// Tx stages changes to secrets in any mount. Nothing is changed until
+// Commit applies all of them and commits once per mount. If any change
+// fails, all changes are rolled back. Commits can not be undone, so if
+// committing one mount fails, the mounts committed before it keep their
+// changes.
+type Tx struct {
+	r    *Store
+	ops  []txOp
+	done bool
+}
+
+type txOp struct {
+	desc  string
+	names []string
+	apply func(context.Context) error
+}
+
+// rawSecret keeps the content of a staged secret, so later changes to the
+// secret don't affect the transaction
+type rawSecret []byte
+
+func (r rawSecret) Bytes() []byte {
+	return r
+}
+
+// Begin starts a new transaction
+func (r *Store) Begin(ctx context.Context) *Tx {
+	return &Tx{r: r}
+}
+
+// Len returns the number of staged changes
+func (t *Tx) Len() int {
+	return len(t.ops)
+}
+
+// Set stages writing a secret. Mount options are checked right away.
+func (t *Tx) Set(ctx context.Context, name string, sec gosecret.Byter) error {
+	if t.done {
+		return ErrTxDone
+	}
+	target, err := t.r.resolve(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := t.r.checkWrite(ctx, target); err != nil {
+		return err
+	}
+	content := rawSecret(append([]byte(nil), sec.Bytes()...))
+	t.add(fmt.Sprintf("Set %s", name), []string{name}, func(ctx context.Context) error {
+		return t.r.Set(ctx, name, content)
+	})
+	return nil
+}
+
+// Delete stages removing a secret. Deleting a link only removes the link,
+// not its target, so the mount of the link is checked. That way dangling
+// links can be deleted, too.
+func (t *Tx) Delete(ctx context.Context, name string) error {
+	if t.done {
+		return ErrTxDone
+	}
+	if err := t.r.checkWrite(ctx, name); err != nil {
+		return err
+	}
+	t.add(fmt.Sprintf("Remove %s", name), []string{name}, func(ctx context.Context) error {
+		return t.r.Delete(ctx, name)
+	})
+	return nil
+}
+
+// Move stages moving a secret or a directory. If to is a link, the secret
+// is written to its target, like in Set. A link in from is moved itself.
+func (t *Tx) Move(ctx context.Context, from, to string) error {
+	if t.done {
+		return ErrTxDone
+	}
+	if err := t.r.checkMove(ctx, from, to, true); err != nil {
+		return err
+	}
+	t.add(fmt.Sprintf("Move %s to %s", from, to), []string{from, to}, func(ctx context.Context) error {
+		return t.r.Move(ctx, from, to)
+	})
+	return nil
+}
+
+func (t *Tx) add(desc string, names []string, apply func(context.Context) error) {
+	debug.Log("staging %s", desc)
+	t.ops = append(t.ops, txOp{desc: desc, names: names, apply: apply})
+}
+
+// Rollback discards all staged changes
+func (t *Tx) Rollback(ctx context.Context) error {
+	if t.done {
+		return ErrTxDone
+	}
+	t.done = true
+	t.ops = nil
+	return nil
+}
+
+// Commit applies all staged changes. Every mount that was changed gets one
+// commit, using the commit message from the context as the subject and the
+// list of changes as the body. The mounts are pushed once at the end.
+// If any change fails, the changed files of all mounts are restored. If a
+// commit fails, only the mounts that were not committed, yet, are restored
+// and the error says so.
+func (t *Tx) Commit(ctx context.Context) error {
+	if t.done {
+		return ErrTxDone
+	}
+	t.done = true
+	if len(t.ops) < 1 {
+		return nil
+	}
+
+	subs := t.r.stores()
+	for i, sub := range subs {
+		if err := sub.BeginTx(); err != nil {
+			_ = t.rollback(ctx, subs[:i])
+			return fmt.Errorf("failed to start transaction on %q: %w", sub.Alias(), err)
+		}
+	}
+
+	octx := ctxutil.WithGitCommit(ctx, false)
+	for _, op := range t.ops {
+		debug.Log("applying %s", op.desc)
+		if err := op.apply(octx); err != nil {
+			if rerr := t.rollback(ctx, subs); rerr != nil {
+				return fmt.Errorf("%s failed: %w. Rolling back failed: %s", op.desc, err, rerr)
+			}
+			return fmt.Errorf("%s failed, all changes were rolled back: %w", op.desc, err)
+		}
+	}
+
+	var committed []*leaf.Store
+	for i, sub := range subs {
+		changed, err := sub.CommitTx(ctx, t.message(ctx, sub))
+		if err != nil {
+			_ = t.rollback(ctx, subs[i+1:])
+			if len(committed) > 0 {
+				return fmt.Errorf("failed to commit %q, other mounts were committed already: %w", sub.Alias(), err)
+			}
+			return fmt.Errorf("failed to commit %q, all changes were rolled back: %w", sub.Alias(), err)
+		}
+		if changed {
+			committed = append(committed, sub)
+		}
+	}
+
+	for _, sub := range committed {
+		if err := sub.Push(t.r.syncCtx(ctx, sub.Alias())); err != nil {
+			return fmt.Errorf("failed to push %q to its git remote: %w", sub.Alias(), err)
+		}
+	}
+	return nil
+}
+
+// rollback restores the changed files of the given stores
+func (t *Tx) rollback(ctx context.Context, subs []*leaf.Store) error {
+	var failed []string
+	for _, sub := range subs {
+		if err := sub.RollbackTx(ctx); err != nil {
+			failed = append(failed, fmt.Sprintf("%q: %s", sub.Alias(), err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// message returns the commit message for one store. It lists the changes
+// that involve this store.
+func (t *Tx) message(ctx context.Context, sub *leaf.Store) string {
+	var lines []string
+	for _, op := range t.ops {
+		for _, name := range op.names {
+			if s, _ := t.r.getStore(name); s.Equals(sub) {
+				lines = append(lines, "- "+op.desc)
+				break
+			}
+		}
+	}
+
+	subject := ctxutil.GetCommitMessage(ctx)
+	if subject == "" {
+		subject = fmt.Sprintf("Apply %d changes", len(lines))
+	}
+	if len(lines) < 1 {
+		return subject
+	}
+	return subject + "\n\n" + strings.Join(lines, "\n")
+}
+
+// stores returns the root store and all mounts
+func (r *Store) stores() []*leaf.Store {
+	subs := []*leaf.Store{r.store}
+	for _, mp := range r.MountPoints() {
+		subs = append(subs, r.mounts[mp])
+	}
+	return subs
+}
@@ -0,0 +1,150 @@
+package root
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	"github.com/itsonlycode/gosecret/internal/config"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+	"github.com/itsonlycode/gosecret/tests/gptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gitLog(t *testing.T, dir string) []string {
+	t.Helper()
+
+	buf, err := exec.Command("git", "-C", dir, "log", "--format=%B%x00").Output()
+	require.NoError(t, err)
+	var msgs []string
+	for _, m := range strings.Split(string(buf), "\x00") {
+		if m = strings.TrimSpace(m); m != "" {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+func TestTx(t *testing.T) {
+	u := gptest.NewUnitTester(t)
+	defer u.Remove()
+
+	ctx := context.Background()
+	ctx = ctxutil.WithAlwaysYes(ctx, true)
+	ctx = ctxutil.WithHidden(ctx, true)
+	ctx = backend.WithCryptoBackend(ctx, backend.Plain)
+
+	rs, err := createRootStore(ctx, u)
+	require.NoError(t, err)
+	require.NoError(t, u.InitStore("sub1"))
+	require.NoError(t, rs.AddMount(ctx, "sub1", u.StoreDir("sub1")))
+
+	gctx := backend.WithStorageBackend(ctx, backend.GitFS)
+	require.NoError(t, rs.RCSInit(gctx, "", "Nobody", "nobody@example.org"))
+	require.NoError(t, rs.RCSInit(gctx, "sub1", "Nobody", "nobody@example.org"))
+	rootCommits := len(gitLog(t, u.StoreDir("")))
+	subCommits := len(gitLog(t, u.StoreDir("sub1")))
+
+	t.Run("commit once per mount", func(t *testing.T) {
+		tx := rs.Begin(ctx)
+		for _, name := range []string{"bulk/a", "bulk/b", "bulk/c", "sub1/bulk/d"} {
+			sec := secrets.New()
+			sec.SetPassword(name)
+			require.NoError(t, tx.Set(ctx, name, sec))
+		}
+		require.NoError(t, tx.Move(ctx, "foo", "sub1/moved"))
+		require.NoError(t, tx.Delete(ctx, "bulk/c"))
+		assert.Equal(t, 6, tx.Len())
+		assert.False(t, rs.Exists(ctx, "bulk/a"))
+
+		require.NoError(t, tx.Commit(ctxutil.WithCommitMessage(ctx, "Bulk update")))
+		assert.Equal(t, ErrTxDone, tx.Commit(ctx))
+
+		for _, name := range []string{"bulk/a", "bulk/b", "sub1/bulk/d", "sub1/moved"} {
+			assert.True(t, rs.Exists(ctx, name), name)
+		}
+		assert.False(t, rs.Exists(ctx, "foo"))
+		assert.False(t, rs.Exists(ctx, "bulk/c"))
+
+		msgs := gitLog(t, u.StoreDir(""))
+		require.Len(t, msgs, rootCommits+1)
+		assert.Equal(t, "Bulk update\n\n- Set bulk/a\n- Set bulk/b\n- Set bulk/c\n- Move foo to sub1/moved\n- Remove bulk/c", msgs[0])
+
+		msgs = gitLog(t, u.StoreDir("sub1"))
+		require.Len(t, msgs, subCommits+1)
+		assert.Equal(t, "Bulk update\n\n- Set sub1/bulk/d\n- Move foo to sub1/moved", msgs[0])
+	})
+
+	t.Run("roll back on error", func(t *testing.T) {
+		rootCommits := len(gitLog(t, u.StoreDir("")))
+		subCommits := len(gitLog(t, u.StoreDir("sub1")))
+
+		tx := rs.Begin(ctx)
+		require.NoError(t, tx.Set(ctx, "bulk/a", secrets.ParsePlain([]byte("changed"))))
+		require.NoError(t, tx.Set(ctx, "sub1/new", secrets.ParsePlain([]byte("new"))))
+		require.NoError(t, tx.Delete(ctx, "sub1/moved"))
+		require.NoError(t, tx.Delete(ctx, "does/not/exist"))
+		assert.Error(t, tx.Commit(ctx))
+
+		sec, err := rs.Get(ctx, "bulk/a")
+		require.NoError(t, err)
+		assert.Equal(t, "bulk/a", sec.Password())
+		assert.False(t, rs.Exists(ctx, "sub1/new"))
+		assert.True(t, rs.Exists(ctx, "sub1/moved"))
+
+		assert.Len(t, gitLog(t, u.StoreDir("")), rootCommits)
+		assert.Len(t, gitLog(t, u.StoreDir("sub1")), subCommits)
+		for _, dir := range []string{u.StoreDir(""), u.StoreDir("sub1")} {
+			buf, err := exec.Command("git", "-C", dir, "status", "--porcelain").Output()
+			require.NoError(t, err)
+			assert.Equal(t, "", string(buf), dir)
+		}
+	})
+
+	t.Run("mount options are checked when staging", func(t *testing.T) {
+		require.NoError(t, rs.Link(ctx, "sub1/moved", "sub1link"))
+		require.NoError(t, rs.SetMountOptions("sub1", config.MountConfig{ReadOnly: true}))
+		defer func() {
+			require.NoError(t, rs.SetMountOptions("sub1", config.MountConfig{}))
+		}()
+
+		tx := rs.Begin(ctx)
+		assert.Error(t, tx.Set(ctx, "sub1/new", secrets.ParsePlain([]byte("new"))))
+		assert.Error(t, tx.Move(ctx, "bulk/a", "sub1/a"))
+		// a link to the read-only mount is resolved
+		assert.Error(t, tx.Move(ctx, "bulk/a", "sub1link"))
+		assert.Error(t, rs.Move(ctx, "bulk/a", "sub1link"))
+		// but deleting it only removes the link
+		assert.NoError(t, tx.Delete(ctx, "sub1link"))
+		assert.NoError(t, tx.Rollback(ctx))
+		assert.Equal(t, ErrTxDone, tx.Set(ctx, "foo", secrets.ParsePlain([]byte("new"))))
+	})
+
+	t.Run("mount that requires signing", func(t *testing.T) {
+		// a fake gpg that signs everything
+		gpg := filepath.Join(t.TempDir(), "gpg")
+		require.NoError(t, os.WriteFile(gpg, []byte("#!/bin/sh\ncat > /dev/null\nprintf '\\n[GNUPG:] SIG_CREATED D 1 8 00 0 0\\n' >&2\nprintf -- '-----BEGIN PGP SIGNATURE-----\\n\\nfake\\n-----END PGP SIGNATURE-----\\n'\n"), 0755))
+		for k, v := range map[string]string{"commit.gpgsign": "true", "gpg.program": gpg} {
+			require.NoError(t, exec.Command("git", "-C", u.StoreDir("sub1"), "config", k, v).Run())
+		}
+		require.NoError(t, rs.SetMountOptions("sub1", config.MountConfig{RequireSigning: true}))
+		defer func() {
+			require.NoError(t, rs.SetMountOptions("sub1", config.MountConfig{}))
+			require.NoError(t, exec.Command("git", "-C", u.StoreDir("sub1"), "config", "commit.gpgsign", "false").Run())
+		}()
+
+		subCommits := len(gitLog(t, u.StoreDir("sub1")))
+		tx := rs.Begin(ctx)
+		require.NoError(t, tx.Set(ctx, "sub1/signed", secrets.ParsePlain([]byte("signed"))))
+		require.NoError(t, tx.Commit(ctx))
+		assert.True(t, rs.Exists(ctx, "sub1/signed"))
+		assert.Len(t, gitLog(t, u.StoreDir("sub1")), subCommits+1)
+	})
+}
@@ -6,8 +6,17 @@ import (
 	"github.com/itsonlycode/gosecret/pkg/gosecret"
 )
 
-// Set encodes and write the ciphertext of one entry to disk
+// Set encodes and write the ciphertext of one entry to disk. If name is a
+// link the secret it points to is updated.
 func (r *Store) Set(ctx context.Context, name string, sec gosecret.Byter) error {
+	name, err := r.resolve(ctx, name)
+	if err != nil {
+		return err
+	}
+	ctx, err = r.writeCtx(ctx, name)
+	if err != nil {
+		return err
+	}
 	store, name := r.getStore(name)
 	return store.Set(ctx, name, sec)
 }
@@ -11,6 +11,7 @@ type Node struct {
 	Template bool
 	Mount    bool
 	Path     string
+	Link     string
 	Subtree  *Tree
 }
 
@@ -42,6 +43,9 @@ func (n Node) Equals(other Node) bool {
 	if n.Type != other.Type {
 		return false
 	}
+	if n.Link != other.Link {
+		return false
+	}
 	if n.Subtree != nil {
 		if other.Subtree == nil {
 			return false
@@ -86,6 +90,8 @@ func (n *Node) format(prefix string, last bool, maxDepth, curDepth int) string {
 		_, _ = out.WriteString(colMount(n.Name + " (" + n.Path + ")"))
 	case n.Type == "dir":
 		_, _ = out.WriteString(colDir(n.Name + sep))
+	case n.Link != "":
+		_, _ = out.WriteString(colLink(n.Name + " -> " + n.Link))
 	default:
 		_, _ = out.WriteString(n.Name)
 	}
@@ -20,6 +20,7 @@ var (
 	colMount = color.New(color.FgCyan, color.Bold).SprintfFunc()
 	colDir   = color.New(color.FgBlue, color.Bold).SprintfFunc()
 	colTpl   = color.New(color.FgGreen, color.Bold).SprintfFunc()
+	colLink  = color.New(color.FgMagenta).SprintfFunc()
 	// sep is intentionally NOT platform-agnostic. This is used for the CLI output
 	// and should always be a regular slash.
 	sep = "/"
@@ -42,20 +43,25 @@ func New(name string) *Root {
 
 // AddFile adds a new file to the tree
 func (r *Root) AddFile(path string, _ string) error {
-	return r.insert(path, false, "")
+	return r.insert(path, false, "", "")
 }
 
 // AddMount adds a new mount point to the tree
 func (r *Root) AddMount(path, dest string) error {
-	return r.insert(path, false, dest)
+	return r.insert(path, false, dest, "")
 }
 
 // AddTemplate adds a template to the tree
 func (r *Root) AddTemplate(path string) error {
-	return r.insert(path, true, "")
+	return r.insert(path, true, "", "")
 }
 
-func (r *Root) insert(path string, template bool, mountPath string) error {
+// AddLink adds a link pointing to target to the tree
+func (r *Root) AddLink(path, target string) error {
+	return r.insert(path, false, "", target)
+}
+
+func (r *Root) insert(path string, template bool, mountPath, link string) error {
 	t := r.Subtree
 	p := strings.Split(path, "/")
 	for i, e := range p {
@@ -68,6 +74,7 @@ func (r *Root) insert(path string, template bool, mountPath string) error {
 			n.Type = "file"
 			n.Subtree = nil
 			n.Template = template
+			n.Link = link
 			if mountPath != "" {
 				n.Mount = true
 				n.Path = mountPath
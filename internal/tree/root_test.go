@@ -76,3 +76,27 @@ func TestMountShadow(t *testing.T) {
 	_, err := r.FindFolder("mnt/m1")
 	assert.Error(t, err)
 }
+
+func TestLink(t *testing.T) {
+	color.NoColor = true
+
+	r := New("gosecret")
+	r.AddFile("foo/bar", "")
+	r.AddLink("foo/baz", "mnt/m1/zab")
+	r.AddMount("mnt/m1", "/tmp/m1")
+	r.AddFile("mnt/m1/zab", "")
+	assert.Equal(t, `gosecret
+├── foo/
+│   ├── bar
+│   └── baz -> mnt/m1/zab
+└── mnt/
+    └── m1 (/tmp/m1)
+        └── zab
+`, r.Format(INF))
+
+	assert.Equal(t, []string{
+		"foo/bar",
+		"foo/baz",
+		"mnt/m1/zab",
+	}, r.List(INF))
+}
@@ -13,6 +13,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"runtime/pprof"
 	"sort"
@@ -22,6 +23,7 @@ import (
 	"github.com/itsonlycode/gosecret/internal/backend/crypto/gpg"
 	_ "github.com/itsonlycode/gosecret/internal/backend/storage"
 	"github.com/itsonlycode/gosecret/internal/queue"
+	"github.com/itsonlycode/gosecret/pkg/appdir"
 	"github.com/itsonlycode/gosecret/pkg/ctxutil"
 	"github.com/itsonlycode/gosecret/pkg/protect"
 
@@ -90,13 +92,12 @@ func main() {
 	sv := getVersion()
 	cli.VersionPrinter = makeVersionPrinter(os.Stdout, sv)
 
-	q := queue.New(ctx)
-	ctx = queue.WithQueue(ctx, q)
 	ctx, app := setupApp(ctx, sv)
 	if err := app.RunContext(ctx, os.Args); err != nil {
+		closeQueue(ctx)
 		log.Fatal(err)
 	}
-	q.Wait(ctx)
+	queueOK := closeQueue(ctx)
 	if mp := os.Getenv("GOSECRET_MEM_PROFILE"); mp != "" {
 		f, err := os.Create(mp)
 		if err != nil {
@@ -108,6 +109,19 @@ func main() {
 			log.Fatalf("could not write heap profile: %s", err)
 		}
 	}
+	if !queueOK {
+		os.Exit(ap.ExitGit)
+	}
+}
+
+// closeQueue waits for the background tasks, e.g. pushes, and reports the
+// ones that failed
+func closeQueue(ctx context.Context) bool {
+	if err := queue.GetQueue(ctx).Close(ctx); err != nil {
+		out.Errorf(ctx, "Background tasks failed: %s", err)
+		return false
+	}
+	return true
 }
 
 func setupApp(ctx context.Context, sv semver.Version) (context.Context, *cli.App) {
@@ -117,6 +131,16 @@ func setupApp(ctx context.Context, sv semver.Version) (context.Context, *cli.App
 	// set config values
 	ctx = initContext(ctx, cfg)
 
+	// background tasks, e.g. pushes. A durable queue retries failed tasks
+	// on the next run.
+	var q *queue.Queue
+	if cfg.DurableQueue {
+		q = queue.NewDurable(ctx, filepath.Join(appdir.UserData(), "queue.json"))
+	} else {
+		q = queue.New(ctx)
+	}
+	ctx = queue.WithQueue(ctx, q)
+
 	// initialize action handlers
 	action, err := ap.New(cfg, sv)
 	if err != nil {
@@ -153,6 +177,14 @@ func setupApp(ctx context.Context, sv semver.Version) (context.Context, *cli.App
 		return action.REPL(c)
 	}
 
+	// commands that fail exit right away, so the queue is closed here
+	app.ExitErrHandler = func(c *cli.Context, err error) {
+		if err != nil {
+			closeQueue(c.Context)
+		}
+		cli.HandleExitCoder(err)
+	}
+
 	app.Commands = getCommands(action, app)
 	return ctx, app
 }
@@ -54,13 +54,20 @@ var commandsWithError = map[string]struct{}{
 	".alias.add":         {},
 	".alias.remove":      {},
 	".alias.delete":      {},
+	".attach":            {},
+	".attach.add":        {},
+	".attach.get":        {},
+	".attach.list":       {},
+	".attach.rm":         {},
 	".audit":             {},
 	".cat":               {},
 	".clone":             {},
+	".clip":              {},
 	".convert":           {},
 	".copy":              {},
 	".create":            {},
 	".delete":            {},
+	".diff":              {},
 	".edit":              {},
 	".env":               {},
 	".find":              {},
@@ -73,16 +80,21 @@ var commandsWithError = map[string]struct{}{
 	".git.remote.remove": {},
 	".grep":              {},
 	".history":           {},
+	".history.restore":   {},
 	".init":              {},
 	".insert":            {},
 	".link":              {},
 	".merge":             {},
 	".mounts.add":        {},
 	".mounts.remove":     {},
+	".mounts.sync":       {},
 	".move":              {},
 	".otp":               {},
+	".otp.add":           {},
 	".recipients.add":    {},
 	".recipients.remove": {},
+	".run":               {},
+	".serve":             {},
 	".show":              {},
 	".sum":               {},
 	".templates.edit":    {},
@@ -123,7 +135,7 @@ func TestGetCommands(t *testing.T) {
 	c.Context = ctx
 
 	commands := getCommands(act, app)
-	assert.Equal(t, 38, len(commands))
+	assert.Equal(t, 43, len(commands))
 
 	prefix := ""
 	testCommands(t, c, commands, prefix)
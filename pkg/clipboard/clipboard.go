@@ -2,48 +2,93 @@ package clipboard
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/itsonlycode/gosecret/internal/notify"
 	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/pkg/appdir"
+	"github.com/itsonlycode/gosecret/pkg/debug"
 
-	"github.com/atotto/clipboard"
 	"github.com/fatih/color"
 )
 
 var (
 	// Helpers can be overridden at compile time, e.g. go build \
 	// -ldflags=='-X github.com/itsonlycode/gosecret/pkg/clipboard.Helpers=termux-api'
-	Helpers = "xsel of xclip"
+	Helpers = "wl-clipboard, xsel or xclip"
 	// ErrNotSupported is returned when the clipboard is not accessible
-	ErrNotSupported = fmt.Errorf("WARNING: No clipboard available. Install " + Helpers + " or use -f to print to console")
+	ErrNotSupported = fmt.Errorf("WARNING: No clipboard available. Install " + Helpers + ", set the clipboard provider in the config or use -f to print to console")
 )
 
 // CopyTo copies the given data to the clipboard and enqueues automatic
 // clearing of the clipboard
 func CopyTo(ctx context.Context, name string, content []byte, timeout int) error {
-	if clipboard.Unsupported {
+	p, err := Select(ctx)
+	if err == ErrNotSupported {
 		out.Printf(ctx, "%s", ErrNotSupported)
 		_ = notify.Notify(ctx, "gosecret - clipboard", fmt.Sprintf("%s", ErrNotSupported))
 		return nil
 	}
+	if err != nil {
+		return err
+	}
 
-	if err := clipboard.WriteAll(string(content)); err != nil {
+	if err := p.Copy(ctx, content); err != nil {
 		_ = notify.Notify(ctx, "gosecret - clipboard", "failed to write to clipboard")
 		return fmt.Errorf("failed to write to clipboard: %w", err)
 	}
 
+	hash := checksum(content)
+	if err := writeLastChecksum(hash); err != nil {
+		debug.Log("failed to record clipboard checksum: %s", err)
+	}
+
 	if timeout < 1 {
 		timeout = 45
 	}
-	if err := clear(ctx, content, timeout); err != nil {
+	if err := clear(ctx, p.Name(), hash, timeout); err != nil {
 		_ = notify.Notify(ctx, "gosecret - clipboard", "failed to clear clipboard")
 		return fmt.Errorf("failed to clear clipboard: %w", err)
 	}
 
-	out.Printf(ctx, "✔ Copied %s to clipboard. Will clear in %d seconds.", color.YellowString(name), timeout)
-	_ = notify.Notify(ctx, "gosecret - clipboard", fmt.Sprintf("✔ Copied %s to clipboard. Will clear in %d seconds.", name, timeout))
+	out.Printf(ctx, "✔ Copied %s to %s. Will clear in %d seconds.", color.YellowString(name), selection(ctx), timeout)
+	_ = notify.Notify(ctx, "gosecret - clipboard", fmt.Sprintf("✔ Copied %s to %s. Will clear in %d seconds.", name, selection(ctx), timeout))
+	return nil
+}
+
+// CopyOnce copies the given data to the clipboard and waits until it was
+// pasted once, but at most timeout seconds. It returns ErrNoPasteOnce if the
+// clipboard provider can't detect pastes.
+func CopyOnce(ctx context.Context, name string, content []byte, timeout int) error {
+	p, err := Select(ctx)
+	if err != nil {
+		return err
+	}
+	c, ok := p.(*command)
+	if !ok || len(c.once) < 1 {
+		return ErrNoPasteOnce
+	}
+
+	if timeout < 1 {
+		timeout = 45
+	}
+	wctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	out.Printf(ctx, "✔ Copied %s to %s. Waiting for it to be pasted.", color.YellowString(name), selection(ctx))
+	_ = notify.Notify(ctx, "gosecret - clipboard", fmt.Sprintf("✔ Copied %s to %s. Waiting for it to be pasted.", name, selection(ctx)))
+
+	if err := c.copyOnce(wctx, content); err != nil {
+		if wctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%s was not pasted within %d seconds", name, timeout)
+		}
+		return fmt.Errorf("failed to write to clipboard: %w", err)
+	}
 	return nil
 }
 
@@ -56,3 +101,30 @@ func killProc(pid int) {
 	// we ignore this error as we're going to return nil anyway
 	_ = proc.Kill()
 }
+
+func checksum(content []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
+
+// checksumFile records the checksum of the last content gosecret copied to
+// the clipboard. That allows unclip to decide if it should clear providers
+// that can't be read.
+func checksumFile() string {
+	return filepath.Join(appdir.UserCache(), "clipboard-checksum")
+}
+
+func writeLastChecksum(hash string) error {
+	fn := checksumFile()
+	if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(fn, []byte(hash), 0600)
+}
+
+func readLastChecksum() string {
+	buf, err := os.ReadFile(checksumFile())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(buf))
+}
@@ -6,7 +6,6 @@ package clipboard
 import (
 	"bytes"
 	"context"
-	"crypto/sha256"
 	"fmt"
 	"os"
 	"os/exec"
@@ -20,9 +19,7 @@ import (
 // process group until the timeout is expired. It will then compare the contents
 // of the clipboard and erase it if it still contains the data gosecret copied
 // to it.
-func clear(ctx context.Context, content []byte, timeout int) error {
-	hash := fmt.Sprintf("%x", sha256.Sum256(content))
-
+func clear(ctx context.Context, provider, hash string, timeout int) error {
 	// kill any pending unclip processes
 	_ = killPrecedessors()
 
@@ -31,7 +28,10 @@ func clear(ctx context.Context, content []byte, timeout int) error {
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Setpgid: true,
 	}
-	cmd.Env = append(os.Environ(), "GOPASS_UNCLIP_CHECKSUM="+hash)
+	cmd.Env = append(os.Environ(), "GOPASS_UNCLIP_CHECKSUM="+hash, "GOPASS_CLIPBOARD="+provider)
+	if IsPrimary(ctx) {
+		cmd.Env = append(cmd.Env, "GOPASS_CLIPBOARD_PRIMARY=true")
+	}
 	if !ctxutil.IsNotifications(ctx) {
 		cmd.Env = append(cmd.Env, "GOPASS_NO_NOTIFY=true")
 	}
@@ -19,6 +19,7 @@ import (
 
 func TestCopyToClipboard(t *testing.T) {
 	_ = os.Setenv("GOPASS_NO_NOTIFY", "true")
+	noClipboard(t)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	clipboard.Unsupported = true
@@ -31,7 +32,7 @@ func TestCopyToClipboard(t *testing.T) {
 
 func TestClearClipboard(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
-	assert.NoError(t, clear(ctx, []byte("bar"), 0))
+	assert.NoError(t, clear(ctx, "xclip", checksum([]byte("bar")), 0))
 	cancel()
 	time.Sleep(50 * time.Millisecond)
 }
@@ -5,8 +5,6 @@ package clipboard
 
 import (
 	"context"
-	"crypto/sha256"
-	"fmt"
 	"os"
 	"os/exec"
 	"strconv"
@@ -16,11 +14,12 @@ import (
 // process group until the timeout is expired. It will then compare the contents
 // of the clipboard and erase it if it still contains the data gosecret copied
 // to it.
-func clear(ctx context.Context, content []byte, timeout int) error {
-	hash := fmt.Sprintf("%x", sha256.Sum256(content))
-
+func clear(ctx context.Context, provider, hash string, timeout int) error {
 	cmd := exec.CommandContext(ctx, os.Args[0], "unclip", "--timeout", strconv.Itoa(timeout))
-	cmd.Env = append(os.Environ(), "GOPASS_UNCLIP_CHECKSUM="+hash)
+	cmd.Env = append(os.Environ(), "GOPASS_UNCLIP_CHECKSUM="+hash, "GOPASS_CLIPBOARD="+provider)
+	if IsPrimary(ctx) {
+		cmd.Env = append(cmd.Env, "GOPASS_CLIPBOARD_PRIMARY=true")
+	}
 	return cmd.Start()
 }
 
@@ -0,0 +1,347 @@
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/debug"
+
+	"github.com/atotto/clipboard"
+)
+
+var (
+	// ErrNotReadable is returned by providers that can only write to the
+	// clipboard, e.g. OSC52
+	ErrNotReadable = errors.New("clipboard can not be read")
+	// ErrNoPasteOnce is returned by CopyOnce if the provider can't detect
+	// when the content was pasted
+	ErrNoPasteOnce = errors.New("clipboard can not detect pastes")
+	// ErrNoPrimary is returned by Select if the primary selection was
+	// requested, but the provider only supports the clipboard
+	ErrNoPrimary = errors.New("clipboard provider does not support the primary selection")
+)
+
+type contextKey int
+
+const ctxKeyPrimary contextKey = iota
+
+// WithPrimary returns a context that makes Select use the primary selection
+// (X11 and Wayland) instead of the clipboard
+func WithPrimary(ctx context.Context, primary bool) context.Context {
+	return context.WithValue(ctx, ctxKeyPrimary, primary)
+}
+
+// IsPrimary returns true if the primary selection should be used. The
+// environment variable GOPASS_CLIPBOARD_PRIMARY is set for unclip.
+func IsPrimary(ctx context.Context) bool {
+	if os.Getenv("GOPASS_CLIPBOARD_PRIMARY") == "true" {
+		return true
+	}
+	v, ok := ctx.Value(ctxKeyPrimary).(bool)
+	return ok && v
+}
+
+// selection returns the name of the selection used in messages
+func selection(ctx context.Context) string {
+	if IsPrimary(ctx) {
+		return "primary selection"
+	}
+	return "clipboard"
+}
+
+// Provider is a clipboard implementation
+type Provider interface {
+	// Name is used to select the provider in the config
+	Name() string
+	// Available returns true if the provider should be used in the current
+	// environment. It's only consulted for automatic selection.
+	Available() bool
+	// Copy replaces the content of the clipboard. Empty content clears it.
+	Copy(ctx context.Context, content []byte) error
+	// Paste returns the content of the clipboard or ErrNotReadable
+	Paste(ctx context.Context) ([]byte, error)
+}
+
+// providers are the known clipboard providers in the order of preference for
+// automatic selection
+var providers = []Provider{
+	&command{
+		name:  "wayland",
+		env:   "WAYLAND_DISPLAY",
+		copy:  []string{"wl-copy"},
+		once:  []string{"wl-copy", "--paste-once", "--foreground"},
+		clear: []string{"wl-copy", "--clear"},
+		paste: []string{"wl-paste", "--no-newline"},
+		primary: &command{
+			name:  "wayland",
+			env:   "WAYLAND_DISPLAY",
+			copy:  []string{"wl-copy", "--primary"},
+			once:  []string{"wl-copy", "--paste-once", "--foreground", "--primary"},
+			clear: []string{"wl-copy", "--clear", "--primary"},
+			paste: []string{"wl-paste", "--no-newline", "--primary"},
+		},
+	},
+	&command{
+		name:  "xclip",
+		env:   "DISPLAY",
+		copy:  []string{"xclip", "-in", "-selection", "clipboard"},
+		once:  []string{"xclip", "-in", "-selection", "clipboard", "-loops", "1", "-quiet"},
+		paste: []string{"xclip", "-out", "-selection", "clipboard"},
+		primary: &command{
+			name:  "xclip",
+			env:   "DISPLAY",
+			copy:  []string{"xclip", "-in", "-selection", "primary"},
+			once:  []string{"xclip", "-in", "-selection", "primary", "-loops", "1", "-quiet"},
+			paste: []string{"xclip", "-out", "-selection", "primary"},
+		},
+	},
+	&command{
+		name:  "xsel",
+		env:   "DISPLAY",
+		copy:  []string{"xsel", "--input", "--clipboard"},
+		clear: []string{"xsel", "--delete", "--clipboard"},
+		paste: []string{"xsel", "--output", "--clipboard"},
+		primary: &command{
+			name:  "xsel",
+			env:   "DISPLAY",
+			copy:  []string{"xsel", "--input", "--primary"},
+			clear: []string{"xsel", "--delete", "--primary"},
+			paste: []string{"xsel", "--output", "--primary"},
+		},
+	},
+	&command{
+		name:  "termux",
+		copy:  []string{"termux-clipboard-set"},
+		paste: []string{"termux-clipboard-get"},
+	},
+	&system{},
+	&osc52{},
+	&command{
+		name:  "tmux",
+		env:   "TMUX",
+		copy:  []string{"tmux", "load-buffer", "-"},
+		clear: []string{"tmux", "delete-buffer"},
+		paste: []string{"tmux", "save-buffer", "-"},
+	},
+}
+
+// Providers returns the names of all known clipboard providers
+func Providers() []string {
+	names := make([]string, 0, len(providers))
+	for _, p := range providers {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+// Select returns the clipboard provider configured in the context or the
+// first one that is available in the current environment. The environment
+// variable GOPASS_CLIPBOARD takes precedence over both. The name none
+// disables the clipboard. With WithPrimary the provider writes to the
+// primary selection instead.
+func Select(ctx context.Context) (Provider, error) {
+	p, err := selectProvider(ctx)
+	if err != nil || !IsPrimary(ctx) {
+		return p, err
+	}
+	if c, ok := p.(*command); ok && c.primary != nil {
+		return c.primary, nil
+	}
+	return nil, fmt.Errorf("%s: %w", p.Name(), ErrNoPrimary)
+}
+
+func selectProvider(ctx context.Context) (Provider, error) {
+	name := os.Getenv("GOPASS_CLIPBOARD")
+	if name == "" {
+		name = ctxutil.GetClipboard(ctx)
+	}
+
+	if name == "none" {
+		return nil, ErrNotSupported
+	}
+	if name != "" && name != "auto" {
+		for _, p := range providers {
+			if p.Name() == name {
+				return p, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown clipboard provider %q. Valid providers: %s", name, strings.Join(Providers(), ", "))
+	}
+
+	for _, p := range providers {
+		if p.Available() {
+			debug.Log("using clipboard provider %s", p.Name())
+			return p, nil
+		}
+	}
+	return nil, ErrNotSupported
+}
+
+// command is a provider that uses external commands
+type command struct {
+	name string
+	// env must be set for this provider to be available
+	env  string
+	copy []string
+	// once copies and waits in the foreground until the content was
+	// pasted once
+	once  []string
+	clear []string
+	paste []string
+	// primary uses the primary selection instead of the clipboard
+	primary *command
+}
+
+func (c *command) Name() string {
+	return c.name
+}
+
+func (c *command) Available() bool {
+	if c.env != "" && os.Getenv(c.env) == "" {
+		return false
+	}
+	_, err := exec.LookPath(c.copy[0])
+	return err == nil
+}
+
+func (c *command) Copy(ctx context.Context, content []byte) error {
+	args := c.copy
+	if len(content) < 1 && len(c.clear) > 0 {
+		args = c.clear
+	}
+	return run(ctx, args, content)
+}
+
+// copyOnce blocks until the content was pasted or the context is done
+func (c *command) copyOnce(ctx context.Context, content []byte) error {
+	if len(c.once) < 1 {
+		return ErrNoPasteOnce
+	}
+	return run(ctx, c.once, content)
+}
+
+// run feeds the content to the command. xclip and wl-copy fork a child that
+// serves the clipboard and inherits stdout and stderr, so those must not be
+// pipes or we would wait for the child to exit. stderr goes to a temporary
+// file instead, it is only read if the command failed.
+func run(ctx context.Context, args []string, content []byte) error {
+	stderr, err := os.CreateTemp("", "gosecret-clipboard-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = stderr.Close()
+		_ = os.Remove(stderr.Name())
+	}()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		buf, _ := os.ReadFile(stderr.Name())
+		return fmt.Errorf("%s failed: %w: %s", args[0], err, strings.TrimSpace(string(buf)))
+	}
+	return nil
+}
+
+func (c *command) Paste(ctx context.Context) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, c.paste[0], c.paste[1:]...)
+	cmd.Stderr = io.Discard
+	buf, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", c.paste[0], err)
+	}
+	return buf, nil
+}
+
+// system uses the native clipboard on macOS and Windows
+type system struct{}
+
+func (s *system) Name() string {
+	return "system"
+}
+
+func (s *system) Available() bool {
+	if clipboard.Unsupported {
+		return false
+	}
+	return runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+}
+
+func (s *system) Copy(_ context.Context, content []byte) error {
+	return clipboard.WriteAll(string(content))
+}
+
+func (s *system) Paste(_ context.Context) ([]byte, error) {
+	cur, err := clipboard.ReadAll()
+	return []byte(cur), err
+}
+
+// osc52Terminal opens the terminal the escape sequences are written to
+var osc52Terminal = func() (io.WriteCloser, error) {
+	return os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+}
+
+// osc52 asks the terminal emulator to set the clipboard using the OSC52
+// escape sequence. That works over SSH and inside tmux or screen if the
+// terminal supports it. The clipboard can not be read.
+type osc52 struct{}
+
+func (o *osc52) Name() string {
+	return "osc52"
+}
+
+// Available returns true in remote sessions. Local sessions should use
+// one of the other providers.
+func (o *osc52) Available() bool {
+	if t := os.Getenv("TERM"); t == "" || t == "dumb" {
+		return false
+	}
+	return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+}
+
+func (o *osc52) Copy(_ context.Context, content []byte) error {
+	tty, err := osc52Terminal()
+	if err != nil {
+		return fmt.Errorf("failed to open terminal: %w", err)
+	}
+	defer func() {
+		_ = tty.Close()
+	}()
+
+	_, err = io.WriteString(tty, osc52Sequence(content))
+	return err
+}
+
+func (o *osc52) Paste(context.Context) ([]byte, error) {
+	return nil, ErrNotReadable
+}
+
+// osc52Sequence returns the escape sequence to set the clipboard. Invalid
+// base64 (!) clears it. tmux and screen need the sequence to be wrapped
+// in a passthrough sequence.
+func osc52Sequence(content []byte) string {
+	payload := "!"
+	if len(content) > 0 {
+		payload = base64.StdEncoding.EncodeToString(content)
+	}
+	seq := "\x1b]52;c;" + payload + "\a"
+
+	switch {
+	case os.Getenv("TMUX") != "":
+		return "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		return "\x1bP" + seq + "\x1b\\"
+	default:
+		return seq
+	}
+}
@@ -0,0 +1,265 @@
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itsonlycode/gosecret/internal/out"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+
+	"github.com/atotto/clipboard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+
+	old, found := os.LookupEnv(key)
+	require.NoError(t, os.Setenv(key, value))
+	t.Cleanup(func() {
+		if found {
+			_ = os.Setenv(key, old)
+			return
+		}
+		_ = os.Unsetenv(key)
+	})
+}
+
+// noClipboard makes sure no provider is available from the environment
+func noClipboard(t *testing.T) {
+	t.Helper()
+
+	for _, k := range []string{"WAYLAND_DISPLAY", "DISPLAY", "TMUX", "SSH_TTY", "SSH_CONNECTION", "GOPASS_CLIPBOARD"} {
+		setenv(t, k, "")
+	}
+	setenv(t, "PATH", t.TempDir()+string(os.PathListSeparator)+os.Getenv("PATH"))
+	setenv(t, "GOPASS_HOMEDIR", t.TempDir())
+	setenv(t, "GOPASS_NO_NOTIFY", "true")
+
+	unsupported := clipboard.Unsupported
+	clipboard.Unsupported = true
+	t.Cleanup(func() {
+		clipboard.Unsupported = unsupported
+	})
+}
+
+// fakeWayland installs wl-copy and wl-paste scripts that use a file as
+// clipboard
+func fakeWayland(t *testing.T) string {
+	t.Helper()
+
+	bin := filepath.SplitList(os.Getenv("PATH"))[0]
+	clip := filepath.Join(t.TempDir(), "clipboard")
+	setenv(t, "CLIPFILE", clip)
+	setenv(t, "WAYLAND_DISPLAY", "wayland-0")
+
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "wl-copy"), []byte("#!/bin/sh\nif [ \"$1\" = \"--paste-once\" ] && [ -n \"$NEVER_PASTED\" ]; then exec sleep 10; fi\nif [ \"$1\" = \"--clear\" ]; then : > \"$CLIPFILE\"; else cat > \"$CLIPFILE\"; fi\n"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "wl-paste"), []byte("#!/bin/sh\ncat \"$CLIPFILE\"\n"), 0755))
+	return clip
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func TestSelect(t *testing.T) {
+	noClipboard(t)
+	ctx := context.Background()
+
+	_, err := Select(ctx)
+	assert.Equal(t, ErrNotSupported, err)
+
+	// OSC52 is only selected automatically in remote sessions
+	setenv(t, "TERM", "xterm-256color")
+	_, err = Select(ctx)
+	assert.Equal(t, ErrNotSupported, err)
+	setenv(t, "SSH_TTY", "/dev/pts/1")
+	p, err := Select(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "osc52", p.Name())
+
+	fakeWayland(t)
+	p, err = Select(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "wayland", p.Name())
+
+	// the config can override the automatic selection
+	p, err = Select(ctxutil.WithClipboard(ctx, "tmux"))
+	require.NoError(t, err)
+	assert.Equal(t, "tmux", p.Name())
+
+	p, err = Select(ctxutil.WithClipboard(ctx, "auto"))
+	require.NoError(t, err)
+	assert.Equal(t, "wayland", p.Name())
+
+	_, err = Select(ctxutil.WithClipboard(ctx, "none"))
+	assert.Equal(t, ErrNotSupported, err)
+
+	_, err = Select(ctxutil.WithClipboard(ctx, "foo"))
+	assert.Error(t, err)
+
+	// and the environment overrides the config
+	setenv(t, "GOPASS_CLIPBOARD", "xsel")
+	p, err = Select(ctxutil.WithClipboard(ctx, "tmux"))
+	require.NoError(t, err)
+	assert.Equal(t, "xsel", p.Name())
+}
+
+func TestCommandProvider(t *testing.T) {
+	noClipboard(t)
+	clip := fakeWayland(t)
+	ctx := context.Background()
+
+	p, err := Select(ctx)
+	require.NoError(t, err)
+	require.NoError(t, p.Copy(ctx, []byte("foo")))
+	buf, err := p.Paste(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(buf))
+
+	// a different checksum means the user copied something else
+	assert.NoError(t, Clear(ctx, checksum([]byte("bar")), false))
+	buf, err = os.ReadFile(clip)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(buf))
+
+	assert.NoError(t, Clear(ctx, checksum([]byte("foo")), false))
+	buf, err = os.ReadFile(clip)
+	require.NoError(t, err)
+	assert.Equal(t, "", string(buf))
+}
+
+func TestCopyForks(t *testing.T) {
+	noClipboard(t)
+	clip := fakeWayland(t)
+	ctx := context.Background()
+
+	// wl-copy and xclip leave a child behind that serves the clipboard
+	bin := filepath.SplitList(os.Getenv("PATH"))[0]
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "wl-copy"), []byte("#!/bin/sh\nif [ -n \"$COPY_FAILS\" ]; then echo \"no display\" >&2; exit 1; fi\ncat > \"$CLIPFILE\"\nsleep 10 &\n"), 0755))
+
+	p, err := Select(ctx)
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, p.Copy(ctx, []byte("foo")))
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+	buf, err := os.ReadFile(clip)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(buf))
+
+	setenv(t, "COPY_FAILS", "true")
+	err = p.Copy(ctx, []byte("foo"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no display")
+}
+
+func TestPrimary(t *testing.T) {
+	noClipboard(t)
+	clip := fakeWayland(t)
+	ctx := WithPrimary(context.Background(), true)
+
+	// the fake wl-copy keeps the primary selection in its own file
+	bin := filepath.SplitList(os.Getenv("PATH"))[0]
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "wl-copy"), []byte("#!/bin/sh\nf=\"$CLIPFILE\"\nfor a in \"$@\"; do [ \"$a\" = \"--primary\" ] && f=\"$CLIPFILE.primary\"; done\nif [ \"$1\" = \"--clear\" ]; then : > \"$f\"; else cat > \"$f\"; fi\n"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "wl-paste"), []byte("#!/bin/sh\nf=\"$CLIPFILE\"\nfor a in \"$@\"; do [ \"$a\" = \"--primary\" ] && f=\"$CLIPFILE.primary\"; done\ncat \"$f\"\n"), 0755))
+
+	assert.True(t, IsPrimary(ctx))
+	assert.False(t, IsPrimary(context.Background()))
+
+	p, err := Select(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "wayland", p.Name())
+	require.NoError(t, p.Copy(ctx, []byte("foo")))
+	buf, err := os.ReadFile(clip + ".primary")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(buf))
+	_, err = os.Stat(clip)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, Clear(ctx, checksum([]byte("foo")), false))
+	buf, err = os.ReadFile(clip + ".primary")
+	require.NoError(t, err)
+	assert.Equal(t, "", string(buf))
+
+	// unclip gets it from the environment
+	setenv(t, "GOPASS_CLIPBOARD_PRIMARY", "true")
+	assert.True(t, IsPrimary(context.Background()))
+	setenv(t, "GOPASS_CLIPBOARD_PRIMARY", "")
+
+	_, err = Select(ctxutil.WithClipboard(ctx, "tmux"))
+	assert.True(t, errors.Is(err, ErrNoPrimary))
+}
+
+func TestOSC52(t *testing.T) {
+	noClipboard(t)
+	setenv(t, "TERM", "xterm")
+	ctx := ctxutil.WithClipboard(context.Background(), "osc52")
+
+	tty := &bytes.Buffer{}
+	old := osc52Terminal
+	osc52Terminal = func() (io.WriteCloser, error) {
+		return nopCloser{tty}, nil
+	}
+	defer func() {
+		osc52Terminal = old
+	}()
+
+	p, err := Select(ctx)
+	require.NoError(t, err)
+	require.NoError(t, p.Copy(ctx, []byte("foo")))
+	assert.Equal(t, "\x1b]52;c;Zm9v\a", tty.String())
+	_, err = p.Paste(ctx)
+	assert.Equal(t, ErrNotReadable, err)
+
+	tty.Reset()
+	setenv(t, "TMUX", "/tmp/tmux-1000/default,1,0")
+	require.NoError(t, p.Copy(ctx, []byte("foo")))
+	assert.Equal(t, "\x1bPtmux;\x1b\x1b]52;c;Zm9v\a\x1b\\", tty.String())
+	setenv(t, "TMUX", "")
+
+	// the clipboard can't be read, so the last copied content decides
+	tty.Reset()
+	require.NoError(t, writeLastChecksum(checksum([]byte("bar"))))
+	assert.NoError(t, Clear(ctx, checksum([]byte("foo")), false))
+	assert.Equal(t, "", tty.String())
+
+	require.NoError(t, writeLastChecksum(checksum([]byte("foo"))))
+	assert.NoError(t, Clear(ctx, checksum([]byte("foo")), false))
+	assert.Equal(t, "\x1b]52;c;!\a", tty.String())
+}
+
+func TestCopyOnce(t *testing.T) {
+	noClipboard(t)
+	clip := fakeWayland(t)
+	ctx := context.Background()
+
+	buf := &bytes.Buffer{}
+	out.Stdout = buf
+	defer func() {
+		out.Stdout = os.Stdout
+	}()
+
+	assert.NoError(t, CopyOnce(ctx, "foo", []byte("bar"), 5))
+	assert.Contains(t, buf.String(), "Waiting for it to be pasted")
+	content, err := os.ReadFile(clip)
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(content))
+
+	setenv(t, "NEVER_PASTED", "true")
+	assert.Error(t, CopyOnce(ctx, "foo", []byte("bar"), 1))
+
+	assert.Equal(t, ErrNoPasteOnce, CopyOnce(ctxutil.WithClipboard(ctx, "osc52"), "foo", []byte("bar"), 1))
+	assert.Equal(t, ErrNotSupported, CopyOnce(ctxutil.WithClipboard(ctx, "none"), "foo", []byte("bar"), 1))
+}
@@ -2,31 +2,36 @@ package clipboard
 
 import (
 	"context"
-	"crypto/sha256"
+	"errors"
 	"fmt"
 
 	"github.com/itsonlycode/gosecret/internal/notify"
-
-	"github.com/atotto/clipboard"
 )
 
-// Clear will attempt to erase the clipboard
-func Clear(ctx context.Context, checksum string, force bool) error {
-	if clipboard.Unsupported {
-		return ErrNotSupported
-	}
-
-	cur, err := clipboard.ReadAll()
+// Clear will attempt to erase the clipboard. Unless force is set it's only
+// cleared if it still contains the content with the given checksum. If the
+// clipboard can't be read we compare against the last content gosecret copied.
+func Clear(ctx context.Context, hash string, force bool) error {
+	p, err := Select(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to read clipboard: %w", err)
+		return err
 	}
 
-	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(cur)))
-	if hash != checksum && !force {
-		return nil
+	cur, err := p.Paste(ctx)
+	switch {
+	case errors.Is(err, ErrNotReadable):
+		if readLastChecksum() != hash && !force {
+			return nil
+		}
+	case err != nil:
+		return fmt.Errorf("failed to read clipboard: %w", err)
+	default:
+		if checksum(cur) != hash && !force {
+			return nil
+		}
 	}
 
-	if err := clipboard.WriteAll(""); err != nil {
+	if err := p.Copy(ctx, nil); err != nil {
 		_ = notify.Notify(ctx, "gosecret - clipboard", "Failed to clear clipboard")
 		return fmt.Errorf("failed to write clipboard: %w", err)
 	}
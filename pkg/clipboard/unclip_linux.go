@@ -7,13 +7,17 @@ import (
 	"context"
 	"strings"
 
+	"github.com/itsonlycode/gosecret/pkg/debug"
+
 	"github.com/godbus/dbus"
 )
 
 func clearClipboardHistory(ctx context.Context) error {
 	conn, err := dbus.SessionBus()
 	if err != nil {
-		return err
+		// no session bus, e.g. in a SSH session, means there is no klipper
+		debug.Log("no dbus session bus, not clearing clipboard history: %s", err)
+		return nil
 	}
 
 	obj := conn.Object("org.kde.klipper", "/klipper")
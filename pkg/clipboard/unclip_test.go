@@ -13,6 +13,7 @@ import (
 )
 
 func TestUnclip(t *testing.T) {
+	noClipboard(t)
 	ctx := context.Background()
 	ctx = ctxutil.WithAlwaysYes(ctx, true)
 
@@ -35,6 +35,9 @@ const (
 	ctxKeyCommitTimestamp
 	ctxKeyShowParsing
 	ctxKeyHidden
+	ctxKeyClipboard
+	ctxKeySyncInterval
+	ctxKeySigningKey
 )
 
 // WithGlobalFlags parses any global flags from the cli context and returns
@@ -469,3 +472,60 @@ func IsHidden(ctx context.Context) bool {
 	}
 	return bv
 }
+
+// WithClipboard returns a context with the name of the clipboard provider set
+func WithClipboard(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, ctxKeyClipboard, name)
+}
+
+// HasClipboard returns true if the clipboard provider was set
+func HasClipboard(ctx context.Context) bool {
+	return hasString(ctx, ctxKeyClipboard)
+}
+
+// GetClipboard returns the name of the clipboard provider or an empty string
+// for automatic selection
+func GetClipboard(ctx context.Context) string {
+	sv, ok := ctx.Value(ctxKeyClipboard).(string)
+	if !ok {
+		return ""
+	}
+	return sv
+}
+
+// WithSyncInterval returns a context with the interval of the automatic
+// sync set. Zero disables it.
+func WithSyncInterval(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, ctxKeySyncInterval, d)
+}
+
+// HasSyncInterval returns true if the interval of the automatic sync was set
+func HasSyncInterval(ctx context.Context) bool {
+	_, ok := ctx.Value(ctxKeySyncInterval).(time.Duration)
+	return ok
+}
+
+// GetSyncInterval returns the interval of the automatic sync or zero if it's
+// disabled
+func GetSyncInterval(ctx context.Context) time.Duration {
+	d, ok := ctx.Value(ctxKeySyncInterval).(time.Duration)
+	if !ok || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// WithSigningKey returns a context with the key to sign commits with set
+func WithSigningKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, ctxKeySigningKey, key)
+}
+
+// GetSigningKey returns the key to sign commits with or an empty string if
+// commits should not be signed
+func GetSigningKey(ctx context.Context) string {
+	sv, ok := ctx.Value(ctxKeySigningKey).(string)
+	if !ok {
+		return ""
+	}
+	return sv
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/urfave/cli/v2"
@@ -121,6 +122,15 @@ func TestCommitMessage(t *testing.T) {
 	assert.Equal(t, "", GetCommitMessage(WithCommitMessage(ctx, "")))
 }
 
+func TestClipboard(t *testing.T) {
+	ctx := context.Background()
+
+	assert.False(t, HasClipboard(ctx))
+	assert.Equal(t, "", GetClipboard(ctx))
+	assert.True(t, HasClipboard(WithClipboard(ctx, "osc52")))
+	assert.Equal(t, "osc52", GetClipboard(WithClipboard(ctx, "osc52")))
+}
+
 func TestComposite(t *testing.T) {
 	ctx := context.Background()
 	ctx = WithTerminal(ctx, false)
@@ -221,3 +231,20 @@ func TestHidden(t *testing.T) {
 	assert.False(t, IsHidden(ctx))
 	assert.True(t, IsHidden(WithHidden(ctx, true)))
 }
+
+func TestSyncInterval(t *testing.T) {
+	ctx := context.Background()
+
+	assert.False(t, HasSyncInterval(ctx))
+	assert.Equal(t, time.Duration(0), GetSyncInterval(ctx))
+	assert.True(t, HasSyncInterval(WithSyncInterval(ctx, time.Minute)))
+	assert.Equal(t, time.Minute, GetSyncInterval(WithSyncInterval(ctx, time.Minute)))
+	assert.Equal(t, time.Duration(0), GetSyncInterval(WithSyncInterval(ctx, -time.Minute)))
+}
+
+func TestSigningKey(t *testing.T) {
+	ctx := context.Background()
+
+	assert.Equal(t, "", GetSigningKey(ctx))
+	assert.Equal(t, "0xDEADBEEF", GetSigningKey(WithSigningKey(ctx, "0xDEADBEEF")))
+}
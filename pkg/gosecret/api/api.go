@@ -69,6 +69,41 @@ func (g *Gosecret) Rename(ctx context.Context, src, dest string) error {
 	return g.rs.Move(ctx, src, dest)
 }
 
+// Begin starts a transaction
+func (g *Gosecret) Begin(ctx context.Context) (gosecret.Tx, error) {
+	return &tx{tx: g.rs.Begin(ctx)}, nil
+}
+
+// tx maps the transactions of the root store to the public API
+type tx struct {
+	tx *root.Tx
+}
+
+// Set stages a new revision of a secret
+func (t *tx) Set(ctx context.Context, name string, sec gosecret.Byter) error {
+	return t.tx.Set(ctx, name, sec)
+}
+
+// Remove stages removing a secret
+func (t *tx) Remove(ctx context.Context, name string) error {
+	return t.tx.Delete(ctx, name)
+}
+
+// Rename stages moving a secret or prefix
+func (t *tx) Rename(ctx context.Context, src, dest string) error {
+	return t.tx.Move(ctx, src, dest)
+}
+
+// Commit applies all staged changes
+func (t *tx) Commit(ctx context.Context) error {
+	return t.tx.Commit(ctx)
+}
+
+// Rollback discards all staged changes
+func (t *tx) Rollback(ctx context.Context) error {
+	return t.tx.Rollback(ctx)
+}
+
 // Sync synchronizes a secret with a remote
 func (g *Gosecret) Sync(ctx context.Context) error {
 	return fmt.Errorf("not yet implemented")
@@ -85,7 +120,7 @@ func (g *Gosecret) String() string {
 
 // Close shuts down all background processes
 func (g *Gosecret) Close(ctx context.Context) error {
-	return queue.GetQueue(ctx).Wait(ctx)
+	return queue.GetQueue(ctx).Close(ctx)
 }
 
 // ConfigDir returns gosecret' configuration directory
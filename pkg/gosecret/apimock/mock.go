@@ -18,7 +18,8 @@ func (m *Secret) Bytes() []byte {
 	return m.Buf
 }
 
-// MockAPI is a gosecret API mock
+// MockAPI is a gosecret API mock. It has no crypto, revisions or mounts, use
+// apitest for a complete in-memory store.
 type MockAPI struct {
 	store *mockstore.MockStore
 }
@@ -78,6 +79,59 @@ func (a *MockAPI) Rename(ctx context.Context, src, dest string) error {
 	return a.store.Move(ctx, src, dest)
 }
 
+// Begin starts a transaction that applies the staged changes in order.
+// Unlike the real store it does not roll back on errors.
+func (a *MockAPI) Begin(ctx context.Context) (gosecret.Tx, error) {
+	return &tx{a: a}, nil
+}
+
+type tx struct {
+	a   *MockAPI
+	ops []func(context.Context) error
+}
+
+// Set stages a new revision of a secret
+func (t *tx) Set(ctx context.Context, name string, sec gosecret.Byter) error {
+	t.ops = append(t.ops, func(ctx context.Context) error {
+		return t.a.Set(ctx, name, sec)
+	})
+	return nil
+}
+
+// Remove stages removing a secret
+func (t *tx) Remove(ctx context.Context, name string) error {
+	t.ops = append(t.ops, func(ctx context.Context) error {
+		return t.a.Remove(ctx, name)
+	})
+	return nil
+}
+
+// Rename stages moving a secret
+func (t *tx) Rename(ctx context.Context, src, dest string) error {
+	t.ops = append(t.ops, func(ctx context.Context) error {
+		return t.a.Rename(ctx, src, dest)
+	})
+	return nil
+}
+
+// Commit applies the staged changes
+func (t *tx) Commit(ctx context.Context) error {
+	ops := t.ops
+	t.ops = nil
+	for _, op := range ops {
+		if err := op(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rollback discards the staged changes
+func (t *tx) Rollback(ctx context.Context) error {
+	t.ops = nil
+	return nil
+}
+
 // Sync does nothing
 func (a *MockAPI) Sync(ctx context.Context) error {
 	return fmt.Errorf("not yet implemented")
@@ -0,0 +1,336 @@
+// Package apitest provides a complete, in-memory gosecret.Store for tests
+// of tools that use the gosecret API.
+//
+// Unlike apimock the store is backed by the real store implementation. It
+// supports mounts, recipients, revisions and syncing, but nothing is
+// encrypted (it uses the plain crypto backend) and secrets never touch the
+// disk (it uses the in-memory storage backend). Only the store locks and the
+// sync state are small files in the user cache directory, like for any
+// store. Set GOPASS_HOMEDIR to a temporary directory to keep them out of the
+// real one. Every mount has its own revision history.
+package apitest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/backend"
+	_ "github.com/itsonlycode/gosecret/internal/backend/crypto/plain" // load plain crypto backend
+	"github.com/itsonlycode/gosecret/internal/backend/storage/inmem"
+	"github.com/itsonlycode/gosecret/internal/config"
+	"github.com/itsonlycode/gosecret/internal/queue"
+	"github.com/itsonlycode/gosecret/internal/store"
+	"github.com/itsonlycode/gosecret/internal/store/root"
+	"github.com/itsonlycode/gosecret/internal/tree"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+)
+
+const (
+	// Recipient is the key every store is initialized for
+	Recipient = "DEADBEEF"
+	// OtherRecipient is another key known to the crypto backend. Use it to
+	// test adding and removing recipients.
+	OtherRecipient = "FEEDBEEF"
+)
+
+var instances uint64
+
+// Store is an in-memory gosecret.Store
+type Store struct {
+	t    testing.TB
+	rs   *root.Store
+	base string
+}
+
+// make sure that *Store implements gosecret.Store
+var _ gosecret.Store = &Store{}
+
+// Commit is a commit in the history of a mount
+type Commit struct {
+	Hash    string
+	Subject string
+	Body    string
+	// Files lists the files changed by this commit
+	Files []string
+}
+
+// New creates a new store with the given mounts. All stores are removed
+// when the test finishes.
+func New(t testing.TB, mounts ...string) *Store {
+	t.Helper()
+
+	s := newStore(t)
+	ctx := s.ctx(context.Background())
+	if err := s.rs.Init(ctx, "", s.path(""), Recipient); err != nil {
+		t.Fatalf("failed to initialize the root store: %s", err)
+	}
+	for _, alias := range mounts {
+		if err := s.rs.Init(ctx, alias, s.path(alias), Recipient); err != nil {
+			t.Fatalf("failed to initialize %s: %s", alias, err)
+		}
+		if err := s.rs.AddMount(ctx, alias, s.path(alias)); err != nil {
+			t.Fatalf("failed to mount %s: %s", alias, err)
+		}
+	}
+	return s
+}
+
+// Clone creates a new store that is cloned from this one, with the same
+// mounts. Each store is the remote (origin) of its clone, so Sync exchanges
+// commits between both.
+func (s *Store) Clone(t testing.TB) *Store {
+	t.Helper()
+
+	c := newStore(t)
+	ctx := c.ctx(context.Background())
+	for _, alias := range append([]string{""}, s.rs.MountPoints()...) {
+		if _, err := backend.Clone(ctx, backend.InMem, s.path(alias), c.path(alias)); err != nil {
+			t.Fatalf("failed to clone %q: %s", alias, err)
+		}
+		if alias == "" {
+			if _, err := c.rs.IsInitialized(ctx); err != nil {
+				t.Fatalf("failed to open the root store: %s", err)
+			}
+			continue
+		}
+		if err := c.rs.AddMount(ctx, alias, c.path(alias)); err != nil {
+			t.Fatalf("failed to mount %s: %s", alias, err)
+		}
+	}
+	return c
+}
+
+func newStore(t testing.TB) *Store {
+	s := &Store{
+		t:    t,
+		base: fmt.Sprintf("/apitest/%d", atomic.AddUint64(&instances, 1)),
+	}
+	s.rs = root.New(&config.Config{
+		Path:   s.path(""),
+		Mounts: make(map[string]config.MountConfig),
+	})
+	t.Cleanup(func() {
+		for _, alias := range append([]string{""}, s.rs.MountPoints()...) {
+			inmem.Discard(s.path(alias))
+		}
+	})
+	return s
+}
+
+// path returns the location of the in-memory repository of the mount
+func (s *Store) path(alias string) string {
+	if alias == "" {
+		return path.Join(s.base, "root")
+	}
+	return path.Join(s.base, "mounts", alias)
+}
+
+func (s *Store) ctx(ctx context.Context) context.Context {
+	ctx = backend.WithCryptoBackend(ctx, backend.Plain)
+	ctx = backend.WithStorageBackend(ctx, backend.InMem)
+	return ctxutil.WithHidden(ctx, true)
+}
+
+// String implements fmt.Stringer
+func (s *Store) String() string {
+	return "apitest"
+}
+
+// List returns all secrets
+func (s *Store) List(ctx context.Context) ([]string, error) {
+	return s.rs.List(ctx, tree.INF)
+}
+
+// Get returns a secret. Revision defaults to the latest one.
+func (s *Store) Get(ctx context.Context, name, revision string) (gosecret.Secret, error) {
+	if revision == "" || revision == "latest" {
+		return s.rs.Get(ctx, name)
+	}
+	_, sec, err := s.rs.GetRevision(ctx, name, revision)
+	return sec, err
+}
+
+// Set adds a new revision of a secret
+func (s *Store) Set(ctx context.Context, name string, sec gosecret.Byter) error {
+	return s.rs.Set(ctx, name, sec)
+}
+
+// Revisions returns the revisions of a secret, latest first
+func (s *Store) Revisions(ctx context.Context, name string) ([]string, error) {
+	rs, err := s.rs.ListRevisions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	revs := make([]string, 0, len(rs))
+	for _, r := range rs {
+		revs = append(revs, r.Hash)
+	}
+	return revs, nil
+}
+
+// Remove removes a single secret
+func (s *Store) Remove(ctx context.Context, name string) error {
+	return s.rs.Delete(ctx, name)
+}
+
+// RemoveAll removes all secrets with a given prefix
+func (s *Store) RemoveAll(ctx context.Context, prefix string) error {
+	return s.rs.Prune(ctx, prefix)
+}
+
+// Rename moves a secret or a prefix
+func (s *Store) Rename(ctx context.Context, src, dest string) error {
+	return s.rs.Move(ctx, src, dest)
+}
+
+// Begin starts a transaction
+func (s *Store) Begin(ctx context.Context) (gosecret.Tx, error) {
+	return &tx{tx: s.rs.Begin(ctx)}, nil
+}
+
+type tx struct {
+	tx *root.Tx
+}
+
+func (t *tx) Set(ctx context.Context, name string, sec gosecret.Byter) error {
+	return t.tx.Set(ctx, name, sec)
+}
+
+func (t *tx) Remove(ctx context.Context, name string) error {
+	return t.tx.Delete(ctx, name)
+}
+
+func (t *tx) Rename(ctx context.Context, src, dest string) error {
+	return t.tx.Move(ctx, src, dest)
+}
+
+func (t *tx) Commit(ctx context.Context) error {
+	return t.tx.Commit(ctx)
+}
+
+func (t *tx) Rollback(ctx context.Context) error {
+	return t.tx.Rollback(ctx)
+}
+
+// Sync pulls and pushes every mount that has a remote, i.e. every store
+// created by Clone and the stores it was cloned from
+func (s *Store) Sync(ctx context.Context) error {
+	for _, alias := range append([]string{""}, s.rs.MountPoints()...) {
+		if err := s.rs.RCSPull(ctx, alias, "", ""); err != nil {
+			if errors.Is(err, store.ErrGitNoRemote) {
+				continue
+			}
+			return fmt.Errorf("failed to pull %q: %w", alias, err)
+		}
+		if err := s.rs.RCSPush(ctx, alias, "", ""); err != nil {
+			return fmt.Errorf("failed to push %q: %w", alias, err)
+		}
+	}
+	return nil
+}
+
+// Close waits for pending background tasks
+func (s *Store) Close(ctx context.Context) error {
+	return queue.GetQueue(ctx).Close(ctx)
+}
+
+// Seed writes the given secrets. The first line of each value is the
+// password, just like in a secret file.
+func (s *Store) Seed(fixtures map[string]string) {
+	s.t.Helper()
+
+	names := make([]string, 0, len(fixtures))
+	for name := range fixtures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ctx := ctxutil.WithCommitMessage(context.Background(), "Seed")
+	for _, name := range names {
+		if err := s.rs.Set(ctx, name, secrets.ParsePlain([]byte(fixtures[name]))); err != nil {
+			s.t.Fatalf("failed to seed %s: %s", name, err)
+		}
+	}
+}
+
+// AddRecipient adds a recipient to a mount. Use OtherRecipient.
+func (s *Store) AddRecipient(mount, id string) {
+	s.t.Helper()
+
+	if err := s.rs.AddRecipient(s.ctx(context.Background()), mount, id); err != nil {
+		s.t.Fatalf("failed to add recipient %s to %q: %s", id, mount, err)
+	}
+}
+
+// Recipients returns the recipients of a mount
+func (s *Store) Recipients(mount string) []string {
+	return s.rs.ListRecipients(context.Background(), mount)
+}
+
+// Commits returns the history of a mount, latest commit first. Use "" for
+// the root store.
+func (s *Store) Commits(mount string) []Commit {
+	s.t.Helper()
+
+	m, found := inmem.Lookup(s.path(mount))
+	if !found {
+		s.t.Fatalf("no such mount: %q", mount)
+		return nil
+	}
+	cs := m.Commits()
+	commits := make([]Commit, 0, len(cs))
+	for _, c := range cs {
+		commits = append(commits, Commit{
+			Hash:    c.Hash,
+			Subject: c.Subject,
+			Body:    c.Body,
+			Files:   c.Files,
+		})
+	}
+	return commits
+}
+
+// AssertCommit checks that the latest commit of a mount has a subject
+// containing the given text and changed the given secrets. The names of
+// the secrets are relative to the mount.
+func (s *Store) AssertCommit(mount, subject string, names ...string) bool {
+	s.t.Helper()
+
+	cs := s.Commits(mount)
+	if len(cs) < 1 {
+		s.t.Errorf("%q has no commits", mount)
+		return false
+	}
+	c := cs[0]
+	ok := true
+	if !strings.Contains(c.Subject, subject) {
+		s.t.Errorf("latest commit of %q is %q, want %q", mount, c.Subject, subject)
+		ok = false
+	}
+	for _, name := range names {
+		if !changes(c, name) {
+			s.t.Errorf("latest commit of %q (%q) does not change %s. Changed: %s", mount, c.Subject, name, strings.Join(c.Files, ", "))
+			ok = false
+		}
+	}
+	return ok
+}
+
+// changes returns true if the commit changed the secret. Secrets are
+// stored with a file extension that depends on the crypto backend.
+func changes(c Commit, name string) bool {
+	for _, f := range c.Files {
+		if f == name || strings.TrimSuffix(f, path.Ext(f)) == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package apitest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/itsonlycode/gosecret/internal/backend/storage/inmem"
+	"github.com/itsonlycode/gosecret/pkg/ctxutil"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	s := New(t, "team")
+	s.Seed(map[string]string{
+		"web/example": "hunter2\nuser: alice",
+		"team/db":     "s3cret",
+	})
+
+	names, err := s.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team/db", "web/example"}, names)
+	s.AssertCommit("", "Seed", "web/example")
+	s.AssertCommit("team", "Seed", "db")
+
+	sec, err := s.Get(ctx, "web/example", "")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", sec.Password())
+
+	t.Run("revisions", func(t *testing.T) {
+		require.NoError(t, s.Set(ctxutil.WithCommitMessage(ctx, "Rotate"), "web/example", secrets.ParsePlain([]byte("hunter3"))))
+		s.AssertCommit("", "Rotate", "web/example")
+
+		revs, err := s.Revisions(ctx, "web/example")
+		require.NoError(t, err)
+		require.Len(t, revs, 2)
+
+		sec, err := s.Get(ctx, "web/example", revs[1])
+		require.NoError(t, err)
+		assert.Equal(t, "hunter2", sec.Password())
+		sec, err = s.Get(ctx, "web/example", "latest")
+		require.NoError(t, err)
+		assert.Equal(t, "hunter3", sec.Password())
+	})
+
+	t.Run("recipients", func(t *testing.T) {
+		assert.Len(t, s.Recipients("team"), 1)
+		s.AddRecipient("team", OtherRecipient)
+		assert.Len(t, s.Recipients("team"), 2)
+		assert.Len(t, s.Recipients(""), 1)
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		before := len(s.Commits("team"))
+		tx, err := s.Begin(ctx)
+		require.NoError(t, err)
+		require.NoError(t, tx.Set(ctx, "team/a", secrets.ParsePlain([]byte("a"))))
+		require.NoError(t, tx.Rename(ctx, "team/db", "team/database"))
+		require.NoError(t, tx.Commit(ctxutil.WithCommitMessage(ctx, "Bulk")))
+
+		assert.Len(t, s.Commits("team"), before+1)
+		s.AssertCommit("team", "Bulk", "a", "db", "database")
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		c := s.Clone(t)
+		names, err := c.List(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"team/a", "team/database", "web/example"}, names)
+
+		require.NoError(t, c.Set(ctx, "team/b", secrets.ParsePlain([]byte("b"))))
+		require.NoError(t, c.Sync(ctx))
+		require.NoError(t, s.Sync(ctx))
+		sec, err := s.Get(ctx, "team/b", "")
+		require.NoError(t, err)
+		assert.Equal(t, "b", sec.Password())
+
+		// the clone did not pull the latest change, so it can't push
+		require.NoError(t, s.Set(ctx, "team/c", secrets.ParsePlain([]byte("c"))))
+		err = c.Set(ctx, "team/d", secrets.ParsePlain([]byte("d")))
+		assert.True(t, errors.Is(err, inmem.ErrRejected), "%s", err)
+		assert.True(t, errors.Is(c.Sync(ctx), inmem.ErrDiverged))
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		require.NoError(t, s.Remove(ctx, "team/a"))
+		require.NoError(t, s.RemoveAll(ctx, "web"))
+		names, err := s.List(ctx)
+		require.NoError(t, err)
+		assert.NotContains(t, names, "team/a")
+		assert.NotContains(t, names, "web/example")
+		require.NoError(t, s.Close(ctx))
+	})
+}
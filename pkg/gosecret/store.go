@@ -50,6 +50,8 @@ type Store interface {
 	RemoveAll(ctx context.Context, prefix string) error
 	// Rename a path (secret of prefix) without decrypting
 	Rename(ctx context.Context, src, dest string) error
+	// Begin starts a transaction. Use it to change many secrets at once.
+	Begin(ctx context.Context) (Tx, error)
 	// Sync with a remote (if configured)
 	// NOTE: We will always auto-sync when mutating the store. Use this to
 	// manually pull in changes.
@@ -57,3 +59,21 @@ type Store interface {
 	// Clean up any resources. MUST be called before the process exists.
 	Close(ctx context.Context) error
 }
+
+// Tx is a set of changes to a store. Nothing is changed until Commit, which
+// applies all changes or none of them. Each affected store gets a single
+// commit and is pushed once. Commits are not undone: if committing one of
+// several mounts fails, the mounts committed before keep their changes.
+type Tx interface {
+	// Set stages a new revision of a secret
+	Set(ctx context.Context, name string, sec Byter) error
+	// Remove stages removing a single secret
+	Remove(ctx context.Context, name string) error
+	// Rename stages moving a secret or prefix
+	Rename(ctx context.Context, src, dest string) error
+	// Commit applies all staged changes. The commit message is taken from
+	// the context, if set.
+	Commit(ctx context.Context) error
+	// Rollback discards all staged changes
+	Rollback(ctx context.Context) error
+}
@@ -0,0 +1,378 @@
+package otp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itsonlycode/gosecret/pkg/gosecret"
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets/secparse"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	// TypeTOTP is a RFC 6238 time-based OTP
+	TypeTOTP = "totp"
+	// TypeHOTP is a RFC 4226 counter-based OTP
+	TypeHOTP = "hotp"
+	// TypeSteam is a Steam Guard code. It is a TOTP with a custom encoding.
+	TypeSteam = "steam"
+
+	// DefaultPeriod is the default period of a TOTP in seconds
+	DefaultPeriod = 30
+	// DefaultDigits is the default number of digits of an OTP
+	DefaultDigits = 6
+	// DefaultAlgorithm is the default HMAC algorithm
+	DefaultAlgorithm = "SHA1"
+
+	steamDigits   = 5
+	steamAlphabet = "23456789BCDFGHJKMNPQRTVWXY"
+)
+
+// Key is an OTP configuration, usually parsed from an otpauth:// URL
+type Key struct {
+	Type      string
+	Label     string
+	Issuer    string
+	Secret    []byte
+	Algorithm string
+	Digits    int
+	Period    int
+	Counter   uint64
+
+	// url is the parsed otpauth URL, if any. It is used to preserve
+	// unknown parameters when the counter is updated.
+	url *url.URL
+	// raw is the string the key was parsed from, as it appears in the secret
+	raw string
+}
+
+// Get returns the OTP configuration from a secret. It looks at the
+// same places as Calculate: an otpauth key or line in the body, the totp key
+// and finally the password.
+func Get(name string, sec gosecret.Secret) (*Key, error) {
+	if otpURL, found := sec.Get("otpauth"); found && strings.HasPrefix(otpURL, "//") {
+		k, err := ParseURL("otpauth:" + otpURL)
+		if err != nil {
+			return nil, err
+		}
+		k.raw = otpURL
+		return k, nil
+	}
+
+	for _, line := range strings.Split(sec.Body(), "\n") {
+		if strings.HasPrefix(line, "otpauth://") {
+			return ParseURL(line)
+		}
+	}
+
+	secKey, found := sec.Get("totp")
+	if !found {
+		secKey = sec.Password()
+	}
+
+	if strings.HasPrefix(secKey, "otpauth://") {
+		return ParseURL(secKey)
+	}
+
+	key, err := base32.StdEncoding.DecodeString(pad(secKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+
+	return &Key{
+		Type:      TypeTOTP,
+		Label:     name,
+		Secret:    key,
+		Algorithm: DefaultAlgorithm,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}, nil
+}
+
+// HasKey returns true if the secret explicitly contains an OTP key, i.e. an
+// otpauth or totp field or an otpauth URL. Unlike Get it does not consider
+// the password, which might just look like a base32 encoded key.
+func HasKey(sec gosecret.Secret) bool {
+	if _, found := sec.Get("otpauth"); found {
+		return true
+	}
+	if _, found := sec.Get("totp"); found {
+		return true
+	}
+	if strings.HasPrefix(sec.Password(), "otpauth://") {
+		return true
+	}
+	for _, line := range strings.Split(sec.Body(), "\n") {
+		if strings.HasPrefix(line, "otpauth://") {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseURL parses and validates an otpauth:// URL. Besides the types totp and
+// hotp it accepts the type steam as well as totp URLs with encoder=steam
+// to denote Steam Guard codes.
+func ParseURL(in string) (*Key, error) {
+	u, err := url.Parse(in)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "otpauth" {
+		return nil, fmt.Errorf("invalid scheme %q", u.Scheme)
+	}
+
+	q := u.Query()
+	k := &Key{
+		Type:      strings.ToLower(u.Host),
+		Label:     strings.TrimPrefix(u.Path, "/"),
+		Issuer:    q.Get("issuer"),
+		Algorithm: DefaultAlgorithm,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+		url:       u,
+		raw:       in,
+	}
+
+	switch k.Type {
+	case TypeTOTP, TypeHOTP, TypeSteam:
+	default:
+		return nil, fmt.Errorf("unsupported OTP type %q", u.Host)
+	}
+	if strings.EqualFold(q.Get("encoder"), TypeSteam) {
+		k.Type = TypeSteam
+	}
+	if k.Type == TypeSteam {
+		k.Digits = steamDigits
+	}
+
+	secret := strings.ToUpper(strings.ReplaceAll(q.Get("secret"), " ", ""))
+	if secret == "" {
+		return nil, fmt.Errorf("missing secret")
+	}
+	k.Secret, err = base32.StdEncoding.DecodeString(pad(secret))
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret: %w", err)
+	}
+
+	if a := q.Get("algorithm"); a != "" {
+		k.Algorithm = strings.ToUpper(a)
+		if hashFunc(k.Algorithm) == nil {
+			return nil, fmt.Errorf("unsupported algorithm %q", a)
+		}
+	}
+
+	if d := q.Get("digits"); d != "" && k.Type != TypeSteam {
+		k.Digits, err = strconv.Atoi(d)
+		if err != nil || k.Digits < 1 || k.Digits > 10 {
+			return nil, fmt.Errorf("invalid number of digits %q", d)
+		}
+	}
+
+	if p := q.Get("period"); p != "" {
+		k.Period, err = strconv.Atoi(p)
+		if err != nil || k.Period < 1 {
+			return nil, fmt.Errorf("invalid period %q", p)
+		}
+	}
+
+	if c := q.Get("counter"); c != "" {
+		k.Counter, err = strconv.ParseUint(c, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid counter %q: %w", c, err)
+		}
+	}
+
+	return k, nil
+}
+
+// Code returns the code that is valid at the given time. For HOTP keys the
+// time is ignored and the code for the current counter is returned.
+func (k *Key) Code(t time.Time) string {
+	if k.Type == TypeHOTP {
+		return k.code(k.Counter)
+	}
+	return k.code(uint64(t.Unix()) / uint64(k.Period))
+}
+
+// Next returns the code that follows the one valid at the given time
+func (k *Key) Next(t time.Time) string {
+	if k.Type == TypeHOTP {
+		return k.code(k.Counter + 1)
+	}
+	return k.Code(t.Add(time.Duration(k.Period) * time.Second))
+}
+
+// Expires returns the time when the code valid at the given time expires.
+// HOTP codes do not expire, so it returns the zero time for those.
+func (k *Key) Expires(t time.Time) time.Time {
+	if k.Type == TypeHOTP {
+		return time.Time{}
+	}
+	// periods start at the Unix epoch, t.Truncate would count them from
+	// the zero time
+	p := int64(k.Period)
+	return time.Unix((t.Unix()/p+1)*p, 0)
+}
+
+// URL returns an otpauth URL for this key. If it was parsed from an URL all
+// unknown parameters are preserved.
+func (k *Key) URL() string {
+	u := &url.URL{
+		Scheme: "otpauth",
+		Host:   k.Type,
+		Path:   "/" + k.Label,
+	}
+	q := url.Values{}
+	if k.url != nil {
+		u.Host = k.url.Host
+		q = k.url.Query()
+	}
+
+	q.Set("secret", strings.TrimRight(base32.StdEncoding.EncodeToString(k.Secret), "="))
+	if k.Issuer != "" {
+		q.Set("issuer", k.Issuer)
+	}
+	if k.Algorithm != DefaultAlgorithm {
+		q.Set("algorithm", k.Algorithm)
+	}
+	if k.Digits != DefaultDigits && k.Type != TypeSteam {
+		q.Set("digits", strconv.Itoa(k.Digits))
+	}
+	if k.Period != DefaultPeriod && k.Type != TypeHOTP {
+		q.Set("period", strconv.Itoa(k.Period))
+	}
+	if k.Type == TypeHOTP {
+		q.Set("counter", strconv.FormatUint(k.Counter, 10))
+	}
+
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Update returns a copy of the secret with the OTP URL replaced by the
+// current URL of this key, e.g. to persist an incremented HOTP counter.
+func (k *Key) Update(sec gosecret.Secret) (gosecret.Secret, error) {
+	if k.raw == "" {
+		return nil, fmt.Errorf("key was not parsed from an URL")
+	}
+
+	repl := k.URL()
+	if strings.HasPrefix(k.raw, "//") {
+		repl = strings.TrimPrefix(repl, "otpauth:")
+	}
+
+	buf := sec.Bytes()
+	if !bytes.Contains(buf, []byte(k.raw)) {
+		return nil, fmt.Errorf("OTP URL not found in secret")
+	}
+	nSec, err := secparse.Parse(bytes.Replace(buf, []byte(k.raw), []byte(repl), 1))
+	if err != nil {
+		return nil, err
+	}
+	k.raw = repl
+	return nSec, nil
+}
+
+// Set returns a copy of the secret that contains the otpauth URL of this
+// key. An existing OTP URL is replaced, otherwise the URL is added as the
+// otpauth key or, if the secret has no key-value support, as a body line.
+func (k *Key) Set(sec gosecret.Secret) (gosecret.Secret, error) {
+	if old, err := Get("", sec); err == nil && old.raw != "" {
+		k.raw = old.raw
+		return k.Update(sec)
+	}
+
+	nSec, err := secparse.Parse(sec.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	repl := strings.TrimPrefix(k.URL(), "otpauth:")
+	if err := nSec.Set("otpauth", repl); err == nil {
+		k.raw = repl
+		return nSec, nil
+	}
+
+	buf := sec.Bytes()
+	if len(buf) > 0 && !bytes.HasSuffix(buf, []byte("\n")) {
+		buf = append(buf, '\n')
+	}
+	buf = append(buf, []byte(k.URL()+"\n")...)
+	k.raw = k.URL()
+	return secparse.Parse(buf)
+}
+
+// WriteQRFile writes the otpauth URL of this key as a QR image to disk
+func (k *Key) WriteQRFile(file string) error {
+	qr, err := qrcode.Encode(k.URL(), qrcode.Medium, 256)
+	if err != nil {
+		return fmt.Errorf("failed to write qr file: %w", err)
+	}
+
+	if err := os.WriteFile(file, qr, 0600); err != nil {
+		return fmt.Errorf("failed to write QR code: %w", err)
+	}
+	return nil
+}
+
+func (k *Key) code(counter uint64) string {
+	var ctr [8]byte
+	binary.BigEndian.PutUint64(ctr[:], counter)
+
+	h := hmac.New(hashFunc(k.Algorithm), k.Secret)
+	_, _ = h.Write(ctr[:])
+	sum := h.Sum(nil)
+
+	// dynamic truncation, see RFC 4226 section 5.4
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	if k.Type == TypeSteam {
+		code := make([]byte, 0, steamDigits)
+		for i := 0; i < steamDigits; i++ {
+			code = append(code, steamAlphabet[value%uint32(len(steamAlphabet))])
+			value /= uint32(len(steamAlphabet))
+		}
+		return string(code)
+	}
+
+	mod := uint64(1)
+	for i := 0; i < k.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", k.Digits, uint64(value)%mod)
+}
+
+func hashFunc(algo string) func() hash.Hash {
+	switch algo {
+	case "SHA1":
+		return sha1.New
+	case "SHA256":
+		return sha256.New
+	case "SHA512":
+		return sha512.New
+	default:
+		return nil
+	}
+}
+
+// pad adds the base32 padding that is usually omitted in OTP secrets
+func pad(s string) string {
+	s = strings.TrimRight(s, "=")
+	if n := len(s) % 8; n != 0 {
+		s += strings.Repeat("=", 8-n)
+	}
+	return s
+}
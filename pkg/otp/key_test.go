@@ -0,0 +1,169 @@
+package otp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets/secparse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// test vectors from RFC 4226 and RFC 6238
+const (
+	rfcSecret       = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	rfcSecretSHA256 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA"
+)
+
+func TestParseURL(t *testing.T) {
+	for _, tc := range []struct {
+		url  string
+		code string
+		at   int64
+	}{
+		{
+			url:  "otpauth://totp/test?secret=" + rfcSecret + "&digits=8",
+			code: "94287082",
+			at:   59,
+		},
+		{
+			url:  "otpauth://totp/test?secret=" + rfcSecretSHA256 + "&digits=8&algorithm=SHA256",
+			code: "46119246",
+			at:   59,
+		},
+		{
+			url:  "otpauth://totp/test?secret=" + rfcSecret + "&digits=8&period=60",
+			code: "94287082",
+			at:   119,
+		},
+		{
+			url:  "otpauth://hotp/test?secret=" + rfcSecret + "&counter=1",
+			code: "287082",
+		},
+		{
+			url:  "otpauth://totp/Steam:test?secret=" + rfcSecret + "&issuer=Steam&encoder=steam",
+			code: "PV9M4",
+			at:   59,
+		},
+		{
+			url:  "otpauth://steam/test?secret=" + rfcSecret,
+			code: "PV9M4",
+			at:   59,
+		},
+	} {
+		k, err := ParseURL(tc.url)
+		require.NoError(t, err, tc.url)
+		assert.Equal(t, tc.code, k.Code(time.Unix(tc.at, 0)), tc.url)
+	}
+
+	for _, u := range []string{
+		"https://example.org",
+		"otpauth://foo/test?secret=" + rfcSecret,
+		"otpauth://totp/test",
+		"otpauth://totp/test?secret=!!!",
+		"otpauth://totp/test?secret=" + rfcSecret + "&algorithm=MD5",
+		"otpauth://totp/test?secret=" + rfcSecret + "&digits=0",
+		"otpauth://totp/test?secret=" + rfcSecret + "&period=-1",
+		"otpauth://hotp/test?secret=" + rfcSecret + "&counter=foo",
+	} {
+		_, err := ParseURL(u)
+		assert.Error(t, err, u)
+	}
+}
+
+func TestKeyExpires(t *testing.T) {
+	k, err := ParseURL("otpauth://totp/test?secret=" + rfcSecret + "&period=60")
+	require.NoError(t, err)
+
+	now := time.Unix(130, 0)
+	assert.Equal(t, time.Unix(180, 0), k.Expires(now))
+	assert.Equal(t, k.Code(time.Unix(180, 0)), k.Next(now))
+	// the next code expires one period later
+	assert.Equal(t, time.Unix(240, 0), k.Expires(k.Expires(now)))
+
+	// 62135596800s between the zero time and the epoch are no multiple of 7
+	k, err = ParseURL("otpauth://totp/test?secret=" + rfcSecret + "&period=7")
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(133, 0), k.Expires(now))
+	assert.Equal(t, time.Unix(133, 0), k.Expires(time.Unix(126, 0)))
+	assert.Equal(t, k.Code(time.Unix(133, 0)), k.Next(now))
+	assert.NotEqual(t, k.Code(time.Unix(132, 0)), k.Code(k.Expires(time.Unix(132, 0))))
+
+	k, err = ParseURL("otpauth://hotp/test?secret=" + rfcSecret)
+	require.NoError(t, err)
+	assert.True(t, k.Expires(now).IsZero())
+	assert.Equal(t, "755224", k.Code(now))
+	assert.Equal(t, "287082", k.Next(now))
+}
+
+func TestKeyUpdate(t *testing.T) {
+	for _, in := range []string{
+		"password\notpauth://hotp/test?secret=" + rfcSecret + "&counter=1&issuer=example\n",
+		"password\notpauth: //hotp/test?secret=" + rfcSecret + "&counter=1&issuer=example\n",
+	} {
+		sec, err := secparse.Parse([]byte(in))
+		require.NoError(t, err)
+
+		k, err := Get("test", sec)
+		require.NoError(t, err, in)
+		assert.Equal(t, "287082", k.Code(time.Now()))
+
+		k.Counter++
+		nSec, err := k.Update(sec)
+		require.NoError(t, err)
+		assert.Equal(t, "password", nSec.Password())
+
+		k, err = Get("test", nSec)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(2), k.Counter)
+		assert.Equal(t, "example", k.Issuer)
+	}
+
+	k, err := ParseURL("otpauth://hotp/test?secret=" + rfcSecret)
+	require.NoError(t, err)
+	sec, err := secparse.Parse([]byte("password\n"))
+	require.NoError(t, err)
+	_, err = k.Update(sec)
+	assert.Error(t, err)
+}
+
+func TestGet(t *testing.T) {
+	sec, err := secparse.Parse([]byte("password\ntotp: " + rfcSecret))
+	require.NoError(t, err)
+
+	k, err := Get("test", sec)
+	require.NoError(t, err)
+	assert.Equal(t, TypeTOTP, k.Type)
+	assert.Equal(t, DefaultPeriod, k.Period)
+	assert.Equal(t, DefaultDigits, k.Digits)
+	assert.Equal(t, "test", k.Label)
+}
+
+func TestHasKey(t *testing.T) {
+	for in, want := range map[string]bool{
+		"password\ntotp: " + rfcSecret:                        true,
+		"password\notpauth: //totp/test?secret=" + rfcSecret:  true,
+		"password\n\notpauth://totp/test?secret=" + rfcSecret: true,
+		"otpauth://totp/test?secret=" + rfcSecret:             true,
+		rfcSecret:             false,
+		"password\nuser: bob": false,
+	} {
+		sec, err := secparse.Parse([]byte(in))
+		require.NoError(t, err)
+		assert.Equal(t, want, HasKey(sec), in)
+	}
+}
+
+func TestKeyWriteQRFile(t *testing.T) {
+	td, err := os.MkdirTemp("", "gosecret-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(td)
+	}()
+
+	k, err := ParseURL(totpURL)
+	require.NoError(t, err)
+	assert.NoError(t, k.WriteQRFile(filepath.Join(td, "qr.png")))
+}
@@ -0,0 +1,174 @@
+package otp
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// MigrationScheme is the URL scheme used by the account export of Google
+// Authenticator
+const MigrationScheme = "otpauth-migration"
+
+// ParseMigrationURL decodes an otpauth-migration://offline?data=… URL as
+// exported by Google Authenticator. The data parameter is a base64 encoded
+// protobuf message that can contain many accounts.
+func ParseMigrationURL(in string) ([]*Key, error) {
+	u, err := url.Parse(in)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != MigrationScheme {
+		return nil, fmt.Errorf("invalid scheme %q", u.Scheme)
+	}
+
+	data := u.Query().Get("data")
+	if data == "" {
+		return nil, fmt.Errorf("missing data")
+	}
+	// a literal + is decoded as space in query strings
+	data = strings.ReplaceAll(data, " ", "+")
+	buf, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		buf, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "="))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid data: %w", err)
+	}
+
+	var keys []*Key
+	err = walkMessage(buf, func(field int, v uint64, b []byte) error {
+		// MigrationPayload.otp_parameters
+		if field != 1 || b == nil {
+			return nil
+		}
+		k, err := parseMigrationKey(b)
+		if err != nil {
+			return fmt.Errorf("invalid account #%d: %w", len(keys)+1, err)
+		}
+		keys = append(keys, k)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) < 1 {
+		return nil, fmt.Errorf("no accounts found")
+	}
+	return keys, nil
+}
+
+// parseMigrationKey decodes an OtpParameters message
+func parseMigrationKey(buf []byte) (*Key, error) {
+	k := &Key{
+		Type:      TypeTOTP,
+		Algorithm: DefaultAlgorithm,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}
+
+	err := walkMessage(buf, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			k.Secret = append([]byte{}, b...)
+		case 2:
+			k.Label = string(b)
+		case 3:
+			k.Issuer = string(b)
+		case 4:
+			switch v {
+			case 0, 1:
+				k.Algorithm = "SHA1"
+			case 2:
+				k.Algorithm = "SHA256"
+			case 3:
+				k.Algorithm = "SHA512"
+			default:
+				return fmt.Errorf("unsupported algorithm %d", v)
+			}
+		case 5:
+			switch v {
+			case 0, 1:
+				k.Digits = 6
+			case 2:
+				k.Digits = 8
+			default:
+				return fmt.Errorf("unsupported number of digits %d", v)
+			}
+		case 6:
+			switch v {
+			case 1:
+				k.Type = TypeHOTP
+			case 0, 2:
+				k.Type = TypeTOTP
+			default:
+				return fmt.Errorf("unsupported OTP type %d", v)
+			}
+		case 7:
+			k.Counter = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(k.Secret) < 1 {
+		return nil, fmt.Errorf("missing secret")
+	}
+	if k.Issuer != "" && !strings.Contains(k.Label, ":") {
+		k.Label = k.Issuer + ":" + k.Label
+	}
+	return k, nil
+}
+
+// walkMessage calls fn for each field of a protobuf message. Varint fields
+// are passed as v, length delimited fields as b. Fixed size fields are
+// skipped since the migration format does not use them.
+func walkMessage(buf []byte, fn func(field int, v uint64, b []byte) error) error {
+	for len(buf) > 0 {
+		tag, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return fmt.Errorf("invalid protobuf tag")
+		}
+		buf = buf[n:]
+
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case 0: // varint
+			v, n := binary.Uvarint(buf)
+			if n <= 0 {
+				return fmt.Errorf("invalid varint in field %d", field)
+			}
+			buf = buf[n:]
+			if err := fn(field, v, nil); err != nil {
+				return err
+			}
+		case 1: // 64 bit
+			if len(buf) < 8 {
+				return fmt.Errorf("truncated field %d", field)
+			}
+			buf = buf[8:]
+		case 2: // length delimited
+			l, n := binary.Uvarint(buf)
+			if n <= 0 || uint64(len(buf)-n) < l {
+				return fmt.Errorf("truncated field %d", field)
+			}
+			b := buf[n : n+int(l)]
+			buf = buf[n+int(l):]
+			if err := fn(field, 0, b); err != nil {
+				return err
+			}
+		case 5: // 32 bit
+			if len(buf) < 4 {
+				return fmt.Errorf("truncated field %d", field)
+			}
+			buf = buf[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d in field %d", tag&7, field)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package otp
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/itsonlycode/gosecret/pkg/gosecret/secrets/secparse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func uvarint(v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutUvarint(buf, v)]
+}
+
+func pbVarint(field int, v uint64) []byte {
+	return append(uvarint(uint64(field<<3)), uvarint(v)...)
+}
+
+func pbBytes(field int, b []byte) []byte {
+	buf := append(uvarint(uint64(field<<3|2)), uvarint(uint64(len(b)))...)
+	return append(buf, b...)
+}
+
+func migrationURL(accounts ...[]byte) string {
+	var payload []byte
+	for _, a := range accounts {
+		payload = append(payload, pbBytes(1, a)...)
+	}
+	// version and batch info, ignored
+	payload = append(payload, pbVarint(2, 1)...)
+	payload = append(payload, pbVarint(3, 1)...)
+	return "otpauth-migration://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString(payload))
+}
+
+func TestParseMigrationURL(t *testing.T) {
+	secret, err := base32.StdEncoding.DecodeString(rfcSecret)
+	require.NoError(t, err)
+
+	var totp []byte
+	totp = append(totp, pbBytes(1, secret)...)
+	totp = append(totp, pbBytes(2, []byte("alice@example.org"))...)
+	totp = append(totp, pbBytes(3, []byte("Example"))...)
+	totp = append(totp, pbVarint(4, 1)...)
+	totp = append(totp, pbVarint(5, 2)...)
+	totp = append(totp, pbVarint(6, 2)...)
+
+	var hotp []byte
+	hotp = append(hotp, pbBytes(1, secret)...)
+	hotp = append(hotp, pbBytes(2, []byte("Other:bob"))...)
+	hotp = append(hotp, pbVarint(6, 1)...)
+	hotp = append(hotp, pbVarint(7, 1)...)
+
+	keys, err := ParseMigrationURL(migrationURL(totp, hotp))
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	assert.Equal(t, TypeTOTP, keys[0].Type)
+	assert.Equal(t, "Example:alice@example.org", keys[0].Label)
+	assert.Equal(t, "Example", keys[0].Issuer)
+	assert.Equal(t, 8, keys[0].Digits)
+	assert.Equal(t, "94287082", keys[0].Code(time.Unix(59, 0)))
+
+	assert.Equal(t, TypeHOTP, keys[1].Type)
+	assert.Equal(t, "Other:bob", keys[1].Label)
+	assert.Equal(t, "287082", keys[1].Code(time.Now()))
+
+	// the URL must round trip through ParseURL
+	k, err := ParseURL(keys[0].URL())
+	require.NoError(t, err)
+	assert.Equal(t, "94287082", k.Code(time.Unix(59, 0)))
+
+	for _, u := range []string{
+		"otpauth://totp/test?secret=" + rfcSecret,
+		"otpauth-migration://offline",
+		"otpauth-migration://offline?data=!!!",
+		migrationURL(),
+		migrationURL(pbBytes(2, []byte("nosecret"))),
+		migrationURL(append(pbBytes(1, secret), pbVarint(4, 4)...)),
+	} {
+		_, err := ParseMigrationURL(u)
+		assert.Error(t, err, u)
+	}
+}
+
+// FuzzParseMigrationURL feeds arbitrary payloads to the protobuf parser of
+// ParseMigrationURL. It must never panic and every key it returns must be
+// usable.
+func FuzzParseMigrationURL(f *testing.F) {
+	secret, err := base32.StdEncoding.DecodeString(rfcSecret)
+	if err != nil {
+		f.Fatal(err)
+	}
+	var account []byte
+	account = append(account, pbBytes(1, secret)...)
+	account = append(account, pbBytes(2, []byte("alice@example.org"))...)
+	account = append(account, pbBytes(3, []byte("Example"))...)
+	account = append(account, pbVarint(4, 2)...)
+	account = append(account, pbVarint(6, 1)...)
+	account = append(account, pbVarint(7, 5)...)
+
+	f.Add(pbBytes(1, account))
+	f.Add(append(pbBytes(1, account), pbBytes(1, pbBytes(1, secret))...))
+	f.Add(pbBytes(1, pbVarint(4, 4)))
+	f.Add([]byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0x0f})
+
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		keys, err := ParseMigrationURL("otpauth-migration://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString(payload)))
+		if err != nil {
+			return
+		}
+		require.NotEmpty(t, keys)
+		for _, k := range keys {
+			require.NotEmpty(t, k.Secret)
+			_ = k.Code(time.Unix(59, 0))
+			_ = k.URL()
+		}
+	})
+}
+
+func TestKeySet(t *testing.T) {
+	k, err := ParseURL("otpauth://totp/test?secret=" + rfcSecret + "&digits=8")
+	require.NoError(t, err)
+
+	for _, in := range []string{
+		"password\nuser: alice\n",
+		"password\n---\nuser: alice\n",
+		"",
+	} {
+		sec, err := secparse.Parse([]byte(in))
+		require.NoError(t, err)
+
+		nSec, err := k.Set(sec)
+		require.NoError(t, err, in)
+
+		got, err := Get("test", nSec)
+		require.NoError(t, err, in)
+		assert.Equal(t, "94287082", got.Code(time.Unix(59, 0)), in)
+		if in != "" {
+			assert.Equal(t, "password", nSec.Password(), in)
+		}
+	}
+
+	// an existing URL is replaced
+	sec, err := secparse.Parse([]byte("password\notpauth: //hotp/test?secret=" + rfcSecret + "&counter=1\n"))
+	require.NoError(t, err)
+	nSec, err := k.Set(sec)
+	require.NoError(t, err)
+	assert.NotContains(t, string(nSec.Bytes()), "hotp")
+	got, err := Get("test", nSec)
+	require.NoError(t, err)
+	assert.Equal(t, TypeTOTP, got.Type)
+}
@@ -10,6 +10,9 @@ import (
 )
 
 // Calculate will compute a OTP code from a given secret
+//
+// Deprecated: Use Get, which honours all parameters of the OTP URL and
+// supports Steam Guard codes.
 func Calculate(name string, sec gosecret.Secret) (twofactor.OTP, string, error) {
 	otpURL, found := sec.Get("otpauth")
 	if found && strings.HasPrefix(otpURL, "//") {
@@ -45,6 +48,8 @@ func Calculate(name string, sec gosecret.Secret) (twofactor.OTP, string, error)
 }
 
 // WriteQRFile writes the given OTP code as a QR image to disk
+//
+// Deprecated: Use Key.WriteQRFile.
 func WriteQRFile(otp twofactor.OTP, label, file string) error {
 	var qr []byte
 	var err error
@@ -17,6 +17,7 @@ func TestBaseConfig(t *testing.T) {
 	assert.NoError(t, err)
 	wanted := `autoclip: false
 autoimport: true
+clipboard: 
 cliptimeout: 45
 exportkeys: false
 nopager: false
@@ -70,6 +71,7 @@ func TestMountConfig(t *testing.T) {
 
 	wanted := `autoclip: false
 autoimport: true
+clipboard: 
 cliptimeout: 45
 exportkeys: false
 nopager: false
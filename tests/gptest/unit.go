@@ -57,6 +57,7 @@ func NewUnitTester(t *testing.T) *Unit {
 	}
 	u.env = map[string]string{
 		"CHECKPOINT_DISABLE":        "true",
+		"GOPASS_CLIPBOARD":          "none",
 		"GNUPGHOME":                 u.GPGHome(),
 		"GOPASS_CONFIG":             u.GPConfig(),
 		"GOPASS_DISABLE_ENCRYPTION": "true",